@@ -0,0 +1,81 @@
+// Package audit carries what the audit log records about the request
+// behind an action, and diffs the state an action changed
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+type ctxKey struct{}
+
+// Request is where an audited action came from
+type Request struct {
+	IP        string
+	UserAgent string
+	RequestID string
+	// ImpersonatorID is the admin acting as the signed-in user, if any
+	ImpersonatorID uint
+}
+
+// WithRequest returns a copy of ctx carrying r
+func WithRequest(ctx context.Context, r Request) context.Context {
+	return context.WithValue(ctx, ctxKey{}, r)
+}
+
+// FromContext returns the Request stored in ctx, empty outside requests
+func FromContext(ctx context.Context) Request {
+	r, _ := ctx.Value(ctxKey{}).(Request)
+	return r
+}
+
+// Change is a field before and after an action; nil where it didn't exist
+type Change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// Diff compares two snapshots by their JSON fields and returns the ones
+// that differ. A nil snapshot has no fields, so creations and deletions
+// list every field.
+func Diff(before, after any) (map[string]Change, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	out := map[string]Change{}
+	for _, k := range keys {
+		if !reflect.DeepEqual(b[k], a[k]) {
+			out[k] = Change{From: b[k], To: a[k]}
+		}
+	}
+	return out, nil
+}
+
+func fields(v any) (map[string]any, error) {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]any
+	return out, json.Unmarshal(data, &out)
+}
@@ -0,0 +1,37 @@
+package audit_test
+
+import (
+	"context"
+	"testing"
+
+	"app/audit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type snapshot struct {
+	Name  string `json:"name"`
+	Stock int    `json:"stock"`
+}
+
+func TestDiff(t *testing.T) {
+	changes, err := audit.Diff(snapshot{Name: "Lamp", Stock: 1}, snapshot{Name: "Lamp", Stock: 3})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]audit.Change{"stock": {From: float64(1), To: float64(3)}}, changes)
+
+	changes, err = audit.Diff(nil, &snapshot{Name: "Lamp"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]audit.Change{"name": {To: "Lamp"}, "stock": {To: float64(0)}}, changes)
+
+	var none *snapshot
+	changes, err = audit.Diff(snapshot{Name: "Lamp"}, none)
+	require.NoError(t, err)
+	assert.Len(t, changes, 2)
+}
+
+func TestRequest(t *testing.T) {
+	assert.Equal(t, audit.Request{}, audit.FromContext(context.Background()))
+	r := audit.Request{IP: "10.0.0.1", UserAgent: "curl", RequestID: "abc"}
+	assert.Equal(t, r, audit.FromContext(audit.WithRequest(context.Background(), r)))
+}
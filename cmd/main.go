@@ -1,39 +1,103 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
 
+	"app/config"
 	"app/database"
+	"app/events"
+	"app/handler"
+	"app/logging"
+	"app/metrics"
+	"app/money"
+	"app/payment"
+	"app/repository"
 	"app/router"
+	"app/service"
+	"app/storage"
+	"app/tracing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 )
 
 func main() {
+	logging.Setup()
+	if err := run(); err != nil {
+		slog.Error("server failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run serves the API until the server fails, returning only then so the
+// deferred cleanups run
+func run() error {
 	app := fiber.New(fiber.Config{
 		Prefork:       true,
 		CaseSensitive: true,
 		StrictRouting: true,
 		ServerHeader:  "Fiber",
 		AppName:       "App Name",
+		// Leaves room for an image upload plus multipart overhead
+		BodyLimit:     service.MaxImageBytes + 1<<20,
 	})
 	app.Options("/api/*", func(c *fiber.Ctx) error {
     c.Set("Access-Control-Allow-Origin", "http://localhost:3000")
     c.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-    c.Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+    c.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, traceparent, tracestate, X-Request-ID")
     c.Set("Access-Control-Allow-Credentials", "true")
     return c.SendStatus(fiber.StatusOK)
 	})
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "http://localhost:3000", // Your Next.js frontend URL
 		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, traceparent, tracestate, X-Request-ID",
+		ExposeHeaders: "traceparent, X-Request-ID",
 		AllowCredentials: true, // Allow cookies (like JWT tokens)
 	}))
 
-	database.ConnectDB()
+	// Prefork workers share metrics through snapshot files
+	dir := config.Config("METRICS_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "retot-metrics")
+	}
+	stop, err := metrics.EnableMultiprocess(dir, 5*time.Second, fiber.IsChild())
+	if err != nil {
+		return fmt.Errorf("enable metrics: %w", err)
+	}
+	defer stop()
+
+	shutdown, err := tracing.Setup(context.Background())
+	if err != nil {
+		return fmt.Errorf("set up tracing: %w", err)
+	}
+	defer shutdown(context.Background())
+
+	db := database.ConnectDB()
+	repos := repository.New(db)
+	// Every prefork process relays real-time updates through Postgres
+	bus := events.FromDB(context.Background(), db, database.DSN())
+
+	blobs, err := storage.FromConfig()
+	if err != nil {
+		return fmt.Errorf("set up storage: %w", err)
+	}
+
+	rates, err := money.RatesFromConfig()
+	if err != nil {
+		return fmt.Errorf("read exchange rates: %w", err)
+	}
+
+	payments, err := payment.FromConfig()
+	if err != nil {
+		return fmt.Errorf("set up payments: %w", err)
+	}
 
-	router.SetupRoutes(app)
-	log.Fatal(app.Listen(":3000"))
+	router.SetupRoutes(app, handler.New(repos, blobs, rates, payments, bus))
+	return app.Listen(":3000")
 }
@@ -0,0 +1,32 @@
+// Command purge anonymizes accounts that were deactivated longer than
+// ACCOUNT_RETENTION_DAYS ago. cmd/worker does so daily; this runs it on
+// demand.
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"app/database"
+	"app/handler"
+	"app/logging"
+	"app/repository"
+	"app/service"
+)
+
+func main() {
+	logging.Setup()
+
+	db := database.ConnectDB()
+	repos := repository.New(db)
+	accounts := service.NewAccountService(repos, service.NewAuditService(repos.Audit), handler.Retention())
+
+	n, err := accounts.Purge(context.Background(), time.Now())
+	if err != nil {
+		slog.Error("purge failed", "anonymized", n, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("purge finished", "anonymized", n)
+}
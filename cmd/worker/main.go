@@ -0,0 +1,73 @@
+// Command worker runs background jobs from the jobs table: emails,
+// image variants, webhook deliveries, scheduled listings and the
+// periodic sweeps. Any number of workers can share the queue.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"app/database"
+	"app/events"
+	"app/handler"
+	"app/jobs"
+	"app/logging"
+	"app/mail"
+	"app/repository"
+	"app/service"
+	"app/storage"
+)
+
+// jobRetention is how long finished jobs are kept
+const jobRetention = 7 * 24 * time.Hour
+
+func main() {
+	logging.Setup()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db := database.ConnectDB()
+	repos := repository.New(db)
+	mailer, err := mail.FromConfig()
+	if err != nil {
+		slog.Error("failed to set up email", "error", err)
+		os.Exit(1)
+	}
+	blobs, err := storage.FromConfig()
+	if err != nil {
+		slog.Error("failed to set up storage", "error", err)
+		os.Exit(1)
+	}
+	// Notifications raised here reach clients of every web process
+	bus := events.FromDB(ctx, db, database.DSN())
+
+	// The same hooks as the web server: restocked items reach wishlist
+	// watchers and webhooks, scheduled publishing is audited, relayed and
+	// screened like the owner's changes. Nothing is paid for here.
+	s := handler.NewServices(repos, blobs, nil, bus)
+
+	host, _ := os.Hostname()
+	runner := jobs.NewRunner(repos.Jobs, fmt.Sprintf("%s:%d", host, os.Getpid()))
+	runner.Register(service.JobSendEmail, service.EmailJob(mailer))
+	runner.Register(service.JobImageVariants, s.Images.RenderVariants)
+	runner.Register(service.JobDeliverWebhook, s.Webhooks.DeliverJob)
+	runner.Register(service.JobExpireReservations, s.Inventory.ExpireJob)
+	runner.Every(service.JobExpireReservations, time.Minute)
+	runner.Register(service.JobItemSchedules, s.Items.ScheduleJob)
+	runner.Every(service.JobItemSchedules, time.Minute)
+	runner.Register(service.JobPurgeAccounts, s.Accounts.PurgeJob)
+	runner.Every(service.JobPurgeAccounts, 24*time.Hour)
+	runner.Register(service.JobPruneJobs, func(ctx context.Context, _ json.RawMessage) error {
+		_, err := repos.Jobs.Prune(ctx, time.Now().Add(-jobRetention))
+		return err
+	})
+	runner.Every(service.JobPruneJobs, 24*time.Hour)
+
+	runner.Run(ctx)
+}
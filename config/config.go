@@ -1,8 +1,9 @@
 package config
 
 import (
-	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
 	"sync"
 
 	"github.com/joho/godotenv"
@@ -15,8 +16,18 @@ func Config(key string) string {
 	once.Do(func() {
 		err := godotenv.Load(".env")
 		if err != nil {
-			fmt.Println("Warning: .env file not loaded")
+			slog.Warn(".env file not loaded")
 		}
 	})
 	return os.Getenv(key)
 }
+
+// Int fetches an integer environment variable, returning def when it is
+// unset or malformed
+func Int(key string, def int) int {
+	n, err := strconv.Atoi(Config(key))
+	if err != nil {
+		return def
+	}
+	return n
+}
@@ -2,47 +2,198 @@ package database
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
+	"os"
 	"strconv"
 
 	"app/config"
+	"app/logging"
+	"app/metrics"
 	"app/model"
+	"app/money"
+	"app/tracing"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-// ConnectDB connect to db
-func ConnectDB() {
-	var err error
+// DSN is the Postgres connection string read from the DB_* variables
+func DSN() string {
 	p := config.Config("DB_PORT")
 	port, err := strconv.ParseUint(p, 10, 32)
 	if err != nil {
 		panic("failed to parse database port")
 	}
 
-	dsn := fmt.Sprintf(
+	return fmt.Sprintf(
 		"host=db port=%d user=%s password=%s dbname=%s sslmode=disable",
 		port,
 		config.Config("DB_USER"),
 		config.Config("DB_PASSWORD"),
 		config.Config("DB_NAME"),
 	)
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+}
+
+// ConnectDB connect to db
+func ConnectDB() *gorm.DB {
+	db, err := gorm.Open(postgres.Open(DSN()), &gorm.Config{Logger: logging.NewGormLogger()})
 	if err != nil {
 		panic("failed to connect database")
 	}
+	if err := instrument(db); err != nil {
+		panic("failed to instrument database")
+	}
+
+	slog.Info("connection opened to database")
+	if err := Migrate(db); err != nil {
+		panic("failed to migrate database")
+	}
+	slog.Info("database migrated")
+	return db
+}
 
-	fmt.Println("Connection Opened to Database")
-	DB.AutoMigrate(&model.Comment{}, &model.Like{}, &model.User{})
-	fmt.Println("Database Migrated")
+// ConnectDBWithDSN opens a migrated SQLite database, used by tests. Every
+// call returns an independent connection; ":memory:" gives a fresh database.
+func ConnectDBWithDSN(dsn string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logging.NewGormLogger()})
+	if err != nil {
+		slog.Error("failed to connect test db", "error", err)
+		os.Exit(1)
+	}
+	// An in-memory database lives and dies with its connection
+	sqlDB, err := db.DB()
+	if err == nil {
+		sqlDB.SetMaxOpenConns(1)
+	}
+	if err := instrument(db); err != nil {
+		slog.Error("failed to instrument test db", "error", err)
+		os.Exit(1)
+	}
+	if err := Migrate(db); err != nil {
+		slog.Error("failed to migrate test db", "error", err)
+		os.Exit(1)
+	}
+	return db
 }
 
-func ConnectDBWithDSN(dsn string) {
-	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+// Migrate creates or updates every table
+func Migrate(db *gorm.DB) error {
+	// Items listed before stock was tracked each hold a single unit
+	backfillStock := db.Migrator().HasTable(&model.Item{}) && !db.Migrator().HasColumn(&model.Item{}, "Stock")
+	// Prices used to be float64 columns
+	legacy := legacyPrices(db)
+	// Comments and likes used to point at posts, which never existed. Their
+	// rows can't be tied to items, so the tables are rebuilt.
+	if db.Migrator().HasColumn(&model.Comment{}, "post_id") {
+		if err := db.Migrator().DropTable(&model.Comment{}, &model.Like{}); err != nil {
+			return err
+		}
+	}
+
+	err := db.AutoMigrate(
+		&model.User{},
+		&model.Category{},
+		&model.Item{},
+		&model.Order{},
+		&model.Review{},
+		&model.Comment{},
+		&model.Like{},
+		&model.ItemImage{},
+		&model.StockReservation{},
+		&model.InventoryAdjustment{},
+		&model.Cart{},
+		&model.CartItem{},
+		&model.Payment{},
+		&model.LedgerEntry{},
+		&model.PaymentEvent{},
+		&model.WishlistEntry{},
+		&model.Notification{},
+		&model.NotificationPreference{},
+		&model.Conversation{},
+		&model.Message{},
+		&model.Block{},
+		&model.Job{},
+		&model.WebhookSubscription{},
+		&model.WebhookDelivery{},
+		&model.AuditLog{},
+		&model.Report{},
+	)
 	if err != nil {
-		log.Fatal("failed to connect test db")
+		return err
+	}
+	if err := protectAuditLog(db); err != nil {
+		return err
+	}
+	if backfillStock {
+		if err := db.Unscoped().Model(&model.Item{}).Where("1 = 1").Update("stock", 1).Error; err != nil {
+			return err
+		}
+	}
+	return migratePrices(db, legacy)
+}
+
+// protectAuditLog makes the audit log append-only on Postgres, where the
+// application's own role could otherwise rewrite it
+func protectAuditLog(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return db.Exec(`
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+	FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+`).Error
+}
+
+// legacyPrice is a float64 price column replaced by a money.Money
+type legacyPrice struct {
+	model                 any
+	table, column, prefix string
+}
+
+func legacyPrices(db *gorm.DB) []legacyPrice {
+	var out []legacyPrice
+	for _, p := range []legacyPrice{
+		{&model.Item{}, "items", "price", "price_"},
+		{&model.Order{}, "orders", "total_price", "total_price_"},
+	} {
+		if db.Migrator().HasColumn(p.model, p.column) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// migratePrices converts legacy float columns into minor units of the
+// default currency, then drops them
+func migratePrices(db *gorm.DB, legacy []legacyPrice) error {
+	currency := money.DefaultCurrency()
+	factor := int(math.Pow10(currency.Exponent()))
+	for _, p := range legacy {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			sql := fmt.Sprintf("UPDATE %[1]s SET %[3]samount = CAST(ROUND(%[2]s * ?) AS BIGINT), %[3]scurrency = ?", p.table, p.column, p.prefix)
+			if err := tx.Exec(sql, factor, string(currency)).Error; err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(p.model, p.column)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func instrument(db *gorm.DB) error {
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		return err
 	}
-	DB = db
+	return db.Use(tracing.GormPlugin{})
 }
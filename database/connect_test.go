@@ -0,0 +1,97 @@
+package database_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"app/database"
+	"app/model"
+	"app/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMigrate_ConvertsFloatPrices(t *testing.T) {
+	t.Setenv("DEFAULT_CURRENCY", "USD")
+	dsn := filepath.Join(t.TempDir(), "legacy.db")
+
+	// The schema as it was when prices were float64
+	type item struct {
+		ID          uint
+		Name        string
+		Description string
+		Price       float64 `gorm:"not null"`
+		UserID      uint
+		CategoryID  uint
+	}
+	type order struct {
+		ID         uint
+		ItemID     uint
+		UserID     uint
+		Quantity   int
+		TotalPrice float64 `gorm:"not null"`
+		CategoryID uint
+	}
+	legacy, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, legacy.AutoMigrate(&item{}, &order{}))
+	require.NoError(t, legacy.Create(&item{ID: 1, Name: "Lamp", Description: "Desk lamp", Price: 19.99, UserID: 1, CategoryID: 1}).Error)
+	require.NoError(t, legacy.Create(&order{ID: 1, ItemID: 1, UserID: 2, Quantity: 3, TotalPrice: 59.97, CategoryID: 1}).Error)
+	sqlDB, _ := legacy.DB()
+	sqlDB.Close()
+
+	db := database.ConnectDBWithDSN(dsn)
+
+	var migrated model.Item
+	require.NoError(t, db.First(&migrated, 1).Error)
+	assert.Equal(t, money.New(1999, "USD"), migrated.Price)
+	assert.Equal(t, 1, migrated.Stock)
+
+	var placed model.Order
+	require.NoError(t, db.First(&placed, 1).Error)
+	assert.Equal(t, money.New(5997, "USD"), placed.TotalPrice)
+
+	assert.False(t, db.Migrator().HasColumn("items", "price"))
+	assert.False(t, db.Migrator().HasColumn("orders", "total_price"))
+
+	// Migrating again leaves converted prices alone
+	require.NoError(t, database.Migrate(db))
+	require.NoError(t, db.First(&migrated, 1).Error)
+	assert.Equal(t, money.New(1999, "USD"), migrated.Price)
+}
+
+func TestMigrate_RepointsCommentsAndLikes(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "legacy.db")
+
+	// Comments and likes used to reference posts
+	type comment struct {
+		ID     uint
+		Body   string `gorm:"not null"`
+		UserID uint   `gorm:"not null"`
+		PostID uint   `gorm:"not null"`
+	}
+	type like struct {
+		ID     uint
+		UserID uint `gorm:"not null"`
+		PostID uint `gorm:"not null"`
+	}
+	legacy, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, legacy.AutoMigrate(&comment{}, &like{}))
+	require.NoError(t, legacy.Create(&comment{Body: "first", UserID: 1, PostID: 7}).Error)
+	require.NoError(t, legacy.Create(&like{UserID: 1, PostID: 7}).Error)
+	sqlDB, _ := legacy.DB()
+	sqlDB.Close()
+
+	db := database.ConnectDBWithDSN(dsn)
+
+	assert.False(t, db.Migrator().HasColumn(&model.Comment{}, "post_id"))
+	assert.True(t, db.Migrator().HasColumn(&model.Like{}, "item_id"))
+	var n int64
+	db.Model(&model.Comment{}).Unscoped().Count(&n)
+	assert.Zero(t, n)
+	require.NoError(t, database.Migrate(db))
+}
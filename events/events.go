@@ -0,0 +1,99 @@
+// Package events carries real-time updates to the users they concern,
+// across every process serving the API
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// Event types
+const (
+	TypeNotification = "notification"
+	TypeOrder        = "order"
+	TypeMessage      = "message"
+	TypeMessagesRead = "messages_read"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before further events are dropped for it
+const subscriberBuffer = 16
+
+// Event is an update for one user. Data is the JSON the client receives.
+type Event struct {
+	UserID uint            `json:"user_id"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// New creates an event for userID carrying data as JSON
+func New(userID uint, kind string, data any) (Event, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{UserID: userID, Type: kind, Data: b}, nil
+}
+
+// Publisher sends events
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Bus sends events to the subscribers of their user, wherever they are
+// connected
+type Bus interface {
+	Publisher
+	// Subscribe returns the events of userID until cancel is called
+	Subscribe(userID uint) (events <-chan Event, cancel func())
+}
+
+// Local is a Bus reaching the subscribers of this process only
+type Local struct {
+	mu   sync.Mutex
+	subs map[uint]map[chan Event]struct{}
+}
+
+// NewLocal creates a Local bus
+func NewLocal() *Local {
+	return &Local{subs: map[uint]map[chan Event]struct{}{}}
+}
+
+// Publish implements Publisher. It never blocks: subscribers too far
+// behind miss the event.
+func (l *Local) Publish(_ context.Context, event Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.subs[event.UserID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe implements Bus
+func (l *Local) Subscribe(userID uint) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	l.mu.Lock()
+	if l.subs[userID] == nil {
+		l.subs[userID] = map[chan Event]struct{}{}
+	}
+	l.subs[userID][ch] = struct{}{}
+	l.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			delete(l.subs[userID], ch)
+			if len(l.subs[userID]) == 0 {
+				delete(l.subs, userID)
+			}
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package events_test
+
+import (
+	"context"
+	"testing"
+
+	"app/events"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocal_DeliversToTheUsersSubscribers(t *testing.T) {
+	bus := events.NewLocal()
+	ctx := context.Background()
+	first, cancelFirst := bus.Subscribe(1)
+	second, cancelSecond := bus.Subscribe(1)
+	other, cancelOther := bus.Subscribe(2)
+	defer cancelOther()
+
+	ev, err := events.New(1, events.TypeOrder, map[string]any{"id": 7, "status": "paid"})
+	require.NoError(t, err)
+	require.NoError(t, bus.Publish(ctx, ev))
+
+	assert.JSONEq(t, `{"id":7,"status":"paid"}`, string((<-first).Data))
+	assert.Equal(t, events.TypeOrder, (<-second).Type)
+	assert.Empty(t, other)
+
+	cancelFirst()
+	cancelFirst()
+	_, open := <-first
+	assert.False(t, open, "cancel closes the subscription")
+	require.NoError(t, bus.Publish(ctx, ev))
+	assert.Len(t, second, 1)
+	cancelSecond()
+}
+
+func TestLocal_DropsForSlowSubscribers(t *testing.T) {
+	bus := events.NewLocal()
+	sub, cancel := bus.Subscribe(1)
+	defer cancel()
+
+	for range 100 {
+		require.NoError(t, bus.Publish(context.Background(), events.Event{UserID: 1, Type: events.TypeNotification}))
+	}
+	assert.Less(t, len(sub), 100, "publishing never blocks")
+}
@@ -0,0 +1,105 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"app/logging"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// channel is the Postgres notification channel events travel on
+const channel = "app_events"
+
+// maxPayload is the largest payload Postgres notifications carry
+const maxPayload = 8000
+
+// Postgres is a Bus reaching every process connected to the same
+// database. Events go out with NOTIFY, so one published inside a
+// transaction is only delivered once it commits. Each process LISTENs on
+// a connection of its own and hands what it hears to its subscribers.
+type Postgres struct {
+	*Local
+	db *gorm.DB
+}
+
+// NewPostgres creates a Postgres bus publishing through db and listening
+// on a connection to dsn until ctx is done
+func NewPostgres(ctx context.Context, db *gorm.DB, dsn string) *Postgres {
+	p := &Postgres{Local: NewLocal(), db: db}
+	go p.listen(ctx, dsn)
+	return p
+}
+
+// Publish implements Publisher. Subscribers of this process get the event
+// back through the database like everyone else.
+func (p *Postgres) Publish(ctx context.Context, event Event) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if len(b) > maxPayload {
+		// Too large to notify; clients refetch on a bare event
+		event.Data = json.RawMessage("null")
+		if b, err = json.Marshal(event); err != nil {
+			return err
+		}
+	}
+	return p.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", channel, string(b)).Error
+}
+
+// listen relays notifications to local subscribers, reconnecting with
+// backoff whenever the connection drops
+func (p *Postgres) listen(ctx context.Context, dsn string) {
+	log := logging.FromContext(ctx)
+	wait := time.Second
+	for {
+		err := p.relay(ctx, dsn, func() { wait = time.Second })
+		if ctx.Err() != nil {
+			return
+		}
+		log.Error("event listener disconnected", "error", err, "retry_in", wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+		wait = min(2*wait, time.Minute)
+	}
+}
+
+func (p *Postgres) relay(ctx context.Context, dsn string, connected func()) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+		return err
+	}
+	connected()
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(n.Payload), &event); err != nil {
+			logging.FromContext(ctx).Warn("dropping malformed event", "error", err)
+			continue
+		}
+		_ = p.Local.Publish(ctx, event)
+	}
+}
+
+// FromDB returns a Postgres bus when db is Postgres and a Local one
+// otherwise, as for SQLite, which only ever has one process
+func FromDB(ctx context.Context, db *gorm.DB, dsn string) Bus {
+	if db.Dialector.Name() == "postgres" {
+		return NewPostgres(ctx, db, dsn)
+	}
+	return NewLocal()
+}
@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"app/middleware"
+	"app/model"
+	"app/repository"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultAuditPage is how many audit log entries a page lists without
+// ?limit=
+const defaultAuditPage = 50
+
+// defaultUserPage is how many users a page lists without ?limit=
+const defaultUserPage = 20
+
+// AdminHandler serves the admin-only API
+type AdminHandler struct {
+	users *service.UserService
+	admin *service.AdminService
+	audit *service.AuditService
+}
+
+// NewAdminHandler creates an AdminHandler
+func NewAdminHandler(users *service.UserService, admin *service.AdminService, audit *service.AuditService) *AdminHandler {
+	return &AdminHandler{users: users, admin: admin, audit: audit}
+}
+
+// RequireAdmin lets through signed-in users with the admin role. It must
+// run after middleware.Protected.
+func (h *AdminHandler) RequireAdmin(c *fiber.Ctx) error {
+	return h.require(c, model.User.IsAdmin, "Admins only")
+}
+
+// RequireModerator lets through signed-in moderators and admins. It must
+// run after middleware.Protected.
+func (h *AdminHandler) RequireModerator(c *fiber.Ctx) error {
+	return h.require(c, model.User.IsModerator, "Moderators only")
+}
+
+// require lets the request through if the signed-in user is allowed
+func (h *AdminHandler) require(c *fiber.Ctx, allowed func(model.User) bool, message string) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	user, err := h.users.Get(c.UserContext(), userID)
+	if errors.Is(err, service.ErrNotFound) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": message})
+	} else if err != nil {
+		middleware.Logger(c).Error("error fetching user", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch user"})
+	}
+	// An admin acting as someone else has only that user's rights
+	if _, impersonating := middleware.GetImpersonatorID(c); impersonating || !allowed(*user) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": message})
+	}
+	return c.Next()
+}
+
+// ListUsers lists users in ID order, a ?page= of ?limit= at a time,
+// filtered by ?q= (part of the username, email or display name), ?role=
+// and ?status=
+func (h *AdminHandler) ListUsers(c *fiber.Ctx) error {
+	filter := repository.UserFilter{Query: c.Query("q"), Role: c.Query("role"), Status: c.Query("status")}
+	switch filter.Status {
+	case "", model.UserActive, model.UserSuspended, model.UserBanned, model.UserDeactivated:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid status"})
+	}
+	page, limit := c.QueryInt("page", 1), c.QueryInt("limit", defaultUserPage)
+	if page < 1 || limit < 1 || limit > service.MaxUserPage {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid page or limit"})
+	}
+
+	users, total, err := h.admin.Users(c.UserContext(), filter, page, limit)
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch users")
+	}
+	now := time.Now()
+	out := AdminUserPage{Users: make([]AdminUser, len(users)), Total: total, Page: page, Limit: limit}
+	for i, u := range users {
+		out.Users[i] = NewAdminUser(u, now)
+	}
+	return c.JSON(out)
+}
+
+// GetUser shows a user, even a deactivated one
+func (h *AdminHandler) GetUser(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	user, err := h.admin.User(c.UserContext(), uint(id))
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch user")
+	}
+	return c.JSON(NewAdminUser(*user, time.Now()))
+}
+
+// SuspendUser suspends a user until a time, or bans them without one, and
+// signs them out everywhere
+func (h *AdminHandler) SuspendUser(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	adminID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input SuspendUserRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	user, err := h.admin.Suspend(c.UserContext(), adminID, uint(id), input.Reason, input.Until)
+	if err != nil {
+		return h.fail(c, err, "Failed to suspend user")
+	}
+	return c.JSON(NewAdminUser(*user, time.Now()))
+}
+
+// UnsuspendUser lifts a user's suspension or ban
+func (h *AdminHandler) UnsuspendUser(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	adminID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	user, err := h.admin.Unsuspend(c.UserContext(), adminID, uint(id))
+	if err != nil {
+		return h.fail(c, err, "Failed to lift suspension")
+	}
+	return c.JSON(NewAdminUser(*user, time.Now()))
+}
+
+// LogoutUser signs a user out of every session
+func (h *AdminHandler) LogoutUser(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	adminID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if _, err := h.admin.Logout(c.UserContext(), adminID, uint(id)); err != nil {
+		return h.fail(c, err, "Failed to sign user out")
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "User signed out everywhere"})
+}
+
+// ImpersonateUser returns an access token acting as a user. Requests made
+// with it are audited with the admin as impersonator. There is no refresh
+// token; impersonation ends when the access token expires.
+func (h *AdminHandler) ImpersonateUser(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	adminID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	user, err := h.admin.Impersonate(c.UserContext(), adminID, uint(id))
+	if err != nil {
+		return h.fail(c, err, "Failed to impersonate user")
+	}
+	t, err := signAccessToken(*user, adminID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	return c.JSON(fiber.Map{"status": "success", "user_id": user.ID, "username": user.Username, "token": t})
+}
+
+func (h *AdminHandler) fail(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	case errors.Is(err, service.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not allowed on this user"})
+	case errors.Is(err, service.ErrInvalidSuspension):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		middleware.Logger(c).Error(message, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
+
+// GetAuditLog lists audit log entries, newest first, a ?page= of ?limit=
+// at a time, filtered by ?actor_id=, ?action=, ?target_type=,
+// ?target_id=, ?from= and ?to=
+func (h *AdminHandler) GetAuditLog(c *fiber.Ctx) error {
+	filter, err := auditFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	page, limit := c.QueryInt("page", 1), c.QueryInt("limit", defaultAuditPage)
+	if page < 1 || limit < 1 || limit > service.MaxAuditPage {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid page or limit"})
+	}
+
+	entries, total, err := h.audit.List(c.UserContext(), filter, page, limit)
+	if err != nil {
+		middleware.Logger(c).Error("error fetching audit log", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch audit log"})
+	}
+	out := AuditLogPage{Entries: make([]AuditLogResponse, len(entries)), Total: total, Page: page, Limit: limit}
+	for i, e := range entries {
+		out.Entries[i] = NewAuditLogResponse(e)
+	}
+	return c.JSON(out)
+}
+
+// ExportAuditLog sends every entry matching the GetAuditLog filters as a
+// CSV file, oldest first. The export itself is audited.
+func (h *AdminHandler) ExportAuditLog(c *fiber.Ctx) error {
+	adminID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	filter, err := auditFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := h.audit.RecordExport(c.UserContext(), adminID, filter); err != nil {
+		middleware.Logger(c).Error("error exporting audit log", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to export audit log"})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Attachment(fmt.Sprintf("audit-%s.csv", time.Now().UTC().Format("20060102-150405")))
+	// The log has no size limit, so it is streamed rather than built in
+	// memory. Once the first row is out an error can only cut it short.
+	ctx, log := c.UserContext(), middleware.Logger(c)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := h.audit.Export(ctx, filter, w); err != nil {
+			log.Error("audit log export cut short", "error", err)
+		}
+	})
+	return nil
+}
+
+// auditFilter reads the audit log filters from the query string. Times are
+// RFC 3339 or dates; a ?to= date includes that whole day.
+func auditFilter(c *fiber.Ctx) (repository.AuditFilter, error) {
+	filter := repository.AuditFilter{Action: c.Query("action"), TargetType: c.Query("target_type")}
+	for _, p := range []struct {
+		name string
+		dst  *uint
+	}{{"actor_id", &filter.ActorID}, {"target_id", &filter.TargetID}} {
+		if v := c.Query(p.name); v != "" {
+			id, err := strconv.ParseUint(v, 10, 0)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s", p.name)
+			}
+			*p.dst = uint(id)
+		}
+	}
+	var err error
+	if filter.From, _, err = parseAuditTime(c.Query("from")); err != nil {
+		return filter, errors.New("invalid from")
+	}
+	var day bool
+	if filter.To, day, err = parseAuditTime(c.Query("to")); err != nil {
+		return filter, errors.New("invalid to")
+	} else if day {
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+// parseAuditTime parses an RFC 3339 time or a date, reporting which it was.
+// The empty string is the zero time.
+func parseAuditTime(s string) (t time.Time, day bool, err error) {
+	if s == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err = time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	t, err = time.Parse(time.DateOnly, s)
+	return t, err == nil, err
+}
@@ -0,0 +1,180 @@
+package handler_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"app/handler"
+	"app/model"
+	"app/repository"
+	"app/router"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin_AuditLog(t *testing.T) {
+	db := setupInventoryDB()
+	require.NoError(t, db.Model(&model.User{}).Where("id = ?", 1).Update("role", model.RoleAdmin).Error)
+	app := fiber.New()
+	router.SetupRoutes(app, handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil))
+
+	resp := sendAs(t, app, 2, "PATCH", "/api/items/1", `{"name":"Desk lamp","price":"19.00"}`)
+	require.Equal(t, 200, resp.StatusCode)
+	resp = sendAs(t, app, 2, "GET", "/api/admin/audit", "")
+	assert.Equal(t, 403, resp.StatusCode)
+
+	resp = send(t, app, "GET", "/api/admin/audit?target_type=item&target_id=1", "")
+	require.Equal(t, 200, resp.StatusCode)
+	var page handler.AuditLogPage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	require.EqualValues(t, 1, page.Total)
+	entry := page.Entries[0]
+	assert.Equal(t, model.AuditItemUpdated, entry.Action)
+	assert.EqualValues(t, 2, *entry.ActorID)
+	assert.NotEmpty(t, entry.RequestID)
+	var changes map[string]map[string]any
+	require.NoError(t, json.Unmarshal(entry.Changes, &changes))
+	assert.Equal(t, map[string]any{"from": "Lamp", "to": "Desk lamp"}, changes["name"])
+	assert.Contains(t, changes, "price")
+	assert.NotContains(t, changes, "stock")
+
+	resp = send(t, app, "GET", "/api/admin/audit?from=yesterday", "")
+	assert.Equal(t, 400, resp.StatusCode)
+	resp = send(t, app, "GET", "/api/admin/audit?to=2000-01-01", "")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	assert.Zero(t, page.Total)
+
+	resp = send(t, app, "GET", "/api/admin/audit/export?actor_id=2", "")
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/csv")
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "attachment")
+	rows, err := csv.NewReader(resp.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "action", rows[0][4])
+	assert.Equal(t, model.AuditItemUpdated, rows[1][4])
+
+	// The export was audited too
+	resp = send(t, app, "GET", "/api/admin/audit?action=audit.export", "")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	require.EqualValues(t, 1, page.Total)
+	assert.EqualValues(t, 1, *page.Entries[0].ActorID)
+}
+
+// sendToken sends a request with a bearer token, as a client would after
+// signing in
+func sendToken(t *testing.T, app *fiber.App, token, method, path, body string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	return resp
+}
+
+func login(t *testing.T, app *fiber.App, identity string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(`{"identity":"`+identity+`","password":"password1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	return resp
+}
+
+func token(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	require.Equal(t, 200, resp.StatusCode)
+	var body struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body.Token
+}
+
+func TestAdmin_ModerateUsers(t *testing.T) {
+	db := setupInventoryDB()
+	hash, err := service.HashPassword("password1")
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&model.User{}).Where("1 = 1").Update("password", hash).Error)
+	require.NoError(t, db.Model(&model.User{}).Where("id = ?", 1).Update("role", model.RoleAdmin).Error)
+	app := fiber.New()
+	router.SetupRoutes(app, handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil))
+
+	resp := send(t, app, "GET", "/api/admin/users?q=SELL", "")
+	require.Equal(t, 200, resp.StatusCode)
+	var page handler.AdminUserPage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	require.EqualValues(t, 1, page.Total)
+	assert.Equal(t, "seller", page.Users[0].Username)
+	assert.Equal(t, model.UserActive, page.Users[0].Status)
+	assert.Equal(t, 400, send(t, app, "GET", "/api/admin/users?status=sleepy", "").StatusCode)
+
+	// Signing the seller out ends their session, but they may sign in again
+	seller := token(t, login(t, app, "seller"))
+	assert.Equal(t, 200, sendToken(t, app, seller, "GET", "/api/user/me", "").StatusCode)
+	assert.Equal(t, 200, send(t, app, "POST", "/api/admin/users/2/logout", "").StatusCode)
+	assert.Equal(t, 401, sendToken(t, app, seller, "GET", "/api/user/me", "").StatusCode)
+	seller = token(t, login(t, app, "seller"))
+	assert.Equal(t, 200, sendToken(t, app, seller, "GET", "/api/user/me", "").StatusCode)
+
+	// Suspended users can neither use their tokens nor sign in
+	assert.Equal(t, 400, send(t, app, "POST", "/api/admin/users/2/suspension", `{"reason":"Spam","until":"2001-01-01T00:00:00Z"}`).StatusCode)
+	assert.Equal(t, 403, send(t, app, "POST", "/api/admin/users/1/suspension", `{"reason":"Oops"}`).StatusCode)
+	until := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	resp = send(t, app, "POST", "/api/admin/users/2/suspension", `{"reason":"Spam","until":"`+until+`"}`)
+	require.Equal(t, 200, resp.StatusCode)
+	var user handler.AdminUser
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&user))
+	assert.Equal(t, model.UserSuspended, user.Status)
+	assert.Equal(t, 403, sendToken(t, app, seller, "GET", "/api/user/me", "").StatusCode)
+	assert.Equal(t, 403, login(t, app, "seller").StatusCode)
+
+	resp = send(t, app, "POST", "/api/admin/users/2/suspension", `{"reason":"Spam again"}`)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&user))
+	assert.Equal(t, model.UserBanned, user.Status)
+	resp = send(t, app, "GET", "/api/admin/users?status=banned", "")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	assert.EqualValues(t, 1, page.Total)
+
+	assert.Equal(t, 200, send(t, app, "DELETE", "/api/admin/users/2/suspension", "").StatusCode)
+	// Suspending signed them out, so the old token stays dead
+	assert.Equal(t, 401, sendToken(t, app, seller, "GET", "/api/user/me", "").StatusCode)
+	assert.Equal(t, 200, login(t, app, "seller").StatusCode)
+}
+
+func TestAdmin_Impersonate(t *testing.T) {
+	db := setupInventoryDB()
+	require.NoError(t, db.Model(&model.User{}).Where("id = ?", 1).Update("role", model.RoleAdmin).Error)
+	app := fiber.New()
+	router.SetupRoutes(app, handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil))
+
+	assert.Equal(t, 403, sendAs(t, app, 2, "POST", "/api/admin/users/1/impersonate", "").StatusCode)
+	assert.Equal(t, 403, send(t, app, "POST", "/api/admin/users/1/impersonate", "").StatusCode)
+	seller := token(t, send(t, app, "POST", "/api/admin/users/2/impersonate", ""))
+
+	// The admin acts with the seller's rights only
+	assert.Equal(t, 200, sendToken(t, app, seller, "PATCH", "/api/items/1", `{"name":"Desk lamp"}`).StatusCode)
+	assert.Equal(t, 403, sendToken(t, app, seller, "GET", "/api/admin/audit", "").StatusCode)
+
+	resp := send(t, app, "GET", "/api/admin/audit?target_type=item", "")
+	var page handler.AuditLogPage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	require.EqualValues(t, 1, page.Total)
+	assert.EqualValues(t, 2, *page.Entries[0].ActorID)
+	require.NotNil(t, page.Entries[0].ImpersonatorID)
+	assert.EqualValues(t, 1, *page.Entries[0].ImpersonatorID)
+
+	resp = send(t, app, "GET", "/api/admin/audit?action=user.impersonate", "")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	require.EqualValues(t, 1, page.Total)
+	assert.EqualValues(t, 2, *page.Entries[0].TargetID)
+}
@@ -1,13 +1,13 @@
 package handler
 
 import (
-	"fmt"
+	"app/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // Hello handle api status
 func Hello(c *fiber.Ctx) error {
-	fmt.Println("Hello I'm ok!")
+	middleware.Logger(c).Debug("health check")
 	return c.JSON(fiber.Map{"status": "success", "message": "Hello i'm ok!", "data": nil})
 }
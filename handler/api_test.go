@@ -5,17 +5,13 @@ import (
 	"os"
 	"testing"
 
-	"app/database"
 	"app/handler"
-	"app/model"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
 )
 
 func setupApiApp() *fiber.App {
-	database.ConnectDBWithDSN(":memory:")
-	database.DB.AutoMigrate(&model.User{})
 	os.Setenv("SECRET", "testsecret")
 
 	app := fiber.New()
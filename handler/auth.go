@@ -2,144 +2,65 @@ package handler
 
 import (
 	"errors"
-	"net/mail"
 	"time"
 
 	"app/config"
-	"app/database"
+	"app/metrics"
+	"app/middleware"
 	"app/model"
-
-	"gorm.io/gorm"
+	"app/service"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword hashes a plaintext password
-func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(hash), err
-}
-
-// CheckPasswords compare password with hash
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// AuthHandler serves login and registration
+type AuthHandler struct {
+	users    *service.UserService
+	accounts *service.AccountService
+	carts    *service.CartService
 }
 
-func getUserByEmail(e string) (*model.User, error) {
-	db := database.DB
-	var user model.User
-	if err := db.Where(&model.User{Email: e}).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
-		}
-		return nil, err
-	}
-	return &user, nil
-}
-
-func getUserByUsername(u string) (*model.User, error) {
-	db := database.DB
-	var user model.User
-	if err := db.Where(&model.User{Username: u}).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
-		}
-		return nil, err
-	}
-	return &user, nil
-}
-
-func valid(email string) bool {
-	_, err := mail.ParseAddress(email)
-	return err == nil
+// NewAuthHandler creates an AuthHandler
+func NewAuthHandler(users *service.UserService, accounts *service.AccountService, carts *service.CartService) *AuthHandler {
+	return &AuthHandler{users: users, accounts: accounts, carts: carts}
 }
 
 // Login get user and password
-func Login(c *fiber.Ctx) error {
-	type LoginInput struct {
-		Identity string `json:"identity"`
-		Password string `json:"password"`
+func (h *AuthHandler) Login(c *fiber.Ctx) error {
+	var input LoginRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
 	}
-	type UserData struct {
-		ID       uint   `json:"id"`
-		Username string `json:"username"`
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-	input := new(LoginInput)
-	var ud UserData
 
-	if err := c.BodyParser(input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+	user, err := h.users.Authenticate(c.UserContext(), input.Identity, input.Password)
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		metrics.LoginFailed()
+		middleware.Logger(c).Info("login failed")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Error on login request",
-			"errors":  err.Error(),
+			"message": "Invalid identity or password",
 		})
-	}
-
-	identity := input.Identity
-	pass := input.Password
-	var userModel *model.User
-	var err error
-
-	if valid(identity) {
-		userModel, err = getUserByEmail(identity)
-	} else {
-		userModel, err = getUserByUsername(identity)
-	}
-
-	const dummyHash = "$2a$10$7zFqzDbD3RrlkMTczbXG9OWZ0FLOXjIxXzSZ.QZxkVXjXcx7QZQiC" // Dummy hash
-
-	if err != nil {
+	} else if errors.Is(err, service.ErrSuspended) {
+		metrics.LoginFailed()
+		middleware.Logger(c).Info("login refused, account suspended")
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Account suspended",
+		})
+	} else if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Internal Server Error",
 			"data":    err,
 		})
-	} else if userModel == nil {
-		CheckPasswordHash(pass, dummyHash) // prevent timing attacks
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid identity or password",
-		})
-	}
-
-	ud = UserData{
-		ID:       userModel.ID,
-		Username: userModel.Username,
-		Email:    userModel.Email,
-		Password: userModel.Password,
 	}
 
-	if !CheckPasswordHash(pass, ud.Password) {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid identity or password",
-		})
-	}
-
-	// Generate Access Token
-	accessToken := jwt.New(jwt.SigningMethodHS256)
-	accessClaims := accessToken.Claims.(jwt.MapClaims)
-	accessClaims["username"] = ud.Username
-	accessClaims["user_id"] = ud.ID
-	accessClaims["exp"] = time.Now().Add(15 * time.Minute).Unix()
-
-	t, err := accessToken.SignedString([]byte(config.Config("SECRET")))
+	t, err := signAccessToken(*user, 0)
 	if err != nil {
 		return c.SendStatus(fiber.StatusInternalServerError)
 	}
-
-	// Generate Refresh Token
-	refreshToken := jwt.New(jwt.SigningMethodHS256)
-	refreshClaims := refreshToken.Claims.(jwt.MapClaims)
-	refreshClaims["user_id"] = ud.ID
-	refreshClaims["exp"] = time.Now().Add(7 * 24 * time.Hour).Unix()
-
-	rt, err := refreshToken.SignedString([]byte(config.Config("REFRESH_SECRET")))
+	rt, err := signRefreshToken(*user)
 	if err != nil {
 		return c.SendStatus(fiber.StatusInternalServerError)
 	}
@@ -160,17 +81,30 @@ func Login(c *fiber.Ctx) error {
 		Expires:  time.Now().Add(7 * 24 * time.Hour),
 	})
 
+	// Keep what the user put in their cart before signing in
+	if guest := c.Cookies(guestCartCookie); guest != "" {
+		if err := h.carts.Merge(c.UserContext(), user.ID, guest); err != nil {
+			middleware.Logger(c).Warn("error merging guest cart", "error", err)
+		} else {
+			c.ClearCookie(guestCartCookie)
+		}
+	}
+
+	metrics.LoginSucceeded()
+	middleware.Logger(c).Info("login succeeded", "user_id", user.ID)
 	return c.JSON(fiber.Map{
 		"status":   "success",
 		"message":  "Login successful",
-		"user_id":  ud.ID,
-		"username": ud.Username,
-		"email":    ud.Email,
+		"user_id":  user.ID,
+		"username": user.Username,
+		"email":    user.Email,
 		"token":    t,
 	})
 }
 
-func RefreshToken(c *fiber.Ctx) error {
+// RefreshToken issues a new access token for the refresh token cookie,
+// unless the user has since been signed out everywhere or suspended
+func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 	cookie := c.Cookies("refresh_token")
 	if cookie == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -191,17 +125,19 @@ func RefreshToken(c *fiber.Ctx) error {
 	}
 
 	claims := token.Claims.(jwt.MapClaims)
-	userID := uint(claims["user_id"].(float64))
-	username := claims["username"].(string)
-
-	// Generate new access token
-	newAccessToken := jwt.New(jwt.SigningMethodHS256)
-	newAccessClaims := newAccessToken.Claims.(jwt.MapClaims)
-	newAccessClaims["user_id"] = userID
-	newAccessClaims["username"] = username
-	newAccessClaims["exp"] = time.Now().Add(time.Minute * 15).Unix()
+	userID, _ := claims["user_id"].(float64)
+	version, _ := claims["token_version"].(float64)
+	user, err := h.users.Session(c.UserContext(), uint(userID), int(version))
+	switch {
+	case errors.Is(err, service.ErrSuspended):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "error", "message": "Account suspended"})
+	case errors.Is(err, service.ErrNotFound), errors.Is(err, service.ErrSessionEnded):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Invalid refresh token"})
+	case err != nil:
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
 
-	t, err := newAccessToken.SignedString([]byte(config.Config("SECRET")))
+	t, err := signAccessToken(*user, 0)
 	if err != nil {
 		return c.SendStatus(fiber.StatusInternalServerError)
 	}
@@ -212,46 +148,86 @@ func RefreshToken(c *fiber.Ctx) error {
 	})
 }
 
-// Register creates a new user
-func Register(c *fiber.Ctx) error {
-	var user model.User
-	if err := c.BodyParser(&user); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid request body", "errors": err.Error()})
+// Session is a middleware.SessionCheck refusing tokens of users signed out
+// everywhere since, suspended or gone
+func (h *AuthHandler) Session(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid token id")
+	}
+	_, err = h.users.Session(c.UserContext(), userID, middleware.GetTokenVersion(c))
+	switch {
+	case errors.Is(err, service.ErrSuspended):
+		return fiber.NewError(fiber.StatusForbidden, "Account suspended")
+	case errors.Is(err, service.ErrNotFound), errors.Is(err, service.ErrSessionEnded):
+		return fiber.NewError(fiber.StatusUnauthorized, "Session ended")
 	}
-	if !valid(user.Email) {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid email address"})
+	return err
+}
+
+// signAccessToken issues a 15 minute access token for user, on behalf of
+// impersonatorID when it isn't zero
+func signAccessToken(user model.User, impersonatorID uint) (string, error) {
+	claims := jwt.MapClaims{
+		"username":      user.Username,
+		"user_id":       user.ID,
+		"token_version": user.TokenVersion,
+		"exp":           time.Now().Add(15 * time.Minute).Unix(),
 	}
-	if len(user.Password) < 8 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Password must be at least 8 characters long"})
+	if impersonatorID != 0 {
+		claims["impersonator_id"] = impersonatorID
 	}
-	if len(user.Username) < 3 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Username must be at least 3 characters long"})
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Config("SECRET")))
+}
+
+// signRefreshToken issues a 7 day refresh token for user
+func signRefreshToken(user model.User) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id":       user.ID,
+		"token_version": user.TokenVersion,
+		"exp":           time.Now().Add(7 * 24 * time.Hour).Unix(),
 	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Config("REFRESH_SECRET")))
+}
 
-	db := database.DB
-	// Check if the username or email already exists
-	existingUser, err := getUserByEmail(user.Email)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Internal Server Error", "errors": err.Error()})
+// Register creates a new user
+func (h *AuthHandler) Register(c *fiber.Ctx) error {
+	var input RegisterRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
 	}
-	if existingUser != nil {
+
+	user, err := h.users.Register(c.UserContext(), input.Username, input.Email, input.Password)
+	switch {
+	case errors.Is(err, service.ErrEmailTaken):
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": "Email already exists"})
-	}
-	existingUser, err = getUserByUsername(user.Username)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Internal Server Error", "errors": err.Error()})
-	}
-	if existingUser != nil {
+	case errors.Is(err, service.ErrUsernameTaken):
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": "Username already exists"})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error creating user", "errors": err.Error()})
 	}
-	hash, err := HashPassword(user.Password)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error hashing password", "errors": err.Error()})
+	middleware.Logger(c).Info("user registered", "user_id", user.ID)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "User created successfully", "data": NewPrivateUser(*user)})
+}
+
+// Reactivate restores a deactivated account within the retention window
+func (h *AuthHandler) Reactivate(c *fiber.Ctx) error {
+	var input LoginRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
 	}
-	user.Password = hash
-	if err := db.Create(&user).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error creating user", "errors": err.Error()})
+
+	user, err := h.accounts.Reactivate(c.UserContext(), input.Identity, input.Password)
+	switch {
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Invalid identity or password"})
+	case errors.Is(err, service.ErrRetentionExpired):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"status": "error", "message": "Account can no longer be reactivated"})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Couldn't reactivate account", "errors": err.Error()})
 	}
+	middleware.Logger(c).Info("account reactivated", "user_id", user.ID)
 
-	return c.JSON(fiber.Map{"status": "success", "message": "User created successfully", "data": fiber.Map{"id": user.ID, "username": user.Username, "email": user.Email}})
+	return c.JSON(fiber.Map{"status": "success", "message": "Account reactivated", "data": NewPrivateUser(*user)})
 }
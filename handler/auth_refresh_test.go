@@ -9,6 +9,8 @@ import (
 
 	"app/database"
 	"app/handler"
+	"app/model"
+	"app/repository"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
@@ -16,20 +18,22 @@ import (
 )
 
 func setupRefreshApp() *fiber.App {
-	database.ConnectDBWithDSN(":memory:")
 	os.Setenv("SECRET", "testsecret")
 	os.Setenv("REFRESH_SECRET", "refreshsecret")
 
+	db := database.ConnectDBWithDSN(":memory:")
+	db.Create(&model.User{ID: 1, Username: "testuser", Email: "test@example.com", Password: "x", TokenVersion: 2})
+	h := handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil)
 	app := fiber.New()
-	app.Get("/auth/refresh", handler.RefreshToken)
+	app.Get("/auth/refresh", h.Auth.RefreshToken)
 	return app
 }
 
-func createRefreshCookie(userID uint, username string) string {
+func createRefreshCookie(userID uint, version int) string {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  userID,
-		"username": username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
+		"user_id":       userID,
+		"token_version": version,
+		"exp":           time.Now().Add(time.Hour * 24).Unix(),
 	})
 	signed, _ := token.SignedString([]byte("refreshsecret"))
 	return signed
@@ -38,7 +42,7 @@ func createRefreshCookie(userID uint, username string) string {
 func TestRefreshToken_Success(t *testing.T) {
 	app := setupRefreshApp()
 	req := httptest.NewRequest("GET", "/auth/refresh", nil)
-	cookie := createRefreshCookie(1, "testuser")
+	cookie := createRefreshCookie(1, 2)
 	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: cookie})
 
 	resp, err := app.Test(req)
@@ -55,3 +59,13 @@ func TestRefreshToken_Invalid(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 401, resp.StatusCode)
 }
+
+func TestRefreshToken_SignedOutEverywhere(t *testing.T) {
+	app := setupRefreshApp()
+	req := httptest.NewRequest("GET", "/auth/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: createRefreshCookie(1, 1)})
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 401, resp.StatusCode)
+}
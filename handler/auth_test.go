@@ -3,16 +3,22 @@ package handler_test
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http/httptest"
 	"os"
 	"testing"
 
 	"app/database"
 	"app/handler"
+	"app/metrics"
 	"app/model"
+	"app/repository"
+	"app/service"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 type RegisterPayload struct {
@@ -26,19 +32,19 @@ type LoginPayload struct {
 	Password string `json:"password"`
 }
 
-func setupAuthApp() *fiber.App {
-	database.ConnectDBWithDSN(":memory:")
-	database.DB.AutoMigrate(&model.User{})
+func setupAuthApp() (*fiber.App, *gorm.DB) {
+	db := database.ConnectDBWithDSN(":memory:")
+	h := handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil)
 	os.Setenv("SECRET", "testsecret")
 
 	app := fiber.New()
-	app.Post("/register", handler.Register)
-	app.Post("/login", handler.Login)
-	return app
+	app.Post("/register", h.Auth.Register)
+	app.Post("/login", h.Auth.Login)
+	return app, db
 }
 
 func TestRegister_Success(t *testing.T) {
-	app := setupAuthApp()
+	app, _ := setupAuthApp()
 	payload := RegisterPayload{"testuser", "test@example.com", "securepass"}
 	body, _ := json.Marshal(payload)
 
@@ -51,9 +57,9 @@ func TestRegister_Success(t *testing.T) {
 }
 
 func TestRegister_Conflict(t *testing.T) {
-	app := setupAuthApp()
+	app, db := setupAuthApp()
 	// Create user
-	database.DB.Create(&model.User{Username: "dupuser", Email: "dup@example.com", Password: "x"})
+	db.Create(&model.User{Username: "dupuser", Email: "dup@example.com", Password: "x"})
 	payload := RegisterPayload{"dupuser", "dup@example.com", "securepass"}
 	body, _ := json.Marshal(payload)
 
@@ -66,10 +72,10 @@ func TestRegister_Conflict(t *testing.T) {
 }
 
 func TestLogin_Success(t *testing.T) {
-	app := setupAuthApp()
+	app, db := setupAuthApp()
 	// Register first
-	hash, _ := handler.HashPassword("securepass")
-	database.DB.Create(&model.User{Username: "tester", Email: "tester@example.com", Password: hash})
+	hash, _ := service.HashPassword("securepass")
+	db.Create(&model.User{Username: "tester", Email: "tester@example.com", Password: hash})
 
 	payload := LoginPayload{"tester", "securepass"}
 	body, _ := json.Marshal(payload)
@@ -83,9 +89,9 @@ func TestLogin_Success(t *testing.T) {
 }
 
 func TestLogin_InvalidPassword(t *testing.T) {
-	app := setupAuthApp()
-	hash, _ := handler.HashPassword("securepass")
-	database.DB.Create(&model.User{Username: "wrongpass", Email: "wp@example.com", Password: hash})
+	app, db := setupAuthApp()
+	hash, _ := service.HashPassword("securepass")
+	db.Create(&model.User{Username: "wrongpass", Email: "wp@example.com", Password: hash})
 
 	payload := LoginPayload{"wrongpass", "wrongpass123"}
 	body, _ := json.Marshal(payload)
@@ -97,3 +103,22 @@ func TestLogin_InvalidPassword(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 401, resp.StatusCode)
 }
+
+func TestRegister_CountedOnEveryRoute(t *testing.T) {
+	db := database.ConnectDBWithDSN(":memory:")
+	h := handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil)
+	app := fiber.New()
+	app.Post("/register", h.Auth.Register)
+	app.Post("/user", h.User.CreateUser)
+	before := testutil.ToFloat64(metrics.RegistrationsTotal)
+
+	for i, path := range []string{"/register", "/user", "/user"} {
+		body, _ := json.Marshal(RegisterPayload{fmt.Sprintf("counted%d", i%2), fmt.Sprintf("counted%d@example.com", i%2), "securepass"})
+		req := httptest.NewRequest("POST", path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{200, 200, 409}[i], resp.StatusCode, path)
+	}
+	assert.Equal(t, before+2, testutil.ToFloat64(metrics.RegistrationsTotal), "a refused registration isn't counted")
+}
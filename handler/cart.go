@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"app/metrics"
+	"app/middleware"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Guest carts are kept in a cookie holding a random token
+const (
+	guestCartCookie = "cart_token"
+	guestCartTTL    = 30 * 24 * time.Hour
+)
+
+// CartHandler serves the shopping cart of a user or an anonymous guest
+type CartHandler struct {
+	carts *service.CartService
+}
+
+// NewCartHandler creates a CartHandler
+func NewCartHandler(carts *service.CartService) *CartHandler {
+	return &CartHandler{carts: carts}
+}
+
+// GetCart shows the cart with current prices and stock
+func (h *CartHandler) GetCart(c *fiber.Ctx) error {
+	cart, err := h.carts.Get(c.UserContext(), h.owner(c, false))
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch cart")
+	}
+	return c.JSON(NewCartResponse(*cart))
+}
+
+// AddToCart adds units of an item to the cart
+func (h *CartHandler) AddToCart(c *fiber.Ctx) error {
+	var input AddCartItemRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	cart, err := h.carts.Add(c.UserContext(), h.owner(c, true), input.ItemID, input.Quantity)
+	if err != nil {
+		return h.fail(c, err, "Failed to add to cart")
+	}
+	return c.JSON(NewCartResponse(*cart))
+}
+
+// UpdateCartItem sets the units of an item in the cart; zero removes it
+func (h *CartHandler) UpdateCartItem(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	var input UpdateCartItemRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	cart, err := h.carts.SetQuantity(c.UserContext(), h.owner(c, false), uint(itemID), input.Quantity)
+	if err != nil {
+		return h.fail(c, err, "Failed to update cart")
+	}
+	return c.JSON(NewCartResponse(*cart))
+}
+
+// RemoveCartItem takes an item out of the cart
+func (h *CartHandler) RemoveCartItem(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+
+	cart, err := h.carts.Remove(c.UserContext(), h.owner(c, false), uint(itemID))
+	if err != nil {
+		return h.fail(c, err, "Failed to update cart")
+	}
+	return c.JSON(NewCartResponse(*cart))
+}
+
+// Checkout orders everything in the current user's cart
+func (h *CartHandler) Checkout(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	orders, err := h.carts.Checkout(c.UserContext(), userID)
+	if err != nil {
+		return h.fail(c, err, "Failed to check out")
+	}
+	metrics.OrdersCreatedTotal.Add(float64(len(orders)))
+	return c.Status(fiber.StatusCreated).JSON(NewOrderResponses(orders))
+}
+
+// owner identifies the cart of the request: the signed-in user or the
+// guest cookie. With create set a guest without a cookie is given one.
+func (h *CartHandler) owner(c *fiber.Ctx, create bool) service.CartOwner {
+	if userID, err := middleware.GetUserID(c); err == nil {
+		return service.CartOwner{UserID: userID}
+	}
+	token := c.Cookies(guestCartCookie)
+	if !validGuestToken(token) {
+		token = ""
+	}
+	if token == "" && create {
+		token = newGuestToken()
+		c.Cookie(&fiber.Cookie{
+			Name:     guestCartCookie,
+			Value:    token,
+			HTTPOnly: true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+			Expires:  time.Now().Add(guestCartTTL),
+		})
+	}
+	return service.CartOwner{GuestToken: token}
+}
+
+func newGuestToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validGuestToken(token string) bool {
+	b, err := hex.DecodeString(token)
+	return err == nil && len(b) == 32
+}
+
+func (h *CartHandler) fail(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	case errors.Is(err, service.ErrOwnItem), errors.Is(err, service.ErrInvalidQuantity), errors.Is(err, service.ErrCartEmpty):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrInsufficientStock), errors.Is(err, service.ErrItemUnavailable):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		middleware.Logger(c).Error(message, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
@@ -0,0 +1,104 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"app/database"
+	"app/handler"
+	"app/model"
+	"app/money"
+	"app/repository"
+	"app/router"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupCartApp(t *testing.T) *fiber.App {
+	os.Setenv("SECRET", "testsecret")
+	os.Setenv("REFRESH_SECRET", "refreshsecret")
+	db := database.ConnectDBWithDSN(":memory:")
+	hash, err := service.HashPassword("securepass")
+	require.NoError(t, err)
+	db.Create(&model.User{ID: 1, Username: "buyer", Email: "buyer@example.com", Password: hash})
+	db.Create(&model.User{ID: 2, Username: "seller", Email: "seller@example.com", Password: hash})
+	db.Create(&model.Category{ID: 1, Name: "Lamps"})
+	db.Create(&model.Item{ID: 1, Name: "Lamp", Description: "Desk lamp", Price: money.New(1999, "EUR"), UserID: 2, CategoryID: 1, Stock: 3})
+
+	app := fiber.New()
+	router.SetupRoutes(app, handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil))
+	return app
+}
+
+// guest sends a request without a token, carrying the guest cart cookie
+func guest(t *testing.T, app *fiber.App, method, path, body string, cookie *http.Cookie) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestCart_GuestMergesOnLoginAndChecksOut(t *testing.T) {
+	app := setupCartApp(t)
+
+	resp := guest(t, app, "POST", "/api/cart/items", `{"item_id":1,"quantity":2}`, nil)
+	require.Equal(t, 200, resp.StatusCode)
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == "cart_token" {
+			cookie = c
+		}
+	}
+	require.NotNil(t, cookie)
+	assert.True(t, cookie.HttpOnly)
+
+	resp = guest(t, app, "GET", "/api/cart/", "", cookie)
+	var cart handler.CartResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&cart))
+	require.Len(t, cart.Lines, 1)
+	assert.Equal(t, "Lamp", cart.Lines[0].Item.Name)
+	assert.Equal(t, []money.Money{money.New(3998, "EUR")}, cart.Totals)
+	assert.True(t, cart.Ready)
+
+	// Another guest has an empty cart
+	resp = guest(t, app, "GET", "/api/cart/", "", nil)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&cart))
+	assert.Empty(t, cart.Lines)
+
+	assert.Equal(t, 400, guest(t, app, "POST", "/api/cart/checkout", "", cookie).StatusCode)
+
+	resp = guest(t, app, "POST", "/api/auth/login", `{"identity":"buyer","password":"securepass"}`, cookie)
+	require.Equal(t, 200, resp.StatusCode)
+
+	resp = send(t, app, "GET", "/api/cart/", "")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&cart))
+	require.Len(t, cart.Lines, 1)
+	assert.Equal(t, 2, cart.Lines[0].Quantity)
+
+	assert.Equal(t, 409, send(t, app, "PUT", "/api/cart/items/1", `{"quantity":4}`).StatusCode)
+	assert.Equal(t, 404, send(t, app, "PUT", "/api/cart/items/9", `{"quantity":1}`).StatusCode)
+
+	resp = send(t, app, "POST", "/api/cart/checkout", "")
+	require.Equal(t, 201, resp.StatusCode)
+	var orders []handler.OrderResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&orders))
+	require.Len(t, orders, 1)
+	assert.Equal(t, money.New(3998, "EUR"), orders[0].TotalPrice)
+
+	resp = send(t, app, "GET", "/api/cart/", "")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&cart))
+	assert.Empty(t, cart.Lines)
+	assert.Equal(t, 400, send(t, app, "POST", "/api/cart/checkout", "").StatusCode)
+}
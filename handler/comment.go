@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"app/middleware"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CommentHandler serves the public comment threads and likes of items
+type CommentHandler struct {
+	comments *service.CommentService
+	likes    *service.LikeService
+}
+
+// NewCommentHandler creates a CommentHandler
+func NewCommentHandler(comments *service.CommentService, likes *service.LikeService) *CommentHandler {
+	return &CommentHandler{comments: comments, likes: likes}
+}
+
+// ListComments shows the comment threads of an item
+func (h *CommentHandler) ListComments(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+
+	threads, err := h.comments.List(c.UserContext(), uint(itemID))
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch comments")
+	}
+	return c.JSON(NewCommentThreads(threads))
+}
+
+// CreateComment comments on an item, or replies to parent_id
+func (h *CommentHandler) CreateComment(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input CreateCommentRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	comment, err := h.comments.Create(c.UserContext(), userID, uint(itemID), input.ParentID, input.Body)
+	if err != nil {
+		return h.fail(c, err, "Failed to create comment")
+	}
+	return c.Status(fiber.StatusCreated).JSON(NewCommentResponse(*comment))
+}
+
+// UpdateComment edits a comment of the current user
+func (h *CommentHandler) UpdateComment(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid comment ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input UpdateCommentRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	comment, err := h.comments.Edit(c.UserContext(), userID, uint(id), input.Body)
+	if err != nil {
+		return h.fail(c, err, "Failed to update comment")
+	}
+	return c.JSON(NewCommentResponse(*comment))
+}
+
+// DeleteComment deletes a comment of the current user
+func (h *CommentHandler) DeleteComment(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid comment ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.comments.Delete(c.UserContext(), userID, uint(id)); err != nil {
+		return h.fail(c, err, "Failed to delete comment")
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Comment deleted"})
+}
+
+// GetLike tells whether the current user likes an item
+func (h *CommentHandler) GetLike(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	liked, count, err := h.likes.Liked(c.UserContext(), userID, uint(itemID))
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch like")
+	}
+	return c.JSON(LikeResponse{Liked: liked, Likes: count})
+}
+
+// Like likes an item; liking twice changes nothing
+func (h *CommentHandler) Like(c *fiber.Ctx) error {
+	return h.setLike(c, true)
+}
+
+// Unlike takes back a like
+func (h *CommentHandler) Unlike(c *fiber.Ctx) error {
+	return h.setLike(c, false)
+}
+
+func (h *CommentHandler) setLike(c *fiber.Ctx, like bool) error {
+	itemID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	set := h.likes.Unlike
+	if like {
+		set = h.likes.Like
+	}
+	count, err := set(c.UserContext(), userID, uint(itemID))
+	if err != nil {
+		return h.fail(c, err, "Failed to update like")
+	}
+	return c.JSON(LikeResponse{Liked: like, Likes: count})
+}
+
+func (h *CommentHandler) fail(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	case errors.Is(err, service.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You did not write this comment"})
+	case errors.Is(err, service.ErrInvalidComment), errors.Is(err, service.ErrThreadTooDeep):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		middleware.Logger(c).Error(message, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
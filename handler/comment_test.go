@@ -0,0 +1,60 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"app/handler"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComments_ThreadAndLikes(t *testing.T) {
+	app := setupInventoryApp()
+
+	resp := send(t, app, "POST", "/api/items/1/comments", `{"body":"Is the cable long?"}`)
+	require.Equal(t, 201, resp.StatusCode)
+	var question handler.CommentResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&question))
+	assert.Equal(t, "buyer", question.Author.Username)
+
+	resp = send(t, app, "POST", "/api/items/1/comments", fmt.Sprintf(`{"body":"Two meters","parent_id":%d}`, question.ID))
+	require.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, 400, send(t, app, "POST", "/api/items/1/comments", `{"body":""}`).StatusCode)
+	assert.Equal(t, 404, send(t, app, "POST", "/api/items/9/comments", `{"body":"hello"}`).StatusCode)
+
+	resp = send(t, app, "PATCH", fmt.Sprintf("/api/comments/%d", question.ID), `{"body":"Is the cable long enough?"}`)
+	require.Equal(t, 200, resp.StatusCode)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/items/1/comments", nil))
+	require.NoError(t, err)
+	var threads []handler.CommentResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&threads))
+	require.Len(t, threads, 1)
+	assert.Equal(t, "Is the cable long enough?", threads[0].Body)
+	assert.NotNil(t, threads[0].EditedAt)
+	require.Len(t, threads[0].Replies, 1)
+
+	resp = send(t, app, "PUT", "/api/items/1/like", "")
+	require.Equal(t, 200, resp.StatusCode)
+	var like handler.LikeResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&like))
+	assert.Equal(t, handler.LikeResponse{Liked: true, Likes: 1}, like)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/items/1", nil))
+	require.NoError(t, err)
+	var item handler.ItemDetail
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&item))
+	assert.Equal(t, 1, item.Likes)
+	assert.Equal(t, 2, item.Comments)
+
+	resp = send(t, app, "DELETE", "/api/items/1/like", "")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&like))
+	assert.Equal(t, handler.LikeResponse{Liked: false, Likes: 0}, like)
+
+	assert.Equal(t, 200, send(t, app, "DELETE", fmt.Sprintf("/api/comments/%d", question.ID), "").StatusCode)
+	assert.Equal(t, 404, send(t, app, "DELETE", fmt.Sprintf("/api/comments/%d", question.ID), "").StatusCode)
+}
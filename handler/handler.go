@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"log/slog"
+	"time"
+
+	"app/config"
+	"app/events"
+	"app/money"
+	"app/payment"
+	"app/repository"
+	"app/service"
+	"app/storage"
+)
+
+// Handlers bundles the HTTP handlers mounted by router.SetupRoutes
+type Handlers struct {
+	Auth      *AuthHandler
+	User      *UserHandler
+	Item      *ItemHandler
+	Image     *ImageHandler
+	Inventory *InventoryHandler
+	Order     *OrderHandler
+	Cart      *CartHandler
+	Payment   *PaymentHandler
+	Seller    *SellerHandler
+	Comment   *CommentHandler
+	Review    *ReviewHandler
+	Wishlist  *WishlistHandler
+	// Notification serves the notification center
+	Notification *NotificationHandler
+	Stream       *StreamHandler
+	Message      *MessageHandler
+	Webhook      *WebhookHandler
+	Admin        *AdminHandler
+	Moderation   *ModerationHandler
+	// Media serves the local blob store; nil when blobs live elsewhere
+	Media *MediaHandler
+}
+
+// Services bundles the services wired by NewServices
+type Services struct {
+	Audit         *service.AuditService
+	Users         *service.UserService
+	Accounts      *service.AccountService
+	Admin         *service.AdminService
+	Items         *service.ItemService
+	Images        *service.ImageService
+	Inventory     *service.InventoryService
+	Orders        *service.OrderService
+	Carts         *service.CartService
+	Payments      *service.PaymentService
+	Stats         *service.StatsService
+	Comments      *service.CommentService
+	Likes         *service.LikeService
+	Reviews       *service.ReviewService
+	Wishlists     *service.WishlistService
+	Notifications *service.NotificationService
+	Messages      *service.MessageService
+	Webhooks      *service.WebhookService
+	Moderation    *service.ModerationService
+}
+
+// NewServices wires the services on top of repos and hooks them up to
+// each other, storing uploads in blobs, collecting payments through
+// provider and publishing real-time updates on bus. The web server and
+// cmd/worker share it so both run the same hooks.
+func NewServices(repos *repository.Repositories, blobs storage.Blob, provider payment.Provider, bus events.Bus) Services {
+	audit := service.NewAuditService(repos.Audit)
+	inventory := service.NewInventoryService(repos.Tx, repos.Items, repos.Inventory)
+	items := service.NewItemService(repos.Tx, repos.Items, repos.Users, repos.Categories, repos.Inventory)
+	orders := service.NewOrderService(repos.Tx, repos.Items, repos.Orders, inventory)
+	s := Services{
+		Audit:         audit,
+		Users:         service.NewUserService(repos.Tx, repos.Users, repos.Items, repos.Reviews, audit),
+		Accounts:      service.NewAccountService(repos, audit, Retention()),
+		Admin:         service.NewAdminService(repos.Tx, repos.Users, audit),
+		Items:         items,
+		Images:        service.NewImageService(repos.Tx, repos.Items, repos.Images, repos.Jobs, blobs),
+		Inventory:     inventory,
+		Orders:        orders,
+		Carts:         service.NewCartService(repos.Tx, repos.Carts, repos.Items, orders),
+		Payments:      service.NewPaymentService(repos.Tx, repos.Orders, repos.Payments, provider, bus),
+		Stats:         service.NewStatsService(repos.Stats),
+		Comments:      service.NewCommentService(repos.Tx, repos.Comments, repos.Items),
+		Likes:         service.NewLikeService(repos.Tx, repos.Likes, repos.Items),
+		Reviews:       service.NewReviewService(repos.Tx, repos.Reviews, repos.Items),
+		Notifications: service.NewNotificationService(repos.Notifications, repos.Users, repos.Jobs, bus),
+		Messages:      service.NewMessageService(repos.Tx, repos.Messages, repos.Items, repos.Users, bus),
+		Webhooks:      service.NewWebhookService(repos.Webhooks, repos.Jobs, service.NewWebhookClient(config.Config("WEBHOOK_ALLOW_PRIVATE") == "true")),
+		Moderation:    service.NewModerationService(repos, items, audit, Blocklist()),
+	}
+	s.Wishlists = service.NewWishlistService(repos.Wishlists, repos.Items, s.Notifications)
+
+	s.Items.OnChange(s.Wishlists.ItemChanged)
+	s.Inventory.OnChange(s.Wishlists.ItemChanged)
+	s.Orders.OnEvent(s.Notifications.Fanout)
+	s.Comments.OnEvent(s.Notifications.Fanout)
+	s.Reviews.OnEvent(s.Notifications.Fanout)
+	s.Orders.OnEvent(s.Webhooks.Relay)
+	s.Items.OnEvent(s.Webhooks.Relay)
+	s.Items.OnEvent(s.Audit.ItemEvent)
+	s.Items.OnEvent(s.Moderation.Screen)
+	s.Inventory.OnChange(s.Webhooks.StockChanged)
+	return s
+}
+
+// New wires services and handlers on top of repos, storing uploads in blobs,
+// converting display prices with rates, collecting payments through
+// provider and streaming real-time updates over bus. A nil bus only
+// reaches clients connected to this process. Emails and image variants are
+// left to the jobs queue, see cmd/worker.
+func New(repos *repository.Repositories, blobs storage.Blob, rates money.RateProvider, provider payment.Provider, bus events.Bus) Handlers {
+	if bus == nil {
+		bus = events.NewLocal()
+	}
+	s := NewServices(repos, blobs, provider, bus)
+
+	h := Handlers{
+		Auth:         NewAuthHandler(s.Users, s.Accounts, s.Carts),
+		User:         NewUserHandler(s.Users, s.Accounts),
+		Item:         NewItemHandler(s.Items, s.Images, money.DefaultCurrency(), rates),
+		Image:        NewImageHandler(s.Images),
+		Inventory:    NewInventoryHandler(s.Inventory),
+		Order:        NewOrderHandler(s.Orders),
+		Cart:         NewCartHandler(s.Carts),
+		Payment:      NewPaymentHandler(s.Payments),
+		Seller:       NewSellerHandler(s.Stats),
+		Comment:      NewCommentHandler(s.Comments, s.Likes),
+		Review:       NewReviewHandler(s.Reviews),
+		Wishlist:     NewWishlistHandler(s.Wishlists),
+		Notification: NewNotificationHandler(s.Notifications),
+		Stream:       NewStreamHandler(bus, s.Users),
+		Message:      NewMessageHandler(s.Messages),
+		Webhook:      NewWebhookHandler(s.Webhooks),
+		Admin:        NewAdminHandler(s.Users, s.Admin, s.Audit),
+		Moderation:   NewModerationHandler(s.Moderation),
+	}
+	if local, ok := blobs.(*storage.Local); ok {
+		h.Media = NewMediaHandler(local)
+	}
+	return h
+}
+
+// Blocklist reads the terms new and edited items are screened for from
+// MODERATION_BLOCKLIST, skipping invalid ones
+func Blocklist() *service.Blocklist {
+	list, err := service.ParseBlocklist(config.Config("MODERATION_BLOCKLIST"))
+	if err != nil {
+		slog.Error("invalid MODERATION_BLOCKLIST terms skipped", "error", err)
+	}
+	return list
+}
+
+// Retention reads how long deactivated accounts are kept from
+// ACCOUNT_RETENTION_DAYS
+func Retention() time.Duration {
+	return time.Duration(config.Int("ACCOUNT_RETENTION_DAYS", service.DefaultRetentionDays)) * 24 * time.Hour
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"app/middleware"
+	"app/model"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ImageHandler manages the pictures of an item
+type ImageHandler struct {
+	images *service.ImageService
+}
+
+// NewImageHandler creates an ImageHandler
+func NewImageHandler(images *service.ImageService) *ImageHandler {
+	return &ImageHandler{images: images}
+}
+
+// UploadImage adds the multipart file field "image" to an item
+func (h *ImageHandler) UploadImage(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	fh, err := c.FormFile("image")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing image file"})
+	}
+	if fh.Size > service.MaxImageBytes {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": service.ErrImageTooLarge.Error()})
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unreadable image file"})
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, service.MaxImageBytes+1))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unreadable image file"})
+	}
+
+	img, err := h.images.Upload(c.UserContext(), userID, uint(itemID), data)
+	if err != nil {
+		return h.fail(c, err, "Failed to upload image")
+	}
+	return c.Status(fiber.StatusCreated).JSON(h.image(c, *img))
+}
+
+// DeleteImage removes a picture from an item
+func (h *ImageHandler) DeleteImage(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	imageID, err := strconv.Atoi(c.Params("imageId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid image ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.images.Delete(c.UserContext(), userID, uint(itemID), uint(imageID)); err != nil {
+		return h.fail(c, err, "Failed to delete image")
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Image deleted successfully"})
+}
+
+// ReorderImages sets the display order of an item's pictures
+func (h *ImageHandler) ReorderImages(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input ReorderImagesRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	images, err := h.images.Reorder(c.UserContext(), userID, uint(itemID), input.ImageIDs)
+	if err != nil {
+		return h.fail(c, err, "Failed to reorder images")
+	}
+	return c.JSON(h.list(c, images))
+}
+
+// SetPrimaryImage makes a picture the one shown in listings
+func (h *ImageHandler) SetPrimaryImage(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	imageID, err := strconv.Atoi(c.Params("imageId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid image ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	images, err := h.images.SetPrimary(c.UserContext(), userID, uint(itemID), uint(imageID))
+	if err != nil {
+		return h.fail(c, err, "Failed to set primary image")
+	}
+	return c.JSON(h.list(c, images))
+}
+
+func (h *ImageHandler) fail(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Item or image not found"})
+	case errors.Is(err, service.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You do not own this item"})
+	case errors.Is(err, service.ErrImageTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrUnsupportedImage):
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrTooManyImages), errors.Is(err, service.ErrInvalidImageOrder):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	default:
+		middleware.Logger(c).Error(message, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
+
+func (h *ImageHandler) image(c *fiber.Ctx, img model.ItemImage) ItemImage {
+	return signImage(c, h.images, img)
+}
+
+func (h *ImageHandler) list(c *fiber.Ctx, images []model.ItemImage) []ItemImage {
+	out := make([]ItemImage, len(images))
+	for i, img := range images {
+		out[i] = h.image(c, img)
+	}
+	return out
+}
+
+// signImage maps img with signed URLs. A signing failure leaves the URLs
+// out rather than failing the whole response.
+func signImage(c *fiber.Ctx, images *service.ImageService, img model.ItemImage) ItemImage {
+	urls, err := images.URLs(c.UserContext(), img)
+	if err != nil {
+		middleware.Logger(c).Warn("error signing image urls", "image_id", img.ID, "error", err)
+	}
+	return NewItemImage(img, urls)
+}
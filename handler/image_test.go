@@ -0,0 +1,211 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"app/database"
+	"app/handler"
+	"app/jobs"
+	"app/model"
+	"app/money"
+	"app/repository"
+	"app/router"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupImageApp also returns a function running the queued jobs, as
+// cmd/worker would
+func setupImageApp(t *testing.T) (*fiber.App, func()) {
+	os.Setenv("SECRET", "testsecret")
+	db := database.ConnectDBWithDSN(":memory:")
+	db.Create(&model.User{ID: 1, Username: "seller", Email: "seller@example.com", Password: "x"})
+	db.Create(&model.User{ID: 2, Username: "other", Email: "other@example.com", Password: "x"})
+	db.Create(&model.Category{ID: 1, Name: "Lamps", Description: "Lamps"})
+	db.Create(&model.Item{ID: 1, Name: "Lamp", Description: "Desk lamp", Price: money.New(1500, "EUR"), UserID: 1, CategoryID: 1})
+	db.Create(&model.Item{ID: 2, Name: "Chair", Description: "Chair", Price: money.New(3000, "EUR"), UserID: 2, CategoryID: 1})
+
+	repos, blobs := repository.New(db), testBlobs()
+	app := fiber.New()
+	router.SetupRoutes(app, handler.New(repos, blobs, testRates(), testPayments(), nil))
+
+	runner := jobs.NewRunner(repos.Jobs, "test")
+	runner.Register(service.JobImageVariants, service.NewImageService(repos.Tx, repos.Items, repos.Images, repos.Jobs, blobs).RenderVariants)
+	return app, func() {
+		_, err := runner.RunOnce(context.Background(), time.Now())
+		require.NoError(t, err)
+	}
+}
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+	}
+	img.Set(0, 0, color.White)
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+func upload(t *testing.T, app *fiber.App, itemID uint, data []byte) *http.Response {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("image", "photo.jpg")
+	require.NoError(t, err)
+	fw.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest("POST", fmt.Sprintf("/api/items/%d/images", itemID), &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", authHeader())
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	return resp
+}
+
+func send(t *testing.T, app *fiber.App, method, path, body string) *http.Response {
+	t.Helper()
+	return sendAs(t, app, 1, method, path, body)
+}
+
+func sendAs(t *testing.T, app *fiber.App, userID uint, method, path, body string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authHeaderFor(userID))
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestItemImages_UploadAndServe(t *testing.T) {
+	app, runJobs := setupImageApp(t)
+
+	resp := upload(t, app, 1, testJPEG(t, 1600, 800))
+	require.Equal(t, 201, resp.StatusCode)
+	var img handler.ItemImage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&img))
+	assert.True(t, img.Primary)
+	assert.Equal(t, 1600, img.Width)
+	for _, v := range []string{"original", "large", "medium", "thumb"} {
+		assert.Contains(t, img.URLs, v)
+	}
+	// Until the worker has resized it every variant is the original
+	assert.Equal(t, img.URLs["original"], img.URLs["thumb"])
+
+	runJobs()
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/items/1", nil))
+	require.NoError(t, err)
+	var detail handler.ItemDetail
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&detail))
+	require.Len(t, detail.Images, 1)
+	img = detail.Images[0]
+	assert.NotEqual(t, img.URLs["original"], img.URLs["thumb"])
+
+	// The signed thumbnail URL is served, a tampered one is not
+	resp, err = app.Test(httptest.NewRequest("GET", img.URLs["thumb"], nil))
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "image/jpeg", resp.Header.Get("Content-Type"))
+	data, _ := io.ReadAll(resp.Body)
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 200, cfg.Width)
+	assert.Equal(t, 100, cfg.Height)
+
+	resp, err = app.Test(httptest.NewRequest("GET", img.URLs["thumb"]+"0", nil))
+	require.NoError(t, err)
+	assert.Equal(t, 403, resp.StatusCode)
+
+	// Listings carry the primary thumbnail
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/items/", nil))
+	require.NoError(t, err)
+	var summaries []handler.ItemSummary
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&summaries))
+	assert.Equal(t, img.URLs["thumb"], summaries[0].ImageURL)
+	assert.Empty(t, summaries[1].ImageURL)
+}
+
+func TestItemImages_Rejections(t *testing.T) {
+	app, _ := setupImageApp(t)
+
+	resp := upload(t, app, 1, []byte("<svg xmlns='http://www.w3.org/2000/svg'/>"))
+	assert.Equal(t, 415, resp.StatusCode)
+
+	resp = upload(t, app, 2, testJPEG(t, 10, 10))
+	assert.Equal(t, 403, resp.StatusCode)
+
+	resp = upload(t, app, 99, testJPEG(t, 10, 10))
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestItemImages_OrderAndPrimary(t *testing.T) {
+	app, _ := setupImageApp(t)
+
+	var ids []uint
+	for i := 0; i < 3; i++ {
+		resp := upload(t, app, 1, testJPEG(t, 20, 20))
+		require.Equal(t, 201, resp.StatusCode)
+		var img handler.ItemImage
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&img))
+		ids = append(ids, img.ID)
+	}
+
+	detail := func() handler.ItemDetail {
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/items/1", nil))
+		require.NoError(t, err)
+		var d handler.ItemDetail
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&d))
+		return d
+	}
+	order := func(d handler.ItemDetail) (out []uint, primary uint) {
+		for _, img := range d.Images {
+			out = append(out, img.ID)
+			if img.Primary {
+				primary = img.ID
+			}
+		}
+		return out, primary
+	}
+
+	got, primary := order(detail())
+	assert.Equal(t, ids, got)
+	assert.Equal(t, ids[0], primary)
+
+	resp := send(t, app, "PUT", "/api/items/1/images/order", fmt.Sprintf(`{"image_ids":[%d,%d,%d]}`, ids[2], ids[0], ids[1]))
+	assert.Equal(t, 200, resp.StatusCode)
+	resp = send(t, app, "PUT", "/api/items/1/images/order", fmt.Sprintf(`{"image_ids":[%d,%d]}`, ids[2], ids[0]))
+	assert.Equal(t, 422, resp.StatusCode)
+
+	resp = send(t, app, "PUT", fmt.Sprintf("/api/items/1/images/%d/primary", ids[1]), "")
+	assert.Equal(t, 200, resp.StatusCode)
+	got, primary = order(detail())
+	assert.Equal(t, []uint{ids[2], ids[0], ids[1]}, got)
+	assert.Equal(t, ids[1], primary)
+
+	// Deleting the primary image promotes the first remaining one
+	resp = send(t, app, "DELETE", fmt.Sprintf("/api/items/1/images/%d", ids[1]), "")
+	assert.Equal(t, 200, resp.StatusCode)
+	got, primary = order(detail())
+	assert.Equal(t, []uint{ids[2], ids[0]}, got)
+	assert.Equal(t, ids[2], primary)
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"app/middleware"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// InventoryHandler serves item stock and reservations
+type InventoryHandler struct {
+	inventory *service.InventoryService
+}
+
+// NewInventoryHandler creates an InventoryHandler
+func NewInventoryHandler(inventory *service.InventoryService) *InventoryHandler {
+	return &InventoryHandler{inventory: inventory}
+}
+
+// GetInventory shows the owner the stock, reservations and adjustment log
+// of an item
+func (h *InventoryHandler) GetInventory(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	level, err := h.inventory.Level(c.UserContext(), userID, uint(itemID))
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch inventory")
+	}
+	return c.JSON(NewInventoryResponse(*level))
+}
+
+// AdjustInventory restocks or corrects the stock of an item
+func (h *InventoryHandler) AdjustInventory(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input AdjustInventoryRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	if _, err := h.inventory.Adjust(c.UserContext(), userID, uint(itemID), input.Delta, input.Reason, input.Note); err != nil {
+		return h.fail(c, err, "Failed to adjust inventory")
+	}
+	level, err := h.inventory.Level(c.UserContext(), userID, uint(itemID))
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch inventory")
+	}
+	return c.JSON(NewInventoryResponse(*level))
+}
+
+// Reserve holds stock of an item for the current user during checkout
+func (h *InventoryHandler) Reserve(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input CreateReservationRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	res, err := h.inventory.Reserve(c.UserContext(), userID, uint(itemID), input.Quantity)
+	if err != nil {
+		return h.fail(c, err, "Failed to reserve stock")
+	}
+	return c.Status(fiber.StatusCreated).JSON(NewReservationResponse(*res))
+}
+
+// Release cancels a reservation of the current user
+func (h *InventoryHandler) Release(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid reservation ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.inventory.Release(c.UserContext(), userID, uint(id)); err != nil {
+		return h.fail(c, err, "Failed to release reservation")
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Reservation released"})
+}
+
+func (h *InventoryHandler) fail(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	case errors.Is(err, service.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You do not own this item"})
+	case errors.Is(err, service.ErrOwnItem), errors.Is(err, service.ErrInvalidQuantity):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrInsufficientStock), errors.Is(err, service.ErrReservationInactive), errors.Is(err, service.ErrItemUnavailable):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		middleware.Logger(c).Error(message, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
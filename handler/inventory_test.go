@@ -0,0 +1,121 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"app/database"
+	"app/handler"
+	"app/model"
+	"app/money"
+	"app/repository"
+	"app/router"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupInventoryApp() *fiber.App {
+	app := fiber.New()
+	router.SetupRoutes(app, handler.New(repository.New(setupInventoryDB()), testBlobs(), testRates(), testPayments(), nil))
+	return app
+}
+
+// setupInventoryDB seeds a buyer (1), a seller (2), two items of the seller
+// and one of the buyer
+func setupInventoryDB() *gorm.DB {
+	os.Setenv("SECRET", "testsecret")
+	db := database.ConnectDBWithDSN(":memory:")
+	db.Create(&model.User{ID: 1, Username: "buyer", Email: "buyer@example.com", Password: "x"})
+	db.Create(&model.User{ID: 2, Username: "seller", Email: "seller@example.com", Password: "x"})
+	db.Create(&model.Category{ID: 1, Name: "Lamps", Description: "Lamps"})
+	db.Create(&model.Item{ID: 1, Name: "Lamp", Description: "Desk lamp", Price: money.New(1500, "EUR"), UserID: 2, CategoryID: 1, Stock: 2})
+	db.Create(&model.Item{ID: 2, Name: "Shade", Description: "Sold out", Price: money.New(500, "EUR"), UserID: 2, CategoryID: 1})
+	db.Create(&model.Item{ID: 3, Name: "Bulb", Description: "Mine", Price: money.New(100, "EUR"), UserID: 1, CategoryID: 1, Stock: 1})
+	return db
+}
+
+func TestItems_InStockFilter(t *testing.T) {
+	app := setupInventoryApp()
+
+	for path, want := range map[string]int{
+		"/api/items/":                      3,
+		"/api/items/?in_stock=true":        2,
+		"/api/items/category/1?in_stock=1": 2,
+	} {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		require.NoError(t, err)
+		var items []handler.ItemSummary
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&items))
+		assert.Len(t, items, want, path)
+		for _, it := range items {
+			assert.Equal(t, it.Stock > 0, it.Available)
+		}
+	}
+}
+
+func TestOrders_DecrementStock(t *testing.T) {
+	app := setupInventoryApp()
+
+	resp := send(t, app, "POST", "/api/orders/", `{"item_id":1,"quantity":2}`)
+	assert.Equal(t, 201, resp.StatusCode)
+	resp = send(t, app, "POST", "/api/orders/", `{"item_id":1,"quantity":1}`)
+	assert.Equal(t, 409, resp.StatusCode, "sold out")
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/items/1", nil))
+	require.NoError(t, err)
+	var item handler.ItemDetail
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&item))
+	assert.Equal(t, 0, item.Stock)
+	assert.False(t, item.Available)
+}
+
+func TestReservations_CheckoutFlow(t *testing.T) {
+	app := setupInventoryApp()
+
+	resp := send(t, app, "POST", "/api/items/1/reservations", `{"quantity":2}`)
+	require.Equal(t, 201, resp.StatusCode)
+	var res handler.ReservationResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	assert.Equal(t, "active", res.Status)
+
+	resp = send(t, app, "POST", "/api/items/1/reservations", `{"quantity":1}`)
+	assert.Equal(t, 409, resp.StatusCode)
+
+	resp = send(t, app, "POST", "/api/orders/", fmt.Sprintf(`{"reservation_id":%d}`, res.ID))
+	assert.Equal(t, 201, resp.StatusCode)
+	resp = send(t, app, "DELETE", fmt.Sprintf("/api/reservations/%d", res.ID), "")
+	assert.Equal(t, 409, resp.StatusCode, "already consumed")
+
+	resp = send(t, app, "POST", "/api/orders/", `{}`)
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
+func TestInventory_OwnerAdjustsAndReadsLog(t *testing.T) {
+	app := setupInventoryApp()
+
+	resp := send(t, app, "POST", "/api/items/1/inventory", `{"delta":5,"reason":"restock"}`)
+	assert.Equal(t, 403, resp.StatusCode)
+
+	resp = send(t, app, "POST", "/api/items/3/inventory", `{"delta":4,"reason":"restock","note":"new box"}`)
+	require.Equal(t, 200, resp.StatusCode)
+	resp = send(t, app, "POST", "/api/items/3/inventory", `{"delta":-1,"reason":"correction"}`)
+	require.Equal(t, 200, resp.StatusCode)
+	resp = send(t, app, "POST", "/api/items/3/inventory", `{"delta":1,"reason":"order"}`)
+	assert.Equal(t, 400, resp.StatusCode, "only manual reasons are accepted")
+
+	resp = send(t, app, "GET", "/api/items/3/inventory", "")
+	require.Equal(t, 200, resp.StatusCode)
+	var inv handler.InventoryResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&inv))
+	assert.Equal(t, 4, inv.Stock)
+	require.Len(t, inv.Adjustments, 2)
+	assert.Equal(t, "correction", inv.Adjustments[0].Reason)
+	assert.Equal(t, 4, inv.Adjustments[0].StockAfter)
+	assert.Equal(t, "new box", inv.Adjustments[1].Note)
+}
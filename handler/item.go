@@ -1,25 +1,78 @@
 package handler
 
 import (
-	"fmt"
-	"log"
+	"errors"
 	"strconv"
 
-	"app/database"
 	"app/middleware"
 	"app/model"
+	"app/money"
+	"app/repository"
+	"app/service"
+	"app/validation"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// ItemHandler serves item listings
+type ItemHandler struct {
+	items  *service.ItemService
+	images *service.ImageService
+	// currency prices new items that don't name one
+	currency money.Currency
+	rates    money.RateProvider
+}
+
+// NewItemHandler creates an ItemHandler
+func NewItemHandler(items *service.ItemService, images *service.ImageService, currency money.Currency, rates money.RateProvider) *ItemHandler {
+	return &ItemHandler{items: items, images: images, currency: currency, rates: rates}
+}
+
+// invalidPrice reports a price with more decimals than its currency has
+var invalidPrice = validation.Errors{{Field: "price", Tag: "amount", Message: "price has more decimal places than its currency allows"}}
+
+// invalidSchedule reports publish and unpublish times that can't apply
+var invalidSchedule = validation.Errors{{Field: "publish_at", Tag: "schedule", Message: service.ErrInvalidSchedule.Error()}}
+
+// itemFilter reads listing filters from the query string; ?in_stock=true
+// hides sold out items
+func itemFilter(c *fiber.Ctx) repository.ItemFilter {
+	return repository.ItemFilter{InStock: c.QueryBool("in_stock")}
+}
+
+// displayCurrency reads ?currency=, the currency to convert prices to for
+// display. It is empty when no conversion was asked for.
+func (h *ItemHandler) displayCurrency(c *fiber.Ctx) (money.Currency, error) {
+	code := c.Query("currency")
+	if code == "" {
+		return "", nil
+	}
+	to, err := money.ParseCurrency(code)
+	if err != nil {
+		return "", err
+	}
+	if _, err := h.rates.Rate(c.UserContext(), h.currency, to); err != nil {
+		return "", err
+	}
+	return to, nil
+}
+
+// unsupportedCurrency answers a ?currency= that can't be displayed
+func unsupportedCurrency(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unsupported currency"})
+}
+
 // GetAllItems gets all items from all categories
-func GetAllItems(c *fiber.Ctx) error {
-	db := database.DB
-	var items []model.Item
+func (h *ItemHandler) GetAllItems(c *fiber.Ctx) error {
+	display, err := h.displayCurrency(c)
+	if err != nil {
+		return unsupportedCurrency(c)
+	}
 
 	// Fetch all items from the database
-	if err := db.Find(&items).Error; err != nil {
-		log.Printf("Error fetching items: %v", err)
+	items, err := h.items.List(c.UserContext(), itemFilter(c))
+	if err != nil {
+		middleware.Logger(c).Error("error fetching items", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Error fetching items",
@@ -29,7 +82,7 @@ func GetAllItems(c *fiber.Ctx) error {
 
 	// Check if items were found
 	if len(items) == 0 {
-		log.Println("No items found")
+		middleware.Logger(c).Debug("no items found")
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"status":  "error",
 			"message": "No items found",
@@ -38,22 +91,25 @@ func GetAllItems(c *fiber.Ctx) error {
 	}
 
 	// Return the list of items
-	return c.JSON(items)
+	return c.JSON(h.summaries(c, items, display))
 }
 
 // GetItemFromCategory gets all items from category with id
-func GetItemFromCategory(c *fiber.Ctx) error {
+func (h *ItemHandler) GetItemFromCategory(c *fiber.Ctx) error {
 	categoryId := c.Params("id")
-	db := database.DB
 	id, err := strconv.Atoi(categoryId)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid category ID"})
 	}
+	display, err := h.displayCurrency(c)
+	if err != nil {
+		return unsupportedCurrency(c)
+	}
 
-	var items []model.Item
 	// Fetch items for the category with the given ID
-	if err := db.Where("category_id = ?", id).Find(&items).Error; err != nil {
-		log.Printf("Error fetching items for category ID %d: %v", id, err)
+	items, err := h.items.ListByCategory(c.UserContext(), uint(id), itemFilter(c))
+	if err != nil {
+		middleware.Logger(c).Error("error fetching items for category", "category_id", id, "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Error fetching items",
@@ -63,7 +119,7 @@ func GetItemFromCategory(c *fiber.Ctx) error {
 
 	// Check if items were found
 	if len(items) == 0 {
-		log.Printf("No items found for category with ID %d", id)
+		middleware.Logger(c).Debug("no items found for category", "category_id", id)
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"status":  "error",
 			"message": "No items found for category with ID " + categoryId,
@@ -72,38 +128,55 @@ func GetItemFromCategory(c *fiber.Ctx) error {
 	}
 
 	// Return the items for the category
-	return c.JSON(items)
+	return c.JSON(h.summaries(c, items, display))
 }
 
 // CreateItem creates a new item
-func CreateItem(c *fiber.Ctx) error {
-	var item model.Item
-	fmt.Println("create item called", c.Locals("user_id"))
-	// Parse the request body into the Item struct
-	if err := c.BodyParser(&item); err != nil {
-		log.Printf("Error parsing request body: %v", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid request body",
-			"data":    nil,
-		})
+func (h *ItemHandler) CreateItem(c *fiber.Ctx) error {
+	var input CreateItemRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
 	}
 
 	userID, err := middleware.GetUserID(c)
 	if err != nil {
-		log.Printf("Error getting user ID: %v", err)
+		middleware.Logger(c).Warn("error getting user ID", "error", err)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Unauthorized",
 			"data":    nil,
 		})
 	}
-	item.User.ID = userID
+
+	currency := h.currency
+	if input.Currency != "" {
+		currency, _ = money.ParseCurrency(input.Currency)
+	}
+	price, err := money.Parse(input.Price.String(), currency)
+	if err != nil {
+		return invalid(c, invalidPrice)
+	}
+
+	item := model.Item{
+		Name:        input.Name,
+		Description: input.Description,
+		Price:       price,
+		CategoryID:  input.CategoryID,
+		Stock:       1,
+	}
+	if input.Stock != nil {
+		item.Stock = *input.Stock
+	}
+	item.Status, item.PublishAt, item.UnpublishAt = input.Status, input.PublishAt, input.UnpublishAt
 
 	// Save the item to the database
-	db := database.DB
-	if err := db.Create(&item).Error; err != nil {
-		log.Printf("Error creating item: %v", err)
+	err = h.items.Create(c.UserContext(), userID, &item)
+	if errors.Is(err, service.ErrInvalidCategory) {
+		return invalid(c, validation.Errors{{Field: "category_id", Tag: "exists", Message: "category_id does not match a category"}})
+	} else if errors.Is(err, service.ErrInvalidSchedule) {
+		return invalid(c, invalidSchedule)
+	} else if err != nil {
+		middleware.Logger(c).Error("error creating item", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Error creating item",
@@ -111,22 +184,30 @@ func CreateItem(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(item)
+	return c.JSON(h.detail(c, item, ""))
 }
 
-// GetItemFromUser gets all items from user with id
-func GetItemFromUser(c *fiber.Ctx) error {
+// GetItemFromUser gets the listed items from user with id; users asking
+// for their own get every item, whatever its status
+func (h *ItemHandler) GetItemFromUser(c *fiber.Ctx) error {
 	userId := c.Params("id")
-	db := database.DB
 	id, err := strconv.Atoi(userId)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
+	display, err := h.displayCurrency(c)
+	if err != nil {
+		return unsupportedCurrency(c)
+	}
 
-	var items []model.Item
 	// Fetch items for the user with the given ID
-	if err := db.Where("user_id = ?", id).Find(&items).Error; err != nil {
-		log.Printf("Error fetching items for user ID %d: %v", id, err)
+	filter := itemFilter(c)
+	if viewer, err := middleware.GetUserID(c); err == nil && viewer == uint(id) {
+		filter.All = true
+	}
+	items, err := h.items.ListByUser(c.UserContext(), uint(id), filter)
+	if err != nil {
+		middleware.Logger(c).Error("error fetching items for user", "owner_id", id, "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Error fetching items",
@@ -136,7 +217,7 @@ func GetItemFromUser(c *fiber.Ctx) error {
 
 	// Check if items were found
 	if len(items) == 0 {
-		log.Printf("No items found for user with ID %d", id)
+		middleware.Logger(c).Debug("no items found for user", "owner_id", id)
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"status":  "error",
 			"message": "No items found for user with ID " + userId,
@@ -145,22 +226,26 @@ func GetItemFromUser(c *fiber.Ctx) error {
 	}
 
 	// Return the items for the user
-	return c.JSON(items)
+	return c.JSON(h.summaries(c, items, display))
 }
 
-// GetItemFromId gets item with id
-func GetItemFromId(c *fiber.Ctx) error {
+// GetItemFromId gets item with id. Drafts are only shown to their owner.
+func (h *ItemHandler) GetItemFromId(c *fiber.Ctx) error {
 	itemId := c.Params("id")
-	db := database.DB
 	id, err := strconv.Atoi(itemId)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
 	}
+	display, err := h.displayCurrency(c)
+	if err != nil {
+		return unsupportedCurrency(c)
+	}
 
-	var item model.Item
-	// Fetch the item with the given ID
-	if err := db.First(&item, id).Error; err != nil {
-		log.Printf("Error fetching item with ID %d: %v", id, err)
+	// Fetch the item with the given ID, as the signed-in user if any
+	viewer, _ := middleware.GetUserID(c)
+	item, err := h.items.Get(c.UserContext(), viewer, uint(id))
+	if err != nil {
+		middleware.Logger(c).Debug("error fetching item", "item_id", id, "error", err)
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Item not found",
@@ -168,12 +253,11 @@ func GetItemFromId(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(item)
+	return c.JSON(h.detail(c, *item, display))
 }
 
 // UpdateItem updates an item with id
-func UpdateItem(c *fiber.Ctx) error {
-	db := database.DB
+func (h *ItemHandler) UpdateItem(c *fiber.Ctx) error {
 	itemId := c.Params("id")
 	id, err := strconv.Atoi(itemId)
 	if err != nil {
@@ -185,33 +269,93 @@ func UpdateItem(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
-	var item model.Item
-	if err := db.First(&item, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Item not found"})
+	var input UpdateItemRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
 	}
 
-	if item.UserID != uint(userID) {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You do not own this item"})
+	update := service.ItemUpdate{Name: input.Name, Description: input.Description}
+	if input.Price != nil {
+		price := input.Price.String()
+		update.Price = &price
+	}
+	if input.Currency != nil {
+		currency, _ := money.ParseCurrency(*input.Currency)
+		update.Currency = &currency
 	}
 
-	var input model.Item
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	item, err := h.items.Update(c.UserContext(), userID, uint(id), update)
+	if err != nil {
+		return h.fail(c, err, "Failed to update item")
 	}
 
-	item.Name = input.Name
-	item.Description = input.Description
-	item.Price = input.Price
+	return c.JSON(h.detail(c, *item, ""))
+}
+
+// SetItemStatus moves an item with id to another status
+func (h *ItemHandler) SetItemStatus(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input SetItemStatusRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	item, err := h.items.SetStatus(c.UserContext(), userID, uint(id), input.Status)
+	if err != nil {
+		return h.fail(c, err, "Failed to change item status")
+	}
+	return c.JSON(h.detail(c, *item, ""))
+}
+
+// ScheduleItem sets when an item with id is published and paused again
+func (h *ItemHandler) ScheduleItem(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input ScheduleItemRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
 
-	if err := db.Save(&item).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update item"})
+	item, err := h.items.Schedule(c.UserContext(), userID, uint(id), input.PublishAt, input.UnpublishAt)
+	if err != nil {
+		return h.fail(c, err, "Failed to schedule item")
 	}
+	return c.JSON(h.detail(c, *item, ""))
+}
 
-	return c.JSON(item)
+func (h *ItemHandler) fail(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidPrice):
+		return invalid(c, invalidPrice)
+	case errors.Is(err, service.ErrInvalidSchedule):
+		return invalid(c, invalidSchedule)
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Item not found"})
+	case errors.Is(err, service.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You do not own this item"})
+	case errors.Is(err, service.ErrInvalidStatus):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		middleware.Logger(c).Error(message, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
 }
 
 // DeleteItem deletes an item with id
-func DeleteItem(c *fiber.Ctx) error {
+func (h *ItemHandler) DeleteItem(c *fiber.Ctx) error {
 	itemId := c.Params("id")
 	id, err := strconv.Atoi(itemId)
 	if err != nil {
@@ -223,19 +367,56 @@ func DeleteItem(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
-	db := database.DB
-	var item model.Item
-	if err := db.First(&item, id).Error; err != nil {
+	err = h.items.Delete(c.UserContext(), userID, uint(id))
+	switch {
+	case errors.Is(err, service.ErrNotFound):
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Item not found"})
+	case errors.Is(err, service.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You do not own this item"})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete item"})
 	}
 
-	if item.UserID != uint(userID) {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You do not own this item"})
+	return c.JSON(fiber.Map{"status": "success", "message": "Item deleted successfully"})
+}
+
+// detail maps item to its detail view with signed image URLs and, unless
+// display is empty, its price in that currency
+func (h *ItemHandler) detail(c *fiber.Ctx, item model.Item, display money.Currency) ItemDetail {
+	d := NewItemDetail(item)
+	d.DisplayPrice = h.convert(c, item.Price, display)
+	for _, img := range item.Images {
+		d.Images = append(d.Images, signImage(c, h.images, img))
 	}
+	return d
+}
 
-	if err := db.Delete(&item).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete item"})
+// summaries maps items to listing views showing the primary thumbnail and,
+// unless display is empty, prices in that currency
+func (h *ItemHandler) summaries(c *fiber.Ctx, items []model.Item, display money.Currency) []ItemSummary {
+	out := NewItemSummaries(items)
+	for i, item := range items {
+		out[i].DisplayPrice = h.convert(c, item.Price, display)
+		for _, img := range item.Images {
+			if img.IsPrimary {
+				out[i].ImageURL = signImage(c, h.images, img).URLs["thumb"]
+				break
+			}
+		}
 	}
+	return out
+}
 
-	return c.JSON(fiber.Map{"status": "success", "message": "Item deleted successfully"})
+// convert returns price in currency to, or nil when no conversion was asked
+// for or no rate is known
+func (h *ItemHandler) convert(c *fiber.Ctx, price money.Money, to money.Currency) *money.Money {
+	if to == "" {
+		return nil
+	}
+	converted, err := money.Convert(c.UserContext(), h.rates, price, to)
+	if err != nil {
+		middleware.Logger(c).Debug("price not converted", "from", price.Currency, "to", to, "error", err)
+		return nil
+	}
+	return &converted
 }
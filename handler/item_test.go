@@ -2,6 +2,7 @@ package handler_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http/httptest"
 	"os"
@@ -13,15 +14,26 @@ import (
 	"app/handler"
 	"app/middleware"
 	"app/model"
+	"app/money"
+	"app/payment"
+	"app/repository"
+	"app/router"
+	"app/storage"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 func authHeader() string {
+	return authHeaderFor(1)
+}
+
+func authHeaderFor(userID uint) string {
 	claims := jwt.MapClaims{
-		"user_id": 1, // must match key used in middleware.GetUserID
+		"user_id": userID, // must match key used in middleware.GetUserID
 		"exp":     time.Now().Add(time.Hour).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -30,20 +42,28 @@ func authHeader() string {
 	return "Bearer " + signed
 }
 
-func truncateTables() {
-	database.DB.Exec("DELETE FROM reviews")
-	database.DB.Exec("DELETE FROM items")
-	database.DB.Exec("DELETE FROM categories")
-	database.DB.Exec("DELETE FROM users")
+// testBlobs stores uploads in a fresh temporary directory
+func testBlobs() *storage.Local {
+	dir, _ := os.MkdirTemp("", "retot-media-")
+	return storage.NewLocal(dir, "/media", []byte("testsecret"))
+}
+
+// testRates converts at 1 EUR = 1.10 USD
+func testRates() *money.StaticRates {
+	rates, _ := money.NewStaticRates("EUR", map[money.Currency]string{"USD": "1.10"})
+	return rates
+}
+
+// testPayments is a fake payment provider signing webhooks with "whsec"
+func testPayments() *payment.Fake {
+	return payment.NewFake([]byte("whsec"))
 }
 
-func setupTestDB() {
-	database.ConnectDBWithDSN(":memory:")
-	database.DB.AutoMigrate(&model.User{}, &model.Category{}, &model.Review{}, &model.Item{})
-	truncateTables()
+func setupTestDB() *gorm.DB {
+	db := database.ConnectDBWithDSN(":memory:")
 
 	// Create test user
-	database.DB.Create(&model.User{
+	db.Create(&model.User{
 		ID:       1,
 		Username: "testuser1",
 		Email:    "test1@example.com",
@@ -52,37 +72,39 @@ func setupTestDB() {
 
 	// Create test category
 	category := model.Category{Name: "Default"}
-	database.DB.Create(&category)
+	db.Create(&category)
 
 	// Create test item tied to user
-	database.DB.Create(&model.Item{
+	db.Create(&model.Item{
 		Name:        "Sample Item",
 		Description: "This is a sample item",
-		Price:       100.0,
+		Price:       money.New(10000, "EUR"),
 		UserID:      1,
 		Reviews:     []model.Review{{UserID: 1, Rating: 5, Comment: "Great item!"}},
 	})
+	return db
 }
 
-func setupProtectedItemApp() *fiber.App {
-	setupTestDB()
+func setupProtectedItemApp() (*fiber.App, *gorm.DB) {
+	db := setupTestDB()
+	h := handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil)
 	os.Setenv("SECRET", "testsecret") // used by middleware
 
 	app := fiber.New()
 
 	// Apply your real middleware to the /api/items group
 	item := app.Group("/api/items", middleware.Protected())
-	item.Post("/", handler.CreateItem)
-	item.Patch("/:id", handler.UpdateItem)
-	item.Delete("/:id", handler.DeleteItem)
+	item.Post("/", h.Item.CreateItem)
+	item.Patch("/:id", h.Item.UpdateItem)
+	item.Delete("/:id", h.Item.DeleteItem)
 
-	return app
+	return app, db
 }
 
 func TestCreateItem(t *testing.T) {
-	app := setupProtectedItemApp()
+	app, db := setupProtectedItemApp()
 	category := model.Category{Name: "Electronics"}
-	database.DB.Create(&category)
+	db.Create(&category)
 
 	body := `{"name":"Laptop","description":"Gaming laptop","price":1299.99,"category_id":` + strconv.Itoa(int(category.ID)) + `}`
 
@@ -96,19 +118,19 @@ func TestCreateItem(t *testing.T) {
 }
 
 func TestUpdateItem(t *testing.T) {
-	app := setupProtectedItemApp()
+	app, db := setupProtectedItemApp()
 
 	category := model.Category{Name: "Books"}
-	database.DB.Create(&category)
+	db.Create(&category)
 
 	item := model.Item{
 		Name:        "Go Book",
 		Description: "Learn Go",
-		Price:       20,
+		Price:       money.New(2000, "EUR"),
 		CategoryID:  category.ID,
 		UserID:      1, // assuming user ID 1 is the test user
 	}
-	database.DB.Create(&item)
+	db.Create(&item)
 
 	body := `{"name":"Updated Go Book","description":"Master Go","price":25}`
 	req := httptest.NewRequest("PATCH", "/api/items/"+strconv.Itoa(int(item.ID)), bytes.NewReader([]byte(body)))
@@ -121,19 +143,19 @@ func TestUpdateItem(t *testing.T) {
 }
 
 func TestDeleteItem(t *testing.T) {
-	app := setupProtectedItemApp()
+	app, db := setupProtectedItemApp()
 
 	category := model.Category{Name: "Games"}
-	database.DB.Create(&category)
+	db.Create(&category)
 
 	item := model.Item{
 		Name:        "PS5",
 		Description: "Console",
-		Price:       500,
+		Price:       money.New(50000, "EUR"),
 		UserID:      1, // assuming user ID 1 is the test user
 		CategoryID:  category.ID,
 	}
-	database.DB.Create(&item)
+	db.Create(&item)
 
 	req := httptest.NewRequest("DELETE", "/api/items/"+strconv.Itoa(int(item.ID)), nil)
 	req.Header.Set("Authorization", authHeader())
@@ -144,22 +166,22 @@ func TestDeleteItem(t *testing.T) {
 }
 
 func TestUpdateItem_Unauthorized(t *testing.T) {
-	app := setupProtectedItemApp()
+	app, db := setupProtectedItemApp()
 
 	// Create item owned by user ID 2
 	user := model.User{ID: 2, Username: "other", Email: "other@example.com", Password: "x"}
-	database.DB.Create(&user)
+	db.Create(&user)
 
 	cat := model.Category{Name: "Test"}
-	database.DB.Create(&cat)
+	db.Create(&cat)
 
 	item := model.Item{
 		Name:        "Not Yours",
 		Description: "Secret",
-		Price:       10,
+		Price:       money.New(1000, "EUR"),
 		UserID:      2,
 	}
-	database.DB.Create(&item)
+	db.Create(&item)
 
 	body := `{"name":"Hack","description":"Hack","price":999}`
 	req := httptest.NewRequest("PATCH", "/api/items/"+strconv.Itoa(int(item.ID)), bytes.NewReader([]byte(body)))
@@ -172,22 +194,22 @@ func TestUpdateItem_Unauthorized(t *testing.T) {
 }
 
 func TestDeleteItem_Unauthorized(t *testing.T) {
-	app := setupProtectedItemApp()
+	app, db := setupProtectedItemApp()
 
 	// Create item owned by user ID 2
 	user := model.User{ID: 2, Username: "other", Email: "other@example.com", Password: "x"}
-	database.DB.Create(&user)
+	db.Create(&user)
 
 	cat := model.Category{Name: "Test"}
-	database.DB.Create(&cat)
+	db.Create(&cat)
 
 	item := model.Item{
 		Name:        "Stolen",
 		Description: "Not yours",
-		Price:       99,
+		Price:       money.New(9900, "EUR"),
 		UserID:      2,
 	}
-	database.DB.Create(&item)
+	db.Create(&item)
 
 	req := httptest.NewRequest("DELETE", "/api/items/"+strconv.Itoa(int(item.ID)), nil)
 	req.Header.Set("Authorization", authHeader()) // user ID 1
@@ -198,3 +220,142 @@ func TestDeleteItem_Unauthorized(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 403, resp.StatusCode)
 }
+
+func TestCreateItem_InvalidInput(t *testing.T) {
+	app, _ := setupProtectedItemApp()
+
+	body := `{"name":"","description":"Free money","price":-5,"user_id":2}`
+	req := httptest.NewRequest("POST", "/api/items/", bytes.NewReader([]byte(body)))
+	req.Header.Set("Authorization", authHeader())
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	var out struct {
+		Errors []struct {
+			Field string `json:"field"`
+		} `json:"errors"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	var fields []string
+	for _, e := range out.Errors {
+		fields = append(fields, e.Field)
+	}
+	assert.ElementsMatch(t, []string{"name", "price", "category_id"}, fields)
+}
+
+func TestItemPrices_CurrenciesAndDisplay(t *testing.T) {
+	os.Setenv("SECRET", "testsecret")
+	db := setupTestDB()
+	db.Create(&model.Category{ID: 9, Name: "Lamps"})
+	app := fiber.New()
+	router.SetupRoutes(app, handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil))
+
+	resp := send(t, app, "POST", "/api/items/", `{"name":"Lamp","description":"Desk lamp","price":"19.99","category_id":9}`)
+	require.Equal(t, 200, resp.StatusCode)
+	var created handler.ItemDetail
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	assert.Equal(t, money.New(1999, "EUR"), created.Price)
+
+	resp = send(t, app, "POST", "/api/items/", `{"name":"Vase","description":"Vase","price":1500,"currency":"jpy","category_id":9}`)
+	require.Equal(t, 200, resp.StatusCode)
+	resp = send(t, app, "POST", "/api/items/", `{"name":"Cup","description":"Cup","price":0.5,"currency":"JPY","category_id":9}`)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	resp = send(t, app, "GET", fmt.Sprintf("/api/items/%d?currency=usd", created.ID), "")
+	require.Equal(t, 200, resp.StatusCode)
+	var shown handler.ItemDetail
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&shown))
+	assert.Equal(t, money.New(1999, "EUR"), shown.Price)
+	assert.Equal(t, &money.Money{Amount: 2199, Currency: "USD"}, shown.DisplayPrice)
+
+	// Without a JPY rate the yen listing keeps only its own price
+	resp = send(t, app, "GET", "/api/items/category/9?currency=USD", "")
+	require.Equal(t, 200, resp.StatusCode)
+	var listed []handler.ItemSummary
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	require.Len(t, listed, 2)
+	assert.NotNil(t, listed[0].DisplayPrice)
+	assert.Nil(t, listed[1].DisplayPrice)
+
+	assert.Equal(t, 400, send(t, app, "GET", "/api/items/?currency=GBP", "").StatusCode)
+	assert.Equal(t, 400, send(t, app, "GET", "/api/items/?currency=XYZ", "").StatusCode)
+
+	resp = send(t, app, "PATCH", fmt.Sprintf("/api/items/%d", created.ID), `{"price":"12.345"}`)
+	assert.Equal(t, 400, resp.StatusCode)
+	resp = send(t, app, "PATCH", fmt.Sprintf("/api/items/%d", created.ID), `{"price":"12.5","currency":"USD"}`)
+	require.Equal(t, 200, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&shown))
+	assert.Equal(t, money.New(1250, "USD"), shown.Price)
+}
+
+func TestItems_StatusAndSchedule(t *testing.T) {
+	app := setupInventoryApp()
+
+	// A draft is only shown to its owner
+	resp := sendAs(t, app, 2, "POST", "/api/items/", `{"name":"Rug","description":"Wool rug","price":"80","category_id":1,"status":"draft"}`)
+	require.Equal(t, 200, resp.StatusCode)
+	var draft handler.ItemDetail
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&draft))
+	assert.Equal(t, model.ItemDraft, draft.Status)
+	assert.False(t, draft.Available)
+	draftPath := fmt.Sprintf("/api/items/%d", draft.ID)
+	assert.Equal(t, 404, send(t, app, "GET", draftPath, "").StatusCode)
+	resp, err := app.Test(httptest.NewRequest("GET", draftPath, nil))
+	require.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.Equal(t, 200, sendAs(t, app, 2, "GET", draftPath, "").StatusCode)
+	assert.NotContains(t, listedItems(t, app, "/api/items/"), draft.ID)
+
+	// Pausing takes an item out of listings and the shop
+	assert.Equal(t, 403, send(t, app, "PUT", "/api/items/1/status", `{"status":"paused"}`).StatusCode)
+	assert.Equal(t, 400, sendAs(t, app, 2, "PUT", "/api/items/1/status", `{"status":"gone"}`).StatusCode)
+	require.Equal(t, 200, sendAs(t, app, 2, "PUT", "/api/items/1/status", `{"status":"paused"}`).StatusCode)
+	assert.Equal(t, 409, sendAs(t, app, 2, "PUT", "/api/items/1/status", `{"status":"draft"}`).StatusCode)
+	assert.Equal(t, []uint{2, 3}, listedItems(t, app, "/api/items/"))
+	assert.Equal(t, 409, send(t, app, "POST", "/api/orders", `{"item_id":1,"quantity":1}`).StatusCode)
+	assert.Equal(t, 409, send(t, app, "POST", "/api/cart/items", `{"item_id":1,"quantity":1}`).StatusCode)
+	assert.Equal(t, 409, send(t, app, "POST", "/api/items/1/reservations", `{"quantity":1}`).StatusCode)
+	assert.Equal(t, 200, send(t, app, "GET", "/api/items/1", "").StatusCode, "paused items can still be looked at")
+
+	// The owner sees every item of theirs, others only the listed ones
+	assert.Equal(t, []uint{2}, listedItems(t, app, "/api/user/id/2/items"))
+	resp = sendAs(t, app, 2, "GET", "/api/user/id/2/items", "")
+	require.Equal(t, 200, resp.StatusCode)
+	var own []handler.ItemSummary
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&own))
+	statuses := map[uint]string{}
+	for _, it := range own {
+		statuses[it.ID] = it.Status
+	}
+	assert.Equal(t, map[uint]string{1: model.ItemPaused, 2: model.ItemActive, draft.ID: model.ItemDraft}, statuses)
+
+	// Scheduling needs times in the future, publishing before unpublishing
+	schedule := draftPath + "/schedule"
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	soon := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	later := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	assert.Equal(t, 400, sendAs(t, app, 2, "PUT", schedule, fmt.Sprintf(`{"publish_at":%q}`, past)).StatusCode)
+	assert.Equal(t, 400, sendAs(t, app, 2, "PUT", schedule, fmt.Sprintf(`{"publish_at":%q,"unpublish_at":%q}`, later, soon)).StatusCode)
+	resp = sendAs(t, app, 2, "PUT", schedule, fmt.Sprintf(`{"publish_at":%q,"unpublish_at":%q}`, soon, later))
+	require.Equal(t, 200, resp.StatusCode)
+	var scheduled handler.ItemDetail
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&scheduled))
+	require.NotNil(t, scheduled.PublishAt)
+	assert.Equal(t, soon, scheduled.PublishAt.UTC().Format(time.RFC3339))
+	require.NotNil(t, scheduled.UnpublishAt)
+
+	// Items created with a publish time start as drafts
+	resp = sendAs(t, app, 2, "POST", "/api/items/", fmt.Sprintf(`{"name":"Mat","description":"Door mat","price":"15","category_id":1,"publish_at":%q}`, soon))
+	require.Equal(t, 200, resp.StatusCode)
+	var mat handler.ItemDetail
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&mat))
+	assert.Equal(t, model.ItemDraft, mat.Status)
+
+	// Archived items are gone for everyone but their owner
+	require.Equal(t, 200, sendAs(t, app, 2, "PUT", "/api/items/1/status", `{"status":"archived"}`).StatusCode)
+	assert.Equal(t, 404, send(t, app, "GET", "/api/items/1", "").StatusCode)
+	assert.Equal(t, 200, sendAs(t, app, 2, "GET", "/api/items/1", "").StatusCode)
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"errors"
+	"path"
+
+	"app/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MediaHandler serves objects of the local blob store through the signed
+// URLs it hands out
+type MediaHandler struct {
+	store *storage.Local
+}
+
+// NewMediaHandler creates a MediaHandler
+func NewMediaHandler(store *storage.Local) *MediaHandler {
+	return &MediaHandler{store: store}
+}
+
+// Serve streams the object named by the rest of the path once the URL
+// signature checks out
+func (h *MediaHandler) Serve(c *fiber.Ctx) error {
+	key := c.Params("*")
+	if err := h.store.Verify(key, c.Query("expires"), c.Query("signature")); err != nil {
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+	rc, err := h.store.Get(c.UserContext(), key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		return c.SendStatus(fiber.StatusNotFound)
+	} else if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	c.Type(path.Ext(key))
+	c.Set(fiber.HeaderCacheControl, "private, max-age=3600")
+	return c.SendStream(rc)
+}
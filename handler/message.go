@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"app/middleware"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultMessagePage is how many messages a page lists without ?limit=
+const defaultMessagePage = 50
+
+// MessageHandler serves private conversations between buyers and sellers
+type MessageHandler struct {
+	messages *service.MessageService
+}
+
+// NewMessageHandler creates a MessageHandler
+func NewMessageHandler(messages *service.MessageService) *MessageHandler {
+	return &MessageHandler{messages: messages}
+}
+
+// StartConversation writes to the seller of an item, opening the
+// conversation about it unless the current user already has one
+func (h *MessageHandler) StartConversation(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input SendMessageRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	conv, err := h.messages.Start(c.UserContext(), userID, uint(itemID), input.Body)
+	if err != nil {
+		return h.fail(c, err, "Failed to send message")
+	}
+	return c.Status(fiber.StatusCreated).JSON(NewConversationResponse(*conv))
+}
+
+// ListConversations lists the current user's conversations, most recently
+// active first
+func (h *MessageHandler) ListConversations(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	summaries, err := h.messages.Conversations(c.UserContext(), userID)
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch conversations")
+	}
+	return c.JSON(NewConversationList(summaries))
+}
+
+// GetConversation shows a conversation of the current user
+func (h *MessageHandler) GetConversation(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid conversation ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	conv, err := h.messages.Conversation(c.UserContext(), userID, uint(id))
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch conversation")
+	}
+	return c.JSON(NewConversationResponse(*conv))
+}
+
+// GetMessages lists the latest ?limit= messages of a conversation, or
+// those sent before the message ?before=, oldest first
+func (h *MessageHandler) GetMessages(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid conversation ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	before, limit := c.QueryInt("before", 0), c.QueryInt("limit", defaultMessagePage)
+	if before < 0 || limit < 1 || limit > service.MaxMessagePage {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid before or limit"})
+	}
+
+	messages, err := h.messages.Messages(c.UserContext(), userID, uint(id), uint(before), limit)
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch messages")
+	}
+	out := make([]MessageResponse, len(messages))
+	for i, m := range messages {
+		out[i] = NewMessageResponse(m)
+	}
+	return c.JSON(out)
+}
+
+// SendMessage adds a message to a conversation
+func (h *MessageHandler) SendMessage(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid conversation ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input SendMessageRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	m, err := h.messages.Send(c.UserContext(), userID, uint(id), input.Body)
+	if err != nil {
+		return h.fail(c, err, "Failed to send message")
+	}
+	return c.Status(fiber.StatusCreated).JSON(NewMessageResponse(*m))
+}
+
+// MarkRead marks the messages the current user received in a conversation
+// read
+func (h *MessageHandler) MarkRead(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid conversation ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	n, err := h.messages.MarkRead(c.UserContext(), userID, uint(id))
+	if err != nil {
+		return h.fail(c, err, "Failed to mark messages read")
+	}
+	return c.JSON(fiber.Map{"marked": n})
+}
+
+// Block stops a user and the current user from messaging each other
+func (h *MessageHandler) Block(c *fiber.Ctx) error {
+	return h.setBlock(c, true)
+}
+
+// Unblock lifts a block the current user placed
+func (h *MessageHandler) Unblock(c *fiber.Ctx) error {
+	return h.setBlock(c, false)
+}
+
+func (h *MessageHandler) setBlock(c *fiber.Ctx, block bool) error {
+	otherID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	set, message := h.messages.Unblock, "User unblocked"
+	if block {
+		set, message = h.messages.Block, "User blocked"
+	}
+	if err := set(c.UserContext(), userID, uint(otherID)); err != nil {
+		return h.fail(c, err, "Failed to update block")
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": message})
+}
+
+func (h *MessageHandler) fail(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	case errors.Is(err, service.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not part of this conversation"})
+	case errors.Is(err, service.ErrBlocked):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidMessage), errors.Is(err, service.ErrMessageSelf), errors.Is(err, service.ErrBlockSelf):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		middleware.Logger(c).Error(message, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
@@ -0,0 +1,57 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"app/handler"
+	"app/model"
+	"app/repository"
+	"app/router"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessages_BuyerAsksSeller(t *testing.T) {
+	db := setupInventoryDB()
+	db.Create(&model.User{ID: 3, Username: "other", Email: "other@example.com", Password: "x"})
+	app := fiber.New()
+	router.SetupRoutes(app, handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil))
+
+	resp := send(t, app, "POST", "/api/items/1/conversations", `{"body":"Does the lamp dim?"}`)
+	require.Equal(t, 201, resp.StatusCode)
+	var conv handler.ConversationResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&conv))
+	assert.Equal(t, "seller", conv.Seller.Username)
+	assert.Equal(t, 400, send(t, app, "POST", "/api/items/3/conversations", `{"body":"Mine"}`).StatusCode)
+
+	path := fmt.Sprintf("/api/conversations/%d", conv.ID)
+	require.Equal(t, 201, sendAs(t, app, 2, "POST", path+"/messages", `{"body":"It does"}`).StatusCode)
+	assert.Equal(t, 403, sendAs(t, app, 3, "GET", path+"/messages", "").StatusCode)
+	assert.Equal(t, 403, sendAs(t, app, 3, "GET", path, "").StatusCode)
+
+	resp = send(t, app, "GET", "/api/conversations", "")
+	require.Equal(t, 200, resp.StatusCode)
+	var list []handler.ConversationResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Len(t, list, 1)
+	assert.Equal(t, int64(1), list[0].Unread)
+	assert.Equal(t, "It does", list[0].LastMessage.Body)
+
+	resp = send(t, app, "POST", path+"/read", "")
+	require.Equal(t, 200, resp.StatusCode)
+	resp = sendAs(t, app, 2, "GET", path+"/messages", "")
+	var messages []handler.MessageResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&messages))
+	require.Len(t, messages, 2)
+	assert.NotNil(t, messages[1].ReadAt, "the seller sees their reply was read")
+
+	require.Equal(t, 200, sendAs(t, app, 2, "PUT", "/api/user/id/1/block", "").StatusCode)
+	assert.Equal(t, 403, send(t, app, "POST", path+"/messages", `{"body":"Hello?"}`).StatusCode)
+	require.Equal(t, 200, sendAs(t, app, 2, "DELETE", "/api/user/id/1/block", "").StatusCode)
+	assert.Equal(t, 404, sendAs(t, app, 2, "DELETE", "/api/user/id/1/block", "").StatusCode)
+}
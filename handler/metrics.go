@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"bytes"
+
+	"app/metrics"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Metrics exposes prometheus metrics for every worker process
+func Metrics(c *fiber.Ctx) error {
+	mfs, err := metrics.Gather()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error gathering metrics", "errors": err.Error()})
+	}
+
+	format := expfmt.NewFormat(expfmt.TypeTextPlain)
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, format)
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error encoding metrics", "errors": err.Error()})
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, string(format))
+	return c.Send(buf.Bytes())
+}
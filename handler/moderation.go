@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"app/middleware"
+	"app/model"
+	"app/repository"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultReportPage is how many reports a page lists without ?limit=
+const defaultReportPage = 50
+
+// ModerationHandler takes reports from users and serves the moderation
+// queue
+type ModerationHandler struct {
+	moderation *service.ModerationService
+}
+
+// NewModerationHandler creates a ModerationHandler
+func NewModerationHandler(moderation *service.ModerationService) *ModerationHandler {
+	return &ModerationHandler{moderation: moderation}
+}
+
+// CreateReport reports an item, review or comment to moderators
+func (h *ModerationHandler) CreateReport(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input CreateReportRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	report, err := h.moderation.Report(c.UserContext(), userID, input.TargetType, input.TargetID, input.Reason, input.Details)
+	if err != nil {
+		return h.fail(c, err, "Failed to file report")
+	}
+	return c.Status(fiber.StatusCreated).JSON(NewReportResponse(*report))
+}
+
+// ListReports lists reports, oldest first, a ?page= of ?limit= at a time.
+// Open reports are listed unless ?status= asks for others; ?target_type=
+// narrows them further.
+func (h *ModerationHandler) ListReports(c *fiber.Ctx) error {
+	filter := repository.ReportFilter{Status: c.Query("status", model.ReportOpen), TargetType: c.Query("target_type")}
+	if filter.Status == "all" {
+		filter.Status = ""
+	}
+	page, limit := c.QueryInt("page", 1), c.QueryInt("limit", defaultReportPage)
+	if page < 1 || limit < 1 || limit > service.MaxReportPage {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid page or limit"})
+	}
+
+	reports, total, err := h.moderation.Reports(c.UserContext(), filter, page, limit)
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch reports")
+	}
+	out := ReportPage{Reports: make([]ReportResponse, len(reports)), Total: total, Page: page, Limit: limit}
+	for i, r := range reports {
+		out.Reports[i] = NewReportResponse(r)
+	}
+	return c.JSON(out)
+}
+
+// ResolveReport hides or removes the reported content, or dismisses the
+// report, closing every open report on the same content
+func (h *ModerationHandler) ResolveReport(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid report ID"})
+	}
+	moderatorID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input ResolveReportRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	report, err := h.moderation.Resolve(c.UserContext(), moderatorID, uint(id), input.Decision, input.Note)
+	if err != nil {
+		return h.fail(c, err, "Failed to resolve report")
+	}
+	return c.JSON(NewReportResponse(*report))
+}
+
+func (h *ModerationHandler) fail(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	case errors.Is(err, service.ErrInvalidReport), errors.Is(err, service.ErrInvalidDecision):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrAlreadyReported), errors.Is(err, service.ErrReportResolved):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		middleware.Logger(c).Error(message, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
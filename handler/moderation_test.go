@@ -0,0 +1,139 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"app/handler"
+	"app/model"
+	"app/repository"
+	"app/router"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupModerationApp adds a moderator (3) to the inventory fixtures
+func setupModerationApp(t *testing.T) *fiber.App {
+	t.Helper()
+	db := setupInventoryDB()
+	require.NoError(t, db.Create(&model.User{ID: 3, Username: "mod", Email: "mod@example.com", Password: "x", Role: model.RoleModerator}).Error)
+	app := fiber.New()
+	router.SetupRoutes(app, handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil))
+	return app
+}
+
+func listedItems(t *testing.T, app *fiber.App, path string) []uint {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+	require.NoError(t, err)
+	var items []handler.ItemSummary
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&items))
+	var ids []uint
+	for _, it := range items {
+		ids = append(ids, it.ID)
+	}
+	return ids
+}
+
+func reportQueue(t *testing.T, app *fiber.App, query string) handler.ReportPage {
+	t.Helper()
+	resp := sendAs(t, app, 3, "GET", "/api/moderation/reports"+query, "")
+	require.Equal(t, 200, resp.StatusCode)
+	var page handler.ReportPage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	return page
+}
+
+func TestModeration_ReportAndHide(t *testing.T) {
+	app := setupModerationApp(t)
+
+	report := `{"target_type":"item","target_id":1,"reason":"fraud","details":"Never ships"}`
+	require.Equal(t, 201, send(t, app, "POST", "/api/reports", report).StatusCode)
+	assert.Equal(t, 409, send(t, app, "POST", "/api/reports", report).StatusCode)
+	require.Equal(t, 201, sendAs(t, app, 3, "POST", "/api/reports", `{"target_type":"item","target_id":1,"reason":"spam"}`).StatusCode)
+	assert.Equal(t, 404, send(t, app, "POST", "/api/reports", `{"target_type":"item","target_id":99,"reason":"spam"}`).StatusCode)
+	assert.Equal(t, 400, send(t, app, "POST", "/api/reports", `{"target_type":"item","target_id":1,"reason":"blocklist"}`).StatusCode)
+
+	assert.Equal(t, 403, send(t, app, "GET", "/api/moderation/reports", "").StatusCode)
+	page := reportQueue(t, app, "")
+	require.EqualValues(t, 2, page.Total)
+	assert.Equal(t, "Never ships", page.Reports[0].Details)
+
+	path := fmt.Sprintf("/api/moderation/reports/%d/resolve", page.Reports[0].ID)
+	assert.Equal(t, 400, sendAs(t, app, 3, "POST", path, `{"decision":"burn"}`).StatusCode)
+	resp := sendAs(t, app, 3, "POST", path, `{"decision":"hide","note":"Scam"}`)
+	require.Equal(t, 200, resp.StatusCode)
+	var resolved handler.ReportResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resolved))
+	assert.Equal(t, model.ReportHidden, resolved.Status)
+	assert.Equal(t, "Scam", resolved.Note)
+	assert.Equal(t, 409, sendAs(t, app, 3, "POST", path, `{"decision":"dismiss"}`).StatusCode)
+
+	// Both reports on the item were closed and it left the listings
+	assert.Zero(t, reportQueue(t, app, "").Total)
+	assert.EqualValues(t, 2, reportQueue(t, app, "?status=hidden").Total)
+	assert.NotContains(t, listedItems(t, app, "/api/items/"), uint(1))
+	assert.NotContains(t, listedItems(t, app, "/api/items/category/1"), uint(1))
+	assert.Contains(t, listedItems(t, app, "/api/items/"), uint(2))
+}
+
+func TestModeration_HideAndRemoveComments(t *testing.T) {
+	app := setupModerationApp(t)
+	require.Equal(t, 201, send(t, app, "POST", "/api/items/1/comments", `{"body":"Buy followers at spam.example"}`).StatusCode)
+	require.Equal(t, 201, sendAs(t, app, 2, "POST", "/api/items/1/comments", `{"body":"Rude words"}`).StatusCode)
+
+	for _, id := range []int{1, 2} {
+		body := fmt.Sprintf(`{"target_type":"comment","target_id":%d,"reason":"offensive"}`, id)
+		require.Equal(t, 201, sendAs(t, app, 3, "POST", "/api/reports", body).StatusCode)
+	}
+	page := reportQueue(t, app, "?target_type=comment")
+	require.Len(t, page.Reports, 2)
+	require.Equal(t, 200, sendAs(t, app, 3, "POST", fmt.Sprintf("/api/moderation/reports/%d/resolve", page.Reports[0].ID), `{"decision":"hide"}`).StatusCode)
+	require.Equal(t, 200, sendAs(t, app, 3, "POST", fmt.Sprintf("/api/moderation/reports/%d/resolve", page.Reports[1].ID), `{"decision":"remove"}`).StatusCode)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/items/1/comments", nil))
+	require.NoError(t, err)
+	var threads []handler.CommentResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&threads))
+	assert.Empty(t, threads)
+}
+
+func TestModeration_Blocklist(t *testing.T) {
+	t.Setenv("MODERATION_BLOCKLIST", `replica, /fake\s+rolex/`)
+	app := setupModerationApp(t)
+
+	resp := send(t, app, "POST", "/api/items/", `{"name":"Watch","description":"A FAKE  Rolex","price":"50","category_id":1}`)
+	require.Equal(t, 200, resp.StatusCode)
+	var item handler.ItemDetail
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&item))
+	require.Equal(t, 200, send(t, app, "POST", "/api/items/", `{"name":"Replicator","description":"Not a match","price":"50","category_id":1}`).StatusCode)
+
+	assert.NotContains(t, listedItems(t, app, "/api/items/"), item.ID)
+	// Only its owner and moderators can still see it
+	itemPath := fmt.Sprintf("/api/items/%d", item.ID)
+	resp, err := app.Test(httptest.NewRequest("GET", itemPath, nil))
+	require.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.Equal(t, 404, sendAs(t, app, 2, "GET", itemPath, "").StatusCode)
+	for _, viewer := range []uint{1, 3} {
+		resp = sendAs(t, app, viewer, "GET", itemPath, "")
+		require.Equal(t, 200, resp.StatusCode)
+		var detail handler.ItemDetail
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&detail))
+		assert.True(t, detail.Hidden)
+	}
+
+	page := reportQueue(t, app, "")
+	require.EqualValues(t, 1, page.Total)
+	assert.Nil(t, page.Reports[0].ReporterID)
+	assert.Equal(t, model.ReportBlocklist, page.Reports[0].Reason)
+	assert.Equal(t, item.ID, page.Reports[0].TargetID)
+
+	// A false positive is published once dismissed
+	require.Equal(t, 200, sendAs(t, app, 3, "POST", fmt.Sprintf("/api/moderation/reports/%d/resolve", page.Reports[0].ID), `{"decision":"dismiss"}`).StatusCode)
+	assert.Contains(t, listedItems(t, app, "/api/items/"), item.ID)
+}
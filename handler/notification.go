@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"app/middleware"
+	"app/model"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultNotificationPage is how many notifications a page lists without
+// ?limit=
+const defaultNotificationPage = 20
+
+// NotificationHandler serves the current user's notification center
+type NotificationHandler struct {
+	notifications *service.NotificationService
+}
+
+// NewNotificationHandler creates a NotificationHandler
+func NewNotificationHandler(notifications *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notifications: notifications}
+}
+
+// GetNotifications lists notifications, newest first, a ?page= of ?limit=
+// at a time, only the unread ones with ?unread=true
+func (h *NotificationHandler) GetNotifications(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	page, limit := c.QueryInt("page", 1), c.QueryInt("limit", defaultNotificationPage)
+	if page < 1 || limit < 1 || limit > service.MaxNotificationPage {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid page or limit"})
+	}
+
+	p, err := h.notifications.List(c.UserContext(), userID, c.QueryBool("unread"), page, limit)
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch notifications")
+	}
+	return c.JSON(NotificationPage{
+		Notifications: NewNotificationResponses(p.Notifications),
+		Unread:        p.Unread,
+		Total:         p.Total,
+		Page:          page,
+		Limit:         limit,
+	})
+}
+
+// MarkRead marks a notification read
+func (h *NotificationHandler) MarkRead(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid notification ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.notifications.MarkRead(c.UserContext(), userID, uint(id)); err != nil {
+		return h.fail(c, err, "Failed to mark notification read")
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Notification read"})
+}
+
+// MarkAllRead marks every notification read
+func (h *NotificationHandler) MarkAllRead(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	n, err := h.notifications.MarkAllRead(c.UserContext(), userID)
+	if err != nil {
+		return h.fail(c, err, "Failed to mark notifications read")
+	}
+	return c.JSON(fiber.Map{"marked": n})
+}
+
+// GetPreferences shows how each type of notification is delivered
+func (h *NotificationHandler) GetPreferences(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	prefs, err := h.notifications.Preferences(c.UserContext(), userID)
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch preferences")
+	}
+	return c.JSON(NewPreferences(prefs))
+}
+
+// UpdatePreferences changes the delivery of the types listed
+func (h *NotificationHandler) UpdatePreferences(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input UpdatePreferencesRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	prefs := make([]model.NotificationPreference, len(input.Preferences))
+	for i, p := range input.Preferences {
+		prefs[i] = model.NotificationPreference{Type: p.Type, InApp: *p.InApp, Email: *p.Email}
+	}
+	prefs, err = h.notifications.SetPreferences(c.UserContext(), userID, prefs)
+	if err != nil {
+		return h.fail(c, err, "Failed to update preferences")
+	}
+	return c.JSON(NewPreferences(prefs))
+}
+
+func (h *NotificationHandler) fail(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Notification not found"})
+	case errors.Is(err, service.ErrUnknownNotification):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		middleware.Logger(c).Error(message, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
@@ -0,0 +1,71 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"app/handler"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifications_Center(t *testing.T) {
+	app := setupInventoryApp()
+
+	// The buyer orders and reviews the seller's lamp, then asks about it
+	require.Equal(t, 201, send(t, app, "POST", "/api/orders/", `{"item_id":1,"quantity":1}`).StatusCode)
+	require.Equal(t, 201, send(t, app, "POST", "/api/items/1/reviews", `{"rating":4,"comment":"Bright"}`).StatusCode)
+	assert.Equal(t, 403, send(t, app, "POST", "/api/items/3/reviews", `{"rating":5}`).StatusCode)
+	assert.Equal(t, 400, send(t, app, "POST", "/api/items/1/reviews", `{"rating":6}`).StatusCode)
+	resp := send(t, app, "POST", "/api/items/1/comments", `{"body":"Is it dimmable?"}`)
+	require.Equal(t, 201, resp.StatusCode)
+	var question handler.CommentResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&question))
+	reply := fmt.Sprintf(`{"body":"Yes","parent_id":%d}`, question.ID)
+	require.Equal(t, 201, sendAs(t, app, 2, "POST", "/api/items/1/comments", reply).StatusCode)
+
+	var page handler.NotificationPage
+	resp = sendAs(t, app, 2, "GET", "/api/notifications?limit=1", "")
+	require.Equal(t, 200, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	assert.Equal(t, int64(2), page.Total)
+	assert.Equal(t, int64(2), page.Unread)
+	require.Len(t, page.Notifications, 1)
+	assert.Equal(t, "review", page.Notifications[0].Type)
+
+	resp = send(t, app, "GET", "/api/notifications", "")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	require.Len(t, page.Notifications, 1)
+	assert.Equal(t, "comment_reply", page.Notifications[0].Type)
+
+	resp = sendAs(t, app, 2, "POST", "/api/notifications/read-all", "")
+	require.Equal(t, 200, resp.StatusCode)
+	resp = sendAs(t, app, 2, "GET", "/api/notifications?unread=true", "")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	assert.Zero(t, page.Unread)
+	assert.Empty(t, page.Notifications)
+	assert.Equal(t, 400, send(t, app, "GET", "/api/notifications?page=0", "").StatusCode)
+}
+
+func TestNotifications_Preferences(t *testing.T) {
+	app := setupInventoryApp()
+
+	resp := sendAs(t, app, 2, "PUT", "/api/notifications/preferences", `{"preferences":[{"type":"review","in_app":false,"email":true}]}`)
+	require.Equal(t, 200, resp.StatusCode)
+	var prefs []handler.NotificationPreference
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&prefs))
+	assert.Contains(t, prefs, handler.NotificationPreference{Type: "review", InApp: false, Email: true})
+	assert.Contains(t, prefs, handler.NotificationPreference{Type: "new_order", InApp: true, Email: false})
+
+	assert.Equal(t, 400, send(t, app, "PUT", "/api/notifications/preferences", `{"preferences":[{"type":"spam","in_app":true,"email":true}]}`).StatusCode)
+	assert.Equal(t, 400, send(t, app, "PUT", "/api/notifications/preferences", `{"preferences":[{"type":"review"}]}`).StatusCode)
+
+	// Reviews are no longer shown to the seller in the app
+	require.Equal(t, 201, send(t, app, "POST", "/api/items/1/reviews", `{"rating":2}`).StatusCode)
+	resp = sendAs(t, app, 2, "GET", "/api/notifications", "")
+	var page handler.NotificationPage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	assert.Empty(t, page.Notifications)
+}
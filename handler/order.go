@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+
+	"app/metrics"
+	"app/middleware"
+	"app/model"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OrderHandler serves buyer orders
+type OrderHandler struct {
+	orders *service.OrderService
+}
+
+// NewOrderHandler creates an OrderHandler
+func NewOrderHandler(orders *service.OrderService) *OrderHandler {
+	return &OrderHandler{orders: orders}
+}
+
+// CreateOrder places an order for the current user
+func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
+	var input CreateOrderRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Unauthorized", "data": nil})
+	}
+
+	var order *model.Order
+	if input.ReservationID != 0 {
+		order, err = h.orders.PlaceReserved(c.UserContext(), userID, input.ReservationID)
+	} else {
+		order, err = h.orders.Place(c.UserContext(), userID, input.ItemID, input.Quantity)
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidQuantity), errors.Is(err, service.ErrOwnItem):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error(), "data": nil})
+	case errors.Is(err, service.ErrInsufficientStock), errors.Is(err, service.ErrReservationInactive), errors.Is(err, service.ErrItemUnavailable):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": err.Error(), "data": nil})
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Item not found", "data": nil})
+	case err != nil:
+		middleware.Logger(c).Error("error creating order", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error creating order", "data": nil})
+	}
+	metrics.OrdersCreatedTotal.Inc()
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"status": "success", "message": "Order created", "data": NewOrderResponse(*order)})
+}
+
+// GetMyOrders lists the orders placed by the current user
+func (h *OrderHandler) GetMyOrders(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Unauthorized", "data": nil})
+	}
+
+	orders, err := h.orders.ListForBuyer(c.UserContext(), userID)
+	if err != nil {
+		middleware.Logger(c).Error("error fetching orders", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error fetching orders", "data": nil})
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Orders found", "data": NewOrderResponses(orders)})
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"app/middleware"
+	"app/payment"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PaymentHandler serves order payments, refunds and provider webhooks
+type PaymentHandler struct {
+	payments *service.PaymentService
+}
+
+// NewPaymentHandler creates a PaymentHandler
+func NewPaymentHandler(payments *service.PaymentService) *PaymentHandler {
+	return &PaymentHandler{payments: payments}
+}
+
+// Pay starts paying an order of the current user, returning the client
+// secret the browser completes the payment with
+func (h *PaymentHandler) Pay(c *fiber.Ctx) error {
+	orderID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	p, err := h.payments.Pay(c.UserContext(), userID, uint(orderID))
+	if err != nil {
+		return h.fail(c, err, "Failed to start payment")
+	}
+	return c.Status(fiber.StatusCreated).JSON(NewPaymentResponse(*p, nil))
+}
+
+// Capture collects the authorized payment of an order the current user sold
+func (h *PaymentHandler) Capture(c *fiber.Ctx) error {
+	orderID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	p, err := h.payments.Capture(c.UserContext(), userID, uint(orderID))
+	if err != nil {
+		return h.fail(c, err, "Failed to capture payment")
+	}
+	out := NewPaymentResponse(*p, nil)
+	out.ClientSecret = ""
+	return c.JSON(out)
+}
+
+// Refund returns part or all of the payment of an order the current user
+// sold. It answers 202 while the provider is still processing the refund.
+func (h *PaymentHandler) Refund(c *fiber.Ctx) error {
+	orderID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input RefundRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	var amount *string
+	if input.Amount != nil {
+		s := input.Amount.String()
+		amount = &s
+	}
+	entry, err := h.payments.Refund(c.UserContext(), userID, uint(orderID), amount)
+	if err != nil {
+		return h.fail(c, err, "Failed to refund payment")
+	}
+	if entry == nil {
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"status": "pending"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(NewLedgerEntry(*entry))
+}
+
+// GetPayment shows the payment of an order and its ledger to the buyer or
+// the seller
+func (h *PaymentHandler) GetPayment(c *fiber.Ctx) error {
+	orderID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	p, ledger, err := h.payments.Payment(c.UserContext(), userID, uint(orderID))
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch payment")
+	}
+	out := NewPaymentResponse(*p, ledger)
+	if p.Order.UserID != userID {
+		out.ClientSecret = ""
+	}
+	return c.JSON(out)
+}
+
+// Webhook receives the provider's payment events. The signature is checked
+// against the raw body; repeated deliveries are acknowledged and ignored.
+func (h *PaymentHandler) Webhook(c *fiber.Ctx) error {
+	header := http.Header{}
+	for k, values := range c.GetReqHeaders() {
+		for _, v := range values {
+			header.Add(k, v)
+		}
+	}
+
+	err := h.payments.HandleWebhook(c.UserContext(), c.Body(), header)
+	switch {
+	case errors.Is(err, payment.ErrInvalidSignature):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid signature"})
+	case err != nil:
+		middleware.Logger(c).Error("error handling payment webhook", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to handle event"})
+	}
+	return c.JSON(fiber.Map{"received": true})
+}
+
+func (h *PaymentHandler) fail(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	case errors.Is(err, service.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "This is not your order"})
+	case errors.Is(err, service.ErrInvalidRefund):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrPaymentState):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		middleware.Logger(c).Error(message, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
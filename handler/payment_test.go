@@ -0,0 +1,68 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"app/database"
+	"app/handler"
+	"app/model"
+	"app/money"
+	"app/payment"
+	"app/repository"
+	"app/router"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayments_PayAndWebhook(t *testing.T) {
+	os.Setenv("SECRET", "testsecret")
+	db := database.ConnectDBWithDSN(":memory:")
+	db.Create(&model.User{ID: 1, Username: "buyer", Email: "buyer@example.com", Password: "x"})
+	db.Create(&model.User{ID: 2, Username: "seller", Email: "seller@example.com", Password: "x"})
+	db.Create(&model.Item{ID: 1, Name: "Lamp", Description: "Desk lamp", Price: money.New(1999, "EUR"), UserID: 2})
+	db.Create(&model.Order{ID: 1, ItemID: 1, UserID: 1, Quantity: 1, TotalPrice: money.New(1999, "EUR"), Status: model.OrderPending})
+	fake := testPayments()
+	app := fiber.New()
+	router.SetupRoutes(app, handler.New(repository.New(db), testBlobs(), testRates(), fake, nil))
+
+	resp := send(t, app, "POST", "/api/orders/1/pay", "")
+	require.Equal(t, 201, resp.StatusCode)
+	var p handler.PaymentResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+	assert.NotEmpty(t, p.ClientSecret)
+	assert.Equal(t, money.New(1999, "EUR"), p.Amount)
+	assert.Equal(t, 403, send(t, app, "POST", "/api/orders/1/capture", "").StatusCode)
+
+	webhook := func(payload []byte, signature string) int {
+		req := httptest.NewRequest("POST", "/api/webhooks/payments", bytes.NewReader(payload))
+		req.Header.Set(payment.FakeSignatureHeader, signature)
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+	payload, header := fake.Webhook(payment.Event{ID: "evt_1", Type: payment.EventSucceeded, IntentID: p.IntentID, Amount: p.Amount})
+	assert.Equal(t, 400, webhook(payload, "t=1,v1=00"))
+	assert.Equal(t, 200, webhook(payload, header.Get(payment.FakeSignatureHeader)))
+	assert.Equal(t, 200, webhook(payload, header.Get(payment.FakeSignatureHeader)))
+
+	resp = send(t, app, "GET", "/api/orders/1/payment", "")
+	require.Equal(t, 200, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+	assert.Equal(t, payment.IntentSucceeded, p.Status)
+	require.Len(t, p.Ledger, 1)
+	assert.Equal(t, model.LedgerCharge, p.Ledger[0].Kind)
+
+	resp = send(t, app, "GET", "/api/orders/", "")
+	var body struct {
+		Data []handler.OrderResponse `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, model.OrderPaid, body.Data[0].Status)
+}
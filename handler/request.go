@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"app/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LoginRequest is the body of POST /auth/login
+type LoginRequest struct {
+	Identity string `json:"identity" validate:"required,max=254"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+// RegisterRequest is the body of POST /auth/register
+type RegisterRequest struct {
+	Username string `json:"username" validate:"required,username"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// CreateUserRequest is the body of POST /user, held to the same rules as
+// registering
+type CreateUserRequest = RegisterRequest
+
+// UpdateUserRequest is the body of PATCH /user/id/:id
+type UpdateUserRequest struct {
+	Username string `json:"username" validate:"required,username"`
+}
+
+// UpdateMeRequest is the body of PATCH /user/me; omitted fields are kept
+type UpdateMeRequest struct {
+	Username    *string `json:"username" validate:"omitempty,username"`
+	DisplayName *string `json:"display_name" validate:"omitempty,max=100"`
+	AvatarURL   *string `json:"avatar_url" validate:"omitempty,url,max=500"`
+	Bio         *string `json:"bio" validate:"omitempty,max=500"`
+}
+
+// DeleteUserRequest is the body of DELETE /user/id/:id and /user/me
+type DeleteUserRequest struct {
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+// CreateItemRequest is the body of POST /items. The owner always comes
+// from the token, so there is no user_id field. Price is a decimal amount
+// in Currency, which defaults to DEFAULT_CURRENCY.
+type CreateItemRequest struct {
+	Name        string      `json:"name" validate:"required,max=100"`
+	Description string      `json:"description" validate:"required,max=2000"`
+	Price       json.Number `json:"price" validate:"required,amount"`
+	Currency    string      `json:"currency" validate:"omitempty,currency"`
+	CategoryID  uint        `json:"category_id" validate:"required,gt=0"`
+	// Stock defaults to a single unit
+	Stock *int `json:"stock" validate:"omitempty,min=0,max=1000000"`
+	// Status is active unless publish_at is set, which makes it a draft
+	Status      string     `json:"status" validate:"omitempty,oneof=draft active"`
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+// UpdateItemRequest is the body of PATCH /items/:id; omitted fields are kept
+type UpdateItemRequest struct {
+	Name        *string      `json:"name" validate:"omitempty,min=1,max=100"`
+	Description *string      `json:"description" validate:"omitempty,min=1,max=2000"`
+	Price       *json.Number `json:"price" validate:"omitempty,amount"`
+	Currency    *string      `json:"currency" validate:"omitempty,currency"`
+}
+
+// SetItemStatusRequest is the body of PUT /items/:id/status
+type SetItemStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=draft active paused sold archived"`
+}
+
+// ScheduleItemRequest is the body of PUT /items/:id/schedule; a time left
+// out clears that schedule
+type ScheduleItemRequest struct {
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+// CreateOrderRequest is the body of POST /orders. Either item_id and
+// quantity or the reservation_id holding them must be given.
+type CreateOrderRequest struct {
+	ItemID        uint `json:"item_id" validate:"required_without=ReservationID,omitempty,gt=0"`
+	Quantity      int  `json:"quantity" validate:"required_without=ReservationID,omitempty,min=1,max=1000"`
+	ReservationID uint `json:"reservation_id" validate:"omitempty,gt=0"`
+}
+
+// AdjustInventoryRequest is the body of POST /items/:id/inventory
+type AdjustInventoryRequest struct {
+	Delta  int    `json:"delta" validate:"required,min=-1000000,max=1000000"`
+	Reason string `json:"reason" validate:"required,oneof=restock correction"`
+	Note   string `json:"note" validate:"max=500"`
+}
+
+// CreateReservationRequest is the body of POST /items/:id/reservations
+type CreateReservationRequest struct {
+	Quantity int `json:"quantity" validate:"required,min=1,max=1000"`
+}
+
+// AddCartItemRequest is the body of POST /cart/items
+type AddCartItemRequest struct {
+	ItemID   uint `json:"item_id" validate:"required,gt=0"`
+	Quantity int  `json:"quantity" validate:"required,min=1,max=1000"`
+}
+
+// UpdateCartItemRequest is the body of PUT /cart/items/:itemId
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity" validate:"min=0,max=1000"`
+}
+
+// RefundRequest is the body of POST /orders/:id/refunds. Without an
+// amount, everything not yet refunded is returned.
+type RefundRequest struct {
+	Amount *json.Number `json:"amount" validate:"omitempty,amount"`
+}
+
+// CreateCommentRequest is the body of POST /items/:id/comments
+type CreateCommentRequest struct {
+	Body     string `json:"body" validate:"required,max=2000"`
+	ParentID *uint  `json:"parent_id" validate:"omitempty,gt=0"`
+}
+
+// UpdateCommentRequest is the body of PATCH /comments/:id
+type UpdateCommentRequest struct {
+	Body string `json:"body" validate:"required,max=2000"`
+}
+
+// CreateReviewRequest is the body of POST /items/:id/reviews
+type CreateReviewRequest struct {
+	Rating  int    `json:"rating" validate:"required,min=1,max=5"`
+	Comment string `json:"comment" validate:"max=2000"`
+}
+
+// NotificationPreferenceInput sets how one type of notification is
+// delivered
+type NotificationPreferenceInput struct {
+	Type  string `json:"type" validate:"required"`
+	InApp *bool  `json:"in_app" validate:"required"`
+	Email *bool  `json:"email" validate:"required"`
+}
+
+// UpdatePreferencesRequest is the body of PUT /notifications/preferences
+type UpdatePreferencesRequest struct {
+	Preferences []NotificationPreferenceInput `json:"preferences" validate:"required,min=1,dive"`
+}
+
+// SendMessageRequest is the body of POST /items/:id/conversations and
+// POST /conversations/:id/messages
+type SendMessageRequest struct {
+	Body string `json:"body" validate:"required,max=2000"`
+}
+
+// ReorderImagesRequest is the body of PUT /items/:id/images/order
+type ReorderImagesRequest struct {
+	ImageIDs []uint `json:"image_ids" validate:"required,min=1,max=10,dive,gt=0"`
+}
+
+// CreateWebhookRequest is the body of POST /user/me/webhooks. An empty
+// secret is generated.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,max=10"`
+	Secret string   `json:"secret" validate:"omitempty,min=16,max=64"`
+}
+
+// UpdateWebhookRequest is the body of PATCH /user/me/webhooks/:id
+type UpdateWebhookRequest struct {
+	URL    *string  `json:"url" validate:"omitempty,url,max=2048"`
+	Events []string `json:"events" validate:"omitempty,min=1,max=10"`
+	Active *bool    `json:"active"`
+}
+
+// SuspendUserRequest is the body of POST /admin/users/:id/suspension.
+// Without until the user is banned.
+type SuspendUserRequest struct {
+	Reason string     `json:"reason" validate:"required,max=500"`
+	Until  *time.Time `json:"until"`
+}
+
+// CreateReportRequest is the body of POST /reports
+type CreateReportRequest struct {
+	TargetType string `json:"target_type" validate:"required,oneof=item review comment"`
+	TargetID   uint   `json:"target_id" validate:"required"`
+	Reason     string `json:"reason" validate:"required,oneof=spam prohibited offensive fraud other"`
+	Details    string `json:"details" validate:"max=2000"`
+}
+
+// ResolveReportRequest is the body of POST /moderation/reports/:id/resolve
+type ResolveReportRequest struct {
+	Decision string `json:"decision" validate:"required,oneof=hide remove dismiss"`
+	Note     string `json:"note" validate:"max=2000"`
+}
+
+// bind parses the request body into dst and validates it
+func bind(c *fiber.Ctx, dst any) error {
+	if err := c.BodyParser(dst); err != nil {
+		return validation.Errors{{Field: "body", Tag: "parse", Message: "request body could not be parsed"}}
+	}
+	return validation.Struct(dst)
+}
+
+// invalid writes the response for a bind error, listing every bad field
+func invalid(c *fiber.Ctx, err error) error {
+	var fields validation.Errors
+	if !errors.As(err, &fields) {
+		fields = validation.Errors{{Field: "body", Tag: "invalid", Message: err.Error()}}
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Invalid request body",
+		"errors":  fields,
+	})
+}
@@ -0,0 +1,757 @@
+package handler
+
+import (
+	"encoding/json"
+	"time"
+
+	"app/model"
+	"app/money"
+	"app/repository"
+	"app/service"
+)
+
+// Response types are the only shapes handlers serialize; models never
+// leave the API directly, so fields such as the password hash can't leak.
+
+// PublicUser is what anyone may see about a user
+type PublicUser struct {
+	ID          uint   `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+}
+
+// PrivateUser is the profile a user sees about themselves
+type PrivateUser struct {
+	ID          uint      `json:"id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url"`
+	Bio         string    `json:"bio"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PublicProfile is a user's profile page with their seller statistics
+type PublicProfile struct {
+	Username      string    `json:"username"`
+	DisplayName   string    `json:"display_name"`
+	AvatarURL     string    `json:"avatar_url"`
+	Bio           string    `json:"bio"`
+	JoinedAt      time.Time `json:"joined_at"`
+	ListingCount  int64     `json:"listing_count"`
+	AverageRating float64   `json:"average_rating"`
+	ReviewCount   int64     `json:"review_count"`
+}
+
+// ItemSummary is an item as shown in listings
+type ItemSummary struct {
+	ID    uint        `json:"id"`
+	Name  string      `json:"name"`
+	Price money.Money `json:"price"`
+	// DisplayPrice is Price converted to the currency asked for with
+	// ?currency=
+	DisplayPrice *money.Money `json:"display_price,omitempty"`
+	CategoryID   uint         `json:"category_id"`
+	UserID       uint         `json:"user_id"`
+	Stock        int          `json:"stock"`
+	Status       string       `json:"status"`
+	// Available is set for listed items in stock
+	Available bool `json:"available"`
+	Likes     int  `json:"likes"`
+	Comments  int  `json:"comments"`
+	// ImageURL is a signed thumbnail URL of the primary image, if any
+	ImageURL string `json:"image_url,omitempty"`
+}
+
+// ItemDetail is a single item with its seller
+type ItemDetail struct {
+	ID          uint        `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+	// DisplayPrice is Price converted to the currency asked for with
+	// ?currency=
+	DisplayPrice *money.Money `json:"display_price,omitempty"`
+	CategoryID   uint         `json:"category_id"`
+	UserID       uint         `json:"user_id"`
+	Stock        int          `json:"stock"`
+	Status       string       `json:"status"`
+	// Available is set for listed items in stock
+	Available bool        `json:"available"`
+	Likes     int         `json:"likes"`
+	Comments  int         `json:"comments"`
+	Seller    *PublicUser `json:"seller,omitempty"`
+	Images    []ItemImage `json:"images"`
+	// Hidden is set while moderators keep the item out of listings
+	Hidden bool `json:"hidden,omitempty"`
+	// PublishAt and UnpublishAt are when the item is scheduled to be
+	// published and paused
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	UnpublishAt *time.Time `json:"unpublish_at,omitempty"`
+}
+
+// ItemImage is a picture of an item with signed URLs for each variant
+type ItemImage struct {
+	ID       uint              `json:"id"`
+	Position int               `json:"position"`
+	Primary  bool              `json:"primary"`
+	Width    int               `json:"width"`
+	Height   int               `json:"height"`
+	URLs     map[string]string `json:"urls"`
+}
+
+// OrderResponse is an order as shown to its buyer
+type OrderResponse struct {
+	ID         uint        `json:"id"`
+	ItemID     uint        `json:"item_id"`
+	Quantity   int         `json:"quantity"`
+	TotalPrice money.Money `json:"total_price"`
+	CategoryID uint        `json:"category_id"`
+	Status     string      `json:"status"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// PaymentResponse is the payment of an order. ClientSecret, which lets
+// the buyer's browser complete the payment, is only shown to the buyer.
+type PaymentResponse struct {
+	ID           uint          `json:"id"`
+	OrderID      uint          `json:"order_id"`
+	Provider     string        `json:"provider"`
+	IntentID     string        `json:"intent_id"`
+	ClientSecret string        `json:"client_secret,omitempty"`
+	Amount       money.Money   `json:"amount"`
+	Status       string        `json:"status"`
+	Ledger       []LedgerEntry `json:"ledger,omitempty"`
+}
+
+// LedgerEntry is a charge, or a refund with a negative amount
+type LedgerEntry struct {
+	Kind      string      `json:"kind"`
+	Amount    money.Money `json:"amount"`
+	Reference string      `json:"reference"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// SellerStatsResponse is a seller's dashboard over a window
+type SellerStatsResponse struct {
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Bucket string    `json:"bucket"`
+	SalesTotals
+	Buckets  []StatsBucket `json:"buckets"`
+	TopItems []TopSoldItem `json:"top_items"`
+}
+
+// SalesTotals sums sales and reviews. Revenue has one sum per currency.
+type SalesTotals struct {
+	Revenue       []money.Money `json:"revenue"`
+	Units         int64         `json:"units"`
+	Orders        int64         `json:"orders"`
+	AverageRating *float64      `json:"average_rating"`
+	Reviews       int64         `json:"reviews"`
+}
+
+// StatsBucket is the totals of a day, week or month starting at Start
+type StatsBucket struct {
+	Start time.Time `json:"start"`
+	SalesTotals
+}
+
+// TopSoldItem is one of a seller's best selling items
+type TopSoldItem struct {
+	ItemID  uint        `json:"item_id"`
+	Name    string      `json:"name"`
+	Units   int64       `json:"units"`
+	Revenue money.Money `json:"revenue"`
+}
+
+// CommentResponse is a comment with its replies. Deleted comments keep
+// their place in a thread without an author or body.
+type CommentResponse struct {
+	ID        uint              `json:"id"`
+	ItemID    uint              `json:"item_id"`
+	ParentID  *uint             `json:"parent_id,omitempty"`
+	Author    *PublicUser       `json:"author,omitempty"`
+	Body      string            `json:"body"`
+	Deleted   bool              `json:"deleted,omitempty"`
+	EditedAt  *time.Time        `json:"edited_at,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	Replies   []CommentResponse `json:"replies"`
+}
+
+// LikeResponse is whether the current user likes an item and its count
+type LikeResponse struct {
+	Liked bool `json:"liked"`
+	Likes int  `json:"likes"`
+}
+
+// WishlistEntry is a saved item. Deleted items stay until removed.
+type WishlistEntry struct {
+	Item    ItemSummary `json:"item"`
+	Deleted bool        `json:"deleted,omitempty"`
+	AddedAt time.Time   `json:"added_at"`
+}
+
+// NotificationResponse is a notification of the current user
+type NotificationResponse struct {
+	ID        uint       `json:"id"`
+	Type      string     `json:"type"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	ItemID    *uint      `json:"item_id,omitempty"`
+	Read      bool       `json:"read"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
+
+// NotificationPage is a page of notifications with the unread count
+type NotificationPage struct {
+	Notifications []NotificationResponse `json:"notifications"`
+	Unread        int64                  `json:"unread"`
+	Total         int64                  `json:"total"`
+	Page          int                    `json:"page"`
+	Limit         int                    `json:"limit"`
+}
+
+// NotificationPreference is how one type of notification is delivered
+type NotificationPreference struct {
+	Type  string `json:"type"`
+	InApp bool   `json:"in_app"`
+	Email bool   `json:"email"`
+}
+
+// ReviewResponse is a review of an item
+type ReviewResponse struct {
+	ID        uint        `json:"id"`
+	ItemID    uint        `json:"item_id"`
+	Author    *PublicUser `json:"author,omitempty"`
+	Rating    int         `json:"rating"`
+	Comment   string      `json:"comment"`
+	CreatedAt *time.Time  `json:"created_at,omitempty"`
+}
+
+// MessageResponse is a message in a conversation
+type MessageResponse struct {
+	ID             uint       `json:"id"`
+	ConversationID uint       `json:"conversation_id"`
+	SenderID       uint       `json:"sender_id"`
+	Body           string     `json:"body"`
+	ReadAt         *time.Time `json:"read_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// ConversationResponse is a conversation about an item. LastMessage and
+// Unread are only set in listings.
+type ConversationResponse struct {
+	ID            uint             `json:"id"`
+	Item          ItemSummary      `json:"item"`
+	Buyer         PublicUser       `json:"buyer"`
+	Seller        PublicUser       `json:"seller"`
+	LastMessage   *MessageResponse `json:"last_message,omitempty"`
+	Unread        int64            `json:"unread"`
+	LastMessageAt time.Time        `json:"last_message_at"`
+	CreatedAt     time.Time        `json:"created_at"`
+}
+
+// WebhookResponse is a webhook subscription of the current user. Secret
+// is only shown when the subscription is created.
+type WebhookResponse struct {
+	ID         uint       `json:"id"`
+	URL        string     `json:"url"`
+	Events     []string   `json:"events"`
+	Active     bool       `json:"active"`
+	Failures   int        `json:"failures"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	Secret     string     `json:"secret,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// WebhookDeliveryResponse is one attempt at delivering an event
+type WebhookDeliveryResponse struct {
+	ID         uint      `json:"id"`
+	EventID    string    `json:"event_id"`
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AdminUser is what admins see about a user
+type AdminUser struct {
+	ID             uint       `json:"id"`
+	Username       string     `json:"username"`
+	Email          string     `json:"email"`
+	DisplayName    string     `json:"display_name"`
+	Role           string     `json:"role"`
+	Status         string     `json:"status"`
+	SuspendedAt    *time.Time `json:"suspended_at,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	SuspendReason  string     `json:"suspend_reason,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeactivatedAt  *time.Time `json:"deactivated_at,omitempty"`
+}
+
+// AdminUserPage is a page of users for admins
+type AdminUserPage struct {
+	Users []AdminUser `json:"users"`
+	Total int64       `json:"total"`
+	Page  int         `json:"page"`
+	Limit int         `json:"limit"`
+}
+
+// ReportResponse is a report of an item, review or comment
+type ReportResponse struct {
+	ID          uint       `json:"id"`
+	ReporterID  *uint      `json:"reporter_id"`
+	TargetType  string     `json:"target_type"`
+	TargetID    uint       `json:"target_id"`
+	Reason      string     `json:"reason"`
+	Details     string     `json:"details"`
+	Status      string     `json:"status"`
+	ModeratorID *uint      `json:"moderator_id,omitempty"`
+	Note        string     `json:"note,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ReportPage is a page of the moderation queue
+type ReportPage struct {
+	Reports []ReportResponse `json:"reports"`
+	Total   int64            `json:"total"`
+	Page    int              `json:"page"`
+	Limit   int              `json:"limit"`
+}
+
+// AuditLogResponse is an entry of the audit log
+type AuditLogResponse struct {
+	ID             uint            `json:"id"`
+	ActorID        *uint           `json:"actor_id"`
+	ImpersonatorID *uint           `json:"impersonator_id,omitempty"`
+	Action         string          `json:"action"`
+	TargetType     string          `json:"target_type"`
+	TargetID       *uint           `json:"target_id"`
+	Changes        json.RawMessage `json:"changes"`
+	IP             string          `json:"ip"`
+	UserAgent      string          `json:"user_agent"`
+	RequestID      string          `json:"request_id"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// AuditLogPage is a page of audit log entries
+type AuditLogPage struct {
+	Entries []AuditLogResponse `json:"entries"`
+	Total   int64              `json:"total"`
+	Page    int                `json:"page"`
+	Limit   int                `json:"limit"`
+}
+
+// CartResponse is a cart priced from the live items
+type CartResponse struct {
+	Lines []CartLine `json:"lines"`
+	// Totals has one sum per currency used in the cart
+	Totals []money.Money `json:"totals"`
+	// Ready reports whether every line can be checked out
+	Ready bool `json:"ready"`
+}
+
+// CartLine is a line of a cart. Item and Subtotal are absent once the item
+// is deleted; Problem explains why a line can't be checked out.
+type CartLine struct {
+	ItemID   uint         `json:"item_id"`
+	Item     *ItemSummary `json:"item,omitempty"`
+	Quantity int          `json:"quantity"`
+	Subtotal *money.Money `json:"subtotal,omitempty"`
+	Problem  string       `json:"problem,omitempty"`
+}
+
+// InventoryResponse is an item's stock as its owner sees it
+type InventoryResponse struct {
+	Stock       int                   `json:"stock"`
+	Reserved    int                   `json:"reserved"`
+	Adjustments []InventoryAdjustment `json:"adjustments"`
+}
+
+// InventoryAdjustment is an entry of an item's inventory log
+type InventoryAdjustment struct {
+	ID            uint      `json:"id"`
+	Delta         int       `json:"delta"`
+	StockAfter    int       `json:"stock_after"`
+	Reason        string    `json:"reason"`
+	Note          string    `json:"note,omitempty"`
+	OrderID       *uint     `json:"order_id,omitempty"`
+	ReservationID *uint     `json:"reservation_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ReservationResponse is stock held for the current user
+type ReservationResponse struct {
+	ID        uint      `json:"id"`
+	ItemID    uint      `json:"item_id"`
+	Quantity  int       `json:"quantity"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewPublicUser maps a user to its public view
+func NewPublicUser(u model.User) PublicUser {
+	return PublicUser{ID: u.ID, Username: u.Username, DisplayName: u.DisplayName, AvatarURL: u.AvatarURL}
+}
+
+// NewPublicUsers maps users to their public views
+func NewPublicUsers(users []model.User) []PublicUser {
+	out := make([]PublicUser, len(users))
+	for i, u := range users {
+		out[i] = NewPublicUser(u)
+	}
+	return out
+}
+
+// NewPrivateUser maps a user to the profile they see about themselves
+func NewPrivateUser(u model.User) PrivateUser {
+	return PrivateUser{
+		ID:          u.ID,
+		Username:    u.Username,
+		Email:       u.Email,
+		DisplayName: u.DisplayName,
+		AvatarURL:   u.AvatarURL,
+		Bio:         u.Bio,
+		Role:        u.Role,
+		CreatedAt:   u.CreatedAt,
+	}
+}
+
+// NewPublicProfile maps a service profile to the profile page view
+func NewPublicProfile(p service.Profile) PublicProfile {
+	return PublicProfile{
+		Username:      p.User.Username,
+		DisplayName:   p.User.DisplayName,
+		AvatarURL:     p.User.AvatarURL,
+		Bio:           p.User.Bio,
+		JoinedAt:      p.User.CreatedAt,
+		ListingCount:  p.ListingCount,
+		AverageRating: p.AverageRating,
+		ReviewCount:   p.ReviewCount,
+	}
+}
+
+// NewItemSummary maps an item to its listing view
+func NewItemSummary(i model.Item) ItemSummary {
+	return ItemSummary{
+		ID: i.ID, Name: i.Name, Price: i.Price, CategoryID: i.CategoryID, UserID: i.UserID,
+		Stock: i.Stock, Status: i.Status, Available: i.Listed() && i.Stock > 0, Likes: i.LikeCount, Comments: i.CommentCount,
+	}
+}
+
+// NewItemSummaries maps items to their listing views
+func NewItemSummaries(items []model.Item) []ItemSummary {
+	out := make([]ItemSummary, len(items))
+	for i, it := range items {
+		out[i] = NewItemSummary(it)
+	}
+	return out
+}
+
+// NewItemDetail maps an item, and its seller when loaded, to the detail view
+func NewItemDetail(i model.Item) ItemDetail {
+	d := ItemDetail{
+		ID:          i.ID,
+		Name:        i.Name,
+		Description: i.Description,
+		Price:       i.Price,
+		CategoryID:  i.CategoryID,
+		UserID:      i.UserID,
+		Stock:       i.Stock,
+		Status:      i.Status,
+		Available:   i.Listed() && i.Stock > 0,
+		Likes:       i.LikeCount,
+		Comments:    i.CommentCount,
+		Images:      []ItemImage{},
+		Hidden:      i.Hidden,
+		PublishAt:   i.PublishAt,
+		UnpublishAt: i.UnpublishAt,
+	}
+	if i.User.ID != 0 {
+		seller := NewPublicUser(i.User)
+		d.Seller = &seller
+	}
+	return d
+}
+
+// NewItemImage maps an item image and its signed variant URLs
+func NewItemImage(img model.ItemImage, urls map[string]string) ItemImage {
+	return ItemImage{ID: img.ID, Position: img.Position, Primary: img.IsPrimary, Width: img.Width, Height: img.Height, URLs: urls}
+}
+
+// NewSellerStatsResponse maps a seller's dashboard
+func NewSellerStatsResponse(s service.SellerStats) SellerStatsResponse {
+	out := SellerStatsResponse{
+		From: s.Query.From, To: s.Query.To, Bucket: s.Query.Bucket,
+		SalesTotals: SalesTotals(s.SalesTotals),
+		Buckets:     make([]StatsBucket, len(s.Buckets)),
+		TopItems:    make([]TopSoldItem, len(s.TopItems)),
+	}
+	for i, b := range s.Buckets {
+		out.Buckets[i] = StatsBucket{Start: b.Start, SalesTotals: SalesTotals(b.SalesTotals)}
+	}
+	for i, t := range s.TopItems {
+		out.TopItems[i] = TopSoldItem(t)
+	}
+	return out
+}
+
+// NewCommentResponse maps a comment without replies
+func NewCommentResponse(c model.Comment) CommentResponse {
+	out := CommentResponse{
+		ID: c.ID, ItemID: c.ItemID, ParentID: c.ParentID, Body: c.Body,
+		EditedAt: c.EditedAt, CreatedAt: c.CreatedAt, Replies: []CommentResponse{},
+	}
+	if c.User.ID != 0 {
+		author := NewPublicUser(c.User)
+		out.Author = &author
+	}
+	return out
+}
+
+// NewCommentThreads maps comment threads
+func NewCommentThreads(threads []service.CommentThread) []CommentResponse {
+	out := make([]CommentResponse, len(threads))
+	for i, t := range threads {
+		if t.Deleted {
+			out[i] = CommentResponse{ID: t.Comment.ID, ItemID: t.Comment.ItemID, ParentID: t.Comment.ParentID, Deleted: true, CreatedAt: t.Comment.CreatedAt}
+		} else {
+			out[i] = NewCommentResponse(t.Comment)
+		}
+		out[i].Replies = NewCommentThreads(t.Replies)
+	}
+	return out
+}
+
+// NewWishlist maps wishlist entries
+func NewWishlist(entries []model.WishlistEntry) []WishlistEntry {
+	out := make([]WishlistEntry, len(entries))
+	for i, e := range entries {
+		deleted := e.Item.DeletedAt.Valid
+		out[i] = WishlistEntry{Item: NewItemSummary(e.Item), Deleted: deleted, AddedAt: e.CreatedAt}
+		if deleted {
+			out[i].Item.Available = false
+		}
+	}
+	return out
+}
+
+// NewNotificationResponses maps notifications
+func NewNotificationResponses(notifications []model.Notification) []NotificationResponse {
+	out := make([]NotificationResponse, len(notifications))
+	for i, n := range notifications {
+		out[i] = NotificationResponse{
+			ID: n.ID, Type: n.Type, Title: n.Title, Body: n.Body, ItemID: n.ItemID,
+			Read: n.ReadAt != nil, CreatedAt: n.CreatedAt, ReadAt: n.ReadAt,
+		}
+	}
+	return out
+}
+
+// NewPreferences maps notification preferences
+func NewPreferences(prefs []model.NotificationPreference) []NotificationPreference {
+	out := make([]NotificationPreference, len(prefs))
+	for i, p := range prefs {
+		out[i] = NotificationPreference{Type: p.Type, InApp: p.InApp, Email: p.Email}
+	}
+	return out
+}
+
+// NewReviewResponse maps a review
+func NewReviewResponse(r model.Review) ReviewResponse {
+	out := ReviewResponse{ID: r.ID, ItemID: r.ItemID, Rating: r.Rating, Comment: r.Comment, CreatedAt: r.CreatedAt}
+	if r.User.ID != 0 {
+		author := NewPublicUser(r.User)
+		out.Author = &author
+	}
+	return out
+}
+
+// NewMessageResponse maps a message
+func NewMessageResponse(m model.Message) MessageResponse {
+	return MessageResponse{
+		ID: m.ID, ConversationID: m.ConversationID, SenderID: m.SenderID,
+		Body: m.Body, ReadAt: m.ReadAt, CreatedAt: m.CreatedAt,
+	}
+}
+
+// NewConversationResponse maps a conversation
+func NewConversationResponse(c model.Conversation) ConversationResponse {
+	return ConversationResponse{
+		ID: c.ID, Item: NewItemSummary(c.Item), Buyer: NewPublicUser(c.Buyer), Seller: NewPublicUser(c.Seller),
+		LastMessageAt: c.LastMessageAt, CreatedAt: c.CreatedAt,
+	}
+}
+
+// NewConversationList maps conversation summaries
+func NewConversationList(summaries []repository.ConversationSummary) []ConversationResponse {
+	out := make([]ConversationResponse, len(summaries))
+	for i, s := range summaries {
+		out[i] = NewConversationResponse(s.Conversation)
+		out[i].Unread = s.Unread
+		if s.LastMessage != nil {
+			last := NewMessageResponse(*s.LastMessage)
+			out[i].LastMessage = &last
+		}
+	}
+	return out
+}
+
+// NewWebhookResponse maps a webhook subscription, leaving its secret out
+func NewWebhookResponse(sub model.WebhookSubscription) WebhookResponse {
+	return WebhookResponse{
+		ID: sub.ID, URL: sub.URL, Events: sub.Events, Active: sub.Active,
+		Failures: sub.Failures, DisabledAt: sub.DisabledAt, CreatedAt: sub.CreatedAt,
+	}
+}
+
+// NewWebhookResponses maps webhook subscriptions
+func NewWebhookResponses(subs []model.WebhookSubscription) []WebhookResponse {
+	out := make([]WebhookResponse, len(subs))
+	for i, sub := range subs {
+		out[i] = NewWebhookResponse(sub)
+	}
+	return out
+}
+
+// NewWebhookDeliveryResponse maps a webhook delivery attempt
+func NewWebhookDeliveryResponse(d model.WebhookDelivery) WebhookDeliveryResponse {
+	return WebhookDeliveryResponse{
+		ID: d.ID, EventID: d.EventID, Event: d.Event, Attempt: d.Attempt, StatusCode: d.StatusCode,
+		Error: d.Error, DurationMS: d.DurationMS, CreatedAt: d.CreatedAt,
+	}
+}
+
+// NewCartResponse maps a priced cart
+func NewCartResponse(v service.CartView) CartResponse {
+	out := CartResponse{Lines: make([]CartLine, len(v.Lines)), Totals: v.Totals, Ready: v.Ready}
+	if out.Totals == nil {
+		out.Totals = []money.Money{}
+	}
+	for i, l := range v.Lines {
+		out.Lines[i] = CartLine{ItemID: l.ItemID, Quantity: l.Quantity, Problem: l.Problem}
+		if l.Item != nil {
+			item := NewItemSummary(*l.Item)
+			subtotal := l.Subtotal
+			out.Lines[i].Item = &item
+			out.Lines[i].Subtotal = &subtotal
+		}
+	}
+	return out
+}
+
+// NewInventoryResponse maps a stock level to its owner view
+func NewInventoryResponse(l service.StockLevel) InventoryResponse {
+	out := InventoryResponse{Stock: l.Stock, Reserved: l.Reserved, Adjustments: make([]InventoryAdjustment, len(l.Adjustments))}
+	for i, a := range l.Adjustments {
+		out.Adjustments[i] = InventoryAdjustment{
+			ID: a.ID, Delta: a.Delta, StockAfter: a.StockAfter, Reason: a.Reason, Note: a.Note,
+			OrderID: a.OrderID, ReservationID: a.ReservationID, CreatedAt: a.CreatedAt,
+		}
+	}
+	return out
+}
+
+// NewReservationResponse maps a stock reservation
+func NewReservationResponse(r model.StockReservation) ReservationResponse {
+	return ReservationResponse{ID: r.ID, ItemID: r.ItemID, Quantity: r.Quantity, Status: r.Status, ExpiresAt: r.ExpiresAt}
+}
+
+// NewOrderResponse maps an order to its buyer view
+func NewOrderResponse(o model.Order) OrderResponse {
+	return OrderResponse{
+		ID: o.ID, ItemID: o.ItemID, Quantity: o.Quantity, TotalPrice: o.TotalPrice,
+		CategoryID: o.CategoryID, Status: o.Status, CreatedAt: o.CreatedAt,
+	}
+}
+
+// NewPaymentResponse maps a payment and its ledger
+func NewPaymentResponse(p model.Payment, ledger []model.LedgerEntry) PaymentResponse {
+	out := PaymentResponse{
+		ID: p.ID, OrderID: p.OrderID, Provider: p.Provider, IntentID: p.IntentID,
+		ClientSecret: p.ClientSecret, Amount: p.Amount, Status: p.Status,
+	}
+	for _, e := range ledger {
+		out.Ledger = append(out.Ledger, NewLedgerEntry(e))
+	}
+	return out
+}
+
+// NewLedgerEntry maps a ledger entry
+func NewLedgerEntry(e model.LedgerEntry) LedgerEntry {
+	return LedgerEntry{Kind: e.Kind, Amount: e.Amount, Reference: e.Reference, CreatedAt: e.CreatedAt}
+}
+
+// NewOrderResponses maps orders to their buyer views
+func NewOrderResponses(orders []model.Order) []OrderResponse {
+	out := make([]OrderResponse, len(orders))
+	for i, o := range orders {
+		out[i] = NewOrderResponse(o)
+	}
+	return out
+}
+
+// NewAuditLogResponse maps an audit log entry to its response
+func NewAuditLogResponse(e model.AuditLog) AuditLogResponse {
+	changes := json.RawMessage(e.Changes)
+	if !json.Valid(changes) {
+		changes = json.RawMessage("{}")
+	}
+	return AuditLogResponse{
+		ID:             e.ID,
+		ActorID:        e.ActorID,
+		ImpersonatorID: e.ImpersonatorID,
+		Action:         e.Action,
+		TargetType:     e.TargetType,
+		TargetID:       e.TargetID,
+		Changes:        changes,
+		IP:             e.IP,
+		UserAgent:      e.UserAgent,
+		RequestID:      e.RequestID,
+		CreatedAt:      e.CreatedAt,
+	}
+}
+
+// NewAdminUser maps a user to what admins see of them at now
+func NewAdminUser(u model.User, now time.Time) AdminUser {
+	out := AdminUser{
+		ID:             u.ID,
+		Username:       u.Username,
+		Email:          u.Email,
+		DisplayName:    u.DisplayName,
+		Role:           u.Role,
+		Status:         u.Status(now),
+		SuspendedAt:    u.SuspendedAt,
+		SuspendedUntil: u.SuspendedUntil,
+		SuspendReason:  u.SuspendReason,
+		CreatedAt:      u.CreatedAt,
+	}
+	if u.DeletedAt.Valid {
+		out.DeactivatedAt = &u.DeletedAt.Time
+	}
+	return out
+}
+
+// NewReportResponse maps a report
+func NewReportResponse(r model.Report) ReportResponse {
+	return ReportResponse{
+		ID:          r.ID,
+		ReporterID:  r.ReporterID,
+		TargetType:  r.TargetType,
+		TargetID:    r.TargetID,
+		Reason:      r.Reason,
+		Details:     r.Details,
+		Status:      r.Status,
+		ModeratorID: r.ModeratorID,
+		Note:        r.Note,
+		ResolvedAt:  r.ResolvedAt,
+		CreatedAt:   r.CreatedAt,
+	}
+}
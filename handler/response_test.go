@@ -0,0 +1,94 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"app/database"
+	"app/handler"
+	"app/model"
+	"app/money"
+	"app/repository"
+	"app/router"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// findPasswordKeys returns the path of every object key naming a password
+func findPasswordKeys(v any, path string) []string {
+	var found []string
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if strings.Contains(strings.ToLower(k), "password") {
+				found = append(found, path+"."+k)
+			}
+			found = append(found, findPasswordKeys(child, path+"."+k)...)
+		}
+	case []any:
+		for _, child := range t {
+			found = append(found, findPasswordKeys(child, path+"[]")...)
+		}
+	}
+	return found
+}
+
+func TestResponses_NeverContainPasswords(t *testing.T) {
+	os.Setenv("SECRET", "testsecret")
+	os.Setenv("REFRESH_SECRET", "refreshsecret")
+
+	db := database.ConnectDBWithDSN(":memory:")
+	hash, _ := service.HashPassword("securepass")
+	db.Create(&model.User{ID: 1, Username: "seller", Email: "seller@example.com", Password: hash, Role: model.RoleAdmin})
+	db.Create(&model.User{ID: 2, Username: "buyer", Email: "buyer@example.com", Password: hash})
+	db.Create(&model.Category{ID: 1, Name: "Books", Description: "Books"})
+	db.Create(&model.Item{ID: 1, Name: "Go Book", Description: "Learn Go", Price: money.New(2000, "EUR"), UserID: 2, CategoryID: 1, Stock: 5})
+
+	app := fiber.New()
+	router.SetupRoutes(app, handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil))
+
+	requests := []struct {
+		method, path, body string
+	}{
+		{"GET", "/api/user/id/1", ""},
+		{"GET", "/api/admin/users", ""},
+		{"GET", "/api/admin/users/2", ""},
+		{"GET", "/api/user/me", ""},
+		{"GET", "/api/user/profile/seller", ""},
+		{"PATCH", "/api/user/me", `{"bio":"Hello"}`},
+		{"POST", "/api/user/", `{"username":"created","email":"created@example.com","password":"securepass"}`},
+		{"POST", "/api/auth/register", `{"username":"newbie","email":"newbie@example.com","password":"securepass"}`},
+		{"POST", "/api/auth/login", `{"identity":"seller","password":"securepass"}`},
+		{"PATCH", "/api/user/id/1", `{"username":"renamed"}`},
+		{"GET", "/api/items/", ""},
+		{"GET", "/api/items/category/1", ""},
+		{"POST", "/api/items/", `{"name":"Lamp","description":"Desk lamp","price":15,"category_id":1}`},
+		{"POST", "/api/orders/", `{"item_id":1,"quantity":2}`},
+		{"GET", "/api/orders/", ""},
+		{"POST", "/api/cart/items", `{"item_id":1,"quantity":1}`},
+		{"GET", "/api/cart/", ""},
+	}
+
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, r.path, bytes.NewReader([]byte(r.body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", authHeader())
+
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Less(t, resp.StatusCode, 300, r.method+" "+r.path)
+
+		raw, _ := io.ReadAll(resp.Body)
+		var body any
+		assert.NoError(t, json.Unmarshal(raw, &body), r.method+" "+r.path)
+		assert.Empty(t, findPasswordKeys(body, ""), r.method+" "+r.path)
+		assert.NotContains(t, string(raw), hash, r.method+" "+r.path)
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"app/middleware"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReviewHandler serves the reviews of items
+type ReviewHandler struct {
+	reviews *service.ReviewService
+}
+
+// NewReviewHandler creates a ReviewHandler
+func NewReviewHandler(reviews *service.ReviewService) *ReviewHandler {
+	return &ReviewHandler{reviews: reviews}
+}
+
+// ListReviews shows the reviews of an item
+func (h *ReviewHandler) ListReviews(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+
+	reviews, err := h.reviews.List(c.UserContext(), uint(itemID))
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch reviews")
+	}
+	out := make([]ReviewResponse, len(reviews))
+	for i, r := range reviews {
+		out[i] = NewReviewResponse(r)
+	}
+	return c.JSON(out)
+}
+
+// CreateReview reviews an item
+func (h *ReviewHandler) CreateReview(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input CreateReviewRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	review, err := h.reviews.Create(c.UserContext(), userID, uint(itemID), input.Rating, input.Comment)
+	if err != nil {
+		return h.fail(c, err, "Failed to create review")
+	}
+	return c.Status(fiber.StatusCreated).JSON(NewReviewResponse(*review))
+}
+
+func (h *ReviewHandler) fail(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Item not found"})
+	case errors.Is(err, service.ErrReviewOwnItem):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidReview):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		middleware.Logger(c).Error(message, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
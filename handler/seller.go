@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"app/middleware"
+	"app/repository"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Stats query defaults
+const (
+	defaultStatsWindow = 30 * 24 * time.Hour
+	defaultTopItems    = 5
+)
+
+// SellerHandler serves the seller dashboard
+type SellerHandler struct {
+	stats *service.StatsService
+}
+
+// NewSellerHandler creates a SellerHandler
+func NewSellerHandler(stats *service.StatsService) *SellerHandler {
+	return &SellerHandler{stats: stats}
+}
+
+// Stats shows the current user's sales and reviews over ?from= to ?to=
+// (dates or RFC 3339 times, the last 30 days by default), bucketed by
+// ?bucket=day, week or month, with the ?top= best selling items
+func (h *SellerHandler) Stats(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	q := service.StatsQuery{
+		To:     time.Now().UTC(),
+		Bucket: c.Query("bucket", repository.BucketDay),
+		Top:    c.QueryInt("top", defaultTopItems),
+	}
+	if s := c.Query("to"); s != "" {
+		if q.To, err = parseStatsTime(s); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to"})
+		}
+	}
+	q.From = q.To.Add(-defaultStatsWindow)
+	if s := c.Query("from"); s != "" {
+		if q.From, err = parseStatsTime(s); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from"})
+		}
+	}
+
+	stats, err := h.stats.Seller(c.UserContext(), userID, q)
+	switch {
+	case errors.Is(err, service.ErrInvalidStatsQuery):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		middleware.Logger(c).Error("error computing seller stats", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to compute stats"})
+	}
+	return c.JSON(NewSellerStatsResponse(*stats))
+}
+
+// parseStatsTime reads a date, meaning midnight UTC, or an RFC 3339 time
+func parseStatsTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
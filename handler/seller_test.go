@@ -0,0 +1,34 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"app/handler"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSellerStats_Query(t *testing.T) {
+	app := setupInventoryApp()
+
+	resp := send(t, app, "GET", "/api/seller/stats?from=2026-01-01&to=2026-03-01&bucket=month", "")
+	require.Equal(t, 200, resp.StatusCode)
+	var stats handler.SellerStatsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	assert.Equal(t, "month", stats.Bucket)
+	assert.Len(t, stats.Buckets, 2)
+	assert.Empty(t, stats.Revenue)
+	assert.Nil(t, stats.AverageRating)
+
+	resp = send(t, app, "GET", "/api/seller/stats", "")
+	require.Equal(t, 200, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	assert.Equal(t, "day", stats.Bucket)
+	assert.Len(t, stats.Buckets, 31)
+
+	for _, q := range []string{"?bucket=year", "?from=yesterday", "?from=2026-03-01&to=2026-01-01", "?top=500"} {
+		assert.Equal(t, 400, send(t, app, "GET", "/api/seller/stats"+q, "").StatusCode, q)
+	}
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+
+	"app/events"
+	"app/middleware"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StreamHandler streams real-time updates to signed-in users
+type StreamHandler struct {
+	bus   events.Bus
+	users *service.UserService
+
+	// Heartbeat is how often an idle stream sends a comment, keeping
+	// proxies from closing it and noticing clients that left. The session
+	// is checked again each time.
+	Heartbeat time.Duration
+}
+
+// NewStreamHandler creates a StreamHandler checking sessions with users
+func NewStreamHandler(bus events.Bus, users *service.UserService) *StreamHandler {
+	return &StreamHandler{bus: bus, users: users, Heartbeat: 15 * time.Second}
+}
+
+// Events streams the current user's events as Server-Sent Events until
+// the client disconnects or the session ends: signed out everywhere,
+// suspended or deleted
+func (h *StreamHandler) Events(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	// The request is gone by the time the stream is written
+	ctx, version := c.UserContext(), middleware.GetTokenVersion(c)
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	sub, cancel := h.bus.Subscribe(userID)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		heartbeat := time.NewTicker(h.Heartbeat)
+		defer heartbeat.Stop()
+
+		// Tells the client the stream is open
+		fmt.Fprint(w, ": connected\n\n")
+		for {
+			if err := w.Flush(); err != nil {
+				return
+			}
+			select {
+			case event, ok := <-sub:
+				if !ok {
+					return
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+			case <-heartbeat.C:
+				// Closing makes the client reconnect, which fails if the
+				// session ended rather than the check
+				if _, err := h.users.Session(ctx, userID, version); err != nil {
+					return
+				}
+				fmt.Fprint(w, ": ping\n\n")
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,88 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"app/events"
+	"app/handler"
+	"app/model"
+	"app/repository"
+	"app/router"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestEvents_StreamsNotifications(t *testing.T) {
+	db := setupInventoryDB()
+	app := fiber.New()
+	router.SetupRoutes(app, handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), events.NewLocal()))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(ln)
+	// Not app.Shutdown: it would wait for the stream to notice the client left
+	defer ln.Close()
+
+	// Browsers' EventSource sends the jwt cookie set at login
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://"+ln.Addr().String()+"/api/events", nil)
+	req.AddCookie(&http.Cookie{Name: "jwt", Value: strings.TrimPrefix(authHeaderFor(2), "Bearer ")})
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := bufio.NewScanner(resp.Body)
+	require.True(t, lines.Scan())
+	assert.Equal(t, ": connected", lines.Text())
+
+	require.Equal(t, 201, send(t, app, "POST", "/api/items/1/reviews", `{"rating":5}`).StatusCode)
+	for lines.Scan() && lines.Text() != "event: notification" {
+	}
+	require.True(t, lines.Scan())
+	assert.Contains(t, lines.Text(), `"type":"review"`)
+
+	assert.Equal(t, 400, guest(t, app, "GET", "/api/events", "", nil).StatusCode)
+}
+
+func TestEvents_EndWithTheSession(t *testing.T) {
+	db := setupInventoryDB()
+	h := handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), events.NewLocal())
+	h.Stream.Heartbeat = 20 * time.Millisecond
+	app := fiber.New()
+	router.SetupRoutes(app, h)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(ln)
+	defer ln.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://"+ln.Addr().String()+"/api/events", nil)
+	req.Header.Set("Authorization", authHeaderFor(2))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, 200, resp.StatusCode)
+	lines := bufio.NewScanner(resp.Body)
+	for range 3 {
+		require.True(t, lines.Scan())
+	}
+	assert.Equal(t, ": ping", lines.Text(), "heartbeats go on while the session lasts")
+
+	// Signing out everywhere closes the stream at the next heartbeat
+	require.NoError(t, db.Model(&model.User{}).Where("id = ?", 2).Update("token_version", gorm.Expr("token_version + 1")).Error)
+	for lines.Scan() {
+	}
+	assert.NoError(t, ctx.Err(), "the stream closed before the client gave up")
+}
@@ -1,173 +1,221 @@
 package handler
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
 	"strconv"
-	"log"
-	"app/database"
-	"app/model"
+	"time"
+
+	"app/middleware"
+	"app/service"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func hashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+// UserHandler serves user accounts
+type UserHandler struct {
+	users    *service.UserService
+	accounts *service.AccountService
 }
 
-func validToken(t *jwt.Token, id string) bool {
-	n, err := strconv.Atoi(id)
-	if err != nil {
-		return false
-	}
-
-	claims := t.Claims.(jwt.MapClaims)
-	uid := int(claims["user_id"].(float64))
-
-	return uid == n
-}
-
-func validUser(id string, p string) bool {
-	db := database.DB
-	var user model.User
-	db.First(&user, id)
-	if user.Username == "" {
-		return false
-	}
-	if !CheckPasswordHash(p, user.Password) {
-		return false
-	}
-	return true
+// NewUserHandler creates a UserHandler
+func NewUserHandler(users *service.UserService, accounts *service.AccountService) *UserHandler {
+	return &UserHandler{users: users, accounts: accounts}
 }
 
 // GetUser get a user
-func GetUser(c *fiber.Ctx) error {
-	id := c.Params("id")
-	db := database.DB
-	var user model.User
-	db.Find(&user, id)
-	if user.Username == "" {
+func (h *UserHandler) GetUser(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No user found with ID", "data": nil})
 	}
-	return c.JSON(fiber.Map{"status": "success", "message": "User found", "data": user})
-}
-
-// GetAllUsers get all users
-func GetAllUsers(c *fiber.Ctx) error {
-	db := database.DB
-	var users []model.User
-	// db.FindInBatches(&users, 10)
-	if err := db.Find(&users).Error; err != nil {
-		log.Fatalf("failed to fetch users: %v", err)
-	}
-	if len(users) == 0 {
-		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No users found", "data": nil})
+	user, err := h.users.Get(c.UserContext(), uint(id))
+	if errors.Is(err, service.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No user found with ID", "data": nil})
+	} else if err != nil {
+		middleware.Logger(c).Error("error fetching user", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error fetching user", "data": nil})
 	}
-	return c.JSON(fiber.Map{"status": "success", "message": "Users found", "data": users})
+	return c.JSON(fiber.Map{"status": "success", "message": "User found", "data": NewPublicUser(*user)})
 }
 
 // CreateUser new user
-func CreateUser(c *fiber.Ctx) error {
-	type NewUser struct {
-		Username string `json:"username" validate:"required,max=50"`
-		Email    string `json:"email" validate:"required,email,max=50"`
-		Password string `json:"password" validate:"required,min=6"`
+func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
+	var input CreateUserRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
 	}
 
-	db := database.DB
-	user := new(model.User)
-	if err := c.BodyParser(user); err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Review your input", "errors": err.Error()})
+	user, err := h.users.Register(c.UserContext(), input.Username, input.Email, input.Password)
+	switch {
+	case errors.Is(err, service.ErrEmailTaken), errors.Is(err, service.ErrUsernameTaken):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": "Couldn't create user", "errors": err.Error()})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Couldn't create user", "errors": err.Error()})
 	}
 
-	validate := validator.New()
-	if err := validate.Struct(user); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body", "errors": err.Error()})
-	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Created user", "data": NewPrivateUser(*user)})
+}
 
-	if len(user.Password) > 72 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body", "errors": "Password too long"})
+// UpdateUser update user
+func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
+	var uui UpdateUserRequest
+	if err := bind(c, &uui); err != nil {
+		return invalid(c, err)
 	}
-
-	hash, err := hashPassword(user.Password)
+	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Couldn't hash password", "errors": err.Error()})
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid user ID", "data": nil})
 	}
-
-	user.Password = hash
-	if err := db.Create(&user).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Couldn't create user", "errors": err.Error()})
+	actorID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Invalid token id", "data": nil})
 	}
 
-	newUser := NewUser{
-		Email:    user.Email,
-		Username: user.Username,
+	user, err := h.users.UpdateUsername(c.UserContext(), actorID, uint(id), uui.Username)
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "error", "message": "Invalid token id", "data": nil})
+	case errors.Is(err, service.ErrUsernameTaken):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": "Username already exists", "data": nil})
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "No user found with ID", "data": nil})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Couldn't update user", "errors": err.Error()})
 	}
 
-	return c.JSON(fiber.Map{
-		"status":  "success",
-		"message": "Created user",
-		"data": fiber.Map{
-			"id":       user.ID,
-			"username": newUser.Username,
-			"email":    newUser.Email,
-		},
-	})
+	return c.JSON(fiber.Map{"status": "success", "message": "User successfully updated", "data": NewPrivateUser(*user)})
 }
 
-// UpdateUser update user
-func UpdateUser(c *fiber.Ctx) error {
-	type UpdateUserInput struct {
-		Username string `json:"username"`
+// DeleteUser deactivates a user; it can be reactivated until anonymized
+func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
+	var pi DeleteUserRequest
+	if err := bind(c, &pi); err != nil {
+		return invalid(c, err)
+	}
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid user ID", "data": nil})
 	}
-	var uui UpdateUserInput
-	if err := c.BodyParser(&uui); err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Review your input", "errors": err.Error()})
+	actorID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Invalid token id", "data": nil})
 	}
-	id := c.Params("id")
-	token := c.Locals("user").(*jwt.Token)
 
-	if !validToken(token, id) {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Invalid token id", "data": nil})
+	err = h.accounts.Deactivate(c.UserContext(), actorID, uint(id), pi.Password)
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "error", "message": "Invalid token id", "data": nil})
+	case errors.Is(err, service.ErrNotFound), errors.Is(err, service.ErrInvalidCredentials):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Not valid user", "data": nil})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Couldn't delete user", "errors": err.Error()})
 	}
+	return c.JSON(fiber.Map{"status": "success", "message": "User successfully deactivated", "data": nil})
+}
 
-	db := database.DB
-	var user model.User
+// GetMe returns the profile of the current user
+func (h *UserHandler) GetMe(c *fiber.Ctx) error {
+	actorID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Invalid token id", "data": nil})
+	}
+	user, err := h.users.Get(c.UserContext(), actorID)
+	if errors.Is(err, service.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "No user found with ID", "data": nil})
+	} else if err != nil {
+		middleware.Logger(c).Error("error fetching user", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error fetching user", "data": nil})
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "User found", "data": NewPrivateUser(*user)})
+}
 
-	db.First(&user, id)
-	user.Username = uui.Username
-	db.Save(&user)
+// UpdateMe changes the profile of the current user
+func (h *UserHandler) UpdateMe(c *fiber.Ctx) error {
+	var input UpdateMeRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+	actorID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Invalid token id", "data": nil})
+	}
 
-	return c.JSON(fiber.Map{"status": "success", "message": "User successfully updated", "data": user})
+	user, err := h.users.UpdateProfile(c.UserContext(), actorID, service.ProfileUpdate{
+		Username:    input.Username,
+		DisplayName: input.DisplayName,
+		AvatarURL:   input.AvatarURL,
+		Bio:         input.Bio,
+	})
+	switch {
+	case errors.Is(err, service.ErrUsernameTaken):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": "Username already exists", "data": nil})
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "No user found with ID", "data": nil})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Couldn't update user", "errors": err.Error()})
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "User successfully updated", "data": NewPrivateUser(*user)})
 }
 
-// DeleteUser delete user
-func DeleteUser(c *fiber.Ctx) error {
-	type PasswordInput struct {
-		Password string `json:"password"`
+// DeleteMe deactivates the current user after confirming their password
+func (h *UserHandler) DeleteMe(c *fiber.Ctx) error {
+	var pi DeleteUserRequest
+	if err := bind(c, &pi); err != nil {
+		return invalid(c, err)
 	}
-	var pi PasswordInput
-	if err := c.BodyParser(&pi); err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Review your input", "errors": err.Error()})
+	actorID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Invalid token id", "data": nil})
 	}
-	id := c.Params("id")
-	token := c.Locals("user").(*jwt.Token)
 
-	if !validToken(token, id) {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Invalid token id", "data": nil})
+	err = h.accounts.Deactivate(c.UserContext(), actorID, actorID, pi.Password)
+	switch {
+	case errors.Is(err, service.ErrNotFound), errors.Is(err, service.ErrInvalidCredentials):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Not valid user", "data": nil})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Couldn't delete user", "errors": err.Error()})
 	}
+	return c.JSON(fiber.Map{"status": "success", "message": "User successfully deactivated", "data": nil})
+}
 
-	if !validUser(id, pi.Password) {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Not valid user", "data": nil})
+// GetProfile returns the public profile of a user by username
+func (h *UserHandler) GetProfile(c *fiber.Ctx) error {
+	profile, err := h.users.Profile(c.UserContext(), c.Params("username"))
+	if errors.Is(err, service.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "No user found with username", "data": nil})
+	} else if err != nil {
+		middleware.Logger(c).Error("error fetching profile", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error fetching profile", "data": nil})
 	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Profile found", "data": NewPublicProfile(*profile)})
+}
 
-	db := database.DB
-	var user model.User
+// ExportMe streams a ZIP archive of everything stored about the current
+// user
+func (h *UserHandler) ExportMe(c *fiber.Ctx) error {
+	actorID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Invalid token id", "data": nil})
+	}
 
-	db.First(&user, id)
+	// Checked up front: once the archive streams, errors can't change the
+	// status any more
+	if _, err := h.users.Get(c.UserContext(), actorID); errors.Is(err, service.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "No user found with ID", "data": nil})
+	} else if err != nil {
+		middleware.Logger(c).Error("error exporting user data", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error exporting user data", "data": nil})
+	}
 
-	db.Delete(&user)
-	return c.JSON(fiber.Map{"status": "success", "message": "User successfully deleted", "data": nil})
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Attachment(fmt.Sprintf("export-%d-%s.zip", actorID, time.Now().UTC().Format("20060102")))
+	ctx, log := c.UserContext(), middleware.Logger(c)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := h.accounts.Export(ctx, actorID, w); err != nil {
+			log.Error("user data export cut short", "error", err)
+		}
+	})
+	return nil
 }
@@ -2,37 +2,47 @@
 package handler_test
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http/httptest"
+	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"app/database"
 	"app/handler"
+	"app/middleware"
 	"app/model"
+	"app/money"
+	"app/repository"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
-func setupTestApp() *fiber.App {
-	database.ConnectDBWithDSN(":memory:")  // uses in-memory SQLite
-	database.DB.AutoMigrate(&model.User{}) // create user table
-	database.DB.Create(&model.User{
+func setupTestApp() (*fiber.App, *gorm.DB) {
+	db := database.ConnectDBWithDSN(":memory:") // fresh in-memory SQLite per test
+	db.Create(&model.User{
 		ID:       1,
 		Username: fmt.Sprintf("testuser_%d", time.Now().UnixNano()),
 		Email:    fmt.Sprintf("testuser_%d@example.com", time.Now().UnixNano()),
 		Password: "hashedpass",
 	})
 
+	h := handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil)
 	app := fiber.New()
-	app.Get("/user/:id", handler.GetUser)
-	return app
+	app.Get("/user/:id", h.User.GetUser)
+	return app, db
 }
 
 func TestGetUser_Success(t *testing.T) {
-	app := setupTestApp()
+	app, db := setupTestApp()
 
 	// Seed user
 	user := model.User{
@@ -40,7 +50,7 @@ func TestGetUser_Success(t *testing.T) {
 		Email:    fmt.Sprintf("testuser_%d@example.com", time.Now().UnixNano()),
 		Password: "secretpass",
 	}
-	database.DB.Create(&user)
+	db.Create(&user)
 
 	// Send request
 	req := httptest.NewRequest("GET", "/user/"+strconv.Itoa(int(user.ID)), nil)
@@ -51,7 +61,7 @@ func TestGetUser_Success(t *testing.T) {
 }
 
 func TestGetUser_NotFound(t *testing.T) {
-	app := setupTestApp()
+	app, _ := setupTestApp()
 
 	req := httptest.NewRequest("GET", "/user/9999", nil)
 	resp, err := app.Test(req)
@@ -59,3 +69,123 @@ func TestGetUser_NotFound(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 404, resp.StatusCode)
 }
+
+func TestCreateUser_SameRulesAsRegister(t *testing.T) {
+	app, db := setupTestApp()
+	h := handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil)
+	app.Post("/user", h.User.CreateUser)
+	email := strings.Repeat("a", 60) + "@example.com"
+
+	for body, status := range map[string]int{
+		`{"username":"shortpw","email":"s@example.com","password":"secret1"}`:           400,
+		fmt.Sprintf(`{"username":"longmail","email":%q,"password":"password1"}`, email): 200,
+	} {
+		req := httptest.NewRequest("POST", "/user", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, status, resp.StatusCode, body)
+	}
+}
+
+func setupMeApp() (*fiber.App, *gorm.DB) {
+	os.Setenv("SECRET", "testsecret")
+	db := database.ConnectDBWithDSN(":memory:")
+	h := handler.New(repository.New(db), testBlobs(), testRates(), testPayments(), nil)
+
+	app := fiber.New()
+	app.Get("/user/me", middleware.Protected(), h.User.GetMe)
+	app.Patch("/user/me", middleware.Protected(), h.User.UpdateMe)
+	app.Get("/user/me/export", middleware.Protected(), h.User.ExportMe)
+	app.Get("/user/profile/:username", h.User.GetProfile)
+	return app, db
+}
+
+func TestMe_GetAndUpdate(t *testing.T) {
+	app, db := setupMeApp()
+	db.Create(&model.User{ID: 1, Username: "me", Email: "me@example.com", Password: "x"})
+
+	req := httptest.NewRequest("PATCH", "/user/me", strings.NewReader(`{"display_name":"Me Myself","bio":"Collector"}`))
+	req.Header.Set("Authorization", authHeader())
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	req = httptest.NewRequest("GET", "/user/me", nil)
+	req.Header.Set("Authorization", authHeader())
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var out struct {
+		Data handler.PrivateUser `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.Equal(t, "me@example.com", out.Data.Email)
+	assert.Equal(t, "Me Myself", out.Data.DisplayName)
+	assert.Equal(t, "Collector", out.Data.Bio)
+}
+
+func TestGetProfile_SellerStats(t *testing.T) {
+	app, db := setupMeApp()
+	db.Create(&model.User{ID: 1, Username: "seller", Email: "s@example.com", Password: "x", DisplayName: "Shop"})
+	db.Create(&model.User{ID: 2, Username: "buyer", Email: "b@example.com", Password: "x"})
+	db.Create(&model.Item{ID: 1, Name: "A", Description: "a", UserID: 1, Reviews: []model.Review{{UserID: 2, Rating: 5, Comment: "great"}}})
+	db.Create(&model.Item{ID: 2, Name: "B", Description: "b", UserID: 1, Reviews: []model.Review{{UserID: 2, Rating: 4, Comment: "good"}}})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/user/profile/seller", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var out struct {
+		Data handler.PublicProfile `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.Equal(t, "Shop", out.Data.DisplayName)
+	assert.Equal(t, int64(2), out.Data.ListingCount)
+	assert.Equal(t, 4.5, out.Data.AverageRating)
+	assert.Equal(t, int64(2), out.Data.ReviewCount)
+	assert.False(t, out.Data.JoinedAt.IsZero())
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/user/profile/nobody", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestExportMe_Zip(t *testing.T) {
+	app, db := setupMeApp()
+	db.Create(&model.User{ID: 1, Username: "me", Email: "me@example.com", Password: "$2a$hash"})
+	db.Create(&model.User{ID: 2, Username: "other", Email: "o@example.com", Password: "x"})
+	db.Create(&model.Item{ID: 1, Name: "Lamp", Description: "old", UserID: 1})
+	db.Create(&model.Item{ID: 2, Name: "Chair", Description: "new", UserID: 2})
+	db.Create(&model.Order{ItemID: 2, UserID: 1, Quantity: 1, TotalPrice: money.New(1000, "EUR")})
+
+	req := httptest.NewRequest("GET", "/user/me/export", nil)
+	req.Header.Set("Authorization", authHeader())
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "application/zip", resp.Header.Get("Content-Type"))
+
+	body, _ := io.ReadAll(resp.Body)
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	assert.NoError(t, err)
+
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	for _, name := range []string{"profile.json", "items.json", "orders.json", "reviews.json", "comments.json", "likes.json", "messages.json"} {
+		assert.Contains(t, files, name)
+	}
+	assert.Contains(t, string(files["profile.json"]), "me@example.com")
+	assert.NotContains(t, string(files["profile.json"]), "$2a$hash")
+	assert.Contains(t, string(files["items.json"]), "Lamp")
+	assert.NotContains(t, string(files["items.json"]), "Chair")
+	assert.Contains(t, string(files["orders.json"]), `"item_id": 2`)
+	assert.Equal(t, "[]\n", string(files["likes.json"]))
+}
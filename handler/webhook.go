@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"app/middleware"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultDeliveryPage is how many deliveries are listed without ?limit=
+const defaultDeliveryPage = 20
+
+// maxDeliveryPage caps ?limit= on the delivery log
+const maxDeliveryPage = 100
+
+// WebhookHandler manages the webhook subscriptions of the current user
+type WebhookHandler struct {
+	webhooks *service.WebhookService
+}
+
+// NewWebhookHandler creates a WebhookHandler
+func NewWebhookHandler(webhooks *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhooks: webhooks}
+}
+
+// ListWebhooks lists the current user's subscriptions
+func (h *WebhookHandler) ListWebhooks(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	subs, err := h.webhooks.List(c.UserContext(), userID)
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch webhooks")
+	}
+	return c.JSON(NewWebhookResponses(subs))
+}
+
+// CreateWebhook subscribes the current user to events of their shop. The
+// response is the only one showing the signing secret.
+func (h *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input CreateWebhookRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	sub, err := h.webhooks.Create(c.UserContext(), userID, input.URL, input.Events, input.Secret)
+	if err != nil {
+		return h.fail(c, err, "Failed to create webhook")
+	}
+	out := NewWebhookResponse(*sub)
+	out.Secret = sub.Secret
+	return c.Status(fiber.StatusCreated).JSON(out)
+}
+
+// UpdateWebhook changes the URL or events of a subscription, or turns it
+// on or off
+func (h *WebhookHandler) UpdateWebhook(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid webhook ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var input UpdateWebhookRequest
+	if err := bind(c, &input); err != nil {
+		return invalid(c, err)
+	}
+
+	sub, err := h.webhooks.Update(c.UserContext(), userID, uint(id), service.WebhookUpdate{
+		URL: input.URL, Events: input.Events, Active: input.Active,
+	})
+	if err != nil {
+		return h.fail(c, err, "Failed to update webhook")
+	}
+	return c.JSON(NewWebhookResponse(*sub))
+}
+
+// DeleteWebhook removes a subscription
+func (h *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid webhook ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.webhooks.Delete(c.UserContext(), userID, uint(id)); err != nil {
+		return h.fail(c, err, "Failed to delete webhook")
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Webhook deleted"})
+}
+
+// GetDeliveries lists the latest delivery attempts of a subscription
+func (h *WebhookHandler) GetDeliveries(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid webhook ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	limit := c.QueryInt("limit", defaultDeliveryPage)
+	if limit < 1 || limit > maxDeliveryPage {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid limit"})
+	}
+
+	deliveries, err := h.webhooks.Deliveries(c.UserContext(), userID, uint(id), limit)
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch deliveries")
+	}
+	out := make([]WebhookDeliveryResponse, len(deliveries))
+	for i, d := range deliveries {
+		out[i] = NewWebhookDeliveryResponse(d)
+	}
+	return c.JSON(out)
+}
+
+// TestWebhook sends a ping event to a subscription and reports how the
+// endpoint answered
+func (h *WebhookHandler) TestWebhook(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid webhook ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	delivery, err := h.webhooks.Test(c.UserContext(), userID, uint(id))
+	if err != nil {
+		return h.fail(c, err, "Failed to send test event")
+	}
+	return c.JSON(NewWebhookDeliveryResponse(*delivery))
+}
+
+func (h *WebhookHandler) fail(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Webhook not found"})
+	case errors.Is(err, service.ErrInvalidWebhook):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrTooManyWebhooks):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		middleware.Logger(c).Error(message, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
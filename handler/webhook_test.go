@@ -0,0 +1,147 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"app/handler"
+	"app/jobs"
+	"app/model"
+	"app/payment"
+	"app/repository"
+	"app/router"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is an integrator endpoint recording what it was sent
+type receiver struct {
+	mu     sync.Mutex
+	status int
+	got    []*http.Request
+	bodies [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.got = append(r.got, req)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+}
+
+func (r *receiver) events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for _, req := range r.got {
+		out = append(out, req.Header.Get(service.WebhookEventHeader))
+	}
+	return out
+}
+
+func TestWebhooks_DeliverSignedEvents(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE", "true")
+	db := setupInventoryDB()
+	repos := repository.New(db)
+	app := fiber.New()
+	router.SetupRoutes(app, handler.New(repos, testBlobs(), testRates(), testPayments(), nil))
+	runner := jobs.NewRunner(repos.Jobs, "test")
+	runner.Register(service.JobDeliverWebhook, service.NewWebhookService(repos.Webhooks, repos.Jobs, service.NewWebhookClient(true)).DeliverJob)
+	runJobs := func() {
+		_, err := runner.RunOnce(context.Background(), time.Now())
+		require.NoError(t, err)
+	}
+
+	endpoint := &receiver{status: http.StatusOK}
+	srv := httptest.NewServer(endpoint)
+	defer srv.Close()
+
+	resp := sendAs(t, app, 2, "POST", "/api/user/me/webhooks", `{"url":"`+srv.URL+`","events":["order.created","bogus"]}`)
+	assert.Equal(t, 400, resp.StatusCode)
+	resp = sendAs(t, app, 2, "POST", "/api/user/me/webhooks", `{"url":"`+srv.URL+`","events":["order.created","item.updated"]}`)
+	require.Equal(t, 201, resp.StatusCode)
+	var sub handler.WebhookResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&sub))
+	assert.NotEmpty(t, sub.Secret)
+	assert.True(t, sub.Active)
+	base := fmt.Sprintf("/api/user/me/webhooks/%d", sub.ID)
+
+	// Only the owner sees it
+	resp = sendAs(t, app, 1, "POST", base+"/test", "")
+	assert.Equal(t, 404, resp.StatusCode)
+
+	resp = sendAs(t, app, 2, "POST", base+"/test", "")
+	require.Equal(t, 200, resp.StatusCode)
+	var ping handler.WebhookDeliveryResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&ping))
+	assert.Equal(t, 200, ping.StatusCode)
+	assert.Equal(t, []string{model.WebhookPing}, endpoint.events())
+
+	// An order is delivered once the worker runs, signed with the secret
+	resp = sendAs(t, app, 1, "POST", "/api/orders", `{"item_id":1,"quantity":1}`)
+	require.Equal(t, 201, resp.StatusCode)
+	assert.Len(t, endpoint.events(), 1, "nothing is sent inline")
+	runJobs()
+	assert.ElementsMatch(t, []string{model.WebhookPing, model.WebhookOrderCreated, model.WebhookItemUpdated}, endpoint.events())
+	for i, req := range endpoint.got {
+		assert.NoError(t, payment.Verify(endpoint.bodies[i], req.Header.Get(service.WebhookSignatureHeader), []byte(sub.Secret), time.Now()))
+		var body struct {
+			ID   string          `json:"id"`
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(endpoint.bodies[i], &body))
+		assert.Equal(t, req.Header.Get(service.WebhookIDHeader), body.ID)
+		if body.Type == model.WebhookOrderCreated {
+			assert.JSONEq(t, `1`, string(mustField(t, body.Data, "quantity")))
+			assert.JSONEq(t, `1`, string(mustField(t, mustField(t, body.Data, "item"), "id")))
+		}
+	}
+
+	// Failures are logged with the response code and retried
+	endpoint.status = http.StatusInternalServerError
+	resp = sendAs(t, app, 2, "PATCH", "/api/items/1", `{"name":"Lamp v2"}`)
+	require.Equal(t, 200, resp.StatusCode)
+	runJobs()
+	resp = sendAs(t, app, 2, "GET", base+"/deliveries?limit=1", "")
+	require.Equal(t, 200, resp.StatusCode)
+	var deliveries []handler.WebhookDeliveryResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&deliveries))
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, model.WebhookItemUpdated, deliveries[0].Event)
+	assert.Equal(t, 500, deliveries[0].StatusCode)
+	assert.Equal(t, 1, deliveries[0].Attempt)
+	pending, err := repos.Jobs.List(context.Background(), model.JobPending, 10)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	resp = sendAs(t, app, 2, "GET", "/api/user/me/webhooks/", "")
+	var subs []handler.WebhookResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&subs))
+	require.Len(t, subs, 1)
+	assert.Equal(t, 1, subs[0].Failures)
+	assert.Empty(t, subs[0].Secret)
+
+	resp = sendAs(t, app, 2, "DELETE", base, "")
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+func mustField(t *testing.T, raw json.RawMessage, name string) json.RawMessage {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(raw, &fields))
+	require.Contains(t, fields, name)
+	return fields[name]
+}
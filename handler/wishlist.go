@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"app/middleware"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WishlistHandler serves the current user's wishlist
+type WishlistHandler struct {
+	wishlists *service.WishlistService
+}
+
+// NewWishlistHandler creates a WishlistHandler
+func NewWishlistHandler(wishlists *service.WishlistService) *WishlistHandler {
+	return &WishlistHandler{wishlists: wishlists}
+}
+
+// GetWishlist lists the saved items, newest first
+func (h *WishlistHandler) GetWishlist(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	entries, err := h.wishlists.List(c.UserContext(), userID)
+	if err != nil {
+		return h.fail(c, err, "Failed to fetch wishlist")
+	}
+	return c.JSON(NewWishlist(entries))
+}
+
+// AddToWishlist saves an item; saving it again changes nothing
+func (h *WishlistHandler) AddToWishlist(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.wishlists.Add(c.UserContext(), userID, uint(itemID)); err != nil {
+		return h.fail(c, err, "Failed to save item")
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Item saved"})
+}
+
+// RemoveFromWishlist takes an item off the wishlist
+func (h *WishlistHandler) RemoveFromWishlist(c *fiber.Ctx) error {
+	itemID, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid item ID"})
+	}
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.wishlists.Remove(c.UserContext(), userID, uint(itemID)); err != nil {
+		return h.fail(c, err, "Failed to remove item")
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Item removed"})
+}
+
+func (h *WishlistHandler) fail(c *fiber.Ctx, err error, message string) error {
+	if errors.Is(err, service.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	}
+	middleware.Logger(c).Error(message, "error", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+}
@@ -0,0 +1,40 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"app/handler"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWishlist_SaveListRemove(t *testing.T) {
+	app := setupInventoryApp()
+
+	assert.Equal(t, 200, send(t, app, "PUT", "/api/wishlist/1", "").StatusCode)
+	assert.Equal(t, 200, send(t, app, "PUT", "/api/wishlist/1", "").StatusCode)
+	assert.Equal(t, 200, send(t, app, "PUT", "/api/wishlist/3", "").StatusCode)
+	assert.Equal(t, 404, send(t, app, "PUT", "/api/wishlist/9", "").StatusCode)
+	assert.Equal(t, 400, send(t, app, "PUT", "/api/wishlist/lamp", "").StatusCode)
+	assert.Equal(t, 400, guest(t, app, "GET", "/api/wishlist", "", nil).StatusCode, "missing token")
+
+	require.Equal(t, 200, send(t, app, "DELETE", "/api/items/3", "").StatusCode)
+
+	resp := send(t, app, "GET", "/api/wishlist", "")
+	require.Equal(t, 200, resp.StatusCode)
+	var entries []handler.WishlistEntry
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&entries))
+	require.Len(t, entries, 2)
+	byID := map[uint]handler.WishlistEntry{}
+	for _, e := range entries {
+		byID[e.Item.ID] = e
+	}
+	assert.True(t, byID[1].Item.Available)
+	assert.True(t, byID[3].Deleted)
+	assert.False(t, byID[3].Item.Available)
+
+	assert.Equal(t, 200, send(t, app, "DELETE", "/api/wishlist/1", "").StatusCode)
+	assert.Equal(t, 404, send(t, app, "DELETE", "/api/wishlist/1", "").StatusCode)
+}
@@ -0,0 +1,148 @@
+// Package imaging decodes uploaded pictures, normalises their orientation
+// and produces re-encoded copies and thumbnails. Re-encoding drops every
+// metadata block, EXIF included.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// MaxPixels bounds the decoded size of an image so a small upload can't
+// expand into gigabytes of memory
+const MaxPixels = 40_000_000
+
+// Errors returned by Decode
+var (
+	ErrUnsupported = errors.New("unsupported image type")
+	ErrTooLarge    = errors.New("image dimensions too large")
+)
+
+// Decode sniffs the type of data, decodes it and applies its EXIF
+// orientation. It returns the content type copies should be encoded as:
+// JPEG stays JPEG, PNG and GIF become PNG.
+func Decode(data []byte) (*image.RGBA, string, error) {
+	var decode func(io.Reader) (image.Image, error)
+	var decodeConfig func(io.Reader) (image.Config, error)
+	out := "image/png"
+	switch mimetype.Detect(data).String() {
+	case "image/jpeg":
+		decode, decodeConfig, out = jpeg.Decode, jpeg.DecodeConfig, "image/jpeg"
+	case "image/png":
+		decode, decodeConfig = png.Decode, png.DecodeConfig
+	case "image/gif":
+		decode, decodeConfig = gif.Decode, gif.DecodeConfig
+	default:
+		return nil, "", ErrUnsupported
+	}
+
+	cfg, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupported
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, "", ErrTooLarge
+	}
+	img, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupported
+	}
+
+	rgba := toRGBA(img)
+	if out == "image/jpeg" {
+		rgba = orient(rgba, exifOrientation(data))
+	}
+	return rgba, out, nil
+}
+
+// Encode writes img to w as contentType
+func Encode(w io.Writer, img image.Image, contentType string) error {
+	if contentType == "image/jpeg" {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	}
+	return png.Encode(w, img)
+}
+
+// Ext returns the file extension for a content type produced by Decode
+func Ext(contentType string) string {
+	if contentType == "image/jpeg" {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+// Fit scales img down, keeping its aspect ratio, so neither side exceeds
+// max. Smaller images are returned unchanged.
+func Fit(img *image.RGBA, max int) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return img
+	}
+	nw, nh := max, h*max/w
+	if h > w {
+		nw, nh = w*max/h, max
+	}
+	return resize(img, max1(nw), max1(nh))
+}
+
+// resize scales src to w x h by averaging the source pixels that fall in
+// each destination pixel, which is exact enough for downscaling
+func resize(src *image.RGBA, w, h int) *image.RGBA {
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, (y+1)*sh/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, (x+1)*sw/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				off := sy*src.Stride + x0*4
+				for sx := x0; sx < x1; sx++ {
+					p := src.Pix[off : off+4 : off+4]
+					r += uint32(p[0])
+					g += uint32(p[1])
+					bl += uint32(p[2])
+					a += uint32(p[3])
+					n++
+					off += 4
+				}
+			}
+			d := dst.PixOffset(x, y)
+			dst.Pix[d+0] = uint8(r / n)
+			dst.Pix[d+1] = uint8(g / n)
+			dst.Pix[d+2] = uint8(bl / n)
+			dst.Pix[d+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
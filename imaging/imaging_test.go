@@ -0,0 +1,98 @@
+package imaging_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"app/imaging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withOrientation inserts an EXIF APP1 segment carrying orientation right
+// after the JPEG start-of-image marker
+func withOrientation(t *testing.T, jpg []byte, orientation uint16) []byte {
+	t.Helper()
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	seg := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(jpg[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(seg)+2))
+	out.Write(seg)
+	out.Write(jpg[2:])
+	return out.Bytes()
+}
+
+func encodeJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+func TestDecode_AppliesOrientationAndStripsExif(t *testing.T) {
+	data := withOrientation(t, encodeJPEG(t, 40, 20), 6)
+	assert.Contains(t, string(data), "Exif")
+
+	img, ct, err := imaging.Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", ct)
+	assert.Equal(t, 20, img.Bounds().Dx(), "rotated a quarter turn")
+	assert.Equal(t, 40, img.Bounds().Dy())
+
+	var out bytes.Buffer
+	require.NoError(t, imaging.Encode(&out, img, ct))
+	assert.NotContains(t, out.String(), "Exif")
+}
+
+func TestDecode_RejectsUnsupported(t *testing.T) {
+	_, _, err := imaging.Decode([]byte("GIF87a"))
+	assert.ErrorIs(t, err, imaging.ErrUnsupported)
+	_, _, err = imaging.Decode([]byte("<svg xmlns='http://www.w3.org/2000/svg'/>"))
+	assert.ErrorIs(t, err, imaging.ErrUnsupported)
+}
+
+func TestDecode_RejectsHugeDimensions(t *testing.T) {
+	// A PNG header claiming 10000x10000 pixels
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))))
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 10000)
+	binary.BigEndian.PutUint32(data[20:], 10000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	_, _, err := imaging.Decode(data)
+	assert.ErrorIs(t, err, imaging.ErrTooLarge)
+}
+
+func TestFit(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 1200, 600))
+	out := imaging.Fit(img, 300)
+	assert.Equal(t, image.Rect(0, 0, 300, 150), out.Bounds())
+
+	small := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	assert.Same(t, small, imaging.Fit(small, 300))
+}
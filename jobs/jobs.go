@@ -0,0 +1,223 @@
+// Package jobs runs background work queued in the jobs table. Services
+// enqueue jobs inside their transactions; workers claim and run them,
+// retrying failures with exponential backoff until they run out of
+// attempts and are marked dead.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"app/logging"
+	"app/model"
+	"app/repository"
+)
+
+// DefaultMaxAttempts is how many times a job runs before it is marked dead
+const DefaultMaxAttempts = 8
+
+// Handler runs a job given its payload. A returned error fails the
+// attempt; the job is retried later.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+// Option changes a job built by New
+type Option func(*model.Job)
+
+// At delays a job until t
+func At(t time.Time) Option {
+	return func(j *model.Job) { j.RunAt = t }
+}
+
+// Unique drops the job if another with the same key was ever enqueued
+// and not pruned yet
+func Unique(key string) Option {
+	return func(j *model.Job) { j.UniqueKey = &key }
+}
+
+// MaxAttempts overrides DefaultMaxAttempts
+func MaxAttempts(n int) Option {
+	return func(j *model.Job) { j.MaxAttempts = n }
+}
+
+// New builds a job of kind carrying payload as JSON, due right away
+func New(kind string, payload any, opts ...Option) (*model.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	job := &model.Job{
+		Kind:        kind,
+		Payload:     string(data),
+		Status:      model.JobPending,
+		RunAt:       time.Now(),
+		MaxAttempts: DefaultMaxAttempts,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	return job, nil
+}
+
+// Backoff is how long a job waits after its attempt-th failure: 10s,
+// doubling each time, at most an hour
+func Backoff(attempt int) time.Duration {
+	const base, limit = 10 * time.Second, time.Hour
+	d := base
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	return min(d, limit)
+}
+
+// schedule enqueues a job of kind once per interval
+type schedule struct {
+	kind     string
+	interval time.Duration
+	// last is the latest slot this runner enqueued
+	last time.Time
+}
+
+// Runner claims due jobs and runs their handlers. Several runners, in one
+// process or many, can share a queue.
+type Runner struct {
+	jobs      repository.JobRepository
+	worker    string
+	handlers  map[string]Handler
+	schedules []*schedule
+
+	// Batch is how many jobs one poll runs
+	Batch int
+	// PollInterval is how long Run sleeps when the queue is empty
+	PollInterval time.Duration
+	// Timeout bounds a single attempt. A job running longer than that is
+	// presumed lost with its worker and handed to another.
+	Timeout time.Duration
+}
+
+// NewRunner creates a Runner claiming jobs under the name worker
+func NewRunner(jobs repository.JobRepository, worker string) *Runner {
+	return &Runner{
+		jobs:         jobs,
+		worker:       worker,
+		handlers:     map[string]Handler{},
+		Batch:        10,
+		PollInterval: time.Second,
+		Timeout:      5 * time.Minute,
+	}
+}
+
+// Register sets the handler of jobs of kind
+func (r *Runner) Register(kind string, h Handler) {
+	r.handlers[kind] = h
+}
+
+// Every enqueues a job of kind, with no payload, at the start of every
+// interval, counted from the Unix epoch. However many runners share the
+// schedule, each interval gets one job.
+func (r *Runner) Every(kind string, interval time.Duration) {
+	r.schedules = append(r.schedules, &schedule{kind: kind, interval: interval})
+}
+
+// Run polls the queue until ctx is done
+func (r *Runner) Run(ctx context.Context) {
+	log := logging.FromContext(ctx)
+	log.Info("worker started", "worker", r.worker)
+	for {
+		n, err := r.RunOnce(ctx, time.Now())
+		if err != nil {
+			log.Error("failed to poll jobs", "error", err)
+		}
+		if n > 0 && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			log.Info("worker stopped", "worker", r.worker)
+			return
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+// RunOnce enqueues scheduled jobs due by now, releases jobs whose worker
+// timed out, then claims and runs up to Batch jobs. It returns how many
+// jobs it ran.
+func (r *Runner) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	for _, s := range r.schedules {
+		slot := now.Truncate(s.interval)
+		if slot.Equal(s.last) {
+			continue
+		}
+		job, err := New(s.kind, nil, At(slot), Unique(fmt.Sprintf("%s@%s", s.kind, slot.UTC().Format(time.RFC3339))))
+		if err != nil {
+			return 0, err
+		}
+		if err := r.jobs.Enqueue(ctx, job); err != nil {
+			return 0, err
+		}
+		s.last = slot
+	}
+	if n, err := r.jobs.Requeue(ctx, now.Add(-r.Timeout)); err != nil {
+		return 0, err
+	} else if n > 0 {
+		logging.FromContext(ctx).Warn("released timed out jobs", "count", n)
+	}
+
+	// Jobs are claimed one at a time as they start, so a lock only times
+	// out Timeout after its own job started, not after the poll did
+	began := time.Now()
+	ran := 0
+	for ran < r.Batch {
+		at := now.Add(time.Since(began))
+		claimed, err := r.jobs.Claim(ctx, r.worker, at, 1)
+		if err != nil {
+			return ran, err
+		}
+		if len(claimed) == 0 {
+			break
+		}
+		job := claimed[0]
+		ran++
+		if err := r.finish(ctx, job, at, r.run(ctx, job)); err != nil {
+			logging.FromContext(ctx).Error("failed to record job outcome", "job", job.ID, "kind", job.Kind, "error", err)
+		}
+	}
+	return ran, nil
+}
+
+// run calls the handler of job, turning a panic into an error
+func (r *Runner) run(ctx context.Context, job model.Job) (err error) {
+	h, ok := r.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("no handler for job kind %q", job.Kind)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v\n%s", p, debug.Stack())
+		}
+	}()
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+	return h(ctx, json.RawMessage(job.Payload))
+}
+
+// finish records the outcome of an attempt. It fails with
+// repository.ErrNotFound when the job's lock was released meanwhile, its
+// outcome then being another worker's to record.
+func (r *Runner) finish(ctx context.Context, job model.Job, now time.Time, failure error) error {
+	log := logging.FromContext(ctx).With("job", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+	switch {
+	case failure == nil:
+		return r.jobs.Complete(ctx, job.ID, r.worker)
+	case job.Attempts >= job.MaxAttempts:
+		log.Error("job failed for good", "error", failure)
+		return r.jobs.Bury(ctx, job.ID, r.worker, failure.Error())
+	default:
+		retry := now.Add(Backoff(job.Attempts))
+		log.Warn("job failed, will retry", "error", failure, "retry_at", retry)
+		return r.jobs.Retry(ctx, job.ID, r.worker, retry, failure.Error())
+	}
+}
@@ -0,0 +1,173 @@
+package jobs_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"app/database"
+	"app/jobs"
+	"app/model"
+	"app/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRunner(t *testing.T) (*jobs.Runner, repository.JobRepository) {
+	repos := repository.New(database.ConnectDBWithDSN(":memory:"))
+	return jobs.NewRunner(repos.Jobs, "test"), repos.Jobs
+}
+
+func listed(t *testing.T, queue repository.JobRepository, status string) []model.Job {
+	t.Helper()
+	out, err := queue.List(context.Background(), status, 100)
+	require.NoError(t, err)
+	return out
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 10*time.Second, jobs.Backoff(1))
+	assert.Equal(t, 20*time.Second, jobs.Backoff(2))
+	assert.Equal(t, 80*time.Second, jobs.Backoff(4))
+	assert.Equal(t, time.Hour, jobs.Backoff(20))
+}
+
+func TestRunner_RetriesThenBuries(t *testing.T) {
+	runner, queue := setupRunner(t)
+	ctx := context.Background()
+
+	var got []string
+	runner.Register("echo", func(_ context.Context, payload json.RawMessage) error {
+		var s string
+		require.NoError(t, json.Unmarshal(payload, &s))
+		got = append(got, s)
+		return nil
+	})
+	runner.Register("flaky", func(context.Context, json.RawMessage) error {
+		return errors.New("boom")
+	})
+
+	echo, err := jobs.New("echo", "hi")
+	require.NoError(t, err)
+	later, err := jobs.New("echo", "later", jobs.At(time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+	flaky, err := jobs.New("flaky", nil, jobs.MaxAttempts(2))
+	require.NoError(t, err)
+	require.NoError(t, queue.Enqueue(ctx, echo, later, flaky))
+	now := time.Now()
+
+	n, err := runner.RunOnce(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n, "the delayed job is not due")
+	assert.Equal(t, []string{"hi"}, got)
+	assert.Len(t, listed(t, queue, model.JobDone), 1)
+
+	pending := listed(t, queue, model.JobPending)
+	require.Len(t, pending, 2)
+	retry := pending[1]
+	assert.Equal(t, "boom", retry.LastError)
+	assert.Equal(t, 1, retry.Attempts)
+	assert.WithinDuration(t, now.Add(jobs.Backoff(1)), retry.RunAt, time.Second)
+
+	// Nothing is due until the backoff has passed
+	n, err = runner.RunOnce(ctx, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	_, err = runner.RunOnce(ctx, now.Add(time.Minute))
+	require.NoError(t, err)
+	dead := listed(t, queue, model.JobDead)
+	require.Len(t, dead, 1)
+	assert.Equal(t, 2, dead[0].Attempts)
+}
+
+func TestRunner_SurvivesPanicsAndUnknownKinds(t *testing.T) {
+	runner, queue := setupRunner(t)
+	ctx := context.Background()
+	runner.Register("panics", func(context.Context, json.RawMessage) error { panic("oops") })
+
+	for _, kind := range []string{"panics", "unknown"} {
+		job, err := jobs.New(kind, nil, jobs.MaxAttempts(1))
+		require.NoError(t, err)
+		require.NoError(t, queue.Enqueue(ctx, job))
+	}
+	_, err := runner.RunOnce(ctx, time.Now())
+	require.NoError(t, err)
+	dead := listed(t, queue, model.JobDead)
+	require.Len(t, dead, 2)
+	assert.Contains(t, dead[0].LastError, "panic: oops")
+	assert.Contains(t, dead[1].LastError, "no handler")
+}
+
+func TestRunner_Schedules(t *testing.T) {
+	runner, queue := setupRunner(t)
+	ctx := context.Background()
+	runs := 0
+	runner.Register("tick", func(context.Context, json.RawMessage) error { runs++; return nil })
+	runner.Every("tick", time.Hour)
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	for _, at := range []time.Time{start.Add(time.Minute), start.Add(30 * time.Minute), start.Add(90 * time.Minute)} {
+		_, err := runner.RunOnce(ctx, at)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, runs, "once per interval")
+
+	// Another runner on the same schedule doesn't enqueue the slot again
+	other := jobs.NewRunner(queue, "other")
+	other.Every("tick", time.Hour)
+	_, err := other.RunOnce(ctx, start.Add(100*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, listed(t, queue, model.JobPending))
+	assert.Len(t, listed(t, queue, model.JobDone), 2)
+}
+
+func TestRunner_RequeuesTimedOutJobs(t *testing.T) {
+	runner, queue := setupRunner(t)
+	ctx := context.Background()
+	job, err := jobs.New("slow", nil)
+	require.NoError(t, err)
+	require.NoError(t, queue.Enqueue(ctx, job))
+	now := time.Now()
+	claimed, err := queue.Claim(ctx, "crashed", now, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+
+	runner.Register("slow", func(context.Context, json.RawMessage) error { return nil })
+	n, err := runner.RunOnce(ctx, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, n, "the lock hasn't timed out yet")
+	n, err = runner.RunOnce(ctx, now.Add(runner.Timeout+time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	done := listed(t, queue, model.JobDone)
+	require.Len(t, done, 1)
+	assert.Equal(t, 2, done[0].Attempts)
+
+	// The crashed worker coming back can't overwrite the outcome
+	err = queue.Bury(ctx, job.ID, "crashed", "late failure")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.Len(t, listed(t, queue, model.JobDone), 1)
+}
+
+func TestRunner_ClaimsJobsAsTheyStart(t *testing.T) {
+	runner, queue := setupRunner(t)
+	ctx := context.Background()
+	var waiting []int
+	runner.Register("count", func(context.Context, json.RawMessage) error {
+		waiting = append(waiting, len(listed(t, queue, model.JobPending)))
+		return nil
+	})
+	for range 3 {
+		job, err := jobs.New("count", nil)
+		require.NoError(t, err)
+		require.NoError(t, queue.Enqueue(ctx, job))
+	}
+
+	n, err := runner.RunOnce(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []int{2, 1, 0}, waiting, "later jobs aren't locked while earlier ones run")
+}
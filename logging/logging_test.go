@@ -0,0 +1,59 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"app/logging"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestNew_RedactsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	log := logging.New(&buf, "info", "json")
+
+	log.Info("login", "username", "alice", "password", "hunter22", "refresh_token", "abc")
+
+	var line map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "alice", line["username"])
+	assert.Equal(t, "[REDACTED]", line["password"])
+	assert.Equal(t, "[REDACTED]", line["refresh_token"])
+	assert.NotContains(t, buf.String(), "hunter22")
+}
+
+func TestNew_Level(t *testing.T) {
+	var buf bytes.Buffer
+	log := logging.New(&buf, "warn", "text")
+
+	log.Info("hidden")
+	log.Warn("shown")
+
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "shown")
+}
+
+func TestGormLogger_OmitsQueryParameters(t *testing.T) {
+	type account struct {
+		ID       uint
+		Password string
+	}
+
+	var buf bytes.Buffer
+	ctx := logging.WithContext(context.Background(), logging.New(&buf, "debug", "json"))
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logging.NewGormLogger().LogMode(gormlogger.Info)})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&account{}))
+
+	db.WithContext(ctx).Create(&account{Password: "$2a$10$supersecrethash"})
+
+	assert.Contains(t, buf.String(), "INSERT INTO")
+	assert.NotContains(t, buf.String(), "supersecrethash")
+}
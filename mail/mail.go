@@ -0,0 +1,88 @@
+// Package mail sends plain-text emails
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"app/config"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTP sends emails through an SMTP server
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP creates an SMTP mailer sending from from through host:port,
+// authenticating when user is set
+func NewSMTP(host string, port int, user, password, from string) *SMTP {
+	s := &SMTP{addr: net.JoinHostPort(host, strconv.Itoa(port)), from: from}
+	if user != "" {
+		s.auth = smtp.PlainAuth("", user, password, host)
+	}
+	return s
+}
+
+// Send implements Mailer
+func (s *SMTP) Send(_ context.Context, msg Message) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\nTo: %s\r\nSubject: %s\r\n", s.from, msg.To, msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(msg.Body)
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, []byte(b.String()))
+}
+
+// FromConfig returns an SMTP mailer when SMTP_HOST is set, and nil
+// otherwise, in which case no emails are sent
+func FromConfig() (Mailer, error) {
+	host := config.Config("SMTP_HOST")
+	if host == "" {
+		return nil, nil
+	}
+	from := config.Config("SMTP_FROM")
+	if from == "" {
+		return nil, fmt.Errorf("SMTP_FROM is required with SMTP_HOST")
+	}
+	port := config.Int("SMTP_PORT", 587)
+	return NewSMTP(host, port, config.Config("SMTP_USER"), config.Config("SMTP_PASSWORD"), from), nil
+}
+
+// Fake records emails instead of sending them, for tests
+type Fake struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+// Send implements Mailer
+func (f *Fake) Send(_ context.Context, msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+// Sent returns the emails sent so far
+func (f *Fake) Sent() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.sent...)
+}
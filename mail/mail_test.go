@@ -0,0 +1,27 @@
+package mail_test
+
+import (
+	"testing"
+
+	"app/mail"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromConfig(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+	m, err := mail.FromConfig()
+	require.NoError(t, err)
+	assert.Nil(t, m, "email is off without SMTP_HOST")
+
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_FROM", "")
+	_, err = mail.FromConfig()
+	assert.Error(t, err)
+
+	t.Setenv("SMTP_FROM", "shop@example.com")
+	m, err = mail.FromConfig()
+	require.NoError(t, err)
+	assert.IsType(t, &mail.SMTP{}, m)
+}
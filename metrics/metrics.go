@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Registry holds every collector exposed on /metrics
+var Registry = prometheus.NewRegistry()
+
+// HTTP metrics, labeled by route template rather than raw path
+var (
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// Database metrics, recorded by the gorm plugin
+var (
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Database query latency in seconds.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	DBQueryErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_query_errors_total",
+		Help: "Total number of failed database queries.",
+	}, []string{"operation", "table"})
+)
+
+// Business metrics
+var (
+	RegistrationsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "user_registrations_total",
+		Help: "Total number of user registrations.",
+	})
+
+	LoginsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "user_logins_total",
+		Help: "Total number of login attempts by result.",
+	}, []string{"result"})
+
+	OrdersCreatedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "orders_created_total",
+		Help: "Total number of orders created.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		DBQueryDuration,
+		DBQueryErrorsTotal,
+		RegistrationsTotal,
+		LoginsTotal,
+		OrdersCreatedTotal,
+	)
+}
+
+// LoginSucceeded records a successful login
+func LoginSucceeded() {
+	LoginsTotal.WithLabelValues("success").Inc()
+}
+
+// LoginFailed records a failed login
+func LoginFailed() {
+	LoginsTotal.WithLabelValues("failure").Inc()
+}
@@ -0,0 +1,254 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// With Prefork every child process owns its own Registry. Each child
+// periodically writes a snapshot of its registry into a shared directory,
+// and whichever child answers a scrape merges the snapshots of all live
+// workers so /metrics reports totals for the whole server.
+
+var snapshotFormat = expfmt.NewFormat(expfmt.TypeProtoDelim)
+
+var shared struct {
+	sync.Mutex
+	dir      string
+	interval time.Duration
+}
+
+// EnableMultiprocess shares metrics between prefork workers through dir.
+// The parent process clears stale snapshots; children start writing theirs.
+func EnableMultiprocess(dir string, interval time.Duration, child bool) (stop func(), err error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if !child {
+		old, _ := filepath.Glob(filepath.Join(dir, "*.prom"))
+		for _, f := range old {
+			os.Remove(f)
+		}
+		return func() {}, nil
+	}
+
+	shared.Lock()
+	shared.dir = dir
+	shared.interval = interval
+	shared.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				writeSnapshot(dir)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		os.Remove(snapshotPath(dir))
+	}, nil
+}
+
+// Gather returns the metrics of this process, merged with those of every
+// other live worker when multiprocess mode is enabled
+func Gather() ([]*dto.MetricFamily, error) {
+	shared.Lock()
+	dir, interval := shared.dir, shared.interval
+	shared.Unlock()
+
+	if dir == "" {
+		return Registry.Gather()
+	}
+	if err := writeSnapshot(dir); err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.prom"))
+	if err != nil {
+		return nil, err
+	}
+	var all []*dto.MetricFamily
+	for _, f := range files {
+		// Workers that stopped writing are gone; their counters drop out
+		// and Prometheus treats it as a counter reset.
+		info, err := os.Stat(f)
+		if err != nil || time.Since(info.ModTime()) > 3*interval {
+			continue
+		}
+		mfs, err := readSnapshot(f)
+		if err != nil {
+			continue
+		}
+		all = append(all, mfs...)
+	}
+	return Merge(all), nil
+}
+
+func snapshotPath(dir string) string {
+	return filepath.Join(dir, fmt.Sprintf("%d.prom", os.Getpid()))
+}
+
+func writeSnapshot(dir string) error {
+	mfs, err := Registry.Gather()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "snapshot-*.tmp")
+	if err != nil {
+		return err
+	}
+	enc := expfmt.NewEncoder(tmp, snapshotFormat)
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), snapshotPath(dir))
+}
+
+func readSnapshot(path string) ([]*dto.MetricFamily, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := expfmt.NewDecoder(f, snapshotFormat)
+	var mfs []*dto.MetricFamily
+	for {
+		mf := &dto.MetricFamily{}
+		if err := dec.Decode(mf); err != nil {
+			if errors.Is(err, io.EOF) {
+				return mfs, nil
+			}
+			return mfs, err
+		}
+		mfs = append(mfs, mf)
+	}
+}
+
+// Merge sums metric families with the same name and label set. Counters,
+// gauges and untyped values are added; histograms add counts, sums and
+// buckets; summaries add counts and sums and drop quantiles.
+func Merge(families []*dto.MetricFamily) []*dto.MetricFamily {
+	byName := map[string]*dto.MetricFamily{}
+	series := map[string]map[string]*dto.Metric{}
+
+	for _, mf := range families {
+		name := mf.GetName()
+		out, ok := byName[name]
+		if !ok {
+			out = &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type}
+			byName[name] = out
+			series[name] = map[string]*dto.Metric{}
+		}
+		for _, m := range mf.Metric {
+			key := labelKey(m.Label)
+			if existing, ok := series[name][key]; ok {
+				add(existing, m)
+				continue
+			}
+			c := clone(m)
+			series[name][key] = c
+			out.Metric = append(out.Metric, c)
+		}
+	}
+
+	names := make([]string, 0, len(byName))
+	for n := range byName {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	merged := make([]*dto.MetricFamily, 0, len(names))
+	for _, n := range names {
+		merged = append(merged, byName[n])
+	}
+	return merged
+}
+
+func labelKey(labels []*dto.LabelPair) string {
+	parts := make([]string, 0, len(labels))
+	for _, l := range labels {
+		parts = append(parts, l.GetName()+"="+l.GetValue())
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "\xff")
+}
+
+func clone(m *dto.Metric) *dto.Metric {
+	c := &dto.Metric{Label: m.Label}
+	switch {
+	case m.Counter != nil:
+		c.Counter = &dto.Counter{Value: f64(m.Counter.GetValue())}
+	case m.Gauge != nil:
+		c.Gauge = &dto.Gauge{Value: f64(m.Gauge.GetValue())}
+	case m.Untyped != nil:
+		c.Untyped = &dto.Untyped{Value: f64(m.Untyped.GetValue())}
+	case m.Histogram != nil:
+		h := &dto.Histogram{
+			SampleCount: u64(m.Histogram.GetSampleCount()),
+			SampleSum:   f64(m.Histogram.GetSampleSum()),
+		}
+		for _, b := range m.Histogram.Bucket {
+			h.Bucket = append(h.Bucket, &dto.Bucket{
+				UpperBound:      f64(b.GetUpperBound()),
+				CumulativeCount: u64(b.GetCumulativeCount()),
+			})
+		}
+		c.Histogram = h
+	case m.Summary != nil:
+		c.Summary = &dto.Summary{
+			SampleCount: u64(m.Summary.GetSampleCount()),
+			SampleSum:   f64(m.Summary.GetSampleSum()),
+		}
+	}
+	return c
+}
+
+func add(dst, src *dto.Metric) {
+	switch {
+	case dst.Counter != nil && src.Counter != nil:
+		*dst.Counter.Value += src.Counter.GetValue()
+	case dst.Gauge != nil && src.Gauge != nil:
+		*dst.Gauge.Value += src.Gauge.GetValue()
+	case dst.Untyped != nil && src.Untyped != nil:
+		*dst.Untyped.Value += src.Untyped.GetValue()
+	case dst.Histogram != nil && src.Histogram != nil:
+		*dst.Histogram.SampleCount += src.Histogram.GetSampleCount()
+		*dst.Histogram.SampleSum += src.Histogram.GetSampleSum()
+		for i, b := range src.Histogram.Bucket {
+			if i < len(dst.Histogram.Bucket) && dst.Histogram.Bucket[i].GetUpperBound() == b.GetUpperBound() {
+				*dst.Histogram.Bucket[i].CumulativeCount += b.GetCumulativeCount()
+			}
+		}
+	case dst.Summary != nil && src.Summary != nil:
+		*dst.Summary.SampleCount += src.Summary.GetSampleCount()
+		*dst.Summary.SampleSum += src.Summary.GetSampleSum()
+	}
+}
+
+func f64(v float64) *float64 { return &v }
+func u64(v uint64) *uint64   { return &v }
@@ -0,0 +1,55 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"app/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func workerRegistry(fn func(c *prometheus.CounterVec, h *prometheus.HistogramVec)) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total", Help: "h"}, []string{"route"})
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "latency_seconds", Help: "h", Buckets: []float64{1, 2}}, []string{"route"})
+	reg.MustRegister(c, h)
+	fn(c, h)
+	return reg
+}
+
+func TestMerge_SumsWorkers(t *testing.T) {
+	a := workerRegistry(func(c *prometheus.CounterVec, h *prometheus.HistogramVec) {
+		c.WithLabelValues("/a").Add(2)
+		h.WithLabelValues("/a").Observe(0.5)
+	})
+	b := workerRegistry(func(c *prometheus.CounterVec, h *prometheus.HistogramVec) {
+		c.WithLabelValues("/a").Add(3)
+		c.WithLabelValues("/b").Add(1)
+		h.WithLabelValues("/a").Observe(1.5)
+	})
+
+	fa, err := a.Gather()
+	assert.NoError(t, err)
+	fb, err := b.Gather()
+	assert.NoError(t, err)
+
+	merged := metrics.Merge(append(fa, fb...))
+	assert.Len(t, merged, 2)
+
+	hist, counter := merged[0], merged[1]
+	assert.Equal(t, "latency_seconds", hist.GetName())
+	assert.Equal(t, "requests_total", counter.GetName())
+
+	values := map[string]float64{}
+	for _, m := range counter.Metric {
+		values[m.Label[0].GetValue()] = m.Counter.GetValue()
+	}
+	assert.Equal(t, map[string]float64{"/a": 5, "/b": 1}, values)
+
+	h := hist.Metric[0].Histogram
+	assert.Equal(t, uint64(2), h.GetSampleCount())
+	assert.Equal(t, 2.0, h.GetSampleSum())
+	assert.Equal(t, uint64(1), h.Bucket[0].GetCumulativeCount())
+	assert.Equal(t, uint64(2), h.Bucket[1].GetCumulativeCount())
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"app/audit"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Audit puts where the request came from into its context, for the audit
+// log. It must run after RequestID.
+func Audit() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.SetUserContext(audit.WithRequest(c.UserContext(), audit.Request{
+			IP:        c.IP(),
+			UserAgent: c.Get(fiber.HeaderUserAgent),
+			RequestID: GetRequestID(c),
+		}))
+		return c.Next()
+	}
+}
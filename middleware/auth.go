@@ -1,22 +1,77 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 
+	"app/audit"
 	"app/config"
 
 	jwtware "github.com/gofiber/contrib/jwt"
 	"github.com/gofiber/fiber/v2"
 )
 
-// Protected protect routes
-func Protected() fiber.Handler {
+// SessionCheck vets a valid token against the current state of its user.
+// It refuses the token by returning a *fiber.Error.
+type SessionCheck func(c *fiber.Ctx) error
+
+// Protected protect routes, running checks on every valid token
+func Protected(checks ...SessionCheck) fiber.Handler {
+	return jwtware.New(jwtware.Config{
+		SigningKey:     jwtware.SigningKey{Key: []byte(config.Config("SECRET"))},
+		ErrorHandler:   jwtError,
+		SuccessHandler: authenticated(checks),
+	})
+}
+
+// Optional authenticates requests that send an Authorization header and
+// lets anonymous ones through, for which GetUserID fails
+func Optional(checks ...SessionCheck) fiber.Handler {
+	return jwtware.New(jwtware.Config{
+		SigningKey:     jwtware.SigningKey{Key: []byte(config.Config("SECRET"))},
+		ErrorHandler:   jwtError,
+		SuccessHandler: authenticated(checks),
+		Filter: func(c *fiber.Ctx) bool {
+			return c.Get(fiber.HeaderAuthorization) == ""
+		},
+	})
+}
+
+// Streaming protects long-lived GET streams like Protected, also taking
+// the token from the jwt cookie set at login, since browsers' EventSource
+// can't send headers
+func Streaming(checks ...SessionCheck) fiber.Handler {
 	return jwtware.New(jwtware.Config{
-		SigningKey:   jwtware.SigningKey{Key: []byte(config.Config("SECRET"))},
-		ErrorHandler: jwtError,
+		SigningKey:     jwtware.SigningKey{Key: []byte(config.Config("SECRET"))},
+		ErrorHandler:   jwtError,
+		SuccessHandler: authenticated(checks),
+		TokenLookup:    "header:Authorization,cookie:jwt",
+		AuthScheme:     "Bearer",
 	})
 }
 
+// authenticated runs checks on a valid token and notes an impersonating
+// admin for the audit log
+func authenticated(checks []SessionCheck) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, check := range checks {
+			var refused *fiber.Error
+			if err := check(c); errors.As(err, &refused) {
+				return c.Status(refused.Code).
+					JSON(fiber.Map{"status": "error", "message": refused.Message, "data": nil})
+			} else if err != nil {
+				return err
+			}
+		}
+		if id, ok := GetImpersonatorID(c); ok {
+			r := audit.FromContext(c.UserContext())
+			r.ImpersonatorID = id
+			c.SetUserContext(audit.WithRequest(c.UserContext(), r))
+		}
+		return c.Next()
+	}
+}
+
 func jwtError(c *fiber.Ctx, err error) error {
 	if strings.Contains(err.Error(), "missing or malformed") {
 		return c.Status(fiber.StatusBadRequest).
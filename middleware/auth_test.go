@@ -3,7 +3,9 @@ package middleware_test
 
 import (
 	"app/middleware"
+	"io"
 	"os"
+	"strconv"
 	"testing"
 	"net/http/httptest"
 	"time"
@@ -64,3 +66,36 @@ func TestProtected_ValidToken(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 200, resp.StatusCode)
 }
+
+func TestOptional_AllowsAnonymous(t *testing.T) {
+	os.Setenv("SECRET", "testsecret")
+	app := fiber.New()
+	app.Get("/cart", middleware.Optional(), func(c *fiber.Ctx) error {
+		id, err := middleware.GetUserID(c)
+		if err != nil {
+			return c.SendString("guest")
+		}
+		return c.SendString(strconv.Itoa(int(id)))
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/cart", nil))
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "guest", string(body))
+
+	signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 7,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("testsecret"))
+	req := httptest.NewRequest("GET", "/cart", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "7", string(body))
+
+	req.Header.Set("Authorization", "Bearer BADTOKEN")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 401, resp.StatusCode)
+}
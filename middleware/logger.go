@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"app/logging"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestLogger stores a request-scoped logger in locals and the user
+// context, and writes one access log line per request
+func RequestLogger() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		l := slog.Default().With(slog.String("request_id", GetRequestID(c)))
+		if sc := trace.SpanContextFromContext(c.UserContext()); sc.IsValid() {
+			l = l.With(slog.String("trace_id", sc.TraceID().String()))
+		}
+		c.Locals("logger", l)
+		c.SetUserContext(logging.WithContext(c.UserContext(), l))
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+
+		attrs := []any{
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.String("route", c.Route().Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("ip", c.IP()),
+		}
+		if uid, ok := userID(c); ok {
+			attrs = append(attrs, slog.Uint64("user_id", uint64(uid)))
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		l.Log(c.UserContext(), level, "request", attrs...)
+		return err
+	}
+}
+
+// Logger returns the request logger annotated with the current route and,
+// once authenticated, the user ID
+func Logger(c *fiber.Ctx) *slog.Logger {
+	l, ok := c.Locals("logger").(*slog.Logger)
+	if !ok {
+		l = slog.Default()
+	}
+	l = l.With(slog.String("route", c.Route().Path))
+	if uid, ok := userID(c); ok {
+		l = l.With(slog.Uint64("user_id", uint64(uid)))
+	}
+	return l
+}
+
+// userID reads the user ID without requiring Protected to have run
+func userID(c *fiber.Ctx) (uint, bool) {
+	if _, ok := c.Locals("user").(*jwt.Token); !ok {
+		return 0, false
+	}
+	uid, err := GetUserID(c)
+	return uid, err == nil
+}
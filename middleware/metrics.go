@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"app/metrics"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Metrics records request count and latency per route template
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		own := c.Route().Path
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			} else {
+				status = fiber.StatusInternalServerError
+			}
+		}
+
+		// Label with the registered route template, never the raw path, so
+		// /api/items/1 and /api/items/2 share a series. Requests that match
+		// no route would otherwise report this middleware's own mount path.
+		route := c.Route().Path
+		if route == own {
+			route = "unmatched"
+		}
+
+		labels := []string{c.Method(), route, strconv.Itoa(status)}
+		metrics.HTTPRequestsTotal.WithLabelValues(labels...).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
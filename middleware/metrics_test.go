@@ -0,0 +1,48 @@
+package middleware_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"app/metrics"
+	"app/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupMetricsApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.Metrics())
+	app.Get("/widgets/:id", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+	return app
+}
+
+func TestMetrics_LabelsByRouteTemplate(t *testing.T) {
+	app := setupMetricsApp()
+	counter := metrics.HTTPRequestsTotal.WithLabelValues("GET", "/widgets/:id", "200")
+	before := testutil.ToFloat64(counter)
+
+	for _, path := range []string{"/widgets/1", "/widgets/2"} {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+	}
+
+	assert.Equal(t, before+2, testutil.ToFloat64(counter))
+}
+
+func TestMetrics_UnmatchedRoute(t *testing.T) {
+	app := setupMetricsApp()
+	counter := metrics.HTTPRequestsTotal.WithLabelValues("GET", "unmatched", "404")
+	before := testutil.ToFloat64(counter)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/nope/123", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+
+	assert.Equal(t, before+1, testutil.ToFloat64(counter))
+}
@@ -0,0 +1,42 @@
+package middleware_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"app/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRequestIDApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.RequestID())
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(middleware.GetRequestID(c))
+	})
+	return app
+}
+
+func TestRequestID_UsesIncomingHeader(t *testing.T) {
+	app := setupRequestIDApp()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc-123", resp.Header.Get("X-Request-ID"))
+}
+
+func TestRequestID_GeneratesWhenMissingOrInvalid(t *testing.T) {
+	app := setupRequestIDApp()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "bad id\nwith newline")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	id := resp.Header.Get("X-Request-ID")
+	assert.Len(t, id, 36)
+	assert.NotContains(t, id, " ")
+}
@@ -0,0 +1,74 @@
+package middleware_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"app/middleware"
+	"app/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type widget struct {
+	ID   uint
+	Name string
+}
+
+func setupTracingApp(t *testing.T) (*fiber.App, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Use(tracing.GormPlugin{}))
+	assert.NoError(t, db.AutoMigrate(&widget{}))
+
+	app := fiber.New()
+	app.Use(middleware.Tracing())
+	app.Get("/widgets/:id", func(c *fiber.Ctx) error {
+		var w widget
+		db.WithContext(c.UserContext()).Where("name = ?", "secret-value").Find(&w)
+		return c.SendStatus(200)
+	})
+	return app, exporter
+}
+
+func TestTracing_ContinuesTraceAndNestsQueries(t *testing.T) {
+	app, exporter := setupTracingApp(t)
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	req := httptest.NewRequest("GET", "/widgets/7", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("traceparent"), traceID)
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+	query, server := spans[0], spans[1]
+
+	assert.Equal(t, "GET /widgets/:id", server.Name)
+	assert.Equal(t, traceID, server.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+
+	assert.Equal(t, "gorm.select", query.Name)
+	assert.Equal(t, server.SpanContext.SpanID(), query.Parent.SpanID())
+	var statement string
+	for _, attr := range query.Attributes {
+		if attr.Key == "db.query.text" {
+			statement = attr.Value.AsString()
+		}
+	}
+	assert.Contains(t, statement, "WHERE name = ?")
+	assert.NotContains(t, statement, "secret-value")
+}
@@ -10,7 +10,10 @@ import (
 
 // GetUserID retrieves the user ID from the request context
 func GetUserID(c *fiber.Ctx) (uint, error) {
-	user := c.Locals("user").(*jwt.Token)
+	user, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return 0, errors.New("request is not authenticated")
+	}
 	claims := user.Claims.(jwt.MapClaims)
 
 	uidFloat, ok := claims["user_id"].(float64)
@@ -20,3 +23,25 @@ func GetUserID(c *fiber.Ctx) (uint, error) {
 
 	return uint(uidFloat), nil
 }
+
+// GetTokenVersion returns the token version the request's token was issued
+// with; tokens without one have version 0
+func GetTokenVersion(c *fiber.Ctx) int {
+	user, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return 0
+	}
+	version, _ := user.Claims.(jwt.MapClaims)["token_version"].(float64)
+	return int(version)
+}
+
+// GetImpersonatorID returns the admin acting as the signed-in user, if the
+// request's token was issued for impersonation
+func GetImpersonatorID(c *fiber.Ctx) (uint, bool) {
+	user, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return 0, false
+	}
+	id, ok := user.Claims.(jwt.MapClaims)["impersonator_id"].(float64)
+	return uint(id), ok && id > 0
+}
@@ -0,0 +1,66 @@
+package model
+
+import "time"
+
+// Audited actions
+const (
+	AuditLoginSucceeded   = "login.succeeded"
+	AuditLoginFailed      = "login.failed"
+	AuditUserRegistered   = "user.register"
+	AuditUserUpdated      = "user.update"
+	AuditUserDeleted      = "user.delete"
+	AuditUserReactivated  = "user.reactivate"
+	AuditUserAnonymized   = "user.anonymize"
+	AuditUserSuspended    = "user.suspend"
+	AuditUserUnsuspended  = "user.unsuspend"
+	AuditUserLoggedOut    = "user.logout"
+	AuditUserImpersonated = "user.impersonate"
+	AuditItemCreated      = "item.create"
+	AuditItemUpdated      = "item.update"
+	AuditItemDeleted      = "item.delete"
+	AuditLogExported      = "audit.export"
+	AuditContentHidden    = "content.hide"
+	AuditContentRemoved   = "content.remove"
+	AuditReportDismissed  = "report.dismiss"
+)
+
+// Reasons a failed sign-in records. What the user typed is never kept:
+// it may be an email address or a password typed in the wrong field.
+const (
+	LoginUnknownUser = "unknown_user"
+	LoginBadPassword = "bad_password"
+	LoginSuspended   = "suspended"
+)
+
+// Audit target types
+const (
+	AuditTargetUser    = "user"
+	AuditTargetItem    = "item"
+	AuditTargetAudit   = "audit_log"
+	AuditTargetReview  = "review"
+	AuditTargetComment = "comment"
+)
+
+// AuditLog records a security-relevant or admin action. Rows are only
+// ever inserted; on Postgres a trigger rejects updates and deletes.
+type AuditLog struct {
+	ID uint `gorm:"primaryKey"`
+	// ActorID is who acted; nil when nobody was signed in
+	ActorID *uint `gorm:"index"`
+	// ImpersonatorID is the admin who acted as ActorID, if any
+	ImpersonatorID *uint  `gorm:"index"`
+	Action         string `gorm:"not null;size:64;index"`
+	TargetType     string `gorm:"not null;size:32;index:idx_audit_log_target,priority:1"`
+	TargetID       *uint  `gorm:"index:idx_audit_log_target,priority:2"`
+	// Changes is a JSON object of the fields changed, each {"from", "to"}
+	Changes   string    `gorm:"not null;type:text"`
+	IP        string    `gorm:"size:64"`
+	UserAgent string    `gorm:"size:512"`
+	RequestID string    `gorm:"size:128"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// TableName keeps the log in a table named for what it is
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Cart holds the items a buyer intends to order. It belongs to a user or,
+// before they sign in, to a guest identified by an anonymous cookie.
+type Cart struct {
+	ID         uint       `gorm:"primaryKey"`
+	UserID     *uint      `gorm:"uniqueIndex"`
+	User       *User      `gorm:"foreignKey:UserID"`
+	GuestToken *string    `gorm:"uniqueIndex;size:64"`
+	Items      []CartItem `gorm:"foreignKey:CartID;constraint:OnDelete:CASCADE"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// CartItem is a line of a cart. Prices are not copied; they are read from
+// the item whenever the cart is shown or checked out.
+type CartItem struct {
+	ID        uint `gorm:"primaryKey"`
+	CartID    uint `gorm:"not null;uniqueIndex:idx_cart_items_line"`
+	ItemID    uint `gorm:"not null;uniqueIndex:idx_cart_items_line"`
+	Item      Item `gorm:"foreignKey:ItemID"`
+	Quantity  int  `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -1,9 +1,32 @@
 package model
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxCommentDepth is how deeply replies may nest; top-level comments have
+// depth 0
+const MaxCommentDepth = 5
+
+// Comment is a public question or remark on an item. Replies point at
+// their parent. A deleted comment loses its body but stays in the thread
+// while it has replies.
 type Comment struct {
-	ID     uint   `gorm:"primaryKey"`
-	Body   string `gorm:"not null"`
-	UserID uint   `gorm:"not null"`
-	PostID uint   `gorm:"not null"`
-	User   User   `gorm:"foreignKey:UserID;references:ID"`
+	ID       uint   `gorm:"primaryKey"`
+	ItemID   uint   `gorm:"not null;index"`
+	Item     Item   `gorm:"foreignKey:ItemID"`
+	ParentID *uint  `gorm:"index"`
+	Depth    int    `gorm:"not null;default:0"`
+	Body     string `gorm:"not null;size:2000"`
+	UserID   uint   `gorm:"not null;index"`
+	User     User   `gorm:"foreignKey:UserID;references:ID"`
+	// EditedAt is set once the author changes the body
+	EditedAt *time.Time
+	// Hidden comments were hidden by moderators and show like deleted ones
+	Hidden    bool `gorm:"not null;default:false"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// Reservation states
+const (
+	ReservationActive   = "active"
+	ReservationConsumed = "consumed"
+	ReservationReleased = "released"
+	ReservationExpired  = "expired"
+)
+
+// StockReservation holds units of an item for a buyer during checkout.
+// The units leave Item.Stock when reserved and return if the reservation
+// is released or expires.
+type StockReservation struct {
+	ID        uint      `gorm:"primaryKey"`
+	ItemID    uint      `gorm:"not null;index"`
+	UserID    uint      `gorm:"not null;index"`
+	Quantity  int       `gorm:"not null"`
+	Status    string    `gorm:"not null;size:16;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	OrderID   *uint
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Inventory adjustment reasons
+const (
+	AdjustmentInitial             = "initial"
+	AdjustmentRestock             = "restock"
+	AdjustmentCorrection          = "correction"
+	AdjustmentOrder               = "order"
+	AdjustmentReservation         = "reservation"
+	AdjustmentReservationReleased = "reservation_released"
+	AdjustmentReservationExpired  = "reservation_expired"
+)
+
+// InventoryAdjustment records one change to an item's stock
+type InventoryAdjustment struct {
+	ID            uint   `gorm:"primaryKey"`
+	ItemID        uint   `gorm:"not null;index"`
+	Delta         int    `gorm:"not null"`
+	StockAfter    int    `gorm:"not null"`
+	Reason        string `gorm:"not null;size:32"`
+	Note          string `gorm:"size:500"`
+	ActorID       *uint
+	OrderID       *uint
+	ReservationID *uint
+	CreatedAt     time.Time
+}
@@ -1,14 +1,67 @@
 package model
 
+import (
+	"slices"
+	"time"
+
+	"app/money"
+
+	"gorm.io/gorm"
+)
+
+// Item statuses. Only active items are listed and can be bought.
+const (
+	ItemDraft    = "draft"
+	ItemActive   = "active"
+	ItemPaused   = "paused"
+	ItemSold     = "sold"
+	ItemArchived = "archived"
+)
+
+// itemTransitions lists the statuses each status may move to; archived
+// items stay archived
+var itemTransitions = map[string][]string{
+	ItemDraft:  {ItemActive, ItemArchived},
+	ItemActive: {ItemPaused, ItemSold, ItemArchived},
+	ItemPaused: {ItemActive, ItemSold, ItemArchived},
+	ItemSold:   {ItemActive, ItemArchived},
+}
+
 type Item struct {
-	ID          uint     `gorm:"primaryKey"`
-	Name        string   `gorm:"not null"`
-	Description string   `gorm:"not null"`
-	Price       float64  `gorm:"not null"`
-	UserID      uint     `gorm:"not null"`
-	User        User     `gorm:"foreignKey:UserID"`
-	CategoryID  uint     `gorm:"not null"`
-	Category    Category `gorm:"foreignKey:CategoryID"`
-	Reviews     []Review `gorm:"foreignKey:ItemID"`
-	Orders      []Order  `gorm:"foreignKey:ItemID"`
+	ID          uint           `gorm:"primaryKey"`
+	Name        string         `gorm:"not null"`
+	Description string         `gorm:"not null"`
+	Price       money.Money    `gorm:"embedded;embeddedPrefix:price_"`
+	Stock       int            `gorm:"not null;default:0"`
+	UserID      uint           `gorm:"not null;index"`
+	User        User           `gorm:"foreignKey:UserID"`
+	CategoryID  uint           `gorm:"not null"`
+	Category    Category       `gorm:"foreignKey:CategoryID"`
+	Reviews     []Review       `gorm:"foreignKey:ItemID"`
+	Orders      []Order        `gorm:"foreignKey:ItemID"`
+	Images      []ItemImage    `gorm:"foreignKey:ItemID"`
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+	// Hidden items were hidden by moderators or pre-moderation and are
+	// left out of listings
+	Hidden bool   `gorm:"not null;default:false"`
+	Status string `gorm:"not null;size:20;default:active;index"`
+	// PublishAt and UnpublishAt schedule the item to become active and
+	// paused; the worker clears them once done
+	PublishAt   *time.Time `gorm:"index"`
+	UnpublishAt *time.Time `gorm:"index"`
+
+	// LikeCount and CommentCount are kept up to date by the services so
+	// listings don't count rows
+	LikeCount    int `gorm:"not null;default:0"`
+	CommentCount int `gorm:"not null;default:0"`
+}
+
+// CanBecome tells whether the item may move from its status to status
+func (i Item) CanBecome(status string) bool {
+	return slices.Contains(itemTransitions[i.Status], status)
+}
+
+// Listed tells whether the item is public: active and not hidden
+func (i Item) Listed() bool {
+	return i.Status == ItemActive && !i.Hidden
 }
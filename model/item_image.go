@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// ItemImage is a picture of an item. The original and its thumbnails are
+// stored under Key, see service.ImageVariants.
+type ItemImage struct {
+	ID          uint   `gorm:"primaryKey"`
+	ItemID      uint   `gorm:"not null;index"`
+	Position    int    `gorm:"not null"`
+	IsPrimary   bool   `gorm:"not null;default:false"`
+	Key         string `gorm:"not null"`
+	ContentType string `gorm:"not null"`
+	Width       int    `gorm:"not null"`
+	Height      int    `gorm:"not null"`
+	// Processing is set until the resized variants are stored. Meanwhile
+	// every variant is served from the original.
+	Processing bool `gorm:"not null;default:false"`
+	CreatedAt  time.Time
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Job statuses
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	// JobDead jobs failed every attempt and wait for someone to look at them
+	JobDead = "dead"
+)
+
+// Job is a unit of background work. Jobs are enqueued in the same
+// transaction as the writes calling for them, so they run if and only if
+// those writes commit.
+type Job struct {
+	ID   uint   `gorm:"primaryKey"`
+	Kind string `gorm:"not null;size:64"`
+	// Payload is the JSON argument of the job
+	Payload     string    `gorm:"not null;type:text"`
+	Status      string    `gorm:"not null;size:20;index:idx_jobs_due,priority:1"`
+	RunAt       time.Time `gorm:"not null;index:idx_jobs_due,priority:2"`
+	Attempts    int       `gorm:"not null;default:0"`
+	MaxAttempts int       `gorm:"not null"`
+	// UniqueKey, when set, drops any later job enqueued with the same key
+	UniqueKey *string `gorm:"size:255;uniqueIndex"`
+	LockedBy  string  `gorm:"size:255"`
+	LockedAt  *time.Time
+	LastError string `gorm:"type:text"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
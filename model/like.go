@@ -1,8 +1,13 @@
 package model
 
+import "time"
+
+// Like is a user liking an item, at most once
 type Like struct {
-	ID     uint `gorm:"primaryKey"`
-	UserID uint `gorm:"not null"`
-	PostID uint `gorm:"not null"`
-	User   User `gorm:"foreignKey:UserID;references:ID"`
+	ID        uint `gorm:"primaryKey"`
+	UserID    uint `gorm:"not null;uniqueIndex:idx_likes_user_item"`
+	ItemID    uint `gorm:"not null;uniqueIndex:idx_likes_user_item;index"`
+	User      User `gorm:"foreignKey:UserID;references:ID"`
+	Item      Item `gorm:"foreignKey:ItemID"`
+	CreatedAt time.Time
 }
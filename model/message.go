@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// Conversation is a private thread between a buyer and the seller of an
+// item. A buyer has at most one conversation per item.
+type Conversation struct {
+	ID       uint `gorm:"primaryKey"`
+	ItemID   uint `gorm:"not null;uniqueIndex:idx_conversations_item_buyer,priority:1"`
+	Item     Item `gorm:"foreignKey:ItemID"`
+	BuyerID  uint `gorm:"not null;uniqueIndex:idx_conversations_item_buyer,priority:2;index"`
+	Buyer    User `gorm:"foreignKey:BuyerID"`
+	SellerID uint `gorm:"not null;index"`
+	Seller   User `gorm:"foreignKey:SellerID"`
+	// LastMessageAt orders conversations by activity
+	LastMessageAt time.Time `gorm:"not null;index"`
+	CreatedAt     time.Time
+}
+
+// Participant reports whether userID takes part in the conversation
+func (c *Conversation) Participant(userID uint) bool {
+	return userID == c.BuyerID || userID == c.SellerID
+}
+
+// Other returns the participant who isn't userID
+func (c *Conversation) Other(userID uint) uint {
+	if userID == c.BuyerID {
+		return c.SellerID
+	}
+	return c.BuyerID
+}
+
+// Message is a message in a conversation. ReadAt is set once the
+// recipient reads it.
+type Message struct {
+	ID             uint   `gorm:"primaryKey"`
+	ConversationID uint   `gorm:"not null;index"`
+	SenderID       uint   `gorm:"not null"`
+	Body           string `gorm:"not null;size:2000"`
+	ReadAt         *time.Time
+	CreatedAt      time.Time
+}
+
+// Block stops BlockedID from messaging BlockerID, and the other way round
+type Block struct {
+	ID        uint `gorm:"primaryKey"`
+	BlockerID uint `gorm:"not null;uniqueIndex:idx_blocks_pair,priority:1"`
+	BlockedID uint `gorm:"not null;uniqueIndex:idx_blocks_pair,priority:2"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// Notification types
+const (
+	NotifyPriceDrop    = "price_drop"
+	NotifyBackInStock  = "back_in_stock"
+	NotifyNewOrder     = "new_order"
+	NotifyReview       = "review"
+	NotifyCommentReply = "comment_reply"
+)
+
+// NotificationTypes lists every notification type, for preferences
+var NotificationTypes = []string{NotifyPriceDrop, NotifyBackInStock, NotifyNewOrder, NotifyReview, NotifyCommentReply}
+
+// Notification tells a user something happened, shown in the app until
+// read
+type Notification struct {
+	ID     uint   `gorm:"primaryKey"`
+	UserID uint   `gorm:"not null;index:idx_notifications_user,priority:1"`
+	Type   string `gorm:"not null;size:40"`
+	Title  string `gorm:"not null;size:200"`
+	Body   string `gorm:"not null;size:1000"`
+	// ItemID is the item the notification is about, if any
+	ItemID    *uint
+	ReadAt    *time.Time
+	CreatedAt time.Time `gorm:"index:idx_notifications_user,priority:2"`
+}
+
+// NotificationPreference is how a user wants one type of notification.
+// Without a row, notifications show in the app and aren't emailed.
+type NotificationPreference struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;uniqueIndex:idx_notification_prefs_user_type,priority:1"`
+	Type      string `gorm:"not null;size:40;uniqueIndex:idx_notification_prefs_user_type,priority:2"`
+	InApp     bool   `gorm:"not null"`
+	Email     bool   `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+// DefaultNotificationPreference is the preference of a user who set none
+func DefaultNotificationPreference(userID uint, kind string) NotificationPreference {
+	return NotificationPreference{UserID: userID, Type: kind, InApp: true}
+}
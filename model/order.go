@@ -1,13 +1,33 @@
 package model
 
+import (
+	"time"
+
+	"app/money"
+)
+
+// Order states. An order waits for payment, is authorized once the buyer
+// pays, and is paid when the seller captures the payment.
+const (
+	OrderPending           = "pending"
+	OrderAuthorized        = "authorized"
+	OrderPaid              = "paid"
+	OrderPartiallyRefunded = "partially_refunded"
+	OrderRefunded          = "refunded"
+	OrderPaymentFailed     = "payment_failed"
+)
+
 // Order represents an order for an item
 type Order struct {
-	ID         uint    `gorm:"primaryKey"`
-	ItemID     uint    `gorm:"not null"`
-	UserID     uint    `gorm:"not null"`
-	Quantity   int     `gorm:"not null"`
-	TotalPrice float64 `gorm:"not null"`
-	CategoryID uint    `gorm:"not null"`
-	Item       Item    `gorm:"foreignKey:ItemID;references:ID"`
-	User       User    `gorm:"foreignKey:UserID;references:ID"`
+	ID         uint        `gorm:"primaryKey"`
+	ItemID     uint        `gorm:"not null;index:idx_orders_item_created,priority:1"`
+	UserID     uint        `gorm:"not null"`
+	Quantity   int         `gorm:"not null"`
+	TotalPrice money.Money `gorm:"embedded;embeddedPrefix:total_price_"`
+	Status     string      `gorm:"not null;size:20;default:pending;index"`
+	CategoryID uint        `gorm:"not null"`
+	Item       Item        `gorm:"foreignKey:ItemID;references:ID"`
+	User       User        `gorm:"foreignKey:UserID;references:ID"`
+	CreatedAt  time.Time   `gorm:"index:idx_orders_item_created,priority:2"`
+	UpdatedAt  time.Time
 }
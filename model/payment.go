@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"app/money"
+)
+
+// Payment is a provider payment intent for an order. An order has at most
+// one live payment; a failed one is replaced when the buyer retries.
+type Payment struct {
+	ID       uint   `gorm:"primaryKey"`
+	OrderID  uint   `gorm:"not null;index"`
+	Order    Order  `gorm:"foreignKey:OrderID"`
+	Provider string `gorm:"not null;size:20;uniqueIndex:idx_payments_intent"`
+	IntentID string `gorm:"not null;size:255;uniqueIndex:idx_payments_intent"`
+	// ClientSecret lets the buyer's browser complete the intent
+	ClientSecret string      `gorm:"size:255"`
+	Amount       money.Money `gorm:"embedded;embeddedPrefix:amount_"`
+	Status       string      `gorm:"not null;size:20"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Ledger entry kinds
+const (
+	LedgerCharge = "charge"
+	LedgerRefund = "refund"
+)
+
+// LedgerEntry records money moving for an order: a positive charge when a
+// payment is captured and a negative row for each refund, partial or full
+type LedgerEntry struct {
+	ID        uint        `gorm:"primaryKey"`
+	OrderID   uint        `gorm:"not null;index"`
+	PaymentID uint        `gorm:"not null;index"`
+	Kind      string      `gorm:"not null;size:20"`
+	Amount    money.Money `gorm:"embedded;embeddedPrefix:amount_"`
+	// Reference is the provider's ID for the charge or refund. It is unique
+	// so an operation reported twice is recorded once.
+	Reference string `gorm:"not null;size:255;uniqueIndex"`
+	CreatedAt time.Time
+}
+
+// PaymentEvent remembers a processed webhook event so retried deliveries
+// are ignored
+type PaymentEvent struct {
+	ID        string `gorm:"primaryKey;size:255"`
+	Type      string `gorm:"not null;size:64"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// Reasons for a report; ReportBlocklist is only used by pre-moderation
+const (
+	ReportSpam       = "spam"
+	ReportProhibited = "prohibited"
+	ReportOffensive  = "offensive"
+	ReportFraud      = "fraud"
+	ReportOther      = "other"
+	ReportBlocklist  = "blocklist"
+)
+
+// What can be reported
+const (
+	ReportItem    = "item"
+	ReportReview  = "review"
+	ReportComment = "comment"
+)
+
+// Report statuses: open reports are in the moderation queue, the others
+// say how a moderator resolved them
+const (
+	ReportOpen      = "open"
+	ReportHidden    = "hidden"
+	ReportRemoved   = "removed"
+	ReportDismissed = "dismissed"
+)
+
+// Report flags an item, review or comment for moderators
+type Report struct {
+	ID uint `gorm:"primaryKey"`
+	// ReporterID is nil for reports filed by pre-moderation
+	ReporterID *uint  `gorm:"index"`
+	TargetType string `gorm:"not null;size:16;index:idx_reports_target,priority:1"`
+	TargetID   uint   `gorm:"not null;index:idx_reports_target,priority:2"`
+	Reason     string `gorm:"not null;size:32"`
+	Details    string `gorm:"not null;size:2000"`
+	Status     string `gorm:"not null;size:16;default:open;index"`
+	// ModeratorID, Note and ResolvedAt are set once the report is resolved
+	ModeratorID *uint
+	Note        string `gorm:"not null;size:2000"`
+	ResolvedAt  *time.Time
+	CreatedAt   time.Time
+}
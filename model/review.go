@@ -1,12 +1,18 @@
 package model
 
+import "time"
+
 // Review represents a review for an item
 type Review struct {
-	ID        uint   `gorm:"primaryKey"`
-	ItemID    uint   `gorm:"not null"`
-	UserID    uint   `gorm:"not null"`
-	Rating    int    `gorm:"not null"` // Rating out of 5
-	Comment   string `gorm:"not null"`
-	Item      Item   `gorm:"foreignKey:ItemID;references:ID"`
-	User      User   `gorm:"foreignKey:UserID;references:ID"`
+	ID      uint   `gorm:"primaryKey"`
+	ItemID  uint   `gorm:"not null;index:idx_reviews_item_created,priority:1"`
+	UserID  uint   `gorm:"not null"`
+	Rating  int    `gorm:"not null"` // Rating out of 5
+	Comment string `gorm:"not null"`
+	Item    Item   `gorm:"foreignKey:ItemID;references:ID"`
+	User    User   `gorm:"foreignKey:UserID;references:ID"`
+	// Hidden reviews were hidden by moderators and don't count
+	Hidden bool `gorm:"not null;default:false"`
+	// CreatedAt is null for reviews written before it was recorded
+	CreatedAt *time.Time `gorm:"index:idx_reviews_item_created,priority:2"`
 }
@@ -1,12 +1,80 @@
 package model
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User roles
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// User statuses, as admins filter them
+const (
+	UserActive      = "active"
+	UserSuspended   = "suspended"
+	UserBanned      = "banned"
+	UserDeactivated = "deactivated"
+)
+
 // User represents a user in the system
 type User struct {
-    ID        uint      `gorm:"primaryKey"`
-    Username  string    `gorm:"unique;not null"`
-    Email     string    `gorm:"unique;not null"`
-    Password  string    `gorm:"not null"`
-    Likes     []Like    `gorm:"foreignKey:UserID;references:ID"`
-    Comments  []Comment `gorm:"foreignKey:UserID;references:ID"`
+	ID          uint   `gorm:"primaryKey"`
+	Username    string `gorm:"unique;not null"`
+	Email       string `gorm:"unique;not null"`
+	Password    string `gorm:"not null"`
+	DisplayName string `gorm:"size:100"`
+	AvatarURL   string `gorm:"size:500"`
+	Bio         string `gorm:"size:500"`
+	Role        string `gorm:"not null;size:20;default:user"`
+	// SuspendedAt is when an admin suspended the user, until SuspendedUntil
+	// or, without it, for good: a ban. Lifting clears both.
+	SuspendedAt    *time.Time
+	SuspendedUntil *time.Time
+	SuspendReason  string `gorm:"size:500"`
+	// TokenVersion is carried by the user's tokens; bumping it signs them
+	// out everywhere
+	TokenVersion int `gorm:"not null;default:0"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	// DeletedAt marks a deactivated account; it is anonymized once the
+	// retention window has passed
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+	AnonymizedAt *time.Time
+	Likes        []Like    `gorm:"foreignKey:UserID;references:ID"`
+	Comments     []Comment `gorm:"foreignKey:UserID;references:ID"`
+}
+
+// IsAdmin tells whether the user may use the admin API
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
 }
 
+// IsModerator tells whether the user may work the moderation queue, as
+// moderators and admins do
+func (u User) IsModerator() bool {
+	return u.Role == RoleModerator || u.Role == RoleAdmin
+}
+
+// Suspended tells whether the user is suspended or banned at now
+func (u User) Suspended(now time.Time) bool {
+	return u.SuspendedAt != nil && (u.SuspendedUntil == nil || now.Before(*u.SuspendedUntil))
+}
+
+// Status is one of the User* statuses at now
+func (u User) Status(now time.Time) string {
+	switch {
+	case u.DeletedAt.Valid:
+		return UserDeactivated
+	case u.Suspended(now) && u.SuspendedUntil == nil:
+		return UserBanned
+	case u.Suspended(now):
+		return UserSuspended
+	default:
+		return UserActive
+	}
+}
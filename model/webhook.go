@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// Webhook event types
+const (
+	WebhookOrderCreated = "order.created"
+	WebhookItemCreated  = "item.created"
+	WebhookItemUpdated  = "item.updated"
+	WebhookItemDeleted  = "item.deleted"
+	// WebhookPing is sent on demand to try a subscription out
+	WebhookPing = "ping"
+)
+
+// WebhookEvents lists the event types a subscription can ask for
+var WebhookEvents = []string{WebhookOrderCreated, WebhookItemCreated, WebhookItemUpdated, WebhookItemDeleted}
+
+// WebhookSubscription asks for the events of a user's shop to be POSTed
+// to URL, signed with Secret
+type WebhookSubscription struct {
+	ID     uint     `gorm:"primaryKey"`
+	UserID uint     `gorm:"not null;index"`
+	URL    string   `gorm:"not null;size:2048"`
+	Events []string `gorm:"not null;type:text;serializer:json"`
+	Secret string   `gorm:"not null;size:64"`
+	Active bool     `gorm:"not null"`
+	// Failures counts failed deliveries since the last success
+	Failures   int `gorm:"not null;default:0"`
+	DisabledAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Wants tells whether the subscription asked for events of kind
+func (s WebhookSubscription) Wants(kind string) bool {
+	for _, e := range s.Events {
+		if e == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one attempt at delivering an event. StatusCode is
+// zero when no response came back.
+type WebhookDelivery struct {
+	ID             uint   `gorm:"primaryKey"`
+	SubscriptionID uint   `gorm:"not null;index"`
+	EventID        string `gorm:"not null;size:36;index"`
+	Event          string `gorm:"not null;size:64"`
+	Attempt        int    `gorm:"not null"`
+	StatusCode     int    `gorm:"not null"`
+	Error          string `gorm:"size:1000"`
+	DurationMS     int64  `gorm:"not null"`
+	CreatedAt      time.Time
+}
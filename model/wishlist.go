@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// WishlistEntry is an item a user saved to watch
+type WishlistEntry struct {
+	ID        uint `gorm:"primaryKey"`
+	UserID    uint `gorm:"not null;uniqueIndex:idx_wishlist_user_item"`
+	ItemID    uint `gorm:"not null;uniqueIndex:idx_wishlist_user_item;index"`
+	User      User `gorm:"foreignKey:UserID;references:ID"`
+	Item      Item `gorm:"foreignKey:ItemID"`
+	CreatedAt time.Time
+}
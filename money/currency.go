@@ -0,0 +1,73 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// Currency is an ISO 4217 alphabetic code such as "EUR"
+type Currency string
+
+// MaxExponent is the largest number of minor unit digits of any supported
+// currency
+const MaxExponent = 3
+
+// exponents maps supported currencies to their number of minor unit digits
+var exponents = map[Currency]int{
+	"AED": 2, "ARS": 2, "AUD": 2, "BGN": 2, "BHD": 3, "BRL": 2, "CAD": 2,
+	"CHF": 2, "CLP": 0, "CNY": 2, "COP": 2, "CZK": 2, "DKK": 2, "EGP": 2,
+	"EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2,
+	"ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3, "MXN": 2, "MYR": 2,
+	"NOK": 2, "NZD": 2, "OMR": 3, "PHP": 2, "PLN": 2, "RON": 2, "SAR": 2,
+	"SEK": 2, "SGD": 2, "THB": 2, "TND": 3, "TRY": 2, "TWD": 2, "UAH": 2,
+	"USD": 2, "VND": 0, "ZAR": 2,
+}
+
+// ParseCurrency returns the supported currency named by code, in any case
+func ParseCurrency(code string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(code)))
+	if !c.Valid() {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return c, nil
+}
+
+// Valid reports whether c is a supported currency
+func (c Currency) Valid() bool {
+	_, ok := exponents[c]
+	return ok
+}
+
+// Exponent returns the number of minor unit digits of c, such as 2 for
+// cents
+func (c Currency) Exponent() int {
+	return exponents[c]
+}
+
+// Scan implements sql.Scanner
+func (c *Currency) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("money: cannot scan %T into Currency", src)
+	}
+	parsed, err := ParseCurrency(s)
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// Value implements driver.Valuer, refusing to store unsupported codes
+func (c Currency) Value() (driver.Value, error) {
+	if !c.Valid() {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCurrency, string(c))
+	}
+	return string(c), nil
+}
@@ -0,0 +1,148 @@
+// Package money represents prices exactly, as integer minor units of an
+// ISO 4217 currency
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Errors returned when parsing or computing amounts
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrOverflow         = errors.New("amount out of range")
+)
+
+// Money is an amount in minor units, such as cents, of a currency. It is
+// stored as two columns; embed it with a prefix:
+//
+//	Price money.Money `gorm:"embedded;embeddedPrefix:price_"`
+type Money struct {
+	Amount int64 `gorm:"not null;default:0"`
+	// The column default only fills rows that predate the column
+	Currency Currency `gorm:"type:varchar(3);not null;default:'EUR'"`
+}
+
+// New returns amount minor units of c
+func New(amount int64, c Currency) Money {
+	return Money{Amount: amount, Currency: c}
+}
+
+// Parse reads a decimal amount such as "19.99" in currency c. More
+// decimal places than c has minor units are rejected rather than rounded.
+func Parse(s string, c Currency) (Money, error) {
+	if !c.Valid() {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, string(c))
+	}
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	r.Mul(r, scale(c))
+	if !r.IsInt() {
+		return Money{}, fmt.Errorf("%w: %q has more than %d decimal places for %s", ErrInvalidAmount, s, c.Exponent(), c)
+	}
+	if !r.Num().IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: r.Num().Int64(), Currency: c}, nil
+}
+
+// scale returns 10^exponent of c
+func scale(c Currency) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.Exponent())), nil))
+}
+
+// IsZero reports whether m has no amount
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Mul returns m times n
+func (m Money) Mul(n int64) (Money, error) {
+	if n != 0 && (m.Amount > math.MaxInt64/abs(n) || m.Amount < -math.MaxInt64/abs(n)) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: m.Amount * n, Currency: m.Currency}, nil
+}
+
+// Add returns m plus o, which must be in the same currency
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Decimal formats the amount with its minor units, such as "19.99"
+func (m Money) Decimal() string {
+	exp := m.Currency.Exponent()
+	digits := fmt.Sprintf("%0*d", exp+1, abs(m.Amount))
+	sign := ""
+	if m.Amount < 0 {
+		sign = "-"
+	}
+	if exp == 0 {
+		return sign + digits
+	}
+	cut := len(digits) - exp
+	return sign + digits[:cut] + "." + digits[cut:]
+}
+
+// String formats m as "19.99 EUR"
+func (m Money) String() string {
+	return m.Decimal() + " " + string(m.Currency)
+}
+
+type moneyJSON struct {
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+// MarshalJSON writes m as {"amount":"19.99","currency":"EUR"}. The amount
+// is a string so clients don't read it into a float.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string   `json:"amount"`
+		Currency Currency `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+// UnmarshalJSON reads the form written by MarshalJSON, also accepting the
+// amount as a JSON number
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v moneyJSON
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	c, err := ParseCurrency(v.Currency)
+	if err != nil {
+		return err
+	}
+	parsed, err := Parse(v.Amount.String(), c)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,113 @@
+package money_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"app/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	m, err := money.Parse("19.99", "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, money.New(1999, "EUR"), m)
+
+	m, err = money.Parse("1500", "JPY")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500), m.Amount)
+
+	m, err = money.Parse("1.005", "KWD")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1005), m.Amount)
+
+	_, err = money.Parse("1.005", "EUR")
+	assert.ErrorIs(t, err, money.ErrInvalidAmount)
+	_, err = money.Parse("0.5", "JPY")
+	assert.ErrorIs(t, err, money.ErrInvalidAmount)
+	_, err = money.Parse("abc", "EUR")
+	assert.ErrorIs(t, err, money.ErrInvalidAmount)
+	_, err = money.Parse("1", "XXX")
+	assert.ErrorIs(t, err, money.ErrUnknownCurrency)
+	_, err = money.Parse("1e30", "EUR")
+	assert.ErrorIs(t, err, money.ErrOverflow)
+}
+
+func TestDecimal(t *testing.T) {
+	assert.Equal(t, "19.99", money.New(1999, "EUR").Decimal())
+	assert.Equal(t, "0.05", money.New(5, "USD").Decimal())
+	assert.Equal(t, "-1.50", money.New(-150, "USD").Decimal())
+	assert.Equal(t, "1500", money.New(1500, "JPY").Decimal())
+	assert.Equal(t, "1.005 KWD", money.New(1005, "KWD").String())
+}
+
+func TestArithmetic_IsExact(t *testing.T) {
+	// 0.1 + 0.2 and 3 * 19.99 are the classic float64 failures
+	a, _ := money.Parse("0.1", "EUR")
+	b, _ := money.Parse("0.2", "EUR")
+	sum, err := a.Add(b)
+	require.NoError(t, err)
+	assert.Equal(t, "0.30", sum.Decimal())
+
+	price, _ := money.Parse("19.99", "EUR")
+	total, err := price.Mul(3)
+	require.NoError(t, err)
+	assert.Equal(t, "59.97", total.Decimal())
+
+	_, err = a.Add(money.New(1, "USD"))
+	assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+	_, err = money.New(1<<62, "EUR").Mul(4)
+	assert.ErrorIs(t, err, money.ErrOverflow)
+}
+
+func TestJSON_RoundTrip(t *testing.T) {
+	raw, err := json.Marshal(money.New(1999, "EUR"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"19.99","currency":"EUR"}`, string(raw))
+
+	var m money.Money
+	require.NoError(t, json.Unmarshal(raw, &m))
+	assert.Equal(t, money.New(1999, "EUR"), m)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":12.5,"currency":"usd"}`), &m))
+	assert.Equal(t, money.New(1250, "USD"), m)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount":"1.001","currency":"EUR"}`), &m))
+	assert.Error(t, json.Unmarshal([]byte(`{"amount":"1","currency":"XYZ"}`), &m))
+}
+
+func TestCurrency_SQL(t *testing.T) {
+	var c money.Currency
+	require.NoError(t, c.Scan([]byte("GBP")))
+	assert.Equal(t, money.Currency("GBP"), c)
+	assert.Error(t, c.Scan("nope"))
+
+	_, err := money.Currency("").Value()
+	assert.ErrorIs(t, err, money.ErrUnknownCurrency)
+}
+
+func TestConvert(t *testing.T) {
+	rates, err := money.NewStaticRates("EUR", map[money.Currency]string{"USD": "1.08", "JPY": "160"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	usd, err := money.Convert(ctx, rates, money.New(1000, "EUR"), "USD")
+	require.NoError(t, err)
+	assert.Equal(t, money.New(1080, "USD"), usd)
+
+	// 1.00 USD = 160/1.08 JPY = 148.148..., rounded to whole yen
+	jpy, err := money.Convert(ctx, rates, money.New(100, "USD"), "JPY")
+	require.NoError(t, err)
+	assert.Equal(t, money.New(148, "JPY"), jpy)
+
+	// 1 JPY is 0.625 cents, rounded to the nearest cent
+	cents, err := money.Convert(ctx, rates, money.New(1, "JPY"), "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, money.New(1, "EUR"), cents)
+
+	_, err = money.Convert(ctx, rates, money.New(100, "EUR"), "GBP")
+	assert.ErrorIs(t, err, money.ErrNoRate)
+}
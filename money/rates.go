@@ -0,0 +1,120 @@
+package money
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"app/config"
+)
+
+// ErrNoRate is returned when a provider can't convert between two
+// currencies
+var ErrNoRate = errors.New("no exchange rate")
+
+// RateProvider supplies exchange rates. Rate returns how many units of to
+// one unit of from is worth.
+type RateProvider interface {
+	Rate(ctx context.Context, from, to Currency) (*big.Rat, error)
+}
+
+// Convert returns m in currency to, rounded half away from zero to the
+// minor units of to
+func Convert(ctx context.Context, p RateProvider, m Money, to Currency) (Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+	if !to.Valid() {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, string(to))
+	}
+	rate, err := p.Rate(ctx, m.Currency, to)
+	if err != nil {
+		return Money{}, err
+	}
+	// minor(to) = minor(from) / 10^exp(from) * rate * 10^exp(to)
+	r := new(big.Rat).SetInt64(m.Amount)
+	r.Mul(r, rate)
+	r.Mul(r, scale(to))
+	r.Quo(r, scale(m.Currency))
+
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+	if !q.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: q.Int64(), Currency: to}, nil
+}
+
+// StaticRates is a fixed table of rates against a base currency
+type StaticRates struct {
+	base  Currency
+	rates map[Currency]*big.Rat
+}
+
+// NewStaticRates builds a table where one unit of base is worth rates[c]
+// units of c, given as decimals such as "1.08"
+func NewStaticRates(base Currency, rates map[Currency]string) (*StaticRates, error) {
+	if !base.Valid() {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCurrency, string(base))
+	}
+	s := &StaticRates{base: base, rates: map[Currency]*big.Rat{base: big.NewRat(1, 1)}}
+	for c, v := range rates {
+		if !c.Valid() {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownCurrency, string(c))
+		}
+		r, ok := new(big.Rat).SetString(v)
+		if !ok || r.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q for %s", v, c)
+		}
+		s.rates[c] = r
+	}
+	return s, nil
+}
+
+// Rate implements RateProvider by crossing both currencies through the base
+func (s *StaticRates) Rate(_ context.Context, from, to Currency) (*big.Rat, error) {
+	f, ok := s.rates[from]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s to %s", ErrNoRate, from, to)
+	}
+	t, ok := s.rates[to]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s to %s", ErrNoRate, from, to)
+	}
+	return new(big.Rat).Quo(t, f), nil
+}
+
+// DefaultCurrency reads the currency of new listings from DEFAULT_CURRENCY,
+// falling back to EUR when it is unset or unknown
+func DefaultCurrency() Currency {
+	c, err := ParseCurrency(config.Config("DEFAULT_CURRENCY"))
+	if err != nil {
+		return "EUR"
+	}
+	return c
+}
+
+// RatesFromConfig reads EXCHANGE_RATES, a list such as "USD=1.08,GBP=0.85"
+// of rates against DefaultCurrency
+func RatesFromConfig() (*StaticRates, error) {
+	rates := map[Currency]string{}
+	for _, pair := range strings.Split(config.Config("EXCHANGE_RATES"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		code, rate, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid EXCHANGE_RATES entry %q", pair)
+		}
+		c, err := ParseCurrency(code)
+		if err != nil {
+			return nil, err
+		}
+		rates[c] = strings.TrimSpace(rate)
+	}
+	return NewStaticRates(DefaultCurrency(), rates)
+}
@@ -0,0 +1,9 @@
+package payment
+
+import "time"
+
+// SetNow fixes the clock used to check webhook signatures
+func (s *Stripe) SetNow(t time.Time) { s.now = func() time.Time { return t } }
+
+// SetNow fixes the clock used to sign and check webhooks
+func (f *Fake) SetNow(t time.Time) { f.now = func() time.Time { return t } }
@@ -0,0 +1,147 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"app/money"
+)
+
+// FakeSignatureHeader carries the signature of Fake webhooks
+const FakeSignatureHeader = "Payment-Signature"
+
+// Fake is an in-memory Provider for development and tests. Nobody pays a
+// fake intent; call Authorize to act as the buyer. Its webhooks are Event
+// values as JSON, signed like Stripe's.
+type Fake struct {
+	mu      sync.Mutex
+	secret  []byte
+	next    int
+	intents map[string]*Intent
+	// refunded sums the refunds of each intent
+	refunded map[string]int64
+	// keys maps idempotency keys to the intent or refund they created
+	keys map[string]any
+	now  func() time.Time
+}
+
+// NewFake creates a Fake verifying webhooks with secret
+func NewFake(secret []byte) *Fake {
+	return &Fake{
+		secret:   secret,
+		intents:  map[string]*Intent{},
+		refunded: map[string]int64{},
+		keys:     map[string]any{},
+		now:      time.Now,
+	}
+}
+
+// Name implements Provider
+func (f *Fake) Name() string { return "fake" }
+
+// CreateIntent implements Provider
+func (f *Fake) CreateIntent(_ context.Context, amount money.Money, idempotencyKey string, _ map[string]string) (*Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if prev, ok := f.keys[idempotencyKey].(*Intent); ok {
+		out := *prev
+		return &out, nil
+	}
+	f.next++
+	id := fmt.Sprintf("pi_fake_%d", f.next)
+	in := &Intent{ID: id, ClientSecret: id + "_secret", Amount: amount, Status: IntentRequiresPayment}
+	f.intents[id] = in
+	if idempotencyKey != "" {
+		f.keys[idempotencyKey] = in
+	}
+	out := *in
+	return &out, nil
+}
+
+// Authorize acts as the buyer paying an intent, leaving it to be captured
+func (f *Fake) Authorize(intentID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	in, ok := f.intents[intentID]
+	if !ok {
+		return ErrNotFound
+	}
+	if in.Status != IntentRequiresPayment {
+		return ErrInvalidState
+	}
+	in.Status = IntentRequiresCapture
+	return nil
+}
+
+// Capture implements Provider
+func (f *Fake) Capture(_ context.Context, intentID string) (*Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	in, ok := f.intents[intentID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	switch in.Status {
+	case IntentRequiresCapture:
+		in.Status = IntentSucceeded
+	case IntentSucceeded:
+	default:
+		return nil, ErrInvalidState
+	}
+	out := *in
+	return &out, nil
+}
+
+// Refund implements Provider
+func (f *Fake) Refund(_ context.Context, intentID string, amount money.Money, idempotencyKey string) (*Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if prev, ok := f.keys[idempotencyKey].(*Refund); ok {
+		out := *prev
+		return &out, nil
+	}
+	in, ok := f.intents[intentID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if in.Status != IntentSucceeded || amount.Currency != in.Amount.Currency || amount.Amount <= 0 ||
+		f.refunded[intentID]+amount.Amount > in.Amount.Amount {
+		return nil, ErrInvalidState
+	}
+	f.refunded[intentID] += amount.Amount
+	f.next++
+	re := &Refund{ID: fmt.Sprintf("re_fake_%d", f.next), IntentID: intentID, Amount: amount, Status: RefundSucceeded}
+	if idempotencyKey != "" {
+		f.keys[idempotencyKey] = re
+	}
+	out := *re
+	return &out, nil
+}
+
+// ParseEvent implements Provider
+func (f *Fake) ParseEvent(payload []byte, header http.Header) (*Event, error) {
+	if err := Verify(payload, header.Get(FakeSignatureHeader), f.secret, f.now()); err != nil {
+		return nil, err
+	}
+	var ev Event
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return nil, fmt.Errorf("fake: malformed event: %w", err)
+	}
+	if ev.ID == "" {
+		return nil, errors.New("fake: event has no ID")
+	}
+	return &ev, nil
+}
+
+// Webhook encodes and signs ev as the fake would deliver it
+func (f *Fake) Webhook(ev Event) ([]byte, http.Header) {
+	payload, _ := json.Marshal(ev)
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, Sign(payload, f.secret, f.now()))
+	return payload, header
+}
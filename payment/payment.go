@@ -0,0 +1,118 @@
+// Package payment talks to payment providers: it creates payment intents,
+// captures and refunds them, and verifies the webhooks reporting their
+// progress
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"app/config"
+	"app/money"
+)
+
+// Errors returned by providers
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrNotFound         = errors.New("payment intent not found")
+	ErrInvalidState     = errors.New("payment intent is not in a state allowing this")
+)
+
+// Intent states, normalized across providers
+const (
+	IntentRequiresPayment = "requires_payment"
+	IntentRequiresCapture = "requires_capture"
+	IntentSucceeded       = "succeeded"
+	IntentCanceled        = "canceled"
+	// IntentFailed is recorded by the app when a failure is reported;
+	// providers let the buyer retry the same intent instead
+	IntentFailed = "failed"
+)
+
+// Refund states
+const (
+	RefundPending   = "pending"
+	RefundSucceeded = "succeeded"
+	RefundFailed    = "failed"
+)
+
+// Webhook event types the app acts on. Other provider events parse with an
+// empty Type.
+const (
+	EventAuthorized = "payment.authorized"
+	EventSucceeded  = "payment.succeeded"
+	EventFailed     = "payment.failed"
+	EventCanceled   = "payment.canceled"
+	EventRefunded   = "refund.succeeded"
+)
+
+// Intent is a payment the buyer completes with the client secret
+type Intent struct {
+	ID           string
+	ClientSecret string
+	Amount       money.Money
+	Status       string
+}
+
+// Refund returns part or all of a captured intent
+type Refund struct {
+	ID       string
+	IntentID string
+	Amount   money.Money
+	Status   string
+}
+
+// Event is a verified webhook delivery
+type Event struct {
+	// ID is unique per event and repeats when the provider retries
+	ID       string      `json:"id"`
+	Type     string      `json:"type"`
+	IntentID string      `json:"intent_id"`
+	Amount   money.Money `json:"amount"`
+	// RefundID is set for refund events
+	RefundID string `json:"refund_id,omitempty"`
+}
+
+// Provider is a payment service. Intents are authorized first and
+// captured separately. The idempotency keys make retried calls return the
+// original result instead of charging or refunding twice.
+type Provider interface {
+	Name() string
+	CreateIntent(ctx context.Context, amount money.Money, idempotencyKey string, metadata map[string]string) (*Intent, error)
+	Capture(ctx context.Context, intentID string) (*Intent, error)
+	Refund(ctx context.Context, intentID string, amount money.Money, idempotencyKey string) (*Refund, error)
+	// ParseEvent verifies the signature of a webhook delivery and decodes it
+	ParseEvent(payload []byte, header http.Header) (*Event, error)
+}
+
+// FromConfig builds the provider selected by PAYMENT_PROVIDER, "stripe"
+// or "fake". There is no default, so the fake is never picked by mistake.
+// It keeps intents in the memory of each process and signs webhooks with
+// PAYMENT_WEBHOOK_SECRET, which must be set.
+func FromConfig() (Provider, error) {
+	switch strings.ToLower(config.Config("PAYMENT_PROVIDER")) {
+	case "":
+		return nil, errors.New("PAYMENT_PROVIDER is not set, choose stripe or fake")
+	case "fake":
+		secret := config.Config("PAYMENT_WEBHOOK_SECRET")
+		if secret == "" {
+			return nil, errors.New("PAYMENT_WEBHOOK_SECRET is required with PAYMENT_PROVIDER=fake")
+		}
+		return NewFake([]byte(secret)), nil
+	case "stripe":
+		stripe, err := NewStripe(StripeConfig{
+			SecretKey:     config.Config("STRIPE_SECRET_KEY"),
+			WebhookSecret: config.Config("STRIPE_WEBHOOK_SECRET"),
+			BaseURL:       config.Config("STRIPE_API_URL"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return stripe, nil
+	default:
+		return nil, fmt.Errorf("unknown PAYMENT_PROVIDER %q", config.Config("PAYMENT_PROVIDER"))
+	}
+}
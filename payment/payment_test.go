@@ -0,0 +1,159 @@
+package payment_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"app/money"
+	"app/payment"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignature(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	payload := []byte(`{"id":"evt_1"}`)
+	header := payment.Sign(payload, []byte("whsec"), now)
+
+	assert.NoError(t, payment.Verify(payload, header, []byte("whsec"), now.Add(time.Minute)))
+	assert.ErrorIs(t, payment.Verify([]byte(`{"id":"evt_2"}`), header, []byte("whsec"), now), payment.ErrInvalidSignature)
+	assert.ErrorIs(t, payment.Verify(payload, header, []byte("other"), now), payment.ErrInvalidSignature)
+	assert.ErrorIs(t, payment.Verify(payload, header, []byte("whsec"), now.Add(10*time.Minute)), payment.ErrInvalidSignature)
+	assert.ErrorIs(t, payment.Verify(payload, "", []byte("whsec"), now), payment.ErrInvalidSignature)
+
+	// A header may carry signatures from an old and a new secret
+	rotated := header + ",v1=" + payment.Sign(payload, []byte("new"), now)[len("t=1700000000,v1="):]
+	assert.NoError(t, payment.Verify(payload, rotated, []byte("new"), now))
+}
+
+func TestStripe_API(t *testing.T) {
+	var got *http.Request
+	var form url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got, form = r, mustParse(t, string(body))
+		switch r.URL.Path {
+		case "/v1/payment_intents":
+			io.WriteString(w, `{"id":"pi_1","client_secret":"pi_1_secret","amount":1999,"currency":"eur","status":"requires_payment_method"}`)
+		case "/v1/payment_intents/pi_1/capture":
+			io.WriteString(w, `{"id":"pi_1","amount":1999,"currency":"eur","status":"succeeded"}`)
+		case "/v1/refunds":
+			io.WriteString(w, `{"id":"re_1","amount":500,"currency":"eur","payment_intent":"pi_1","status":"succeeded"}`)
+		default:
+			w.WriteHeader(404)
+			io.WriteString(w, `{"error":{"message":"No such payment_intent"}}`)
+		}
+	}))
+	defer srv.Close()
+
+	stripe, err := payment.NewStripe(payment.StripeConfig{SecretKey: "sk_test", WebhookSecret: "whsec", BaseURL: srv.URL})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	in, err := stripe.CreateIntent(ctx, money.New(1999, "EUR"), "order-7", map[string]string{"order_id": "7"})
+	require.NoError(t, err)
+	assert.Equal(t, &payment.Intent{ID: "pi_1", ClientSecret: "pi_1_secret", Amount: money.New(1999, "EUR"), Status: payment.IntentRequiresPayment}, in)
+	user, _, _ := got.BasicAuth()
+	assert.Equal(t, "sk_test", user)
+	assert.Equal(t, "order-7", got.Header.Get("Idempotency-Key"))
+	assert.Equal(t, "1999", form.Get("amount"))
+	assert.Equal(t, "eur", form.Get("currency"))
+	assert.Equal(t, "manual", form.Get("capture_method"))
+	assert.Equal(t, "7", form.Get("metadata[order_id]"))
+
+	in, err = stripe.Capture(ctx, "pi_1")
+	require.NoError(t, err)
+	assert.Equal(t, payment.IntentSucceeded, in.Status)
+
+	re, err := stripe.Refund(ctx, "pi_1", money.New(500, "EUR"), "refund-1")
+	require.NoError(t, err)
+	assert.Equal(t, &payment.Refund{ID: "re_1", IntentID: "pi_1", Amount: money.New(500, "EUR"), Status: payment.RefundSucceeded}, re)
+	assert.Equal(t, "pi_1", form.Get("payment_intent"))
+
+	_, err = stripe.Capture(ctx, "pi_missing")
+	assert.ErrorIs(t, err, payment.ErrNotFound)
+}
+
+func mustParse(t *testing.T, s string) url.Values {
+	v, err := url.ParseQuery(s)
+	require.NoError(t, err)
+	return v
+}
+
+func TestStripe_ParseEvent(t *testing.T) {
+	stripe, err := payment.NewStripe(payment.StripeConfig{SecretKey: "sk_test", WebhookSecret: "whsec"})
+	require.NoError(t, err)
+	now := time.Unix(1_700_000_000, 0)
+	stripe.SetNow(now)
+
+	parse := func(payload string) (*payment.Event, error) {
+		h := http.Header{}
+		h.Set("Stripe-Signature", payment.Sign([]byte(payload), []byte("whsec"), now))
+		return stripe.ParseEvent([]byte(payload), h)
+	}
+
+	ev, err := parse(`{"id":"evt_1","type":"payment_intent.succeeded","data":{"object":{"id":"pi_1","amount":1999,"amount_received":1999,"currency":"eur"}}}`)
+	require.NoError(t, err)
+	assert.Equal(t, &payment.Event{ID: "evt_1", Type: payment.EventSucceeded, IntentID: "pi_1", Amount: money.New(1999, "EUR")}, ev)
+
+	ev, err = parse(`{"id":"evt_2","type":"refund.updated","data":{"object":{"id":"re_1","amount":500,"currency":"eur","payment_intent":"pi_1","status":"succeeded"}}}`)
+	require.NoError(t, err)
+	assert.Equal(t, &payment.Event{ID: "evt_2", Type: payment.EventRefunded, IntentID: "pi_1", Amount: money.New(500, "EUR"), RefundID: "re_1"}, ev)
+
+	ev, err = parse(`{"id":"evt_3","type":"customer.created","data":{"object":{}}}`)
+	require.NoError(t, err)
+	assert.Empty(t, ev.Type)
+
+	_, err = stripe.ParseEvent([]byte(`{"id":"evt_1"}`), http.Header{"Stripe-Signature": {"t=1,v1=00"}})
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+}
+
+func TestFake_Flow(t *testing.T) {
+	fake := payment.NewFake([]byte("secret"))
+	ctx := context.Background()
+
+	in, err := fake.CreateIntent(ctx, money.New(1000, "EUR"), "order-1", nil)
+	require.NoError(t, err)
+	again, err := fake.CreateIntent(ctx, money.New(1000, "EUR"), "order-1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, in.ID, again.ID)
+
+	_, err = fake.Capture(ctx, in.ID)
+	assert.ErrorIs(t, err, payment.ErrInvalidState)
+	require.NoError(t, fake.Authorize(in.ID))
+	_, err = fake.Capture(ctx, in.ID)
+	require.NoError(t, err)
+
+	_, err = fake.Refund(ctx, in.ID, money.New(600, "EUR"), "r1")
+	require.NoError(t, err)
+	_, err = fake.Refund(ctx, in.ID, money.New(600, "EUR"), "r2")
+	assert.ErrorIs(t, err, payment.ErrInvalidState, "more than was paid")
+
+	payload, header := fake.Webhook(payment.Event{ID: "evt_1", Type: payment.EventSucceeded, IntentID: in.ID, Amount: in.Amount})
+	ev, err := fake.ParseEvent(payload, header)
+	require.NoError(t, err)
+	assert.Equal(t, in.ID, ev.IntentID)
+}
+
+func TestFromConfig(t *testing.T) {
+	t.Setenv("SECRET", "jwt-secret")
+	t.Setenv("PAYMENT_PROVIDER", "")
+	t.Setenv("PAYMENT_WEBHOOK_SECRET", "")
+	_, err := payment.FromConfig()
+	assert.ErrorContains(t, err, "PAYMENT_PROVIDER", "the fake is never a silent default")
+
+	t.Setenv("PAYMENT_PROVIDER", "fake")
+	_, err = payment.FromConfig()
+	assert.ErrorContains(t, err, "PAYMENT_WEBHOOK_SECRET", "the JWT secret doesn't sign payment webhooks")
+
+	t.Setenv("PAYMENT_WEBHOOK_SECRET", "whsec")
+	provider, err := payment.FromConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "fake", provider.Name())
+}
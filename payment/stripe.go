@@ -0,0 +1,242 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"app/money"
+)
+
+// StripeConfig holds the API and webhook secrets of a Stripe account.
+// BaseURL defaults to https://api.stripe.com and may point at a compatible
+// server such as stripe-mock.
+type StripeConfig struct {
+	SecretKey     string
+	WebhookSecret string
+	BaseURL       string
+}
+
+// Stripe is a Provider backed by the Stripe API
+type Stripe struct {
+	cfg    StripeConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewStripe creates a Stripe provider
+func NewStripe(cfg StripeConfig) (*Stripe, error) {
+	if cfg.SecretKey == "" || cfg.WebhookSecret == "" {
+		return nil, errors.New("stripe: secret key and webhook secret are required")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.stripe.com"
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	return &Stripe{cfg: cfg, client: &http.Client{Timeout: 30 * time.Second}, now: time.Now}, nil
+}
+
+// Name implements Provider
+func (s *Stripe) Name() string { return "stripe" }
+
+// stripeIntent is the part of a Stripe PaymentIntent the app reads
+type stripeIntent struct {
+	ID               string `json:"id"`
+	ClientSecret     string `json:"client_secret"`
+	Amount           int64  `json:"amount"`
+	AmountCapturable int64  `json:"amount_capturable"`
+	AmountReceived   int64  `json:"amount_received"`
+	Currency         string `json:"currency"`
+	Status           string `json:"status"`
+}
+
+// stripeRefund is the part of a Stripe Refund the app reads
+type stripeRefund struct {
+	ID            string `json:"id"`
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+	PaymentIntent string `json:"payment_intent"`
+	Status        string `json:"status"`
+}
+
+// CreateIntent implements Provider. Intents use manual capture.
+func (s *Stripe) CreateIntent(ctx context.Context, amount money.Money, idempotencyKey string, metadata map[string]string) (*Intent, error) {
+	form := url.Values{
+		"amount":                             {strconv.FormatInt(amount.Amount, 10)},
+		"currency":                           {strings.ToLower(string(amount.Currency))},
+		"capture_method":                     {"manual"},
+		"automatic_payment_methods[enabled]": {"true"},
+	}
+	for k, v := range metadata {
+		form.Set("metadata["+k+"]", v)
+	}
+	var pi stripeIntent
+	if err := s.post(ctx, "/v1/payment_intents", form, idempotencyKey, &pi); err != nil {
+		return nil, err
+	}
+	return pi.intent()
+}
+
+// Capture implements Provider
+func (s *Stripe) Capture(ctx context.Context, intentID string) (*Intent, error) {
+	var pi stripeIntent
+	if err := s.post(ctx, "/v1/payment_intents/"+url.PathEscape(intentID)+"/capture", url.Values{}, "capture-"+intentID, &pi); err != nil {
+		return nil, err
+	}
+	return pi.intent()
+}
+
+// Refund implements Provider
+func (s *Stripe) Refund(ctx context.Context, intentID string, amount money.Money, idempotencyKey string) (*Refund, error) {
+	form := url.Values{
+		"payment_intent": {intentID},
+		"amount":         {strconv.FormatInt(amount.Amount, 10)},
+	}
+	var re stripeRefund
+	if err := s.post(ctx, "/v1/refunds", form, idempotencyKey, &re); err != nil {
+		return nil, err
+	}
+	return re.refund()
+}
+
+// ParseEvent implements Provider, verifying the Stripe-Signature header
+func (s *Stripe) ParseEvent(payload []byte, header http.Header) (*Event, error) {
+	if err := Verify(payload, header.Get("Stripe-Signature"), []byte(s.cfg.WebhookSecret), s.now()); err != nil {
+		return nil, err
+	}
+	var ev struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data struct {
+			Object json.RawMessage `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return nil, fmt.Errorf("stripe: malformed event: %w", err)
+	}
+	if ev.ID == "" {
+		return nil, errors.New("stripe: event has no ID")
+	}
+	out := &Event{ID: ev.ID}
+
+	switch ev.Type {
+	case "payment_intent.amount_capturable_updated", "payment_intent.succeeded",
+		"payment_intent.payment_failed", "payment_intent.canceled":
+		var pi stripeIntent
+		if err := json.Unmarshal(ev.Data.Object, &pi); err != nil {
+			return nil, fmt.Errorf("stripe: malformed payment intent: %w", err)
+		}
+		amount := pi.Amount
+		switch ev.Type {
+		case "payment_intent.amount_capturable_updated":
+			out.Type, amount = EventAuthorized, pi.AmountCapturable
+		case "payment_intent.succeeded":
+			out.Type, amount = EventSucceeded, pi.AmountReceived
+		case "payment_intent.payment_failed":
+			out.Type = EventFailed
+		default:
+			out.Type = EventCanceled
+		}
+		m, err := stripeMoney(amount, pi.Currency)
+		if err != nil {
+			return nil, err
+		}
+		out.IntentID, out.Amount = pi.ID, m
+	case "refund.created", "refund.updated":
+		var re stripeRefund
+		if err := json.Unmarshal(ev.Data.Object, &re); err != nil {
+			return nil, fmt.Errorf("stripe: malformed refund: %w", err)
+		}
+		if re.Status != "succeeded" {
+			return out, nil
+		}
+		m, err := stripeMoney(re.Amount, re.Currency)
+		if err != nil {
+			return nil, err
+		}
+		out.Type, out.IntentID, out.Amount, out.RefundID = EventRefunded, re.PaymentIntent, m, re.ID
+	}
+	return out, nil
+}
+
+func (s *Stripe) post(ctx context.Context, path string, form url.Values, idempotencyKey string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.BaseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.cfg.SecretKey, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(body, &e)
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %s", ErrNotFound, e.Error.Message)
+		}
+		return fmt.Errorf("stripe: %s: %s", resp.Status, e.Error.Message)
+	}
+	return json.Unmarshal(body, out)
+}
+
+func (pi stripeIntent) intent() (*Intent, error) {
+	m, err := stripeMoney(pi.Amount, pi.Currency)
+	if err != nil {
+		return nil, err
+	}
+	status := IntentRequiresPayment
+	switch pi.Status {
+	case "requires_capture":
+		status = IntentRequiresCapture
+	case "succeeded":
+		status = IntentSucceeded
+	case "canceled":
+		status = IntentCanceled
+	}
+	return &Intent{ID: pi.ID, ClientSecret: pi.ClientSecret, Amount: m, Status: status}, nil
+}
+
+func (re stripeRefund) refund() (*Refund, error) {
+	m, err := stripeMoney(re.Amount, re.Currency)
+	if err != nil {
+		return nil, err
+	}
+	status := RefundPending
+	switch re.Status {
+	case "succeeded":
+		status = RefundSucceeded
+	case "failed", "canceled":
+		status = RefundFailed
+	}
+	return &Refund{ID: re.ID, IntentID: re.PaymentIntent, Amount: m, Status: status}, nil
+}
+
+// stripeMoney reads a Stripe amount, which is in the currency's minor units
+func stripeMoney(amount int64, currency string) (money.Money, error) {
+	c, err := money.ParseCurrency(currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return money.New(amount, c), nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+// AuditFilter selects audit log entries; zero fields match everything
+type AuditFilter struct {
+	ActorID    uint
+	Action     string
+	TargetType string
+	TargetID   uint
+	// From and To bound the creation time, From inclusive
+	From, To time.Time
+	Offset   int
+	Limit    int
+}
+
+// AuditRepository appends to the audit log and searches it. It has no way
+// to change or remove entries.
+type AuditRepository interface {
+	Append(ctx context.Context, entry *model.AuditLog) error
+	// List returns a page of matching entries, newest first, and how many
+	// match in all
+	List(ctx context.Context, filter AuditFilter) ([]model.AuditLog, int64, error)
+	// Each calls fn with every matching entry, oldest first, in batches
+	// of size, ignoring the filter's Offset and Limit
+	Each(ctx context.Context, filter AuditFilter, size int, fn func([]model.AuditLog) error) error
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func (r *auditRepository) Append(ctx context.Context, entry *model.AuditLog) error {
+	return conn(ctx, r.db).Create(entry).Error
+}
+
+func (r *auditRepository) List(ctx context.Context, filter AuditFilter) ([]model.AuditLog, int64, error) {
+	q := auditQuery(conn(ctx, r.db), filter)
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var out []model.AuditLog
+	err := q.Order("id DESC").Offset(filter.Offset).Limit(filter.Limit).Find(&out).Error
+	return out, total, err
+}
+
+func (r *auditRepository) Each(ctx context.Context, filter AuditFilter, size int, fn func([]model.AuditLog) error) error {
+	var batch []model.AuditLog
+	return auditQuery(conn(ctx, r.db), filter).FindInBatches(&batch, size, func(*gorm.DB, int) error {
+		return fn(batch)
+	}).Error
+}
+
+func auditQuery(db *gorm.DB, filter AuditFilter) *gorm.DB {
+	q := db.Model(&model.AuditLog{})
+	if filter.ActorID != 0 {
+		q = q.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		q = q.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		q = q.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		q = q.Where("target_id = ?", filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		q = q.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		q = q.Where("created_at < ?", filter.To)
+	}
+	return q
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+// CartRepository persists carts and their lines
+type CartRepository interface {
+	// FindByUser and FindByGuest load a cart with its lines and their
+	// items. Lines whose item was deleted keep a zero Item.
+	FindByUser(ctx context.Context, userID uint) (*model.Cart, error)
+	FindByGuest(ctx context.Context, token string) (*model.Cart, error)
+	Create(ctx context.Context, cart *model.Cart) error
+	// Delete removes a cart and its lines
+	Delete(ctx context.Context, cart *model.Cart) error
+	// SaveLine creates or updates the line for line.ItemID in line.CartID
+	SaveLine(ctx context.Context, line *model.CartItem) error
+	DeleteLine(ctx context.Context, cartID, itemID uint) error
+	ClearLines(ctx context.Context, cartID uint) error
+}
+
+type cartRepository struct {
+	db *gorm.DB
+}
+
+func (r *cartRepository) find(ctx context.Context, query string, arg any) (*model.Cart, error) {
+	var cart model.Cart
+	err := conn(ctx, r.db).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Items.Item").
+		Where(query, arg).First(&cart).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &cart, nil
+}
+
+func (r *cartRepository) FindByUser(ctx context.Context, userID uint) (*model.Cart, error) {
+	return r.find(ctx, "user_id = ?", userID)
+}
+
+func (r *cartRepository) FindByGuest(ctx context.Context, token string) (*model.Cart, error) {
+	return r.find(ctx, "guest_token = ?", token)
+}
+
+func (r *cartRepository) Create(ctx context.Context, cart *model.Cart) error {
+	return conn(ctx, r.db).Create(cart).Error
+}
+
+func (r *cartRepository) Delete(ctx context.Context, cart *model.Cart) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("cart_id = ?", cart.ID).Delete(&model.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(cart).Error
+	})
+}
+
+func (r *cartRepository) SaveLine(ctx context.Context, line *model.CartItem) error {
+	db := conn(ctx, r.db)
+	var existing model.CartItem
+	err := db.Where("cart_id = ? AND item_id = ?", line.CartID, line.ItemID).First(&existing).Error
+	switch {
+	case err == nil:
+		line.ID = existing.ID
+		line.CreatedAt = existing.CreatedAt
+		return db.Omit("Item").Save(line).Error
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return db.Omit("Item").Create(line).Error
+	default:
+		return err
+	}
+}
+
+func (r *cartRepository) DeleteLine(ctx context.Context, cartID, itemID uint) error {
+	res := conn(ctx, r.db).Where("cart_id = ? AND item_id = ?", cartID, itemID).Delete(&model.CartItem{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *cartRepository) ClearLines(ctx context.Context, cartID uint) error {
+	return conn(ctx, r.db).Where("cart_id = ?", cartID).Delete(&model.CartItem{}).Error
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+// CategoryRepository reads categories
+type CategoryRepository interface {
+	FindByID(ctx context.Context, id uint) (*model.Category, error)
+	List(ctx context.Context) ([]model.Category, error)
+}
+
+type categoryRepository struct {
+	db *gorm.DB
+}
+
+func (r *categoryRepository) FindByID(ctx context.Context, id uint) (*model.Category, error) {
+	var category model.Category
+	if err := conn(ctx, r.db).First(&category, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &category, nil
+}
+
+func (r *categoryRepository) List(ctx context.Context) ([]model.Category, error) {
+	var categories []model.Category
+	if err := conn(ctx, r.db).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+// CommentRepository persists comments
+type CommentRepository interface {
+	ListByUser(ctx context.Context, userID uint) ([]model.Comment, error)
+	// ListByItem returns the comments of an item with their authors,
+	// oldest first, including deleted ones so threads keep their shape
+	ListByItem(ctx context.Context, itemID uint) ([]model.Comment, error)
+	// FindByID loads a comment with its author
+	FindByID(ctx context.Context, id uint) (*model.Comment, error)
+	Create(ctx context.Context, c *model.Comment) error
+	Update(ctx context.Context, c *model.Comment) error
+	SetHidden(ctx context.Context, id uint, hidden bool) error
+	// Delete erases the body of a comment and soft-deletes it
+	Delete(ctx context.Context, c *model.Comment) error
+}
+
+type commentRepository struct {
+	db *gorm.DB
+}
+
+func (r *commentRepository) ListByUser(ctx context.Context, userID uint) ([]model.Comment, error) {
+	var comments []model.Comment
+	if err := conn(ctx, r.db).Where("user_id = ?", userID).Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (r *commentRepository) ListByItem(ctx context.Context, itemID uint) ([]model.Comment, error) {
+	var comments []model.Comment
+	err := conn(ctx, r.db).Unscoped().Preload("User", unscoped).
+		Where("item_id = ?", itemID).Order("id").Find(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (r *commentRepository) FindByID(ctx context.Context, id uint) (*model.Comment, error) {
+	var c model.Comment
+	if err := conn(ctx, r.db).Preload("User", unscoped).First(&c, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &c, nil
+}
+
+func (r *commentRepository) Create(ctx context.Context, c *model.Comment) error {
+	return conn(ctx, r.db).Omit("Item", "User").Create(c).Error
+}
+
+func (r *commentRepository) Update(ctx context.Context, c *model.Comment) error {
+	return conn(ctx, r.db).Model(c).Updates(map[string]any{"body": c.Body, "edited_at": c.EditedAt}).Error
+}
+
+func (r *commentRepository) SetHidden(ctx context.Context, id uint, hidden bool) error {
+	return conn(ctx, r.db).Model(&model.Comment{}).Where("id = ?", id).Update("hidden", hidden).Error
+}
+
+func (r *commentRepository) Delete(ctx context.Context, c *model.Comment) error {
+	db := conn(ctx, r.db)
+	if err := db.Model(c).Update("body", "").Error; err != nil {
+		return err
+	}
+	return db.Delete(c).Error
+}
+
+// unscoped preloads authors even once their account is deactivated
+func unscoped(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
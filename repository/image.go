@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+// ImageRepository persists item images
+type ImageRepository interface {
+	// ListByItem returns the images of an item in display order
+	ListByItem(ctx context.Context, itemID uint) ([]model.ItemImage, error)
+	FindByID(ctx context.Context, id uint) (*model.ItemImage, error)
+	Create(ctx context.Context, image *model.ItemImage) error
+	Update(ctx context.Context, image *model.ItemImage) error
+	Delete(ctx context.Context, image *model.ItemImage) error
+}
+
+type imageRepository struct {
+	db *gorm.DB
+}
+
+func (r *imageRepository) ListByItem(ctx context.Context, itemID uint) ([]model.ItemImage, error) {
+	var images []model.ItemImage
+	if err := conn(ctx, r.db).Where("item_id = ?", itemID).Order("position").Find(&images).Error; err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (r *imageRepository) FindByID(ctx context.Context, id uint) (*model.ItemImage, error) {
+	var image model.ItemImage
+	if err := conn(ctx, r.db).First(&image, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &image, nil
+}
+
+func (r *imageRepository) Create(ctx context.Context, image *model.ItemImage) error {
+	return conn(ctx, r.db).Create(image).Error
+}
+
+func (r *imageRepository) Update(ctx context.Context, image *model.ItemImage) error {
+	return conn(ctx, r.db).Save(image).Error
+}
+
+func (r *imageRepository) Delete(ctx context.Context, image *model.ItemImage) error {
+	return conn(ctx, r.db).Delete(image).Error
+}
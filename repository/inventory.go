@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InventoryRepository persists stock reservations and the adjustment log
+type InventoryRepository interface {
+	CreateReservation(ctx context.Context, r *model.StockReservation) error
+	// FindReservationForUpdate loads a reservation and locks its row, see
+	// ItemRepository.FindForUpdate
+	FindReservationForUpdate(ctx context.Context, id uint) (*model.StockReservation, error)
+	UpdateReservation(ctx context.Context, r *model.StockReservation) error
+	// ListExpiredReservations returns active reservations that expired
+	// before now
+	ListExpiredReservations(ctx context.Context, now time.Time) ([]model.StockReservation, error)
+	// ReservedQuantity sums the active reservations of an item
+	ReservedQuantity(ctx context.Context, itemID uint) (int, error)
+	LogAdjustment(ctx context.Context, a *model.InventoryAdjustment) error
+	// ListAdjustments returns the log of an item, newest first
+	ListAdjustments(ctx context.Context, itemID uint) ([]model.InventoryAdjustment, error)
+}
+
+type inventoryRepository struct {
+	db *gorm.DB
+}
+
+func (r *inventoryRepository) CreateReservation(ctx context.Context, res *model.StockReservation) error {
+	return conn(ctx, r.db).Create(res).Error
+}
+
+func (r *inventoryRepository) FindReservationForUpdate(ctx context.Context, id uint) (*model.StockReservation, error) {
+	var res model.StockReservation
+	if err := conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).First(&res, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &res, nil
+}
+
+func (r *inventoryRepository) UpdateReservation(ctx context.Context, res *model.StockReservation) error {
+	return conn(ctx, r.db).Save(res).Error
+}
+
+func (r *inventoryRepository) ListExpiredReservations(ctx context.Context, now time.Time) ([]model.StockReservation, error) {
+	var out []model.StockReservation
+	err := conn(ctx, r.db).
+		Where("status = ? AND expires_at < ?", model.ReservationActive, now).
+		Order("id").Find(&out).Error
+	return out, err
+}
+
+func (r *inventoryRepository) ReservedQuantity(ctx context.Context, itemID uint) (int, error) {
+	var n int
+	err := conn(ctx, r.db).Model(&model.StockReservation{}).
+		Where("item_id = ? AND status = ?", itemID, model.ReservationActive).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&n).Error
+	return n, err
+}
+
+func (r *inventoryRepository) LogAdjustment(ctx context.Context, a *model.InventoryAdjustment) error {
+	return conn(ctx, r.db).Create(a).Error
+}
+
+func (r *inventoryRepository) ListAdjustments(ctx context.Context, itemID uint) ([]model.InventoryAdjustment, error) {
+	var out []model.InventoryAdjustment
+	err := conn(ctx, r.db).Where("item_id = ?", itemID).Order("id DESC").Find(&out).Error
+	return out, err
+}
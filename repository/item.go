@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ItemFilter narrows item listings, which only include active items that
+// aren't hidden unless All is set
+type ItemFilter struct {
+	// InStock keeps only items with stock left
+	InStock bool
+	// All keeps items of every status, hidden ones too, for their owner
+	All bool
+}
+
+func (f ItemFilter) apply(db *gorm.DB) *gorm.DB {
+	if !f.All {
+		db = db.Where("hidden = ? AND status = ?", false, model.ItemActive)
+	}
+	if f.InStock {
+		db = db.Where("stock > 0")
+	}
+	return db
+}
+
+// ItemRepository persists items
+type ItemRepository interface {
+	List(ctx context.Context, filter ItemFilter) ([]model.Item, error)
+	ListByCategory(ctx context.Context, categoryID uint, filter ItemFilter) ([]model.Item, error)
+	ListByUser(ctx context.Context, userID uint, filter ItemFilter) ([]model.Item, error)
+	// ListScheduled returns the items whose publish or unpublish time is
+	// at or before now
+	ListScheduled(ctx context.Context, now time.Time) ([]model.Item, error)
+	FindByID(ctx context.Context, id uint) (*model.Item, error)
+	// FindForUpdate loads an item and locks its row until the surrounding
+	// transaction ends. Postgres uses SELECT ... FOR UPDATE; SQLite has
+	// no row locks but already serializes writing transactions.
+	FindForUpdate(ctx context.Context, id uint) (*model.Item, error)
+	// SetStock stores a new stock level for an item
+	SetStock(ctx context.Context, id uint, stock int) error
+	SetHidden(ctx context.Context, id uint, hidden bool) error
+	// AdjustCounts adds to the like and comment counters of an item
+	AdjustCounts(ctx context.Context, id uint, likes, comments int) error
+	// CountByUser counts the items of userID listed publicly
+	CountByUser(ctx context.Context, userID uint) (int64, error)
+	Create(ctx context.Context, item *model.Item) error
+	Update(ctx context.Context, item *model.Item) error
+	Delete(ctx context.Context, item *model.Item) error
+	// DeleteByUser soft-deletes every item listed by userID
+	DeleteByUser(ctx context.Context, userID uint) error
+	// RestoreByUser undeletes the items of userID deleted at or after since
+	RestoreByUser(ctx context.Context, userID uint, since time.Time) error
+}
+
+type itemRepository struct {
+	db *gorm.DB
+}
+
+// withImages preloads item images in display order
+func withImages(db *gorm.DB) *gorm.DB {
+	return db.Preload("Images", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	})
+}
+
+func (r *itemRepository) List(ctx context.Context, filter ItemFilter) ([]model.Item, error) {
+	var items []model.Item
+	if err := filter.apply(withImages(conn(ctx, r.db))).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *itemRepository) ListByCategory(ctx context.Context, categoryID uint, filter ItemFilter) ([]model.Item, error) {
+	var items []model.Item
+	if err := filter.apply(withImages(conn(ctx, r.db))).Where("category_id = ?", categoryID).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *itemRepository) ListByUser(ctx context.Context, userID uint, filter ItemFilter) ([]model.Item, error) {
+	var items []model.Item
+	if err := filter.apply(withImages(conn(ctx, r.db))).Where("user_id = ?", userID).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *itemRepository) ListScheduled(ctx context.Context, now time.Time) ([]model.Item, error) {
+	var items []model.Item
+	err := conn(ctx, r.db).Where("publish_at <= ? OR unpublish_at <= ?", now, now).Order("id").Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *itemRepository) FindByID(ctx context.Context, id uint) (*model.Item, error) {
+	var item model.Item
+	if err := withImages(conn(ctx, r.db)).Preload("User").First(&item, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &item, nil
+}
+
+func (r *itemRepository) FindForUpdate(ctx context.Context, id uint) (*model.Item, error) {
+	var item model.Item
+	if err := conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &item, nil
+}
+
+func (r *itemRepository) SetStock(ctx context.Context, id uint, stock int) error {
+	return conn(ctx, r.db).Model(&model.Item{}).Where("id = ?", id).Update("stock", stock).Error
+}
+
+func (r *itemRepository) SetHidden(ctx context.Context, id uint, hidden bool) error {
+	return conn(ctx, r.db).Model(&model.Item{}).Where("id = ?", id).Update("hidden", hidden).Error
+}
+
+func (r *itemRepository) AdjustCounts(ctx context.Context, id uint, likes, comments int) error {
+	return conn(ctx, r.db).Model(&model.Item{}).Where("id = ?", id).Updates(map[string]any{
+		"like_count":    gorm.Expr("like_count + ?", likes),
+		"comment_count": gorm.Expr("comment_count + ?", comments),
+	}).Error
+}
+
+func (r *itemRepository) CountByUser(ctx context.Context, userID uint) (int64, error) {
+	var n int64
+	err := ItemFilter{}.apply(conn(ctx, r.db).Model(&model.Item{})).Where("user_id = ?", userID).Count(&n).Error
+	return n, err
+}
+
+func (r *itemRepository) Create(ctx context.Context, item *model.Item) error {
+	return conn(ctx, r.db).Create(item).Error
+}
+
+func (r *itemRepository) Update(ctx context.Context, item *model.Item) error {
+	return conn(ctx, r.db).Omit(clause.Associations).Save(item).Error
+}
+
+func (r *itemRepository) Delete(ctx context.Context, item *model.Item) error {
+	return conn(ctx, r.db).Delete(item).Error
+}
+
+func (r *itemRepository) DeleteByUser(ctx context.Context, userID uint) error {
+	return conn(ctx, r.db).Where("user_id = ?", userID).Delete(&model.Item{}).Error
+}
+
+func (r *itemRepository) RestoreByUser(ctx context.Context, userID uint, since time.Time) error {
+	return conn(ctx, r.db).Unscoped().Model(&model.Item{}).
+		Where("user_id = ? AND deleted_at >= ?", userID, since).
+		Update("deleted_at", nil).Error
+}
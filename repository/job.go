@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobRepository is the background job queue
+type JobRepository interface {
+	// Enqueue adds jobs to the queue, inside the caller's transaction if
+	// any. Jobs whose UniqueKey is taken are skipped.
+	Enqueue(ctx context.Context, jobs ...*model.Job) error
+	// Claim marks up to limit pending jobs due by now as running for
+	// worker and returns them. Rows claimed by other workers are skipped
+	// rather than waited for.
+	Claim(ctx context.Context, worker string, now time.Time, limit int) ([]model.Job, error)
+	// Complete, Retry and Bury record the outcome of a job running for
+	// worker. They fail with ErrNotFound once its lock was released, so a
+	// worker that timed out can't overwrite a later attempt.
+	Complete(ctx context.Context, id uint, worker string) error
+	// Retry puts a failed job back in the queue to run at runAt
+	Retry(ctx context.Context, id uint, worker string, runAt time.Time, reason string) error
+	// Bury marks a job dead after its last failed attempt
+	Bury(ctx context.Context, id uint, worker string, reason string) error
+	// Requeue releases running jobs locked before cutoff, whose worker
+	// presumably died, returning how many it released. Jobs out of
+	// attempts are buried instead.
+	Requeue(ctx context.Context, cutoff time.Time) (int64, error)
+	// Prune deletes jobs done before cutoff, returning how many
+	Prune(ctx context.Context, cutoff time.Time) (int64, error)
+	// List returns the jobs in a status, oldest first
+	List(ctx context.Context, status string, limit int) ([]model.Job, error)
+}
+
+type jobRepository struct {
+	db *gorm.DB
+}
+
+func (r *jobRepository) Enqueue(ctx context.Context, jobs ...*model.Job) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	return conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(jobs).Error
+}
+
+func (r *jobRepository) Claim(ctx context.Context, worker string, now time.Time, limit int) ([]model.Job, error) {
+	var jobs []model.Job
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ?", model.JobPending, now).
+			Order("run_at, id").Limit(limit).Find(&jobs).Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+		ids := make([]uint, len(jobs))
+		for i := range jobs {
+			ids[i] = jobs[i].ID
+			jobs[i].Status = model.JobRunning
+			jobs[i].Attempts++
+			jobs[i].LockedBy = worker
+			jobs[i].LockedAt = &now
+		}
+		return tx.Model(&model.Job{}).Where("id IN ?", ids).Updates(map[string]any{
+			"status":    model.JobRunning,
+			"attempts":  gorm.Expr("attempts + 1"),
+			"locked_by": worker,
+			"locked_at": now,
+		}).Error
+	})
+	return jobs, err
+}
+
+func (r *jobRepository) Complete(ctx context.Context, id uint, worker string) error {
+	return r.finish(ctx, id, worker, map[string]any{"status": model.JobDone, "last_error": ""})
+}
+
+func (r *jobRepository) Retry(ctx context.Context, id uint, worker string, runAt time.Time, reason string) error {
+	return r.finish(ctx, id, worker, map[string]any{"status": model.JobPending, "run_at": runAt, "last_error": reason})
+}
+
+func (r *jobRepository) Bury(ctx context.Context, id uint, worker string, reason string) error {
+	return r.finish(ctx, id, worker, map[string]any{"status": model.JobDead, "last_error": reason})
+}
+
+// finish releases the lock worker holds on a running job, applying changes
+func (r *jobRepository) finish(ctx context.Context, id uint, worker string, changes map[string]any) error {
+	changes["locked_by"] = ""
+	changes["locked_at"] = nil
+	res := conn(ctx, r.db).Model(&model.Job{}).
+		Where("id = ? AND locked_by = ? AND status = ?", id, worker, model.JobRunning).
+		Updates(changes)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *jobRepository) Requeue(ctx context.Context, cutoff time.Time) (int64, error) {
+	res := conn(ctx, r.db).Model(&model.Job{}).
+		Where("status = ? AND locked_at < ?", model.JobRunning, cutoff).
+		Updates(map[string]any{
+			"status":     gorm.Expr("CASE WHEN attempts >= max_attempts THEN ? ELSE ? END", model.JobDead, model.JobPending),
+			"last_error": "worker lock expired",
+			"locked_by":  "",
+			"locked_at":  nil,
+		})
+	return res.RowsAffected, res.Error
+}
+
+func (r *jobRepository) Prune(ctx context.Context, cutoff time.Time) (int64, error) {
+	res := conn(ctx, r.db).Where("status = ? AND updated_at < ?", model.JobDone, cutoff).Delete(&model.Job{})
+	return res.RowsAffected, res.Error
+}
+
+func (r *jobRepository) List(ctx context.Context, status string, limit int) ([]model.Job, error) {
+	var jobs []model.Job
+	err := conn(ctx, r.db).Where("status = ?", status).Order("id").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LikeRepository persists likes
+type LikeRepository interface {
+	ListByUser(ctx context.Context, userID uint) ([]model.Like, error)
+	// Add likes an item, returning false when userID already liked it
+	Add(ctx context.Context, userID, itemID uint) (bool, error)
+	// Remove unlikes an item, returning false when it wasn't liked
+	Remove(ctx context.Context, userID, itemID uint) (bool, error)
+	Exists(ctx context.Context, userID, itemID uint) (bool, error)
+}
+
+type likeRepository struct {
+	db *gorm.DB
+}
+
+func (r *likeRepository) ListByUser(ctx context.Context, userID uint) ([]model.Like, error) {
+	var likes []model.Like
+	if err := conn(ctx, r.db).Where("user_id = ?", userID).Find(&likes).Error; err != nil {
+		return nil, err
+	}
+	return likes, nil
+}
+
+func (r *likeRepository) Add(ctx context.Context, userID, itemID uint) (bool, error) {
+	res := conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(&model.Like{UserID: userID, ItemID: itemID})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *likeRepository) Remove(ctx context.Context, userID, itemID uint) (bool, error) {
+	res := conn(ctx, r.db).Where("user_id = ? AND item_id = ?", userID, itemID).Delete(&model.Like{})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *likeRepository) Exists(ctx context.Context, userID, itemID uint) (bool, error) {
+	var n int64
+	err := conn(ctx, r.db).Model(&model.Like{}).Where("user_id = ? AND item_id = ?", userID, itemID).Count(&n).Error
+	return n > 0, err
+}
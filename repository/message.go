@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ConversationSummary is a conversation with its latest message and how
+// many messages the user listing it hasn't read
+type ConversationSummary struct {
+	Conversation model.Conversation
+	LastMessage  *model.Message
+	Unread       int64
+}
+
+// MessageRepository persists conversations, their messages and blocks
+type MessageRepository interface {
+	// FindConversation loads a conversation with its item and participants
+	FindConversation(ctx context.Context, id uint) (*model.Conversation, error)
+	// FindByItemAndBuyer returns ErrNotFound when the buyer hasn't written
+	// about the item yet
+	FindByItemAndBuyer(ctx context.Context, itemID, buyerID uint) (*model.Conversation, error)
+	CreateConversation(ctx context.Context, c *model.Conversation) error
+	// ListConversations returns the conversations of a user, most recently
+	// active first
+	ListConversations(ctx context.Context, userID uint) ([]ConversationSummary, error)
+	// ListMessages returns up to limit messages of a conversation older
+	// than before, or the latest ones when before is 0, oldest first
+	ListMessages(ctx context.Context, conversationID, before uint, limit int) ([]model.Message, error)
+	// ListSentBy returns the messages a user sent, oldest first
+	ListSentBy(ctx context.Context, userID uint) ([]model.Message, error)
+	// AddMessage stores a message and bumps the conversation's activity
+	AddMessage(ctx context.Context, m *model.Message) error
+	// MarkRead marks the messages of a conversation sent to readerID read,
+	// returning how many were unread
+	MarkRead(ctx context.Context, conversationID, readerID uint, at time.Time) (int64, error)
+	Block(ctx context.Context, blockerID, blockedID uint) error
+	// Unblock returns ErrNotFound when blockedID wasn't blocked
+	Unblock(ctx context.Context, blockerID, blockedID uint) error
+	// Blocked reports whether either user blocked the other
+	Blocked(ctx context.Context, a, b uint) (bool, error)
+}
+
+type messageRepository struct {
+	db *gorm.DB
+}
+
+func (r *messageRepository) FindConversation(ctx context.Context, id uint) (*model.Conversation, error) {
+	var c model.Conversation
+	err := conn(ctx, r.db).Preload("Item", unscoped).Preload("Buyer", unscoped).Preload("Seller", unscoped).First(&c, id).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &c, nil
+}
+
+func (r *messageRepository) FindByItemAndBuyer(ctx context.Context, itemID, buyerID uint) (*model.Conversation, error) {
+	var c model.Conversation
+	if err := conn(ctx, r.db).Where("item_id = ? AND buyer_id = ?", itemID, buyerID).First(&c).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &c, nil
+}
+
+func (r *messageRepository) CreateConversation(ctx context.Context, c *model.Conversation) error {
+	return conn(ctx, r.db).Omit("Item", "Buyer", "Seller").Create(c).Error
+}
+
+func (r *messageRepository) ListConversations(ctx context.Context, userID uint) ([]ConversationSummary, error) {
+	db := conn(ctx, r.db)
+	var conversations []model.Conversation
+	err := db.Preload("Item", unscoped).Preload("Buyer", unscoped).Preload("Seller", unscoped).
+		Where("buyer_id = ? OR seller_id = ?", userID, userID).
+		Order("last_message_at DESC, id DESC").Find(&conversations).Error
+	if err != nil || len(conversations) == 0 {
+		return nil, err
+	}
+	ids := make([]uint, len(conversations))
+	for i, c := range conversations {
+		ids[i] = c.ID
+	}
+
+	var last []model.Message
+	latest := db.Model(&model.Message{}).Select("MAX(id)").Where("conversation_id IN ?", ids).Group("conversation_id")
+	if err := db.Where("id IN (?)", latest).Find(&last).Error; err != nil {
+		return nil, err
+	}
+	var unread []struct {
+		ConversationID uint
+		N              int64
+	}
+	err = db.Model(&model.Message{}).Select("conversation_id, COUNT(*) AS n").
+		Where("conversation_id IN ? AND sender_id <> ? AND read_at IS NULL", ids, userID).
+		Group("conversation_id").Scan(&unread).Error
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]ConversationSummary, len(conversations))
+	for i, c := range conversations {
+		out[i].Conversation = c
+		for j := range last {
+			if last[j].ConversationID == c.ID {
+				out[i].LastMessage = &last[j]
+			}
+		}
+		for _, u := range unread {
+			if u.ConversationID == c.ID {
+				out[i].Unread = u.N
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r *messageRepository) ListMessages(ctx context.Context, conversationID, before uint, limit int) ([]model.Message, error) {
+	q := conn(ctx, r.db).Where("conversation_id = ?", conversationID)
+	if before != 0 {
+		q = q.Where("id < ?", before)
+	}
+	var out []model.Message
+	if err := q.Order("id DESC").Limit(limit).Find(&out).Error; err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
+
+func (r *messageRepository) ListSentBy(ctx context.Context, userID uint) ([]model.Message, error) {
+	var out []model.Message
+	err := conn(ctx, r.db).Where("sender_id = ?", userID).Order("id").Find(&out).Error
+	return out, err
+}
+
+func (r *messageRepository) AddMessage(ctx context.Context, m *model.Message) error {
+	db := conn(ctx, r.db)
+	if err := db.Create(m).Error; err != nil {
+		return err
+	}
+	return db.Model(&model.Conversation{ID: m.ConversationID}).Update("last_message_at", m.CreatedAt).Error
+}
+
+func (r *messageRepository) MarkRead(ctx context.Context, conversationID, readerID uint, at time.Time) (int64, error) {
+	res := conn(ctx, r.db).Model(&model.Message{}).
+		Where("conversation_id = ? AND sender_id <> ? AND read_at IS NULL", conversationID, readerID).
+		Update("read_at", at)
+	return res.RowsAffected, res.Error
+}
+
+func (r *messageRepository) Block(ctx context.Context, blockerID, blockedID uint) error {
+	return conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.Block{BlockerID: blockerID, BlockedID: blockedID}).Error
+}
+
+func (r *messageRepository) Unblock(ctx context.Context, blockerID, blockedID uint) error {
+	res := conn(ctx, r.db).Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).Delete(&model.Block{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *messageRepository) Blocked(ctx context.Context, a, b uint) (bool, error) {
+	var n int64
+	err := conn(ctx, r.db).Model(&model.Block{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", a, b, b, a).
+		Count(&n).Error
+	return n > 0, err
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NotificationFilter pages through the notifications of a user
+type NotificationFilter struct {
+	UnreadOnly bool
+	Offset     int
+	Limit      int
+}
+
+// NotificationRepository persists notifications and the preferences of
+// who gets them
+type NotificationRepository interface {
+	Create(ctx context.Context, notifications []model.Notification) error
+	// List returns a page of the notifications of a user, newest first,
+	// and how many match the filter in all
+	List(ctx context.Context, userID uint, filter NotificationFilter) ([]model.Notification, int64, error)
+	CountUnread(ctx context.Context, userID uint) (int64, error)
+	// MarkRead marks a notification of userID read, returning ErrNotFound
+	// for anyone else's
+	MarkRead(ctx context.Context, userID, id uint, at time.Time) error
+	// MarkAllRead marks every unread notification of userID read,
+	// returning how many it marked
+	MarkAllRead(ctx context.Context, userID uint, at time.Time) (int64, error)
+	// Preferences returns the stored preferences of a user
+	Preferences(ctx context.Context, userID uint) ([]model.NotificationPreference, error)
+	// PreferencesFor returns the stored preferences of several users for
+	// one type, keyed by user
+	PreferencesFor(ctx context.Context, userIDs []uint, kind string) (map[uint]model.NotificationPreference, error)
+	SavePreferences(ctx context.Context, prefs []model.NotificationPreference) error
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func (r *notificationRepository) Create(ctx context.Context, notifications []model.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return conn(ctx, r.db).Create(&notifications).Error
+}
+
+func (r *notificationRepository) List(ctx context.Context, userID uint, filter NotificationFilter) ([]model.Notification, int64, error) {
+	q := conn(ctx, r.db).Model(&model.Notification{}).Where("user_id = ?", userID)
+	if filter.UnreadOnly {
+		q = q.Where("read_at IS NULL")
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var out []model.Notification
+	err := q.Order("created_at DESC, id DESC").Offset(filter.Offset).Limit(filter.Limit).Find(&out).Error
+	return out, total, err
+}
+
+func (r *notificationRepository) CountUnread(ctx context.Context, userID uint) (int64, error) {
+	var n int64
+	err := conn(ctx, r.db).Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&n).Error
+	return n, err
+}
+
+func (r *notificationRepository) MarkRead(ctx context.Context, userID, id uint, at time.Time) error {
+	var n model.Notification
+	if err := conn(ctx, r.db).Where("user_id = ?", userID).First(&n, id).Error; err != nil {
+		return translate(err)
+	}
+	if n.ReadAt != nil {
+		return nil
+	}
+	return conn(ctx, r.db).Model(&n).Update("read_at", at).Error
+}
+
+func (r *notificationRepository) MarkAllRead(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	res := conn(ctx, r.db).Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Update("read_at", at)
+	return res.RowsAffected, res.Error
+}
+
+func (r *notificationRepository) Preferences(ctx context.Context, userID uint) ([]model.NotificationPreference, error) {
+	var out []model.NotificationPreference
+	err := conn(ctx, r.db).Where("user_id = ?", userID).Find(&out).Error
+	return out, err
+}
+
+func (r *notificationRepository) PreferencesFor(ctx context.Context, userIDs []uint, kind string) (map[uint]model.NotificationPreference, error) {
+	out := map[uint]model.NotificationPreference{}
+	if len(userIDs) == 0 {
+		return out, nil
+	}
+	var prefs []model.NotificationPreference
+	if err := conn(ctx, r.db).Where("user_id IN ? AND type = ?", userIDs, kind).Find(&prefs).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range prefs {
+		out[p.UserID] = p
+	}
+	return out, nil
+}
+
+func (r *notificationRepository) SavePreferences(ctx context.Context, prefs []model.NotificationPreference) error {
+	if len(prefs) == 0 {
+		return nil
+	}
+	return conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"in_app", "email", "updated_at"}),
+	}).Create(&prefs).Error
+}
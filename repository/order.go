@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderRepository persists orders
+type OrderRepository interface {
+	// FindByID loads an order with its item, even if the item was deleted
+	FindByID(ctx context.Context, id uint) (*model.Order, error)
+	// FindForUpdate is FindByID locking the order row, see
+	// ItemRepository.FindForUpdate
+	FindForUpdate(ctx context.Context, id uint) (*model.Order, error)
+	ListByUser(ctx context.Context, userID uint) ([]model.Order, error)
+	Create(ctx context.Context, order *model.Order) error
+	UpdateStatus(ctx context.Context, id uint, status string) error
+}
+
+type orderRepository struct {
+	db *gorm.DB
+}
+
+func (r *orderRepository) FindByID(ctx context.Context, id uint) (*model.Order, error) {
+	return r.find(conn(ctx, r.db), id)
+}
+
+func (r *orderRepository) FindForUpdate(ctx context.Context, id uint) (*model.Order, error) {
+	return r.find(conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}), id)
+}
+
+func (r *orderRepository) find(db *gorm.DB, id uint) (*model.Order, error) {
+	var order model.Order
+	err := db.Preload("Item", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).First(&order, id).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &order, nil
+}
+
+func (r *orderRepository) ListByUser(ctx context.Context, userID uint) ([]model.Order, error) {
+	var orders []model.Order
+	if err := conn(ctx, r.db).Where("user_id = ?", userID).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (r *orderRepository) Create(ctx context.Context, order *model.Order) error {
+	return conn(ctx, r.db).Create(order).Error
+}
+
+func (r *orderRepository) UpdateStatus(ctx context.Context, id uint, status string) error {
+	return conn(ctx, r.db).Model(&model.Order{}).Where("id = ?", id).Update("status", status).Error
+}
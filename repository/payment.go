@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PaymentRepository persists payments, their ledger and processed webhook
+// events
+type PaymentRepository interface {
+	Create(ctx context.Context, p *model.Payment) error
+	Update(ctx context.Context, p *model.Payment) error
+	// FindByOrder returns the newest payment of an order
+	FindByOrder(ctx context.Context, orderID uint) (*model.Payment, error)
+	FindByIntent(ctx context.Context, provider, intentID string) (*model.Payment, error)
+	// RecordEvent stores a processed event, returning false when it was
+	// already recorded
+	RecordEvent(ctx context.Context, e *model.PaymentEvent) (bool, error)
+	// AddLedgerEntry records e unless an entry with its Reference exists,
+	// returning whether it was added
+	AddLedgerEntry(ctx context.Context, e *model.LedgerEntry) (bool, error)
+	// ListLedger returns the entries of an order, oldest first
+	ListLedger(ctx context.Context, orderID uint) ([]model.LedgerEntry, error)
+}
+
+type paymentRepository struct {
+	db *gorm.DB
+}
+
+func (r *paymentRepository) Create(ctx context.Context, p *model.Payment) error {
+	return conn(ctx, r.db).Omit("Order").Create(p).Error
+}
+
+func (r *paymentRepository) Update(ctx context.Context, p *model.Payment) error {
+	return conn(ctx, r.db).Omit("Order").Save(p).Error
+}
+
+func (r *paymentRepository) FindByOrder(ctx context.Context, orderID uint) (*model.Payment, error) {
+	var p model.Payment
+	if err := conn(ctx, r.db).Where("order_id = ?", orderID).Order("id DESC").First(&p).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &p, nil
+}
+
+func (r *paymentRepository) FindByIntent(ctx context.Context, provider, intentID string) (*model.Payment, error) {
+	var p model.Payment
+	if err := conn(ctx, r.db).Where("provider = ? AND intent_id = ?", provider, intentID).First(&p).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &p, nil
+}
+
+func (r *paymentRepository) RecordEvent(ctx context.Context, e *model.PaymentEvent) (bool, error) {
+	res := conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(e)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *paymentRepository) AddLedgerEntry(ctx context.Context, e *model.LedgerEntry) (bool, error) {
+	res := conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(e)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *paymentRepository) ListLedger(ctx context.Context, orderID uint) ([]model.LedgerEntry, error) {
+	var out []model.LedgerEntry
+	err := conn(ctx, r.db).Where("order_id = ?", orderID).Order("id").Find(&out).Error
+	return out, err
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+// ReportFilter selects reports; zero fields match everything
+type ReportFilter struct {
+	Status     string
+	TargetType string
+	Offset     int
+	Limit      int
+}
+
+// ReportRepository persists reports of content and how they were resolved
+type ReportRepository interface {
+	Create(ctx context.Context, report *model.Report) error
+	FindByID(ctx context.Context, id uint) (*model.Report, error)
+	// HasOpen tells whether reporterID already has an open report on the
+	// target; a nil reporterID asks about pre-moderation reports
+	HasOpen(ctx context.Context, reporterID *uint, targetType string, targetID uint) (bool, error)
+	// List returns a page of matching reports, oldest first, and how many
+	// match in all
+	List(ctx context.Context, filter ReportFilter) ([]model.Report, int64, error)
+	// Resolve closes every open report on the target with status and
+	// returns how many it closed
+	Resolve(ctx context.Context, targetType string, targetID uint, status string, moderatorID uint, note string, at time.Time) (int64, error)
+}
+
+type reportRepository struct {
+	db *gorm.DB
+}
+
+func (r *reportRepository) Create(ctx context.Context, report *model.Report) error {
+	return conn(ctx, r.db).Create(report).Error
+}
+
+func (r *reportRepository) FindByID(ctx context.Context, id uint) (*model.Report, error) {
+	var report model.Report
+	if err := conn(ctx, r.db).First(&report, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &report, nil
+}
+
+func (r *reportRepository) HasOpen(ctx context.Context, reporterID *uint, targetType string, targetID uint) (bool, error) {
+	q := conn(ctx, r.db).Model(&model.Report{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, model.ReportOpen)
+	if reporterID == nil {
+		q = q.Where("reporter_id IS NULL")
+	} else {
+		q = q.Where("reporter_id = ?", *reporterID)
+	}
+	var n int64
+	err := q.Count(&n).Error
+	return n > 0, err
+}
+
+func (r *reportRepository) List(ctx context.Context, filter ReportFilter) ([]model.Report, int64, error) {
+	q := conn(ctx, r.db).Model(&model.Report{})
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if filter.TargetType != "" {
+		q = q.Where("target_type = ?", filter.TargetType)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var reports []model.Report
+	err := q.Order("id").Offset(filter.Offset).Limit(filter.Limit).Find(&reports).Error
+	return reports, total, err
+}
+
+func (r *reportRepository) Resolve(ctx context.Context, targetType string, targetID uint, status string, moderatorID uint, note string, at time.Time) (int64, error) {
+	res := conn(ctx, r.db).Model(&model.Report{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, model.ReportOpen).
+		Updates(map[string]any{"status": status, "moderator_id": moderatorID, "note": note, "resolved_at": at})
+	return res.RowsAffected, res.Error
+}
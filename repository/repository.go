@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when a lookup matches no row
+var ErrNotFound = errors.New("record not found")
+
+type txKey struct{}
+
+type afterCommitKey struct{}
+
+// TxManager runs a function inside a database transaction. Repositories
+// called with the context passed to fn take part in that transaction.
+type TxManager interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Repositories groups the gorm-backed repositories sharing one connection
+type Repositories struct {
+	Tx            TxManager
+	Users         UserRepository
+	Items         ItemRepository
+	Orders        OrderRepository
+	Categories    CategoryRepository
+	Reviews       ReviewRepository
+	Comments      CommentRepository
+	Likes         LikeRepository
+	Images        ImageRepository
+	Inventory     InventoryRepository
+	Carts         CartRepository
+	Payments      PaymentRepository
+	Stats         StatsRepository
+	Wishlists     WishlistRepository
+	Notifications NotificationRepository
+	Messages      MessageRepository
+	Jobs          JobRepository
+	Webhooks      WebhookRepository
+	Audit         AuditRepository
+	Reports       ReportRepository
+}
+
+// New builds every repository on top of db
+func New(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Tx:            &gormTx{db: db},
+		Users:         &userRepository{db: db},
+		Items:         &itemRepository{db: db},
+		Orders:        &orderRepository{db: db},
+		Categories:    &categoryRepository{db: db},
+		Reviews:       &reviewRepository{db: db},
+		Comments:      &commentRepository{db: db},
+		Likes:         &likeRepository{db: db},
+		Images:        &imageRepository{db: db},
+		Inventory:     &inventoryRepository{db: db},
+		Carts:         &cartRepository{db: db},
+		Payments:      &paymentRepository{db: db},
+		Stats:         &statsRepository{db: db},
+		Wishlists:     &wishlistRepository{db: db},
+		Notifications: &notificationRepository{db: db},
+		Messages:      &messageRepository{db: db},
+		Jobs:          &jobRepository{db: db},
+		Webhooks:      &webhookRepository{db: db},
+		Audit:         &auditRepository{db: db},
+		Reports:       &reportRepository{db: db},
+	}
+}
+
+type gormTx struct {
+	db *gorm.DB
+}
+
+// Transaction implements TxManager. Nested calls reuse the outer transaction.
+func (t *gormTx) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	var after []func()
+	ctx = context.WithValue(ctx, afterCommitKey{}, &after)
+	err := t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+	if err != nil {
+		return err
+	}
+	for _, f := range after {
+		f()
+	}
+	return nil
+}
+
+// AfterCommit runs f once the transaction bound to ctx commits, and never
+// if it rolls back. Outside a transaction f runs right away.
+func AfterCommit(ctx context.Context, f func()) {
+	if after, ok := ctx.Value(afterCommitKey{}).(*[]func()); ok {
+		*after = append(*after, f)
+		return
+	}
+	f()
+}
+
+// conn returns the transaction bound to ctx, or db scoped to ctx
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// translate maps gorm errors onto repository errors
+func translate(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
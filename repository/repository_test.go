@@ -0,0 +1,86 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"app/database"
+	"app/model"
+	"app/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransaction_RollsBackComposedWrites(t *testing.T) {
+	repos := repository.New(database.ConnectDBWithDSN(":memory:"))
+	ctx := context.Background()
+	boom := errors.New("boom")
+
+	err := repos.Tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := repos.Users.Create(ctx, &model.User{Username: "a", Email: "a@example.com", Password: "x"}); err != nil {
+			return err
+		}
+		// Nested transactions join the outer one
+		return repos.Tx.Transaction(ctx, func(ctx context.Context) error {
+			if err := repos.Items.Create(ctx, &model.Item{Name: "Lamp", Description: "d", UserID: 1}); err != nil {
+				return err
+			}
+			return boom
+		})
+	})
+	assert.ErrorIs(t, err, boom)
+
+	users, err := repos.Users.List(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+	items, err := repos.Items.List(ctx, repository.ItemFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestAfterCommit(t *testing.T) {
+	repos := repository.New(database.ConnectDBWithDSN(":memory:"))
+	ctx := context.Background()
+	var ran []string
+
+	_ = repos.Tx.Transaction(ctx, func(ctx context.Context) error {
+		repository.AfterCommit(ctx, func() { ran = append(ran, "rolled back") })
+		return errors.New("boom")
+	})
+	_ = repos.Tx.Transaction(ctx, func(ctx context.Context) error {
+		return repos.Tx.Transaction(ctx, func(ctx context.Context) error {
+			repository.AfterCommit(ctx, func() { ran = append(ran, "committed") })
+			assert.Empty(t, ran, "waits for the outer commit")
+			return nil
+		})
+	})
+	repository.AfterCommit(ctx, func() { ran = append(ran, "no transaction") })
+
+	assert.Equal(t, []string{"committed", "no transaction"}, ran)
+}
+
+func TestUserRepository_NotFound(t *testing.T) {
+	repos := repository.New(database.ConnectDBWithDSN(":memory:"))
+
+	_, err := repos.Users.FindByUsername(context.Background(), "nobody")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestUsers_SearchMatchesLiterally(t *testing.T) {
+	repos := repository.New(database.ConnectDBWithDSN(":memory:"))
+	ctx := context.Background()
+	for _, name := range []string{"ann_b", "annxb", "Bob"} {
+		assert.NoError(t, repos.Users.Create(ctx, &model.User{Username: name, Email: name + "@example.com", Password: "x"}))
+	}
+
+	users, total, err := repos.Users.Search(ctx, repository.UserFilter{Query: "N_B", Limit: 10}, time.Now())
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	assert.Equal(t, "ann_b", users[0].Username)
+
+	_, total, err = repos.Users.Search(ctx, repository.UserFilter{Query: "%", Limit: 10}, time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, total)
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+// ReviewRepository persists item reviews
+type ReviewRepository interface {
+	// ListByItem and SellerRating leave hidden reviews out
+	ListByItem(ctx context.Context, itemID uint) ([]model.Review, error)
+	ListByUser(ctx context.Context, userID uint) ([]model.Review, error)
+	FindByID(ctx context.Context, id uint) (*model.Review, error)
+	Create(ctx context.Context, review *model.Review) error
+	SetHidden(ctx context.Context, id uint, hidden bool) error
+	Delete(ctx context.Context, review *model.Review) error
+	SellerRating(ctx context.Context, sellerID uint) (average float64, count int64, err error)
+}
+
+type reviewRepository struct {
+	db *gorm.DB
+}
+
+func (r *reviewRepository) ListByItem(ctx context.Context, itemID uint) ([]model.Review, error) {
+	var reviews []model.Review
+	if err := conn(ctx, r.db).Preload("User").Where("item_id = ? AND hidden = ?", itemID, false).Order("id").Find(&reviews).Error; err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+func (r *reviewRepository) ListByUser(ctx context.Context, userID uint) ([]model.Review, error) {
+	var reviews []model.Review
+	if err := conn(ctx, r.db).Where("user_id = ?", userID).Find(&reviews).Error; err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+func (r *reviewRepository) FindByID(ctx context.Context, id uint) (*model.Review, error) {
+	var review model.Review
+	if err := conn(ctx, r.db).First(&review, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &review, nil
+}
+
+func (r *reviewRepository) Create(ctx context.Context, review *model.Review) error {
+	return conn(ctx, r.db).Create(review).Error
+}
+
+func (r *reviewRepository) SetHidden(ctx context.Context, id uint, hidden bool) error {
+	return conn(ctx, r.db).Model(&model.Review{}).Where("id = ?", id).Update("hidden", hidden).Error
+}
+
+func (r *reviewRepository) Delete(ctx context.Context, review *model.Review) error {
+	return conn(ctx, r.db).Delete(review).Error
+}
+
+func (r *reviewRepository) SellerRating(ctx context.Context, sellerID uint) (float64, int64, error) {
+	var row struct {
+		Average float64
+		Count   int64
+	}
+	err := conn(ctx, r.db).Model(&model.Review{}).
+		Select("COALESCE(AVG(reviews.rating), 0) AS average, COUNT(reviews.id) AS count").
+		Joins("JOIN items ON items.id = reviews.item_id").
+		Where("items.user_id = ? AND reviews.hidden = ?", sellerID, false).
+		Scan(&row).Error
+	return row.Average, row.Count, err
+}
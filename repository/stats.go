@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"app/model"
+	"app/money"
+
+	"gorm.io/gorm"
+)
+
+// Stats buckets
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// SalesBucket sums a seller's orders in one currency over one bucket
+type SalesBucket struct {
+	Start    time.Time
+	Currency money.Currency
+	// Revenue is in the currency's minor units
+	Revenue int64
+	Units   int64
+	Orders  int64
+}
+
+// RatingBucket sums the ratings a seller's items received over one bucket
+type RatingBucket struct {
+	Start   time.Time
+	Sum     int64
+	Reviews int64
+}
+
+// TopItem is an item's sales in one currency
+type TopItem struct {
+	ItemID   uint
+	Name     string
+	Currency money.Currency
+	Revenue  int64
+	Units    int64
+}
+
+// StatsRepository aggregates sales and reviews of a seller's items over
+// [from, to). Buckets start at midnight UTC, weeks on Monday. Orders whose
+// payment failed or that were refunded in full are not sales.
+type StatsRepository interface {
+	Sales(ctx context.Context, sellerID uint, from, to time.Time, bucket string) ([]SalesBucket, error)
+	Ratings(ctx context.Context, sellerID uint, from, to time.Time, bucket string) ([]RatingBucket, error)
+	// TopItems returns the items selling the most units
+	TopItems(ctx context.Context, sellerID uint, from, to time.Time, limit int) ([]TopItem, error)
+}
+
+type statsRepository struct {
+	db *gorm.DB
+}
+
+// lostSales are order statuses that don't count as sales
+var lostSales = []string{model.OrderPaymentFailed, model.OrderRefunded}
+
+func (r *statsRepository) Sales(ctx context.Context, sellerID uint, from, to time.Time, bucket string) ([]SalesBucket, error) {
+	db := conn(ctx, r.db)
+	start, err := bucketStart(db, bucket, "orders.created_at")
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Start    string
+		Currency money.Currency
+		Revenue  int64
+		Units    int64
+		Orders   int64
+	}
+	err = db.Table("orders").
+		Select(start+" AS start, orders.total_price_currency AS currency, SUM(orders.total_price_amount) AS revenue, SUM(orders.quantity) AS units, COUNT(*) AS orders").
+		Joins("JOIN items ON items.id = orders.item_id").
+		Where("items.user_id = ? AND orders.created_at >= ? AND orders.created_at < ? AND orders.status NOT IN ?", sellerID, from, to, lostSales).
+		Group("start, currency").Order("start, currency").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SalesBucket, len(rows))
+	for i, row := range rows {
+		t, err := parseBucket(row.Start)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = SalesBucket{Start: t, Currency: row.Currency, Revenue: row.Revenue, Units: row.Units, Orders: row.Orders}
+	}
+	return out, nil
+}
+
+func (r *statsRepository) Ratings(ctx context.Context, sellerID uint, from, to time.Time, bucket string) ([]RatingBucket, error) {
+	db := conn(ctx, r.db)
+	start, err := bucketStart(db, bucket, "reviews.created_at")
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Start   string
+		Sum     int64
+		Reviews int64
+	}
+	err = db.Table("reviews").
+		Select(start+" AS start, SUM(reviews.rating) AS sum, COUNT(*) AS reviews").
+		Joins("JOIN items ON items.id = reviews.item_id").
+		Where("items.user_id = ? AND reviews.created_at >= ? AND reviews.created_at < ?", sellerID, from, to).
+		Group("start").Order("start").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make([]RatingBucket, len(rows))
+	for i, row := range rows {
+		t, err := parseBucket(row.Start)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = RatingBucket{Start: t, Sum: row.Sum, Reviews: row.Reviews}
+	}
+	return out, nil
+}
+
+func (r *statsRepository) TopItems(ctx context.Context, sellerID uint, from, to time.Time, limit int) ([]TopItem, error) {
+	var out []TopItem
+	err := conn(ctx, r.db).Table("orders").
+		Select("items.id AS item_id, items.name AS name, orders.total_price_currency AS currency, SUM(orders.total_price_amount) AS revenue, SUM(orders.quantity) AS units").
+		Joins("JOIN items ON items.id = orders.item_id").
+		Where("items.user_id = ? AND orders.created_at >= ? AND orders.created_at < ? AND orders.status NOT IN ?", sellerID, from, to, lostSales).
+		Group("items.id, items.name, orders.total_price_currency").
+		Order("units DESC, items.id").
+		Limit(limit).
+		Scan(&out).Error
+	return out, err
+}
+
+// bucketStart returns the SQL truncating column to the start of its bucket
+func bucketStart(db *gorm.DB, bucket, column string) (string, error) {
+	if bucket != BucketDay && bucket != BucketWeek && bucket != BucketMonth {
+		return "", fmt.Errorf("unknown stats bucket %q", bucket)
+	}
+	if db.Dialector.Name() == "postgres" {
+		return fmt.Sprintf("date_trunc('%s', %s AT TIME ZONE 'UTC')", bucket, column), nil
+	}
+	switch bucket {
+	case BucketWeek:
+		return fmt.Sprintf("date(%[1]s, '-' || ((strftime('%%w', %[1]s) + 6) %% 7) || ' days')", column), nil
+	case BucketMonth:
+		return fmt.Sprintf("strftime('%%Y-%%m-01', %s)", column), nil
+	default:
+		return fmt.Sprintf("date(%s)", column), nil
+	}
+}
+
+// parseBucket reads a bucket start: a date from SQLite, a timestamp
+// from Postgres
+func parseBucket(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("stats bucket %q: %w", s, err)
+	}
+	return t.UTC(), nil
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+// UserFilter selects users for admins; zero fields match everything
+type UserFilter struct {
+	// Query matches part of the username, email or display name
+	Query string
+	Role  string
+	// Status is one of the model.User* statuses
+	Status string
+	Offset int
+	Limit  int
+}
+
+// UserRepository persists users
+type UserRepository interface {
+	FindByID(ctx context.Context, id uint) (*model.User, error)
+	// FindAny finds user id even if deactivated
+	FindAny(ctx context.Context, id uint) (*model.User, error)
+	// Search returns a page of users matching filter at now, in ID order,
+	// and how many match in all
+	Search(ctx context.Context, filter UserFilter, now time.Time) ([]model.User, int64, error)
+	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	FindByUsername(ctx context.Context, username string) (*model.User, error)
+	List(ctx context.Context) ([]model.User, error)
+	Create(ctx context.Context, user *model.User) error
+	Update(ctx context.Context, user *model.User) error
+	Delete(ctx context.Context, user *model.User) error
+
+	// EmailTaken and UsernameTaken also see deactivated accounts, which
+	// keep their unique columns until anonymized
+	EmailTaken(ctx context.Context, email string) (bool, error)
+	UsernameTaken(ctx context.Context, username string) (bool, error)
+	// FindDeactivated looks up a deactivated, not yet anonymized account
+	// by email or username
+	FindDeactivated(ctx context.Context, identity string) (*model.User, error)
+	Restore(ctx context.Context, user *model.User) error
+	// ListPurgeable returns accounts deactivated before cutoff that still
+	// hold personal data
+	ListPurgeable(ctx context.Context, cutoff time.Time) ([]model.User, error)
+	// SaveDeactivated updates an account whether or not it is deactivated
+	SaveDeactivated(ctx context.Context, user *model.User) error
+}
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+func (r *userRepository) FindByID(ctx context.Context, id uint) (*model.User, error) {
+	var user model.User
+	if err := conn(ctx, r.db).First(&user, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) FindAny(ctx context.Context, id uint) (*model.User, error) {
+	var user model.User
+	if err := conn(ctx, r.db).Unscoped().First(&user, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) Search(ctx context.Context, filter UserFilter, now time.Time) ([]model.User, int64, error) {
+	q := conn(ctx, r.db).Model(&model.User{})
+	if filter.Query != "" {
+		like := "%" + likeEscaper.Replace(strings.ToLower(filter.Query)) + "%"
+		q = q.Where(`(LOWER(username) LIKE ? ESCAPE '\' OR LOWER(email) LIKE ? ESCAPE '\' OR LOWER(display_name) LIKE ? ESCAPE '\')`, like, like, like)
+	}
+	if filter.Role != "" {
+		q = q.Where("role = ?", filter.Role)
+	}
+	switch filter.Status {
+	case model.UserActive:
+		q = q.Where("(suspended_at IS NULL OR suspended_until <= ?)", now)
+	case model.UserSuspended:
+		q = q.Where("suspended_at IS NOT NULL AND suspended_until > ?", now)
+	case model.UserBanned:
+		q = q.Where("suspended_at IS NOT NULL AND suspended_until IS NULL")
+	case model.UserDeactivated:
+		q = q.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []model.User
+	err := q.Order("id").Offset(filter.Offset).Limit(filter.Limit).Find(&users).Error
+	return users, total, err
+}
+
+// likeEscaper makes user input match literally in a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (r *userRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	var user model.User
+	if err := conn(ctx, r.db).Where(&model.User{Email: email}).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) FindByUsername(ctx context.Context, username string) (*model.User, error) {
+	var user model.User
+	if err := conn(ctx, r.db).Where(&model.User{Username: username}).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) List(ctx context.Context) ([]model.User, error) {
+	var users []model.User
+	if err := conn(ctx, r.db).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepository) Create(ctx context.Context, user *model.User) error {
+	return conn(ctx, r.db).Create(user).Error
+}
+
+func (r *userRepository) Update(ctx context.Context, user *model.User) error {
+	return conn(ctx, r.db).Save(user).Error
+}
+
+func (r *userRepository) Delete(ctx context.Context, user *model.User) error {
+	return conn(ctx, r.db).Delete(user).Error
+}
+
+func (r *userRepository) EmailTaken(ctx context.Context, email string) (bool, error) {
+	var n int64
+	err := conn(ctx, r.db).Unscoped().Model(&model.User{}).Where("email = ?", email).Count(&n).Error
+	return n > 0, err
+}
+
+func (r *userRepository) UsernameTaken(ctx context.Context, username string) (bool, error) {
+	var n int64
+	err := conn(ctx, r.db).Unscoped().Model(&model.User{}).Where("username = ?", username).Count(&n).Error
+	return n > 0, err
+}
+
+func (r *userRepository) FindDeactivated(ctx context.Context, identity string) (*model.User, error) {
+	var user model.User
+	err := conn(ctx, r.db).Unscoped().
+		Where("deleted_at IS NOT NULL AND anonymized_at IS NULL").
+		Where("email = ? OR username = ?", identity, identity).
+		First(&user).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) Restore(ctx context.Context, user *model.User) error {
+	user.DeletedAt = gorm.DeletedAt{}
+	return conn(ctx, r.db).Unscoped().Model(user).Update("deleted_at", nil).Error
+}
+
+func (r *userRepository) ListPurgeable(ctx context.Context, cutoff time.Time) ([]model.User, error) {
+	var users []model.User
+	err := conn(ctx, r.db).Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ? AND anonymized_at IS NULL", cutoff).
+		Find(&users).Error
+	return users, err
+}
+
+func (r *userRepository) SaveDeactivated(ctx context.Context, user *model.User) error {
+	return conn(ctx, r.db).Unscoped().Save(user).Error
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+// WebhookRepository persists webhook subscriptions and their deliveries
+type WebhookRepository interface {
+	ListByUser(ctx context.Context, userID uint) ([]model.WebhookSubscription, error)
+	// ListActive returns the enabled subscriptions of a user
+	ListActive(ctx context.Context, userID uint) ([]model.WebhookSubscription, error)
+	CountByUser(ctx context.Context, userID uint) (int64, error)
+	FindByID(ctx context.Context, id uint) (*model.WebhookSubscription, error)
+	Create(ctx context.Context, sub *model.WebhookSubscription) error
+	Update(ctx context.Context, sub *model.WebhookSubscription) error
+	// Delete removes a subscription and its delivery log
+	Delete(ctx context.Context, sub *model.WebhookSubscription) error
+	// AddFailure counts a failed delivery, disabling the subscription once
+	// it failed max times in a row. It returns whether it did.
+	AddFailure(ctx context.Context, id uint, max int, at time.Time) (bool, error)
+	ResetFailures(ctx context.Context, id uint) error
+	RecordDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	// CountAttempts returns how many deliveries of an event were tried
+	CountAttempts(ctx context.Context, subscriptionID uint, eventID string) (int64, error)
+	// Deliveries returns the latest deliveries of a subscription, newest
+	// first
+	Deliveries(ctx context.Context, subscriptionID uint, limit int) ([]model.WebhookDelivery, error)
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func (r *webhookRepository) ListByUser(ctx context.Context, userID uint) ([]model.WebhookSubscription, error) {
+	var subs []model.WebhookSubscription
+	err := conn(ctx, r.db).Where("user_id = ?", userID).Order("id").Find(&subs).Error
+	return subs, err
+}
+
+func (r *webhookRepository) ListActive(ctx context.Context, userID uint) ([]model.WebhookSubscription, error) {
+	var subs []model.WebhookSubscription
+	err := conn(ctx, r.db).Where("user_id = ? AND active", userID).Order("id").Find(&subs).Error
+	return subs, err
+}
+
+func (r *webhookRepository) CountByUser(ctx context.Context, userID uint) (int64, error) {
+	var n int64
+	err := conn(ctx, r.db).Model(&model.WebhookSubscription{}).Where("user_id = ?", userID).Count(&n).Error
+	return n, err
+}
+
+func (r *webhookRepository) FindByID(ctx context.Context, id uint) (*model.WebhookSubscription, error) {
+	var sub model.WebhookSubscription
+	if err := conn(ctx, r.db).First(&sub, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &sub, nil
+}
+
+func (r *webhookRepository) Create(ctx context.Context, sub *model.WebhookSubscription) error {
+	return conn(ctx, r.db).Create(sub).Error
+}
+
+func (r *webhookRepository) Update(ctx context.Context, sub *model.WebhookSubscription) error {
+	return conn(ctx, r.db).Save(sub).Error
+}
+
+func (r *webhookRepository) Delete(ctx context.Context, sub *model.WebhookSubscription) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("subscription_id = ?", sub.ID).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(sub).Error
+	})
+}
+
+func (r *webhookRepository) AddFailure(ctx context.Context, id uint, max int, at time.Time) (bool, error) {
+	db := conn(ctx, r.db)
+	if err := db.Model(&model.WebhookSubscription{}).Where("id = ?", id).
+		Update("failures", gorm.Expr("failures + 1")).Error; err != nil {
+		return false, err
+	}
+	res := db.Model(&model.WebhookSubscription{}).Where("id = ? AND active AND failures >= ?", id, max).
+		Updates(map[string]any{"active": false, "disabled_at": at})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *webhookRepository) ResetFailures(ctx context.Context, id uint) error {
+	return conn(ctx, r.db).Model(&model.WebhookSubscription{}).Where("id = ? AND failures > 0", id).
+		Update("failures", 0).Error
+}
+
+func (r *webhookRepository) RecordDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return conn(ctx, r.db).Create(delivery).Error
+}
+
+func (r *webhookRepository) CountAttempts(ctx context.Context, subscriptionID uint, eventID string) (int64, error) {
+	var n int64
+	err := conn(ctx, r.db).Model(&model.WebhookDelivery{}).
+		Where("subscription_id = ? AND event_id = ?", subscriptionID, eventID).Count(&n).Error
+	return n, err
+}
+
+func (r *webhookRepository) Deliveries(ctx context.Context, subscriptionID uint, limit int) ([]model.WebhookDelivery, error) {
+	var out []model.WebhookDelivery
+	err := conn(ctx, r.db).Where("subscription_id = ?", subscriptionID).Order("id DESC").Limit(limit).Find(&out).Error
+	return out, err
+}
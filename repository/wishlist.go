@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WishlistRepository persists wishlists
+type WishlistRepository interface {
+	// List returns the entries of a user, newest first, with their items
+	// even once deleted
+	List(ctx context.Context, userID uint) ([]model.WishlistEntry, error)
+	// Add saves an item, returning false when it was already saved
+	Add(ctx context.Context, userID, itemID uint) (bool, error)
+	// Remove returns ErrNotFound when the item wasn't saved
+	Remove(ctx context.Context, userID, itemID uint) error
+	// Watchers returns the users who saved an item
+	Watchers(ctx context.Context, itemID uint) ([]uint, error)
+}
+
+type wishlistRepository struct {
+	db *gorm.DB
+}
+
+func (r *wishlistRepository) List(ctx context.Context, userID uint) ([]model.WishlistEntry, error) {
+	var out []model.WishlistEntry
+	err := conn(ctx, r.db).Preload("Item", unscoped).Where("user_id = ?", userID).Order("id DESC").Find(&out).Error
+	return out, err
+}
+
+func (r *wishlistRepository) Add(ctx context.Context, userID, itemID uint) (bool, error) {
+	res := conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(&model.WishlistEntry{UserID: userID, ItemID: itemID})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *wishlistRepository) Remove(ctx context.Context, userID, itemID uint) error {
+	res := conn(ctx, r.db).Where("user_id = ? AND item_id = ?", userID, itemID).Delete(&model.WishlistEntry{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *wishlistRepository) Watchers(ctx context.Context, itemID uint) ([]uint, error) {
+	var ids []uint
+	err := conn(ctx, r.db).Model(&model.WishlistEntry{}).Where("item_id = ?", itemID).Order("user_id").Pluck("user_id", &ids).Error
+	return ids, err
+}
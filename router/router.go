@@ -5,35 +5,152 @@ import (
 	"app/middleware"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 )
 
 // SetupRoutes setup router api
-func SetupRoutes(app *fiber.App) {
-	// Middleware
-	api := app.Group("/api", logger.New())
+func SetupRoutes(app *fiber.App, h handler.Handlers) {
+	// Logging, tracing, metrics and audit context
+	app.Use(middleware.RequestID(), middleware.Tracing(), middleware.RequestLogger(), middleware.Metrics(), middleware.Audit())
+	app.Get("/metrics", handler.Metrics)
+	if h.Media != nil {
+		app.Get("/media/*", h.Media.Serve)
+	}
+
+	// Middleware; tokens are checked against the user's sessions
+	protected := middleware.Protected(h.Auth.Session)
+	api := app.Group("/api")
 	api.Get("/", handler.Hello)
 
 	// Auth
 	auth := api.Group("/auth")
-	auth.Post("/login", handler.Login)
-	auth.Post("/logout", middleware.Protected(), handler.Logout)
-	auth.Post("/register", handler.Register)
-	auth.Get("/refresh", handler.RefreshToken)
+	auth.Post("/login", h.Auth.Login)
+	auth.Post("/logout", protected, handler.Logout)
+	auth.Post("/register", h.Auth.Register)
+	auth.Get("/refresh", h.Auth.RefreshToken)
+	auth.Post("/reactivate", h.Auth.Reactivate)
 
 	// User
 	user := api.Group("/user")
-	user.Get("/id/:id", handler.GetUser)
-	user.Post("/", handler.CreateUser)
-	user.Get("/all", handler.GetAllUsers)
-	user.Patch("/id/:id", middleware.Protected(), handler.UpdateUser)
-	user.Delete("/id/:id", middleware.Protected(), handler.DeleteUser)
-	
-	// Item
+	user.Get("/me", protected, h.User.GetMe)
+	user.Patch("/me", protected, h.User.UpdateMe)
+	user.Delete("/me", protected, h.User.DeleteMe)
+	user.Get("/me/export", protected, h.User.ExportMe)
+	user.Get("/profile/:username", h.User.GetProfile)
+	user.Get("/id/:id", h.User.GetUser)
+	user.Post("/", h.User.CreateUser)
+	user.Patch("/id/:id", protected, h.User.UpdateUser)
+	user.Delete("/id/:id", protected, h.User.DeleteUser)
+	user.Put("/id/:id/block", protected, h.Message.Block)
+	user.Delete("/id/:id/block", protected, h.Message.Unblock)
+
+	// Outgoing webhooks
+	webhooks := user.Group("/me/webhooks", protected)
+	webhooks.Get("/", h.Webhook.ListWebhooks)
+	webhooks.Post("/", h.Webhook.CreateWebhook)
+	webhooks.Patch("/:id", h.Webhook.UpdateWebhook)
+	webhooks.Delete("/:id", h.Webhook.DeleteWebhook)
+	webhooks.Get("/:id/deliveries", h.Webhook.GetDeliveries)
+	webhooks.Post("/:id/test", h.Webhook.TestWebhook)
+
+	// Item; owners also see their drafts and unlisted items
+	optional := middleware.Optional(h.Auth.Session)
+	user.Get("/id/:id/items", optional, h.Item.GetItemFromUser)
 	item := api.Group("/items")
-	item.Get("/", handler.GetAllItems)
-	item.Get("/category/:id", handler.GetItemFromCategory)
-	item.Post("/", middleware.Protected(), handler.CreateItem)
-	item.Patch("/:id", middleware.Protected(), handler.UpdateItem)
-	item.Delete("/:id", middleware.Protected(), handler.DeleteItem)
+	item.Get("/", h.Item.GetAllItems)
+	item.Get("/category/:id", h.Item.GetItemFromCategory)
+	item.Post("/", protected, h.Item.CreateItem)
+	item.Patch("/:id", protected, h.Item.UpdateItem)
+	item.Delete("/:id", protected, h.Item.DeleteItem)
+	item.Get("/:id", optional, h.Item.GetItemFromId)
+	item.Put("/:id/status", protected, h.Item.SetItemStatus)
+	item.Put("/:id/schedule", protected, h.Item.ScheduleItem)
+
+	// Item images
+	item.Post("/:id/images", protected, h.Image.UploadImage)
+	item.Put("/:id/images/order", protected, h.Image.ReorderImages)
+	item.Put("/:id/images/:imageId/primary", protected, h.Image.SetPrimaryImage)
+	item.Delete("/:id/images/:imageId", protected, h.Image.DeleteImage)
+
+	// Inventory
+	item.Get("/:id/inventory", protected, h.Inventory.GetInventory)
+	item.Post("/:id/inventory", protected, h.Inventory.AdjustInventory)
+	item.Post("/:id/reservations", protected, h.Inventory.Reserve)
+	api.Delete("/reservations/:id", protected, h.Inventory.Release)
+
+	// Reviews
+	item.Get("/:id/reviews", h.Review.ListReviews)
+	item.Post("/:id/reviews", protected, h.Review.CreateReview)
+
+	// Comments and likes
+	item.Get("/:id/comments", h.Comment.ListComments)
+	item.Post("/:id/comments", protected, h.Comment.CreateComment)
+	api.Patch("/comments/:id", protected, h.Comment.UpdateComment)
+	api.Delete("/comments/:id", protected, h.Comment.DeleteComment)
+	item.Get("/:id/like", protected, h.Comment.GetLike)
+	item.Put("/:id/like", protected, h.Comment.Like)
+	item.Delete("/:id/like", protected, h.Comment.Unlike)
+
+	// Wishlist and notifications
+	wishlist := api.Group("/wishlist", protected)
+	wishlist.Get("/", h.Wishlist.GetWishlist)
+	wishlist.Put("/:itemId", h.Wishlist.AddToWishlist)
+	wishlist.Delete("/:itemId", h.Wishlist.RemoveFromWishlist)
+	notifications := api.Group("/notifications", protected)
+	notifications.Get("/", h.Notification.GetNotifications)
+	notifications.Post("/read-all", h.Notification.MarkAllRead)
+	notifications.Post("/:id/read", h.Notification.MarkRead)
+	notifications.Get("/preferences", h.Notification.GetPreferences)
+	notifications.Put("/preferences", h.Notification.UpdatePreferences)
+
+	// Messaging
+	item.Post("/:id/conversations", protected, h.Message.StartConversation)
+	conversation := api.Group("/conversations", protected)
+	conversation.Get("/", h.Message.ListConversations)
+	conversation.Get("/:id", h.Message.GetConversation)
+	conversation.Get("/:id/messages", h.Message.GetMessages)
+	conversation.Post("/:id/messages", h.Message.SendMessage)
+	conversation.Post("/:id/read", h.Message.MarkRead)
+
+	// Real-time updates
+	api.Get("/events", middleware.Streaming(h.Auth.Session), h.Stream.Events)
+
+	// Order
+	order := api.Group("/orders", protected)
+	order.Get("/", h.Order.GetMyOrders)
+	order.Post("/", h.Order.CreateOrder)
+
+	// Payments
+	order.Post("/:id/pay", h.Payment.Pay)
+	order.Post("/:id/capture", h.Payment.Capture)
+	order.Post("/:id/refunds", h.Payment.Refund)
+	order.Get("/:id/payment", h.Payment.GetPayment)
+	api.Post("/webhooks/payments", h.Payment.Webhook)
+
+	// Seller dashboard
+	api.Get("/seller/stats", protected, h.Seller.Stats)
+
+	// Admin
+	admin := api.Group("/admin", protected, h.Admin.RequireAdmin)
+	admin.Get("/audit", h.Admin.GetAuditLog)
+	admin.Get("/audit/export", h.Admin.ExportAuditLog)
+	admin.Get("/users", h.Admin.ListUsers)
+	admin.Get("/users/:id", h.Admin.GetUser)
+	admin.Post("/users/:id/suspension", h.Admin.SuspendUser)
+	admin.Delete("/users/:id/suspension", h.Admin.UnsuspendUser)
+	admin.Post("/users/:id/logout", h.Admin.LogoutUser)
+	admin.Post("/users/:id/impersonate", h.Admin.ImpersonateUser)
+
+	// Reports and moderation
+	api.Post("/reports", protected, h.Moderation.CreateReport)
+	moderation := api.Group("/moderation", protected, h.Admin.RequireModerator)
+	moderation.Get("/reports", h.Moderation.ListReports)
+	moderation.Post("/reports/:id/resolve", h.Moderation.ResolveReport)
+
+	// Cart, for guests too
+	cart := api.Group("/cart", optional)
+	cart.Get("/", h.Cart.GetCart)
+	cart.Post("/items", h.Cart.AddToCart)
+	cart.Put("/items/:itemId", h.Cart.UpdateCartItem)
+	cart.Delete("/items/:itemId", h.Cart.RemoveCartItem)
+	cart.Post("/checkout", protected, h.Cart.Checkout)
 }
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"app/logging"
+	"app/model"
+	"app/money"
+	"app/repository"
+)
+
+// DefaultRetentionDays is how long a deactivated account can be restored
+// before it is anonymized
+const DefaultRetentionDays = 30
+
+// AccountService handles the account lifecycle: deactivation, restoring
+// within the retention window, data export and anonymization
+type AccountService struct {
+	tx        repository.TxManager
+	users     repository.UserRepository
+	items     repository.ItemRepository
+	orders    repository.OrderRepository
+	reviews   repository.ReviewRepository
+	comments  repository.CommentRepository
+	likes     repository.LikeRepository
+	messages  repository.MessageRepository
+	audit     *AuditService
+	retention time.Duration
+}
+
+// NewAccountService creates an AccountService keeping deactivated accounts
+// for retention before they may be purged, recording changes in audit
+func NewAccountService(repos *repository.Repositories, audit *AuditService, retention time.Duration) *AccountService {
+	return &AccountService{
+		tx:        repos.Tx,
+		users:     repos.Users,
+		items:     repos.Items,
+		orders:    repos.Orders,
+		reviews:   repos.Reviews,
+		comments:  repos.Comments,
+		likes:     repos.Likes,
+		messages:  repos.Messages,
+		audit:     audit,
+		retention: retention,
+	}
+}
+
+// Deactivate soft-deletes user id and their listings after confirming
+// their password; only the user themselves may do so
+func (s *AccountService) Deactivate(ctx context.Context, actorID, id uint, password string) error {
+	if actorID != id {
+		return ErrForbidden
+	}
+	user, err := s.users.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !CheckPasswordHash(password, user.Password) {
+		return ErrInvalidCredentials
+	}
+	return s.tx.Transaction(ctx, func(ctx context.Context) error {
+		// Items are deleted after the user so Reactivate can find them by
+		// the user's deletion time
+		if err := s.users.Delete(ctx, user); err != nil {
+			return err
+		}
+		if err := s.items.DeleteByUser(ctx, user.ID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditEntry{
+			ActorID: actorID, Action: model.AuditUserDeleted, TargetType: model.AuditTargetUser, TargetID: id,
+		})
+	})
+}
+
+// Reactivate restores a deactivated account and the listings removed with
+// it, as long as the retention window has not passed
+func (s *AccountService) Reactivate(ctx context.Context, identity, password string) (*model.User, error) {
+	user, err := s.users.FindDeactivated(ctx, identity)
+	if errors.Is(err, repository.ErrNotFound) {
+		CheckPasswordHash(password, dummyHash) // prevent timing attacks
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !CheckPasswordHash(password, user.Password) {
+		return nil, ErrInvalidCredentials
+	}
+	deactivated := user.DeletedAt.Time
+	if time.Since(deactivated) > s.retention {
+		return nil, ErrRetentionExpired
+	}
+	err = s.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.items.RestoreByUser(ctx, user.ID, deactivated); err != nil {
+			return err
+		}
+		if err := s.users.Restore(ctx, user); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditEntry{
+			ActorID: user.ID, Action: model.AuditUserReactivated, TargetType: model.AuditTargetUser, TargetID: user.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Purge anonymizes every account deactivated longer than the retention
+// window ago and returns how many were anonymized
+func (s *AccountService) Purge(ctx context.Context, now time.Time) (int, error) {
+	users, err := s.users.ListPurgeable(ctx, now.Add(-s.retention))
+	if err != nil {
+		return 0, err
+	}
+	for i := range users {
+		user := &users[i]
+		user.Username = fmt.Sprintf("deleted-%d", user.ID)
+		user.Email = fmt.Sprintf("deleted-%d@anonymized.invalid", user.ID)
+		user.Password = ""
+		user.DisplayName = ""
+		user.AvatarURL = ""
+		user.Bio = ""
+		user.AnonymizedAt = &now
+		err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+			if err := s.users.SaveDeactivated(ctx, user); err != nil {
+				return err
+			}
+			// No diff: the log must not keep what anonymizing removed
+			return s.audit.Record(ctx, AuditEntry{
+				Action: model.AuditUserAnonymized, TargetType: model.AuditTargetUser, TargetID: user.ID,
+			})
+		})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(users), nil
+}
+
+// PurgeJob handles JobPurgeAccounts jobs
+func (s *AccountService) PurgeJob(ctx context.Context, _ json.RawMessage) error {
+	n, err := s.Purge(ctx, time.Now())
+	if n > 0 {
+		logging.FromContext(ctx).Info("purged accounts", "anonymized", n)
+	}
+	return err
+}
+
+type exportProfile struct {
+	ID          uint      `json:"id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url"`
+	Bio         string    `json:"bio"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type exportItem struct {
+	ID          uint        `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+	CategoryID  uint        `json:"category_id"`
+}
+
+type exportOrder struct {
+	ID         uint        `json:"id"`
+	ItemID     uint        `json:"item_id"`
+	Quantity   int         `json:"quantity"`
+	TotalPrice money.Money `json:"total_price"`
+}
+
+type exportReview struct {
+	ID      uint   `json:"id"`
+	ItemID  uint   `json:"item_id"`
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment"`
+}
+
+type exportComment struct {
+	ID        uint      `json:"id"`
+	ItemID    uint      `json:"item_id"`
+	ParentID  *uint     `json:"parent_id,omitempty"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportLike struct {
+	ID        uint      `json:"id"`
+	ItemID    uint      `json:"item_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportMessage struct {
+	ID             uint      `json:"id"`
+	ConversationID uint      `json:"conversation_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Export writes a ZIP archive of everything stored about userID to w, one
+// JSON file per kind of record. Each kind is loaded just before it is
+// written, so w can be a response streamed as it goes.
+func (s *AccountService) Export(ctx context.Context, userID uint, w io.Writer) error {
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	files := []struct {
+		name string
+		load func() (any, error)
+	}{
+		{"profile.json", func() (any, error) {
+			return exportProfile{
+				ID: user.ID, Username: user.Username, Email: user.Email, DisplayName: user.DisplayName,
+				AvatarURL: user.AvatarURL, Bio: user.Bio, CreatedAt: user.CreatedAt,
+			}, nil
+		}},
+		{"items.json", func() (any, error) {
+			items, err := s.items.ListByUser(ctx, userID, repository.ItemFilter{All: true})
+			return mapSlice(items, func(i model.Item) exportItem {
+				return exportItem{ID: i.ID, Name: i.Name, Description: i.Description, Price: i.Price, CategoryID: i.CategoryID}
+			}), err
+		}},
+		{"orders.json", func() (any, error) {
+			orders, err := s.orders.ListByUser(ctx, userID)
+			return mapSlice(orders, func(o model.Order) exportOrder {
+				return exportOrder{ID: o.ID, ItemID: o.ItemID, Quantity: o.Quantity, TotalPrice: o.TotalPrice}
+			}), err
+		}},
+		{"reviews.json", func() (any, error) {
+			reviews, err := s.reviews.ListByUser(ctx, userID)
+			return mapSlice(reviews, func(r model.Review) exportReview {
+				return exportReview{ID: r.ID, ItemID: r.ItemID, Rating: r.Rating, Comment: r.Comment}
+			}), err
+		}},
+		{"comments.json", func() (any, error) {
+			comments, err := s.comments.ListByUser(ctx, userID)
+			return mapSlice(comments, func(c model.Comment) exportComment {
+				return exportComment{ID: c.ID, ItemID: c.ItemID, ParentID: c.ParentID, Body: c.Body, CreatedAt: c.CreatedAt}
+			}), err
+		}},
+		{"likes.json", func() (any, error) {
+			likes, err := s.likes.ListByUser(ctx, userID)
+			return mapSlice(likes, func(l model.Like) exportLike {
+				return exportLike{ID: l.ID, ItemID: l.ItemID, CreatedAt: l.CreatedAt}
+			}), err
+		}},
+		{"messages.json", func() (any, error) {
+			messages, err := s.messages.ListSentBy(ctx, userID)
+			return mapSlice(messages, func(m model.Message) exportMessage {
+				return exportMessage{ID: m.ID, ConversationID: m.ConversationID, Body: m.Body, CreatedAt: m.CreatedAt}
+			}), err
+		}},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		data, err := f.load()
+		if err != nil {
+			return err
+		}
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// mapSlice converts every element of in, always returning a non-nil slice
+// so empty collections export as []
+func mapSlice[T, U any](in []T, fn func(T) U) []U {
+	out := make([]U, 0, len(in))
+	for _, v := range in {
+		out = append(out, fn(v))
+	}
+	return out
+}
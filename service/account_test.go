@@ -0,0 +1,88 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"app/database"
+	"app/model"
+	"app/repository"
+	"app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupAccounts(t *testing.T) (*service.AccountService, *service.UserService, *gorm.DB) {
+	db := database.ConnectDBWithDSN(":memory:")
+	repos := repository.New(db)
+	hash, err := service.HashPassword("password1")
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&model.User{ID: 1, Username: "seller", Email: "s@example.com", Password: hash}).Error)
+	require.NoError(t, db.Create(&model.Item{ID: 1, Name: "Lamp", Description: "old", UserID: 1}).Error)
+	audit := service.NewAuditService(repos.Audit)
+	return service.NewAccountService(repos, audit, 30*24*time.Hour),
+		service.NewUserService(repos.Tx, repos.Users, repos.Items, repos.Reviews, audit), db
+}
+
+func TestAccount_DeactivateAndReactivate(t *testing.T) {
+	accounts, users, db := setupAccounts(t)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, accounts.Deactivate(ctx, 2, 1, "password1"), service.ErrForbidden)
+	assert.ErrorIs(t, accounts.Deactivate(ctx, 1, 1, "wrong"), service.ErrInvalidCredentials)
+	require.NoError(t, accounts.Deactivate(ctx, 1, 1, "password1"))
+
+	_, err := users.Authenticate(ctx, "seller", "password1")
+	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+	var n int64
+	db.Model(&model.Item{}).Count(&n)
+	assert.Zero(t, n)
+
+	// The username stays reserved during the grace period
+	_, err = users.Register(ctx, "seller", "new@example.com", "password1")
+	assert.ErrorIs(t, err, service.ErrUsernameTaken)
+
+	_, err = accounts.Reactivate(ctx, "s@example.com", "wrong")
+	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+	user, err := accounts.Reactivate(ctx, "s@example.com", "password1")
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), user.ID)
+
+	_, err = users.Authenticate(ctx, "seller", "password1")
+	assert.NoError(t, err)
+	db.Model(&model.Item{}).Count(&n)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestAccount_Purge(t *testing.T) {
+	accounts, users, db := setupAccounts(t)
+	ctx := context.Background()
+	require.NoError(t, accounts.Deactivate(ctx, 1, 1, "password1"))
+
+	purged, err := accounts.Purge(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, purged, "accounts inside the retention window are kept")
+
+	later := time.Now().Add(31 * 24 * time.Hour)
+	_, err = accounts.Reactivate(ctx, "seller", "password1")
+	require.NoError(t, err)
+	require.NoError(t, accounts.Deactivate(ctx, 1, 1, "password1"))
+	purged, err = accounts.Purge(ctx, later)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	var user model.User
+	require.NoError(t, db.Unscoped().First(&user, 1).Error)
+	assert.Equal(t, "deleted-1", user.Username)
+	assert.Equal(t, "deleted-1@anonymized.invalid", user.Email)
+	assert.Empty(t, user.Password)
+	assert.NotNil(t, user.AnonymizedAt)
+
+	_, err = accounts.Reactivate(ctx, "seller", "password1")
+	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+	_, err = users.Register(ctx, "seller", "s@example.com", "password1")
+	assert.NoError(t, err)
+}
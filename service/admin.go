@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+
+	"app/model"
+	"app/repository"
+)
+
+// MaxUserPage caps how many users one page of the admin user list shows
+const MaxUserPage = 100
+
+// AdminService lets admins moderate users. Every change is audited.
+type AdminService struct {
+	tx    repository.TxManager
+	users repository.UserRepository
+	audit *AuditService
+}
+
+// NewAdminService creates an AdminService
+func NewAdminService(tx repository.TxManager, users repository.UserRepository, audit *AuditService) *AdminService {
+	return &AdminService{tx: tx, users: users, audit: audit}
+}
+
+// Users returns a page of the users matching filter, deactivated ones
+// only when asked for, and how many match in all. Pages count from 1.
+func (s *AdminService) Users(ctx context.Context, filter repository.UserFilter, page, limit int) ([]model.User, int64, error) {
+	filter.Offset, filter.Limit = (page-1)*limit, limit
+	return s.users.Search(ctx, filter, time.Now())
+}
+
+// User returns user id, even if deactivated
+func (s *AdminService) User(ctx context.Context, id uint) (*model.User, error) {
+	return s.users.FindAny(ctx, id)
+}
+
+// Suspend keeps user id out until until, or for good when until is nil,
+// and signs them out everywhere. Admins can't be suspended.
+func (s *AdminService) Suspend(ctx context.Context, adminID, id uint, reason string, until *time.Time) (*model.User, error) {
+	now := time.Now()
+	if reason == "" || utf8.RuneCountInString(reason) > 500 || (until != nil && !until.After(now)) {
+		return nil, ErrInvalidSuspension
+	}
+	return s.change(ctx, adminID, id, model.AuditUserSuspended, func(user *model.User) error {
+		if user.IsAdmin() {
+			return ErrForbidden
+		}
+		user.SuspendedAt, user.SuspendedUntil, user.SuspendReason = &now, until, reason
+		user.TokenVersion++
+		return nil
+	})
+}
+
+// Unsuspend lifts the suspension or ban of user id
+func (s *AdminService) Unsuspend(ctx context.Context, adminID, id uint) (*model.User, error) {
+	return s.change(ctx, adminID, id, model.AuditUserUnsuspended, func(user *model.User) error {
+		user.SuspendedAt, user.SuspendedUntil, user.SuspendReason = nil, nil, ""
+		return nil
+	})
+}
+
+// Logout signs user id out everywhere by invalidating their tokens
+func (s *AdminService) Logout(ctx context.Context, adminID, id uint) (*model.User, error) {
+	return s.change(ctx, adminID, id, model.AuditUserLoggedOut, func(user *model.User) error {
+		user.TokenVersion++
+		return nil
+	})
+}
+
+// Impersonate checks adminID may act as user id and records that they do.
+// Only active users who aren't admins can be impersonated.
+func (s *AdminService) Impersonate(ctx context.Context, adminID, id uint) (*model.User, error) {
+	return s.change(ctx, adminID, id, model.AuditUserImpersonated, func(user *model.User) error {
+		if user.IsAdmin() || user.Suspended(time.Now()) {
+			return ErrForbidden
+		}
+		return nil
+	})
+}
+
+// change applies fn to user id and audits it as action by adminID, with
+// the user's suspension before and after
+func (s *AdminService) change(ctx context.Context, adminID, id uint, action string, fn func(*model.User) error) (*model.User, error) {
+	if adminID == id {
+		return nil, ErrForbidden
+	}
+	var user *model.User
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.users.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		before := newSuspensionSnapshot(*user, now)
+		if err := fn(user); err != nil {
+			return err
+		}
+		if err := s.users.Update(ctx, user); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditEntry{
+			ActorID: adminID, Action: action, TargetType: model.AuditTargetUser, TargetID: id,
+			Before: before, After: newSuspensionSnapshot(*user, now),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
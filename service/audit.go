@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"app/audit"
+	"app/model"
+	"app/money"
+	"app/repository"
+)
+
+// MaxAuditPage caps how many audit log entries one page lists
+const MaxAuditPage = 200
+
+// AuditEntry describes an audited action. ActorID is zero when nobody was
+// signed in; TargetID is zero when the target is unknown. Before and After
+// are snapshots whose differing JSON fields are recorded.
+type AuditEntry struct {
+	ActorID    uint
+	Action     string
+	TargetType string
+	TargetID   uint
+	Before     any
+	After      any
+}
+
+// AuditService writes the audit log and lets admins search and export it
+type AuditService struct {
+	audit repository.AuditRepository
+}
+
+// NewAuditService creates an AuditService
+func NewAuditService(audit repository.AuditRepository) *AuditService {
+	return &AuditService{audit: audit}
+}
+
+// Record appends e to the audit log, inside the caller's transaction if
+// any, along with the request found in ctx
+func (s *AuditService) Record(ctx context.Context, e AuditEntry) error {
+	changes, err := audit.Diff(e.Before, e.After)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	req := audit.FromContext(ctx)
+	entry := &model.AuditLog{
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		Changes:    string(data),
+		IP:         req.IP,
+		UserAgent:  truncateRunes(req.UserAgent, 512),
+		RequestID:  req.RequestID,
+	}
+	if e.ActorID != 0 {
+		entry.ActorID = &e.ActorID
+	}
+	if e.TargetID != 0 {
+		entry.TargetID = &e.TargetID
+	}
+	if req.ImpersonatorID != 0 {
+		entry.ImpersonatorID = &req.ImpersonatorID
+	}
+	return s.audit.Append(ctx, entry)
+}
+
+// ItemEvent is an EventHook recording items created, updated and deleted
+func (s *AuditService) ItemEvent(ctx context.Context, event Event) error {
+	e := AuditEntry{ActorID: event.ActorID, TargetType: model.AuditTargetItem, TargetID: event.Item.ID}
+	switch event.Type {
+	case EventItemCreated:
+		e.Action, e.After = model.AuditItemCreated, newItemSnapshot(event.Item)
+	case EventItemUpdated:
+		e.Action, e.After = model.AuditItemUpdated, newItemSnapshot(event.Item)
+		if event.Before != nil {
+			e.Before = newItemSnapshot(*event.Before)
+		}
+	case EventItemDeleted:
+		e.Action, e.Before = model.AuditItemDeleted, newItemSnapshot(event.Item)
+	default:
+		return nil
+	}
+	return s.Record(ctx, e)
+}
+
+// List returns a page of matching entries, newest first, and how many
+// match in all. Pages count from 1.
+func (s *AuditService) List(ctx context.Context, filter repository.AuditFilter, page, limit int) ([]model.AuditLog, int64, error) {
+	filter.Offset, filter.Limit = (page-1)*limit, limit
+	return s.audit.List(ctx, filter)
+}
+
+// RecordExport records that adminID exports the entries matching filter.
+// It comes first, so an export that can't be audited isn't started.
+func (s *AuditService) RecordExport(ctx context.Context, adminID uint, filter repository.AuditFilter) error {
+	return s.Record(ctx, AuditEntry{
+		ActorID: adminID, Action: model.AuditLogExported, TargetType: model.AuditTargetAudit,
+		After: exportedFilter(filter),
+	})
+}
+
+// Export writes every entry matching filter to w as CSV, oldest first, a
+// batch at a time, flushing after each
+func (s *AuditService) Export(ctx context.Context, filter repository.AuditFilter, w io.Writer) error {
+	out := csv.NewWriter(w)
+	header := []string{"id", "created_at", "actor_id", "impersonator_id", "action", "target_type", "target_id", "changes", "ip", "user_agent", "request_id"}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	err := s.audit.Each(ctx, filter, 500, func(entries []model.AuditLog) error {
+		for _, e := range entries {
+			row := []string{
+				strconv.FormatUint(uint64(e.ID), 10), e.CreatedAt.UTC().Format(time.RFC3339),
+				optionalID(e.ActorID), optionalID(e.ImpersonatorID), e.Action, e.TargetType, optionalID(e.TargetID),
+				e.Changes, e.IP, e.UserAgent, e.RequestID,
+			}
+			for i := range row {
+				row[i] = csvCell(row[i])
+			}
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+		out.Flush()
+		return out.Error()
+	})
+	if err != nil {
+		return err
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// exportedFilter is what the audit log shows of an export's filter
+func exportedFilter(f repository.AuditFilter) map[string]any {
+	out := map[string]any{}
+	if f.ActorID != 0 {
+		out["actor_id"] = f.ActorID
+	}
+	if f.Action != "" {
+		out["action"] = f.Action
+	}
+	if f.TargetType != "" {
+		out["target_type"] = f.TargetType
+	}
+	if f.TargetID != 0 {
+		out["target_id"] = f.TargetID
+	}
+	if !f.From.IsZero() {
+		out["from"] = f.From
+	}
+	if !f.To.IsZero() {
+		out["to"] = f.To
+	}
+	return out
+}
+
+func optionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+// csvCell defuses values a spreadsheet would run as a formula
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// userSnapshot is what the audit log shows of a user. Role and status
+// are kept with their values. Personal details, the username included,
+// are only named in Changed: the log outlives anonymizing, so it tells
+// that a user was renamed but not from what to what.
+type userSnapshot struct {
+	Role    string   `json:"role"`
+	Status  string   `json:"status"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+func newUserSnapshot(u model.User, now time.Time) userSnapshot {
+	return userSnapshot{Role: u.Role, Status: u.Status(now)}
+}
+
+// changedUserFields lists the personal details that differ between before
+// and after
+func changedUserFields(before, after model.User) []string {
+	var out []string
+	for _, f := range []struct {
+		name     string
+		from, to string
+	}{
+		{"username", before.Username, after.Username},
+		{"email", before.Email, after.Email},
+		{"display_name", before.DisplayName, after.DisplayName},
+		{"avatar_url", before.AvatarURL, after.AvatarURL},
+		{"bio", before.Bio, after.Bio},
+	} {
+		if f.from != f.to {
+			out = append(out, f.name)
+		}
+	}
+	return out
+}
+
+// suspensionSnapshot is what the audit log shows of a user's suspension
+type suspensionSnapshot struct {
+	SuspendedUntil *time.Time `json:"suspended_until"`
+	Reason         string     `json:"reason"`
+	Status         string     `json:"status"`
+}
+
+func newSuspensionSnapshot(u model.User, now time.Time) suspensionSnapshot {
+	return suspensionSnapshot{SuspendedUntil: u.SuspendedUntil, Reason: u.SuspendReason, Status: u.Status(now)}
+}
+
+// itemSnapshot is what the audit log shows of an item
+type itemSnapshot struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+	Stock       int         `json:"stock"`
+	CategoryID  uint        `json:"category_id"`
+	Status      string      `json:"status"`
+	PublishAt   *time.Time  `json:"publish_at"`
+	UnpublishAt *time.Time  `json:"unpublish_at"`
+}
+
+func newItemSnapshot(i model.Item) itemSnapshot {
+	return itemSnapshot{
+		Name: i.Name, Description: i.Description, Price: i.Price, Stock: i.Stock, CategoryID: i.CategoryID,
+		Status: i.Status, PublishAt: i.PublishAt, UnpublishAt: i.UnpublishAt,
+	}
+}
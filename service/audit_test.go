@@ -0,0 +1,115 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"testing"
+	"time"
+
+	"app/audit"
+	"app/database"
+	"app/model"
+	"app/repository"
+	"app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudit_SignInsAndProfileChanges(t *testing.T) {
+	db := database.ConnectDBWithDSN(":memory:")
+	repos := repository.New(db)
+	log := service.NewAuditService(repos.Audit)
+	users := service.NewUserService(repos.Tx, repos.Users, repos.Items, repos.Reviews, log)
+	ctx := audit.WithRequest(context.Background(), audit.Request{IP: "203.0.113.7", UserAgent: "curl/8", RequestID: "req-1"})
+
+	user, err := users.Register(ctx, "ann", "ann@example.com", "password1")
+	require.NoError(t, err)
+	_, err = users.Authenticate(ctx, "ann", "wrong")
+	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+	_, err = users.Authenticate(ctx, "nobody", "wrong")
+	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+	_, err = users.Authenticate(ctx, "ann@example.com", "password1")
+	require.NoError(t, err)
+	bio := "Hi"
+	_, err = users.UpdateProfile(ctx, user.ID, service.ProfileUpdate{Bio: &bio})
+	require.NoError(t, err)
+	// Saving the same profile again changes nothing worth logging
+	_, err = users.UpdateProfile(ctx, user.ID, service.ProfileUpdate{Bio: &bio})
+	require.NoError(t, err)
+
+	entries, total, err := log.List(ctx, repository.AuditFilter{}, 1, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 5, total)
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	assert.Equal(t, []string{
+		model.AuditUserUpdated, model.AuditLoginSucceeded, model.AuditLoginFailed,
+		model.AuditLoginFailed, model.AuditUserRegistered,
+	}, actions)
+	// Personal details are named but never logged
+	assert.JSONEq(t, `{"changed":{"from":null,"to":["bio"]}}`, entries[0].Changes)
+	assert.Equal(t, "203.0.113.7", entries[0].IP)
+	assert.Equal(t, "req-1", entries[0].RequestID)
+	assert.Nil(t, entries[2].ActorID)
+	assert.Nil(t, entries[2].TargetID)
+	assert.Equal(t, user.ID, *entries[3].TargetID)
+	assert.JSONEq(t, `{"role":{"from":null,"to":"user"},"status":{"from":null,"to":"active"}}`, entries[4].Changes)
+	assert.NotContains(t, entries[4].Changes, "password")
+	assert.NotContains(t, entries[4].Changes, "ann")
+}
+
+func TestAudit_FailedSignInsKeepNoIdentity(t *testing.T) {
+	db := database.ConnectDBWithDSN(":memory:")
+	repos := repository.New(db)
+	users := service.NewUserService(repos.Tx, repos.Users, repos.Items, repos.Reviews, service.NewAuditService(repos.Audit))
+	ctx := context.Background()
+	user, err := users.Register(ctx, "ann", "ann@example.com", "password1")
+	require.NoError(t, err)
+
+	// A password typed into the username field
+	_, err = users.Authenticate(ctx, "Tr0ub4dor&3", "Tr0ub4dor&3")
+	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+	_, err = users.Authenticate(ctx, "ann@example.com", "Tr0ub4dor&3")
+	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+	until := time.Now().Add(time.Hour)
+	require.NoError(t, db.Model(user).Updates(map[string]any{"suspended_at": time.Now(), "suspended_until": until}).Error)
+	_, err = users.Authenticate(ctx, "ann@example.com", "password1")
+	assert.ErrorIs(t, err, service.ErrSuspended)
+
+	var rows []map[string]any
+	require.NoError(t, db.Table("audit_log").Where("action = ?", model.AuditLoginFailed).Order("id").Find(&rows).Error)
+	require.Len(t, rows, 3)
+	for i, reason := range []string{model.LoginUnknownUser, model.LoginBadPassword, model.LoginSuspended} {
+		assert.JSONEq(t, fmt.Sprintf(`{"reason":{"from":null,"to":%q}}`, reason), rows[i]["changes"].(string))
+		dump := fmt.Sprint(rows[i])
+		assert.NotContains(t, dump, "Tr0ub4dor")
+		assert.NotContains(t, dump, "ann@example.com")
+	}
+	assert.Nil(t, rows[0]["target_id"], "unknown users have no target")
+	assert.EqualValues(t, user.ID, rows[1]["target_id"])
+}
+
+func TestAudit_ExportDefusesFormulas(t *testing.T) {
+	db := database.ConnectDBWithDSN(":memory:")
+	log := service.NewAuditService(repository.New(db).Audit)
+	ctx := audit.WithRequest(context.Background(), audit.Request{UserAgent: "=HYPERLINK(\"http://evil\")"})
+	require.NoError(t, log.Record(ctx, service.AuditEntry{Action: model.AuditLoginFailed, TargetType: model.AuditTargetUser}))
+
+	var buf bytes.Buffer
+	filter := repository.AuditFilter{Action: model.AuditLoginFailed}
+	require.NoError(t, log.RecordExport(context.Background(), 9, filter))
+	require.NoError(t, log.Export(context.Background(), filter, &buf))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, `'=HYPERLINK("http://evil")`, rows[1][9])
+
+	_, total, err := log.List(context.Background(), repository.AuditFilter{ActorID: 9, Action: model.AuditLogExported}, 1, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+}
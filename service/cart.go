@@ -0,0 +1,306 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"app/model"
+	"app/money"
+	"app/repository"
+)
+
+// Problems that keep a cart line from being checked out
+const (
+	LineUnavailable       = "item_unavailable"
+	LineInsufficientStock = "insufficient_stock"
+)
+
+// MaxCartQuantity caps the units of one item in a cart
+const MaxCartQuantity = 1000
+
+// CartOwner identifies a cart: a signed-in user, or else a guest token
+type CartOwner struct {
+	UserID     uint
+	GuestToken string
+}
+
+// CartLine is a cart line priced from the live item
+type CartLine struct {
+	ItemID uint
+	// Item is nil once the item has been deleted
+	Item     *model.Item
+	Quantity int
+	Subtotal money.Money
+	// Problem is empty when the line can be checked out
+	Problem string
+}
+
+// CartView is a cart with current prices and stock
+type CartView struct {
+	Lines []CartLine
+	// Totals has one sum per currency, in the order lines first use them
+	Totals []money.Money
+	// Ready reports whether every line can be checked out
+	Ready bool
+}
+
+// CartService keeps carts and converts them into orders
+type CartService struct {
+	tx     repository.TxManager
+	carts  repository.CartRepository
+	items  repository.ItemRepository
+	orders *OrderService
+}
+
+// NewCartService creates a CartService
+func NewCartService(tx repository.TxManager, carts repository.CartRepository, items repository.ItemRepository, orders *OrderService) *CartService {
+	return &CartService{tx: tx, carts: carts, items: items, orders: orders}
+}
+
+// Get returns the owner's cart, empty when they have none yet
+func (s *CartService) Get(ctx context.Context, owner CartOwner) (*CartView, error) {
+	cart, err := s.find(ctx, owner)
+	if errors.Is(err, repository.ErrNotFound) {
+		return view(&model.Cart{})
+	} else if err != nil {
+		return nil, err
+	}
+	return view(cart)
+}
+
+// Add puts quantity more units of an item in the owner's cart
+func (s *CartService) Add(ctx context.Context, owner CartOwner, itemID uint, quantity int) (*CartView, error) {
+	if quantity < 1 {
+		return nil, ErrInvalidQuantity
+	}
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		cart, err := s.findOrCreate(ctx, owner)
+		if err != nil {
+			return err
+		}
+		for _, line := range cart.Items {
+			if line.ItemID == itemID {
+				quantity += line.Quantity
+			}
+		}
+		return s.setLine(ctx, owner, cart.ID, itemID, quantity)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, owner)
+}
+
+// SetQuantity changes the units of an item already in the owner's cart;
+// zero removes the line
+func (s *CartService) SetQuantity(ctx context.Context, owner CartOwner, itemID uint, quantity int) (*CartView, error) {
+	if quantity == 0 {
+		return s.Remove(ctx, owner, itemID)
+	}
+	if quantity < 0 {
+		return nil, ErrInvalidQuantity
+	}
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		cart, err := s.find(ctx, owner)
+		if err != nil {
+			return err
+		}
+		if !hasLine(cart, itemID) {
+			return ErrNotFound
+		}
+		return s.setLine(ctx, owner, cart.ID, itemID, quantity)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, owner)
+}
+
+// Remove takes an item out of the owner's cart
+func (s *CartService) Remove(ctx context.Context, owner CartOwner, itemID uint) (*CartView, error) {
+	cart, err := s.find(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.carts.DeleteLine(ctx, cart.ID, itemID); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, owner)
+}
+
+// Checkout turns buyerID's cart into one order per line and empties it.
+// Either every line is ordered or, if any fails, none is.
+func (s *CartService) Checkout(ctx context.Context, buyerID uint) ([]model.Order, error) {
+	var orders []model.Order
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		cart, err := s.carts.FindByUser(ctx, buyerID)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && len(cart.Items) == 0) {
+			return ErrCartEmpty
+		} else if err != nil {
+			return err
+		}
+		// Lock items in a fixed order so concurrent checkouts can't deadlock
+		lines := append([]model.CartItem(nil), cart.Items...)
+		sort.Slice(lines, func(i, j int) bool { return lines[i].ItemID < lines[j].ItemID })
+		for _, line := range lines {
+			order, err := s.orders.place(ctx, buyerID, line.ItemID, line.Quantity)
+			if err != nil {
+				return fmt.Errorf("item %d: %w", line.ItemID, err)
+			}
+			orders = append(orders, *order)
+		}
+		return s.carts.ClearLines(ctx, cart.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// Merge moves a guest cart into userID's cart after they sign in. Units of
+// an item in both are added up, capped at the stock. Lines for items that
+// are gone or that the user sells are dropped.
+func (s *CartService) Merge(ctx context.Context, userID uint, guestToken string) error {
+	if guestToken == "" {
+		return nil
+	}
+	return s.tx.Transaction(ctx, func(ctx context.Context) error {
+		guest, err := s.carts.FindByGuest(ctx, guestToken)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		owner := CartOwner{UserID: userID}
+		cart, err := s.findOrCreate(ctx, owner)
+		if err != nil {
+			return err
+		}
+		for _, line := range guest.Items {
+			if line.Item.ID == 0 || line.Item.UserID == userID {
+				continue
+			}
+			quantity := line.Quantity
+			for _, mine := range cart.Items {
+				if mine.ItemID == line.ItemID {
+					quantity += mine.Quantity
+				}
+			}
+			quantity = min(quantity, line.Item.Stock, MaxCartQuantity)
+			if quantity < 1 {
+				continue
+			}
+			if err := s.carts.SaveLine(ctx, &model.CartItem{CartID: cart.ID, ItemID: line.ItemID, Quantity: quantity}); err != nil {
+				return err
+			}
+		}
+		return s.carts.Delete(ctx, guest)
+	})
+}
+
+// setLine validates that the item can be bought by owner in quantity and
+// stores the line
+func (s *CartService) setLine(ctx context.Context, owner CartOwner, cartID, itemID uint, quantity int) error {
+	if quantity > MaxCartQuantity {
+		return ErrInvalidQuantity
+	}
+	item, err := s.items.FindByID(ctx, itemID)
+	if err != nil {
+		return err
+	}
+	if owner.UserID != 0 && item.UserID == owner.UserID {
+		return ErrOwnItem
+	}
+	if !item.Listed() {
+		return ErrItemUnavailable
+	}
+	if item.Stock < quantity {
+		return ErrInsufficientStock
+	}
+	return s.carts.SaveLine(ctx, &model.CartItem{CartID: cartID, ItemID: itemID, Quantity: quantity})
+}
+
+func (s *CartService) find(ctx context.Context, owner CartOwner) (*model.Cart, error) {
+	if owner.UserID != 0 {
+		return s.carts.FindByUser(ctx, owner.UserID)
+	}
+	if owner.GuestToken == "" {
+		return nil, ErrNotFound
+	}
+	return s.carts.FindByGuest(ctx, owner.GuestToken)
+}
+
+func (s *CartService) findOrCreate(ctx context.Context, owner CartOwner) (*model.Cart, error) {
+	cart, err := s.find(ctx, owner)
+	if !errors.Is(err, repository.ErrNotFound) {
+		return cart, err
+	}
+	cart = &model.Cart{}
+	if owner.UserID != 0 {
+		cart.UserID = &owner.UserID
+	} else if owner.GuestToken != "" {
+		cart.GuestToken = &owner.GuestToken
+	} else {
+		return nil, ErrForbidden
+	}
+	if err := s.carts.Create(ctx, cart); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+func hasLine(cart *model.Cart, itemID uint) bool {
+	for _, line := range cart.Items {
+		if line.ItemID == itemID {
+			return true
+		}
+	}
+	return false
+}
+
+// view prices every line of cart from its item
+func view(cart *model.Cart) (*CartView, error) {
+	v := &CartView{Lines: make([]CartLine, 0, len(cart.Items)), Ready: len(cart.Items) > 0}
+	for _, line := range cart.Items {
+		l := CartLine{ItemID: line.ItemID, Quantity: line.Quantity}
+		if line.Item.ID == 0 || !line.Item.Listed() {
+			l.Problem = LineUnavailable
+			v.Lines = append(v.Lines, l)
+			v.Ready = false
+			continue
+		}
+		item := line.Item
+		l.Item = &item
+		subtotal, err := item.Price.Mul(int64(line.Quantity))
+		if err != nil {
+			return nil, err
+		}
+		l.Subtotal = subtotal
+		if item.Stock < line.Quantity {
+			l.Problem = LineInsufficientStock
+			v.Ready = false
+		}
+		v.Lines = append(v.Lines, l)
+		if err := v.addTotal(subtotal); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (v *CartView) addTotal(m money.Money) error {
+	for i, t := range v.Totals {
+		if t.Currency == m.Currency {
+			sum, err := t.Add(m)
+			if err != nil {
+				return err
+			}
+			v.Totals[i] = sum
+			return nil
+		}
+	}
+	v.Totals = append(v.Totals, m)
+	return nil
+}
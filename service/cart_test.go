@@ -0,0 +1,142 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"app/database"
+	"app/model"
+	"app/money"
+	"app/repository"
+	"app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupCarts(t *testing.T) (*service.CartService, *gorm.DB) {
+	db := database.ConnectDBWithDSN(":memory:")
+	repos := repository.New(db)
+	require.NoError(t, db.Create(&model.User{ID: 1, Username: "seller", Email: "s@example.com", Password: "x"}).Error)
+	require.NoError(t, db.Create(&model.User{ID: 2, Username: "buyer", Email: "b@example.com", Password: "x"}).Error)
+	require.NoError(t, db.Create(&model.Item{ID: 1, Name: "Lamp", Description: "d", Price: money.New(1999, "EUR"), UserID: 1, Stock: 5}).Error)
+	require.NoError(t, db.Create(&model.Item{ID: 2, Name: "Bulb", Description: "d", Price: money.New(250, "EUR"), UserID: 1, Stock: 1}).Error)
+	require.NoError(t, db.Create(&model.Item{ID: 3, Name: "Vase", Description: "d", Price: money.New(3000, "JPY"), UserID: 1, Stock: 2}).Error)
+	inventory := service.NewInventoryService(repos.Tx, repos.Items, repos.Inventory)
+	orders := service.NewOrderService(repos.Tx, repos.Items, repos.Orders, inventory)
+	return service.NewCartService(repos.Tx, repos.Carts, repos.Items, orders), db
+}
+
+func TestCart_AddAndView(t *testing.T) {
+	carts, db := setupCarts(t)
+	ctx := context.Background()
+	buyer := service.CartOwner{UserID: 2}
+
+	_, err := carts.Add(ctx, buyer, 1, 2)
+	require.NoError(t, err)
+	_, err = carts.Add(ctx, buyer, 3, 1)
+	require.NoError(t, err)
+	cart, err := carts.Add(ctx, buyer, 1, 1)
+	require.NoError(t, err)
+
+	require.Len(t, cart.Lines, 2)
+	assert.Equal(t, 3, cart.Lines[0].Quantity)
+	assert.Equal(t, money.New(5997, "EUR"), cart.Lines[0].Subtotal)
+	assert.Equal(t, []money.Money{money.New(5997, "EUR"), money.New(3000, "JPY")}, cart.Totals)
+	assert.True(t, cart.Ready)
+
+	_, err = carts.Add(ctx, buyer, 2, 2)
+	assert.ErrorIs(t, err, service.ErrInsufficientStock)
+	_, err = carts.Add(ctx, buyer, 9, 1)
+	assert.ErrorIs(t, err, service.ErrNotFound)
+	_, err = carts.Add(ctx, service.CartOwner{UserID: 1}, 1, 1)
+	assert.ErrorIs(t, err, service.ErrOwnItem)
+
+	// Prices and stock are live
+	db.Model(&model.Item{}).Where("id = 1").Updates(map[string]any{"price_amount": 1000, "stock": 2})
+	require.NoError(t, db.Delete(&model.Item{}, 3).Error)
+	cart, err = carts.Get(ctx, buyer)
+	require.NoError(t, err)
+	assert.Equal(t, money.New(3000, "EUR"), cart.Lines[0].Subtotal)
+	assert.Equal(t, service.LineInsufficientStock, cart.Lines[0].Problem)
+	assert.Equal(t, service.LineUnavailable, cart.Lines[1].Problem)
+	assert.False(t, cart.Ready)
+
+	cart, err = carts.SetQuantity(ctx, buyer, 1, 0)
+	require.NoError(t, err)
+	assert.Len(t, cart.Lines, 1)
+	_, err = carts.SetQuantity(ctx, buyer, 1, 1)
+	assert.ErrorIs(t, err, service.ErrNotFound)
+}
+
+func TestCart_CheckoutIsAtomic(t *testing.T) {
+	carts, db := setupCarts(t)
+	ctx := context.Background()
+	buyer := service.CartOwner{UserID: 2}
+
+	_, err := carts.Checkout(ctx, 2)
+	assert.ErrorIs(t, err, service.ErrCartEmpty)
+
+	_, err = carts.Add(ctx, buyer, 1, 2)
+	require.NoError(t, err)
+	_, err = carts.Add(ctx, buyer, 2, 1)
+	require.NoError(t, err)
+
+	// The bulb sells out after it was added, so nothing is ordered
+	db.Model(&model.Item{}).Where("id = 2").Update("stock", 0)
+	_, err = carts.Checkout(ctx, 2)
+	assert.ErrorIs(t, err, service.ErrInsufficientStock)
+	var n int64
+	db.Model(&model.Order{}).Count(&n)
+	assert.Zero(t, n)
+	var lamp model.Item
+	db.First(&lamp, 1)
+	assert.Equal(t, 5, lamp.Stock)
+
+	db.Model(&model.Item{}).Where("id = 2").Update("stock", 1)
+	orders, err := carts.Checkout(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	assert.Equal(t, money.New(3998, "EUR"), orders[0].TotalPrice)
+	db.First(&lamp, 1)
+	assert.Equal(t, 3, lamp.Stock)
+
+	cart, err := carts.Get(ctx, buyer)
+	require.NoError(t, err)
+	assert.Empty(t, cart.Lines)
+}
+
+func TestCart_MergeGuestCart(t *testing.T) {
+	carts, db := setupCarts(t)
+	ctx := context.Background()
+	guest := service.CartOwner{GuestToken: "guest-token"}
+
+	_, err := carts.Add(ctx, guest, 1, 4)
+	require.NoError(t, err)
+	_, err = carts.Add(ctx, guest, 3, 1)
+	require.NoError(t, err)
+	_, err = carts.Add(ctx, service.CartOwner{UserID: 2}, 1, 3)
+	require.NoError(t, err)
+
+	require.NoError(t, carts.Merge(ctx, 2, "guest-token"))
+
+	cart, err := carts.Get(ctx, service.CartOwner{UserID: 2})
+	require.NoError(t, err)
+	require.Len(t, cart.Lines, 2)
+	assert.Equal(t, 5, cart.Lines[0].Quantity, "capped at the stock")
+	assert.Equal(t, uint(3), cart.Lines[1].ItemID)
+
+	var n int64
+	db.Model(&model.Cart{}).Where("guest_token IS NOT NULL").Count(&n)
+	assert.Zero(t, n)
+	assert.NoError(t, carts.Merge(ctx, 2, "guest-token"), "merging twice is harmless")
+
+	// The seller's own listings don't follow them into their cart
+	_, err = carts.Add(ctx, service.CartOwner{GuestToken: "other"}, 1, 1)
+	require.NoError(t, err)
+	require.NoError(t, carts.Merge(ctx, 1, "other"))
+	cart, err = carts.Get(ctx, service.CartOwner{UserID: 1})
+	require.NoError(t, err)
+	assert.Empty(t, cart.Lines)
+}
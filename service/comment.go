@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"app/model"
+	"app/repository"
+)
+
+// MaxCommentLength caps a comment body, in characters
+const MaxCommentLength = 2000
+
+// CommentThread is a comment with its replies. Deleted and hidden
+// comments only appear while they have replies, with an empty body.
+type CommentThread struct {
+	Comment model.Comment
+	Deleted bool
+	Replies []CommentThread
+}
+
+// CommentService keeps the public threads on items. Its hooks see replies.
+type CommentService struct {
+	EventHooks
+	tx       repository.TxManager
+	comments repository.CommentRepository
+	items    repository.ItemRepository
+}
+
+// NewCommentService creates a CommentService
+func NewCommentService(tx repository.TxManager, comments repository.CommentRepository, items repository.ItemRepository) *CommentService {
+	return &CommentService{tx: tx, comments: comments, items: items}
+}
+
+// List returns the threads of an item, oldest first
+func (s *CommentService) List(ctx context.Context, itemID uint) ([]CommentThread, error) {
+	if _, err := s.items.FindByID(ctx, itemID); err != nil {
+		return nil, err
+	}
+	comments, err := s.comments.ListByItem(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	children := map[uint][]model.Comment{}
+	var roots []model.Comment
+	for _, c := range comments {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+	return threads(roots, children), nil
+}
+
+// threads nests children under comments, dropping deleted comments left
+// without replies
+func threads(comments []model.Comment, children map[uint][]model.Comment) []CommentThread {
+	out := []CommentThread{}
+	for _, c := range comments {
+		t := CommentThread{Comment: c, Deleted: c.DeletedAt.Valid || c.Hidden, Replies: threads(children[c.ID], children)}
+		if t.Deleted && len(t.Replies) == 0 {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// Create comments on an item as userID, replying to parentID if set
+func (s *CommentService) Create(ctx context.Context, userID, itemID uint, parentID *uint, body string) (*model.Comment, error) {
+	body, err := commentBody(body)
+	if err != nil {
+		return nil, err
+	}
+	c := &model.Comment{ItemID: itemID, UserID: userID, Body: body}
+	err = s.tx.Transaction(ctx, func(ctx context.Context) error {
+		item, err := s.items.FindByID(ctx, itemID)
+		if err != nil {
+			return err
+		}
+		var parent *model.Comment
+		if parentID != nil {
+			parent, err = s.comments.FindByID(ctx, *parentID)
+			if err != nil {
+				return err
+			}
+			if parent.ItemID != itemID {
+				return ErrNotFound
+			}
+			if parent.Depth >= model.MaxCommentDepth {
+				return ErrThreadTooDeep
+			}
+			c.ParentID, c.Depth = &parent.ID, parent.Depth+1
+		}
+		if err := s.comments.Create(ctx, c); err != nil {
+			return err
+		}
+		if err := s.items.AdjustCounts(ctx, itemID, 0, 1); err != nil {
+			return err
+		}
+		if parent == nil {
+			return nil
+		}
+		return s.emit(ctx, Event{Type: model.NotifyCommentReply, ActorID: userID, Item: *item, Comment: c, Parent: parent})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.comments.FindByID(ctx, c.ID)
+}
+
+// Edit changes the body of a comment; only its author may
+func (s *CommentService) Edit(ctx context.Context, userID, id uint, body string) (*model.Comment, error) {
+	body, err := commentBody(body)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.comments.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserID != userID {
+		return nil, ErrForbidden
+	}
+	now := time.Now()
+	c.Body, c.EditedAt = body, &now
+	if err := s.comments.Update(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Delete removes a comment; only its author may. Its replies stay.
+func (s *CommentService) Delete(ctx context.Context, userID, id uint) error {
+	return s.tx.Transaction(ctx, func(ctx context.Context) error {
+		c, err := s.comments.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if c.UserID != userID {
+			return ErrForbidden
+		}
+		if err := s.comments.Delete(ctx, c); err != nil {
+			return err
+		}
+		return s.items.AdjustCounts(ctx, c.ItemID, 0, -1)
+	})
+}
+
+func commentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > MaxCommentLength {
+		return "", ErrInvalidComment
+	}
+	return body, nil
+}
@@ -0,0 +1,130 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"app/database"
+	"app/model"
+	"app/money"
+	"app/repository"
+	"app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupComments(t *testing.T) (*service.CommentService, *service.LikeService, *gorm.DB) {
+	db := database.ConnectDBWithDSN(":memory:")
+	repos := repository.New(db)
+	require.NoError(t, db.Create(&model.User{ID: 1, Username: "seller", Email: "s@example.com", Password: "x"}).Error)
+	require.NoError(t, db.Create(&model.User{ID: 2, Username: "buyer", Email: "b@example.com", Password: "x"}).Error)
+	require.NoError(t, db.Create(&model.Item{ID: 1, Name: "Lamp", Description: "d", Price: money.New(1000, "EUR"), UserID: 1}).Error)
+	require.NoError(t, db.Create(&model.Item{ID: 2, Name: "Vase", Description: "d", Price: money.New(1000, "EUR"), UserID: 1}).Error)
+	return service.NewCommentService(repos.Tx, repos.Comments, repos.Items),
+		service.NewLikeService(repos.Tx, repos.Likes, repos.Items), db
+}
+
+func commentCount(t *testing.T, db *gorm.DB, itemID uint) int {
+	var item model.Item
+	require.NoError(t, db.First(&item, itemID).Error)
+	return item.CommentCount
+}
+
+func TestComments_Threads(t *testing.T) {
+	comments, _, db := setupComments(t)
+	ctx := context.Background()
+
+	question, err := comments.Create(ctx, 2, 1, nil, "  Does it come with a bulb?  ")
+	require.NoError(t, err)
+	assert.Equal(t, "Does it come with a bulb?", question.Body)
+	answer, err := comments.Create(ctx, 1, 1, &question.ID, "Yes, a warm white one.")
+	require.NoError(t, err)
+	assert.Equal(t, 1, answer.Depth)
+	_, err = comments.Create(ctx, 2, 1, &answer.ID, "Thanks!")
+	require.NoError(t, err)
+	other, err := comments.Create(ctx, 2, 1, nil, "Still available?")
+	require.NoError(t, err)
+	assert.Equal(t, 4, commentCount(t, db, 1))
+
+	_, err = comments.Create(ctx, 2, 2, &question.ID, "wrong item")
+	assert.ErrorIs(t, err, service.ErrNotFound)
+	_, err = comments.Create(ctx, 2, 1, nil, "   ")
+	assert.ErrorIs(t, err, service.ErrInvalidComment)
+	_, err = comments.Create(ctx, 2, 1, nil, strings.Repeat("é", service.MaxCommentLength+1))
+	assert.ErrorIs(t, err, service.ErrInvalidComment)
+
+	_, err = comments.Edit(ctx, 1, question.ID, "hijacked")
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	edited, err := comments.Edit(ctx, 2, question.ID, "Does it come with a bulb included?")
+	require.NoError(t, err)
+	assert.NotNil(t, edited.EditedAt)
+
+	// A deleted comment with replies stays as a placeholder; one without
+	// replies disappears
+	assert.ErrorIs(t, comments.Delete(ctx, 1, question.ID), service.ErrForbidden)
+	require.NoError(t, comments.Delete(ctx, 2, question.ID))
+	require.NoError(t, comments.Delete(ctx, 2, other.ID))
+	assert.Equal(t, 2, commentCount(t, db, 1))
+	_, err = comments.Create(ctx, 1, 1, &question.ID, "reply to deleted")
+	assert.ErrorIs(t, err, service.ErrNotFound)
+
+	threads, err := comments.List(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, threads, 1)
+	assert.True(t, threads[0].Deleted)
+	assert.Empty(t, threads[0].Comment.Body)
+	require.Len(t, threads[0].Replies, 1)
+	assert.Equal(t, "seller", threads[0].Replies[0].Comment.User.Username)
+	require.Len(t, threads[0].Replies[0].Replies, 1)
+	assert.Equal(t, "Thanks!", threads[0].Replies[0].Replies[0].Comment.Body)
+
+	_, err = comments.List(ctx, 9)
+	assert.ErrorIs(t, err, service.ErrNotFound)
+}
+
+func TestComments_DepthLimit(t *testing.T) {
+	comments, _, _ := setupComments(t)
+	ctx := context.Background()
+
+	var parent *uint
+	for depth := 0; depth <= model.MaxCommentDepth; depth++ {
+		c, err := comments.Create(ctx, 2, 1, parent, "deeper")
+		require.NoError(t, err)
+		parent = &c.ID
+	}
+	_, err := comments.Create(ctx, 2, 1, parent, "too deep")
+	assert.ErrorIs(t, err, service.ErrThreadTooDeep)
+}
+
+func TestLikes_Toggle(t *testing.T) {
+	_, likes, _ := setupComments(t)
+	ctx := context.Background()
+
+	n, err := likes.Like(ctx, 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = likes.Like(ctx, 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "liking twice counts once")
+	n, err = likes.Like(ctx, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	liked, n, err := likes.Liked(ctx, 2, 1)
+	require.NoError(t, err)
+	assert.True(t, liked)
+	assert.Equal(t, 2, n)
+
+	n, err = likes.Unlike(ctx, 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = likes.Unlike(ctx, 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = likes.Like(ctx, 2, 9)
+	assert.ErrorIs(t, err, service.ErrNotFound)
+}
@@ -0,0 +1,277 @@
+package service_test
+
+import (
+	"context"
+	"time"
+
+	"app/model"
+	"app/repository"
+)
+
+type fakeTx struct{}
+
+func (fakeTx) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type fakeItems struct {
+	items map[uint]*model.Item
+}
+
+// newFakeItems stores items, active unless they say otherwise as with the
+// column default
+func newFakeItems(items ...model.Item) *fakeItems {
+	f := &fakeItems{items: map[uint]*model.Item{}}
+	for i := range items {
+		if items[i].Status == "" {
+			items[i].Status = model.ItemActive
+		}
+		f.items[items[i].ID] = &items[i]
+	}
+	return f
+}
+
+func (f *fakeItems) List(_ context.Context, filter repository.ItemFilter) ([]model.Item, error) {
+	var out []model.Item
+	for _, it := range f.items {
+		if !filter.InStock || it.Stock > 0 {
+			out = append(out, *it)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeItems) ListByCategory(_ context.Context, categoryID uint, filter repository.ItemFilter) ([]model.Item, error) {
+	var out []model.Item
+	for _, it := range f.items {
+		if it.CategoryID == categoryID && (!filter.InStock || it.Stock > 0) {
+			out = append(out, *it)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeItems) ListByUser(_ context.Context, userID uint, filter repository.ItemFilter) ([]model.Item, error) {
+	var out []model.Item
+	for _, it := range f.items {
+		if it.UserID == userID && (filter.All || it.Listed()) {
+			out = append(out, *it)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeItems) FindByID(_ context.Context, id uint) (*model.Item, error) {
+	it, ok := f.items[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	cp := *it
+	return &cp, nil
+}
+
+func (f *fakeItems) FindForUpdate(ctx context.Context, id uint) (*model.Item, error) {
+	return f.FindByID(ctx, id)
+}
+
+func (f *fakeItems) SetStock(_ context.Context, id uint, stock int) error {
+	it, ok := f.items[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	it.Stock = stock
+	return nil
+}
+
+func (f *fakeItems) ListScheduled(_ context.Context, now time.Time) ([]model.Item, error) {
+	var out []model.Item
+	for _, it := range f.items {
+		if (it.PublishAt != nil && !it.PublishAt.After(now)) || (it.UnpublishAt != nil && !it.UnpublishAt.After(now)) {
+			out = append(out, *it)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeItems) SetHidden(_ context.Context, id uint, hidden bool) error {
+	it, ok := f.items[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	it.Hidden = hidden
+	return nil
+}
+
+func (f *fakeItems) AdjustCounts(_ context.Context, id uint, likes, comments int) error {
+	it, ok := f.items[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	it.LikeCount += likes
+	it.CommentCount += comments
+	return nil
+}
+
+func (f *fakeItems) CountByUser(_ context.Context, userID uint) (int64, error) {
+	var n int64
+	for _, it := range f.items {
+		if it.UserID == userID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeItems) Create(_ context.Context, item *model.Item) error {
+	item.ID = uint(len(f.items) + 1)
+	cp := *item
+	f.items[item.ID] = &cp
+	return nil
+}
+
+func (f *fakeItems) Update(_ context.Context, item *model.Item) error {
+	cp := *item
+	f.items[item.ID] = &cp
+	return nil
+}
+
+func (f *fakeItems) Delete(_ context.Context, item *model.Item) error {
+	delete(f.items, item.ID)
+	return nil
+}
+
+func (f *fakeItems) DeleteByUser(_ context.Context, userID uint) error {
+	for id, it := range f.items {
+		if it.UserID == userID {
+			delete(f.items, id)
+		}
+	}
+	return nil
+}
+
+func (f *fakeItems) RestoreByUser(context.Context, uint, time.Time) error {
+	return nil
+}
+
+type fakeOrders struct {
+	orders []model.Order
+}
+
+func (f *fakeOrders) FindByID(_ context.Context, id uint) (*model.Order, error) {
+	for i := range f.orders {
+		if f.orders[i].ID == id {
+			return &f.orders[i], nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (f *fakeOrders) FindForUpdate(ctx context.Context, id uint) (*model.Order, error) {
+	return f.FindByID(ctx, id)
+}
+
+func (f *fakeOrders) UpdateStatus(_ context.Context, id uint, status string) error {
+	for i := range f.orders {
+		if f.orders[i].ID == id {
+			f.orders[i].Status = status
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+func (f *fakeOrders) ListByUser(_ context.Context, userID uint) ([]model.Order, error) {
+	var out []model.Order
+	for _, o := range f.orders {
+		if o.UserID == userID {
+			out = append(out, o)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeOrders) Create(_ context.Context, order *model.Order) error {
+	order.ID = uint(len(f.orders) + 1)
+	f.orders = append(f.orders, *order)
+	return nil
+}
+
+type fakeCategories struct {
+	ids map[uint]bool
+}
+
+func (f fakeCategories) FindByID(_ context.Context, id uint) (*model.Category, error) {
+	if !f.ids[id] {
+		return nil, repository.ErrNotFound
+	}
+	return &model.Category{ID: id}, nil
+}
+
+func (f fakeCategories) List(context.Context) ([]model.Category, error) {
+	var out []model.Category
+	for id := range f.ids {
+		out = append(out, model.Category{ID: id})
+	}
+	return out, nil
+}
+
+type fakeInventory struct {
+	reservations []model.StockReservation
+	log          []model.InventoryAdjustment
+}
+
+func (f *fakeInventory) CreateReservation(_ context.Context, r *model.StockReservation) error {
+	r.ID = uint(len(f.reservations) + 1)
+	f.reservations = append(f.reservations, *r)
+	return nil
+}
+
+func (f *fakeInventory) FindReservationForUpdate(_ context.Context, id uint) (*model.StockReservation, error) {
+	for _, r := range f.reservations {
+		if r.ID == id {
+			return &r, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (f *fakeInventory) UpdateReservation(_ context.Context, r *model.StockReservation) error {
+	f.reservations[r.ID-1] = *r
+	return nil
+}
+
+func (f *fakeInventory) ListExpiredReservations(_ context.Context, now time.Time) ([]model.StockReservation, error) {
+	var out []model.StockReservation
+	for _, r := range f.reservations {
+		if r.Status == model.ReservationActive && r.ExpiresAt.Before(now) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeInventory) ReservedQuantity(_ context.Context, itemID uint) (int, error) {
+	n := 0
+	for _, r := range f.reservations {
+		if r.ItemID == itemID && r.Status == model.ReservationActive {
+			n += r.Quantity
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeInventory) LogAdjustment(_ context.Context, a *model.InventoryAdjustment) error {
+	a.ID = uint(len(f.log) + 1)
+	f.log = append(f.log, *a)
+	return nil
+}
+
+func (f *fakeInventory) ListAdjustments(_ context.Context, itemID uint) ([]model.InventoryAdjustment, error) {
+	var out []model.InventoryAdjustment
+	for i := len(f.log) - 1; i >= 0; i-- {
+		if f.log[i].ItemID == itemID {
+			out = append(out, f.log[i])
+		}
+	}
+	return out, nil
+}
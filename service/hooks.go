@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+
+	"app/model"
+)
+
+// ItemChange is an item before and after its price or stock changed
+type ItemChange struct {
+	Before model.Item
+	After  model.Item
+}
+
+// ItemHook reacts to an item change. It runs inside the transaction
+// making the change, so an error undoes the change.
+type ItemHook func(ctx context.Context, change ItemChange) error
+
+// ItemHooks are the hooks of a service changing items
+type ItemHooks struct {
+	hooks []ItemHook
+}
+
+// OnChange registers hook to run on every item change
+func (h *ItemHooks) OnChange(hook ItemHook) {
+	h.hooks = append(h.hooks, hook)
+}
+
+func (h *ItemHooks) fire(ctx context.Context, change ItemChange) error {
+	for _, hook := range h.hooks {
+		if err := hook(ctx, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Item lifecycle event types. Nobody is notified of these; they reach
+// webhooks.
+const (
+	EventItemCreated = "item_created"
+	EventItemUpdated = "item_updated"
+	EventItemDeleted = "item_deleted"
+)
+
+// Event is something that happened to an item that others may want to
+// hear about. Type is one of the model.Notify* types or an EventItem*
+// type; the fields that don't apply to it are nil.
+type Event struct {
+	Type    string
+	ActorID uint
+	Item    model.Item
+	// Before is Item as it was, for updates
+	Before  *model.Item
+	Order   *model.Order
+	Review  *model.Review
+	Comment *model.Comment
+	// Parent is the comment Comment replies to
+	Parent *model.Comment
+}
+
+// EventHook reacts to an event inside the transaction that caused it
+type EventHook func(ctx context.Context, event Event) error
+
+// EventHooks are the hooks of a service emitting events
+type EventHooks struct {
+	hooks []EventHook
+}
+
+// OnEvent registers hook to run on every event
+func (h *EventHooks) OnEvent(hook EventHook) {
+	h.hooks = append(h.hooks, hook)
+}
+
+func (h *EventHooks) emit(ctx context.Context, event Event) error {
+	for _, hook := range h.hooks {
+		if err := hook(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"time"
+
+	"app/imaging"
+	"app/logging"
+	"app/model"
+	"app/repository"
+	"app/storage"
+
+	"github.com/google/uuid"
+)
+
+// Upload limits
+const (
+	MaxImageBytes    = 10 << 20
+	MaxImagesPerItem = 10
+)
+
+// ImageURLTTL is how long the signed URLs in item responses stay valid
+const ImageURLTTL = time.Hour
+
+// ImageVariant is a stored rendition of an image, scaled to fit within
+// MaxSize pixels; zero keeps the original dimensions
+type ImageVariant struct {
+	Name    string
+	MaxSize int
+}
+
+// ImageVariants lists the renditions stored for every uploaded image. The
+// original comes first; it is stored on upload, the others by a
+// JobImageVariants job.
+var ImageVariants = []ImageVariant{
+	{Name: "original", MaxSize: 0},
+	{Name: "large", MaxSize: 1200},
+	{Name: "medium", MaxSize: 600},
+	{Name: "thumb", MaxSize: 200},
+}
+
+// ImageService stores item pictures and keeps their order and primary
+// image consistent
+type ImageService struct {
+	tx     repository.TxManager
+	items  repository.ItemRepository
+	images repository.ImageRepository
+	jobs   repository.JobRepository
+	blobs  storage.Blob
+}
+
+// NewImageService creates an ImageService
+func NewImageService(tx repository.TxManager, items repository.ItemRepository, images repository.ImageRepository, jobs repository.JobRepository, blobs storage.Blob) *ImageService {
+	return &ImageService{tx: tx, items: items, images: images, jobs: jobs, blobs: blobs}
+}
+
+// imageJob is the payload of JobImageVariants jobs
+type imageJob struct {
+	ImageID uint `json:"image_id"`
+}
+
+// Upload adds a picture to an item owned by actorID. The upload is
+// re-encoded, which strips EXIF and other metadata, and stored; the
+// resized variants are left to a job. The first picture of an item
+// becomes its primary image.
+func (s *ImageService) Upload(ctx context.Context, actorID, itemID uint, data []byte) (*model.ItemImage, error) {
+	if len(data) > MaxImageBytes {
+		return nil, ErrImageTooLarge
+	}
+	if _, err := ownedItem(ctx, s.items, actorID, itemID); err != nil {
+		return nil, err
+	}
+	existing, err := s.images.ListByItem(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= MaxImagesPerItem {
+		return nil, ErrTooManyImages
+	}
+
+	img, contentType, err := imaging.Decode(data)
+	switch {
+	case errors.Is(err, imaging.ErrUnsupported):
+		return nil, ErrUnsupportedImage
+	case errors.Is(err, imaging.ErrTooLarge):
+		return nil, ErrImageTooLarge
+	case err != nil:
+		return nil, err
+	}
+
+	record := &model.ItemImage{
+		ItemID:      itemID,
+		Key:         fmt.Sprintf("items/%d/%s", itemID, uuid.NewString()),
+		ContentType: contentType,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Processing:  true,
+	}
+	if err := s.store(ctx, *record, img, ImageVariants[:1]); err != nil {
+		s.removeBlobs(ctx, *record)
+		return nil, err
+	}
+
+	err = s.tx.Transaction(ctx, func(ctx context.Context) error {
+		// Re-read inside the transaction so concurrent uploads don't both
+		// claim the same position or primary flag
+		existing, err := s.images.ListByItem(ctx, itemID)
+		if err != nil {
+			return err
+		}
+		record.Position = len(existing)
+		record.IsPrimary = len(existing) == 0
+		if err := s.images.Create(ctx, record); err != nil {
+			return err
+		}
+		return enqueue(ctx, s.jobs, JobImageVariants, imageJob{ImageID: record.ID})
+	})
+	if err != nil {
+		s.removeBlobs(ctx, *record)
+		return nil, err
+	}
+	return record, nil
+}
+
+// Delete removes a picture from an item owned by actorID. If it was the
+// primary image the next picture takes its place.
+func (s *ImageService) Delete(ctx context.Context, actorID, itemID, imageID uint) error {
+	if _, err := ownedItem(ctx, s.items, actorID, itemID); err != nil {
+		return err
+	}
+	var removed model.ItemImage
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		images, err := s.images.ListByItem(ctx, itemID)
+		if err != nil {
+			return err
+		}
+		rest := make([]model.ItemImage, 0, len(images))
+		found := false
+		for _, img := range images {
+			if img.ID == imageID {
+				removed, found = img, true
+				continue
+			}
+			rest = append(rest, img)
+		}
+		if !found {
+			return ErrNotFound
+		}
+		if err := s.images.Delete(ctx, &removed); err != nil {
+			return err
+		}
+		primary := uint(0)
+		if removed.IsPrimary && len(rest) > 0 {
+			primary = rest[0].ID
+		}
+		return s.arrange(ctx, rest, primary)
+	})
+	if err != nil {
+		return err
+	}
+	s.removeBlobs(ctx, removed)
+	return nil
+}
+
+// Reorder sets the display order of an item's pictures. ids must list
+// every picture of the item exactly once.
+func (s *ImageService) Reorder(ctx context.Context, actorID, itemID uint, ids []uint) ([]model.ItemImage, error) {
+	if _, err := ownedItem(ctx, s.items, actorID, itemID); err != nil {
+		return nil, err
+	}
+	var ordered []model.ItemImage
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		images, err := s.images.ListByItem(ctx, itemID)
+		if err != nil {
+			return err
+		}
+		if len(ids) != len(images) {
+			return ErrInvalidImageOrder
+		}
+		byID := make(map[uint]model.ItemImage, len(images))
+		for _, img := range images {
+			byID[img.ID] = img
+		}
+		for _, id := range ids {
+			img, ok := byID[id]
+			if !ok {
+				return ErrInvalidImageOrder
+			}
+			delete(byID, id)
+			ordered = append(ordered, img)
+		}
+		return s.arrange(ctx, ordered, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ordered, nil
+}
+
+// SetPrimary makes imageID the primary picture of an item owned by actorID
+func (s *ImageService) SetPrimary(ctx context.Context, actorID, itemID, imageID uint) ([]model.ItemImage, error) {
+	if _, err := ownedItem(ctx, s.items, actorID, itemID); err != nil {
+		return nil, err
+	}
+	var images []model.ItemImage
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		images, err = s.images.ListByItem(ctx, itemID)
+		if err != nil {
+			return err
+		}
+		for _, img := range images {
+			if img.ID == imageID {
+				return s.arrange(ctx, images, imageID)
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// RenderVariants handles JobImageVariants jobs, storing the resized
+// variants of an uploaded image from its original
+func (s *ImageService) RenderVariants(ctx context.Context, payload json.RawMessage) error {
+	var job imageJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	record, err := s.images.FindByID(ctx, job.ImageID)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Deleted before its turn came
+		return nil
+	}
+	if err != nil || !record.Processing {
+		return err
+	}
+
+	r, err := s.blobs.Get(ctx, variantKey(*record, ImageVariants[0]))
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+	img, _, err := imaging.Decode(data)
+	if err != nil {
+		return err
+	}
+	if err := s.store(ctx, *record, img, ImageVariants[1:]); err != nil {
+		return err
+	}
+
+	return s.tx.Transaction(ctx, func(ctx context.Context) error {
+		current, err := s.images.FindByID(ctx, record.ID)
+		if errors.Is(err, repository.ErrNotFound) {
+			s.removeBlobs(ctx, *record)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		current.Processing = false
+		return s.images.Update(ctx, current)
+	})
+}
+
+// URLs returns a signed URL for every variant of img, keyed by name. The
+// variants of an image still processing all point at its original.
+func (s *ImageService) URLs(ctx context.Context, img model.ItemImage) (map[string]string, error) {
+	urls := make(map[string]string, len(ImageVariants))
+	for _, v := range ImageVariants {
+		key := variantKey(img, v)
+		if img.Processing {
+			key = variantKey(img, ImageVariants[0])
+		}
+		u, err := s.blobs.SignedURL(ctx, key, ImageURLTTL)
+		if err != nil {
+			return nil, err
+		}
+		urls[v.Name] = u
+	}
+	return urls, nil
+}
+
+// arrange saves images at consecutive positions in slice order. primary
+// names the new primary image; zero keeps the current one.
+func (s *ImageService) arrange(ctx context.Context, images []model.ItemImage, primary uint) error {
+	for i := range images {
+		img := &images[i]
+		isPrimary := img.IsPrimary
+		if primary != 0 {
+			isPrimary = img.ID == primary
+		}
+		if img.Position == i && img.IsPrimary == isPrimary {
+			continue
+		}
+		img.Position, img.IsPrimary = i, isPrimary
+		if err := s.images.Update(ctx, img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// store encodes and saves the variants of img listed
+func (s *ImageService) store(ctx context.Context, record model.ItemImage, img *image.RGBA, variants []ImageVariant) error {
+	for _, v := range variants {
+		scaled := img
+		if v.MaxSize > 0 {
+			scaled = imaging.Fit(img, v.MaxSize)
+		}
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, scaled, record.ContentType); err != nil {
+			return err
+		}
+		if err := s.blobs.Put(ctx, variantKey(record, v), &buf, record.ContentType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeBlobs deletes the stored variants of img. Failures only leave
+// unreachable objects behind, so they are logged rather than returned.
+func (s *ImageService) removeBlobs(ctx context.Context, img model.ItemImage) {
+	for _, v := range ImageVariants {
+		if err := s.blobs.Delete(ctx, variantKey(img, v)); err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "failed to delete image blob", "key", variantKey(img, v), "error", err)
+		}
+	}
+}
+
+func variantKey(img model.ItemImage, v ImageVariant) string {
+	return img.Key + "/" + v.Name + imaging.Ext(img.ContentType)
+}
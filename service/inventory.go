@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"app/logging"
+	"app/model"
+	"app/repository"
+)
+
+// ReservationTTL is how long reserved stock is held for a buyer
+const ReservationTTL = 15 * time.Minute
+
+// InventoryService tracks item stock. Every change locks the item row and
+// is written to the adjustment log in the same transaction. Its hooks see
+// stock changes.
+type InventoryService struct {
+	ItemHooks
+	tx        repository.TxManager
+	items     repository.ItemRepository
+	inventory repository.InventoryRepository
+}
+
+// NewInventoryService creates an InventoryService
+func NewInventoryService(tx repository.TxManager, items repository.ItemRepository, inventory repository.InventoryRepository) *InventoryService {
+	return &InventoryService{tx: tx, items: items, inventory: inventory}
+}
+
+// StockLevel is the inventory of an item as its owner sees it
+type StockLevel struct {
+	Stock       int
+	Reserved    int
+	Adjustments []model.InventoryAdjustment
+}
+
+// Level returns the stock, active reservations and adjustment log of an
+// item owned by actorID
+func (s *InventoryService) Level(ctx context.Context, actorID, itemID uint) (*StockLevel, error) {
+	item, err := ownedItem(ctx, s.items, actorID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	reserved, err := s.inventory.ReservedQuantity(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	log, err := s.inventory.ListAdjustments(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	return &StockLevel{Stock: item.Stock, Reserved: reserved, Adjustments: log}, nil
+}
+
+// Adjust changes the stock of an item owned by actorID by delta. reason is
+// model.AdjustmentRestock or model.AdjustmentCorrection.
+func (s *InventoryService) Adjust(ctx context.Context, actorID, itemID uint, delta int, reason, note string) (int, error) {
+	if delta == 0 {
+		return 0, ErrInvalidQuantity
+	}
+	if _, err := ownedItem(ctx, s.items, actorID, itemID); err != nil {
+		return 0, err
+	}
+	var stock int
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		item, err := s.items.FindForUpdate(ctx, itemID)
+		if err != nil {
+			return err
+		}
+		err = s.change(ctx, item, delta, model.InventoryAdjustment{Reason: reason, Note: note, ActorID: &actorID})
+		stock = item.Stock
+		return err
+	})
+	return stock, err
+}
+
+// Reserve holds quantity units of an item for buyerID for ReservationTTL
+func (s *InventoryService) Reserve(ctx context.Context, buyerID, itemID uint, quantity int) (*model.StockReservation, error) {
+	if quantity < 1 {
+		return nil, ErrInvalidQuantity
+	}
+	var res *model.StockReservation
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		item, err := s.items.FindForUpdate(ctx, itemID)
+		if err != nil {
+			return err
+		}
+		if item.UserID == buyerID {
+			return ErrOwnItem
+		}
+		if !item.Listed() {
+			return ErrItemUnavailable
+		}
+		if item.Stock < quantity {
+			return ErrInsufficientStock
+		}
+		res = &model.StockReservation{
+			ItemID:    itemID,
+			UserID:    buyerID,
+			Quantity:  quantity,
+			Status:    model.ReservationActive,
+			ExpiresAt: time.Now().Add(ReservationTTL),
+		}
+		if err := s.inventory.CreateReservation(ctx, res); err != nil {
+			return err
+		}
+		return s.change(ctx, item, -quantity, model.InventoryAdjustment{
+			Reason: model.AdjustmentReservation, ActorID: &buyerID, ReservationID: &res.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Release gives the units of an active reservation held by buyerID back to
+// stock
+func (s *InventoryService) Release(ctx context.Context, buyerID, reservationID uint) error {
+	return s.tx.Transaction(ctx, func(ctx context.Context) error {
+		res, err := s.inventory.FindReservationForUpdate(ctx, reservationID)
+		if err != nil {
+			return err
+		}
+		if res.UserID != buyerID {
+			return ErrNotFound
+		}
+		if res.Status != model.ReservationActive {
+			return ErrReservationInactive
+		}
+		return s.restore(ctx, res, model.ReservationReleased, model.AdjustmentReservationReleased)
+	})
+}
+
+// ExpireReservations returns the stock of every reservation that expired
+// before now and reports how many were expired
+func (s *InventoryService) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	expired, err := s.inventory.ListExpiredReservations(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, r := range expired {
+		restored := false
+		err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+			// Another worker may have handled it since it was listed
+			res, err := s.inventory.FindReservationForUpdate(ctx, r.ID)
+			if err != nil {
+				return err
+			}
+			if res.Status != model.ReservationActive {
+				return nil
+			}
+			restored = true
+			return s.restore(ctx, res, model.ReservationExpired, model.AdjustmentReservationExpired)
+		})
+		if err != nil {
+			return n, err
+		}
+		if restored {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ExpireJob handles JobExpireReservations jobs, expiring every
+// reservation past its deadline
+func (s *InventoryService) ExpireJob(ctx context.Context, _ json.RawMessage) error {
+	n, err := s.ExpireReservations(ctx, time.Now())
+	if n > 0 {
+		logging.FromContext(ctx).Info("expired reservations", "count", n)
+	}
+	return err
+}
+
+// take removes quantity units of a locked item for an order. It must run
+// inside a transaction.
+func (s *InventoryService) take(ctx context.Context, item *model.Item, quantity int, buyerID, orderID uint) error {
+	return s.change(ctx, item, -quantity, model.InventoryAdjustment{
+		Reason: model.AdjustmentOrder, ActorID: &buyerID, OrderID: &orderID,
+	})
+}
+
+// consume locks an active reservation of buyerID for use by an order. Its
+// units already left stock when it was made. It must run inside a
+// transaction.
+func (s *InventoryService) consume(ctx context.Context, buyerID, reservationID uint) (*model.StockReservation, error) {
+	res, err := s.inventory.FindReservationForUpdate(ctx, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	if res.UserID != buyerID {
+		return nil, ErrNotFound
+	}
+	// An expired reservation awaiting the sweeper no longer holds stock
+	if res.Status != model.ReservationActive || time.Now().After(res.ExpiresAt) {
+		return nil, ErrReservationInactive
+	}
+	return res, nil
+}
+
+// attach marks a consumed reservation as used by orderID
+func (s *InventoryService) attach(ctx context.Context, res *model.StockReservation, orderID uint) error {
+	res.Status = model.ReservationConsumed
+	res.OrderID = &orderID
+	return s.inventory.UpdateReservation(ctx, res)
+}
+
+// restore returns the units of res to stock and closes it with status
+func (s *InventoryService) restore(ctx context.Context, res *model.StockReservation, status, reason string) error {
+	item, err := s.items.FindForUpdate(ctx, res.ItemID)
+	if err != nil {
+		return err
+	}
+	res.Status = status
+	if err := s.inventory.UpdateReservation(ctx, res); err != nil {
+		return err
+	}
+	return s.change(ctx, item, res.Quantity, model.InventoryAdjustment{Reason: reason, ReservationID: &res.ID})
+}
+
+// change applies delta to a locked item and logs it
+func (s *InventoryService) change(ctx context.Context, item *model.Item, delta int, entry model.InventoryAdjustment) error {
+	if item.Stock+delta < 0 {
+		return ErrInsufficientStock
+	}
+	before := *item
+	item.Stock += delta
+	if err := s.items.SetStock(ctx, item.ID, item.Stock); err != nil {
+		return err
+	}
+	entry.ItemID = item.ID
+	entry.Delta = delta
+	entry.StockAfter = item.Stock
+	if err := s.inventory.LogAdjustment(ctx, &entry); err != nil {
+		return err
+	}
+	return s.fire(ctx, ItemChange{Before: before, After: *item})
+}
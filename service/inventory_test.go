@@ -0,0 +1,139 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"app/database"
+	"app/model"
+	"app/money"
+	"app/repository"
+	"app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventory_ReserveReleaseExpire(t *testing.T) {
+	ctx := context.Background()
+	items := newFakeItems(model.Item{ID: 1, UserID: 2, Stock: 3})
+	inv := &fakeInventory{}
+	svc := service.NewInventoryService(fakeTx{}, items, inv)
+
+	_, err := svc.Reserve(ctx, 2, 1, 1)
+	assert.ErrorIs(t, err, service.ErrOwnItem)
+	_, err = svc.Reserve(ctx, 1, 1, 4)
+	assert.ErrorIs(t, err, service.ErrInsufficientStock)
+
+	first, err := svc.Reserve(ctx, 1, 1, 2)
+	require.NoError(t, err)
+	second, err := svc.Reserve(ctx, 1, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, items.items[1].Stock)
+
+	assert.ErrorIs(t, svc.Release(ctx, 3, first.ID), service.ErrNotFound, "only the buyer may release")
+	require.NoError(t, svc.Release(ctx, 1, first.ID))
+	assert.Equal(t, 2, items.items[1].Stock)
+	assert.ErrorIs(t, svc.Release(ctx, 1, first.ID), service.ErrReservationInactive)
+
+	n, err := svc.ExpireReservations(ctx, second.ExpiresAt.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 3, items.items[1].Stock)
+
+	reasons := []string{}
+	for _, a := range inv.log {
+		reasons = append(reasons, a.Reason)
+	}
+	assert.Equal(t, []string{
+		model.AdjustmentReservation, model.AdjustmentReservation,
+		model.AdjustmentReservationReleased, model.AdjustmentReservationExpired,
+	}, reasons)
+	assert.Equal(t, 3, inv.log[3].StockAfter)
+}
+
+func TestInventory_ExpireCountsRestoredReservations(t *testing.T) {
+	ctx := context.Background()
+	items := newFakeItems(model.Item{ID: 1, UserID: 2, Stock: 1})
+	svc := service.NewInventoryService(fakeTx{}, items, &fakeInventory{})
+	res, err := svc.Reserve(ctx, 1, 1, 1)
+	require.NoError(t, err)
+	boom := errors.New("boom")
+	svc.OnChange(func(context.Context, service.ItemChange) error { return boom })
+
+	n, err := svc.ExpireReservations(ctx, res.ExpiresAt.Add(time.Second))
+	assert.ErrorIs(t, err, boom)
+	assert.Zero(t, n, "a reservation that wasn't restored isn't counted")
+}
+
+func TestInventory_AdjustRequiresOwner(t *testing.T) {
+	ctx := context.Background()
+	items := newFakeItems(model.Item{ID: 1, UserID: 2, Stock: 1})
+	svc := service.NewInventoryService(fakeTx{}, items, &fakeInventory{})
+
+	_, err := svc.Adjust(ctx, 1, 1, 5, model.AdjustmentRestock, "")
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	_, err = svc.Adjust(ctx, 2, 1, -2, model.AdjustmentCorrection, "")
+	assert.ErrorIs(t, err, service.ErrInsufficientStock)
+
+	stock, err := svc.Adjust(ctx, 2, 1, 5, model.AdjustmentRestock, "new shipment")
+	require.NoError(t, err)
+	assert.Equal(t, 6, stock)
+}
+
+func TestOrderService_PlaceReserved(t *testing.T) {
+	ctx := context.Background()
+	items := newFakeItems(model.Item{ID: 1, Price: money.New(400, "EUR"), UserID: 2, Stock: 2})
+	inv := &fakeInventory{}
+	inventory := service.NewInventoryService(fakeTx{}, items, inv)
+	svc := service.NewOrderService(fakeTx{}, items, &fakeOrders{}, inventory)
+
+	res, err := inventory.Reserve(ctx, 1, 1, 2)
+	require.NoError(t, err)
+	_, err = svc.Place(ctx, 3, 1, 1)
+	assert.ErrorIs(t, err, service.ErrInsufficientStock, "reserved units are not for sale")
+
+	_, err = svc.PlaceReserved(ctx, 3, res.ID)
+	assert.ErrorIs(t, err, service.ErrNotFound)
+	order, err := svc.PlaceReserved(ctx, 1, res.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.New(800, "EUR"), order.TotalPrice)
+	assert.Equal(t, 0, items.items[1].Stock)
+	assert.Equal(t, model.ReservationConsumed, inv.reservations[0].Status)
+
+	_, err = svc.PlaceReserved(ctx, 1, res.ID)
+	assert.ErrorIs(t, err, service.ErrReservationInactive)
+}
+
+func TestOrderService_NoOversellingUnderConcurrency(t *testing.T) {
+	db := database.ConnectDBWithDSN(":memory:")
+	repos := repository.New(db)
+	require.NoError(t, db.Create(&model.User{ID: 1, Username: "seller", Email: "s@example.com", Password: "x"}).Error)
+	require.NoError(t, db.Create(&model.Item{ID: 1, Name: "Lamp", Description: "d", Price: money.New(100, "EUR"), UserID: 1, Stock: 5}).Error)
+	inventory := service.NewInventoryService(repos.Tx, repos.Items, repos.Inventory)
+	svc := service.NewOrderService(repos.Tx, repos.Items, repos.Orders, inventory)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	placed := 0
+	for buyer := uint(2); buyer < 12; buyer++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.Place(context.Background(), buyer, 1, 1); err == nil {
+				mu.Lock()
+				placed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var item model.Item
+	require.NoError(t, db.First(&item, 1).Error)
+	assert.Equal(t, 5, placed)
+	assert.Equal(t, 0, item.Stock)
+}
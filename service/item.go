@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"app/logging"
+	"app/model"
+	"app/money"
+	"app/repository"
+)
+
+// ItemUpdate lists the fields an owner may change on an item; nil fields
+// are left unchanged. Price is a decimal such as "19.99", read in Currency
+// or else the item's current currency.
+type ItemUpdate struct {
+	Name        *string
+	Description *string
+	Price       *string
+	Currency    *money.Currency
+}
+
+// ItemService holds listing rules, chiefly that only owners change items
+// and move them between statuses. Its item hooks see price changes; its
+// event hooks see items created, updated and deleted.
+type ItemService struct {
+	ItemHooks
+	EventHooks
+	tx         repository.TxManager
+	items      repository.ItemRepository
+	users      repository.UserRepository
+	categories repository.CategoryRepository
+	inventory  repository.InventoryRepository
+}
+
+// NewItemService creates an ItemService, looking up viewers in users to
+// tell moderators apart
+func NewItemService(tx repository.TxManager, items repository.ItemRepository, users repository.UserRepository, categories repository.CategoryRepository, inventory repository.InventoryRepository) *ItemService {
+	return &ItemService{tx: tx, items: items, users: users, categories: categories, inventory: inventory}
+}
+
+// List returns every listed item matching filter
+func (s *ItemService) List(ctx context.Context, filter repository.ItemFilter) ([]model.Item, error) {
+	return s.items.List(ctx, filter)
+}
+
+// ListByCategory returns the items in a category matching filter
+func (s *ItemService) ListByCategory(ctx context.Context, categoryID uint, filter repository.ItemFilter) ([]model.Item, error) {
+	return s.items.ListByCategory(ctx, categoryID, filter)
+}
+
+// ListByUser returns the items of a user matching filter
+func (s *ItemService) ListByUser(ctx context.Context, userID uint, filter repository.ItemFilter) ([]model.Item, error) {
+	return s.items.ListByUser(ctx, userID, filter)
+}
+
+// Get returns the item with id as viewerID, zero when signed out, may see
+// it. Drafts and archived items are only their owner's to see; hidden
+// items are their owner's and moderators'. Others get ErrNotFound.
+func (s *ItemService) Get(ctx context.Context, viewerID, id uint) (*model.Item, error) {
+	item, err := s.items.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if viewerID != 0 && viewerID == item.UserID {
+		return item, nil
+	}
+	if item.Status == model.ItemDraft || item.Status == model.ItemArchived {
+		return nil, ErrNotFound
+	}
+	if item.Hidden {
+		if viewerID == 0 {
+			return nil, ErrNotFound
+		}
+		viewer, err := s.users.FindByID(ctx, viewerID)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && !viewer.IsModerator()) {
+			return nil, ErrNotFound
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return item, nil
+}
+
+// Create lists item for ownerID with its initial stock. Client supplied
+// IDs and associations are discarded so an item can't be created for
+// someone else. Items start active, or as drafts when they are scheduled
+// to be published.
+func (s *ItemService) Create(ctx context.Context, ownerID uint, item *model.Item) error {
+	if _, err := s.categories.FindByID(ctx, item.CategoryID); errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidCategory
+	} else if err != nil {
+		return err
+	}
+	item.ID = 0
+	item.UserID = ownerID
+	item.User = model.User{}
+	item.Category = model.Category{}
+	item.Reviews = nil
+	item.Orders = nil
+	item.Images = nil
+	if item.Stock < 0 {
+		return ErrInvalidQuantity
+	}
+	if !item.Price.Currency.Valid() || item.Price.Amount < 0 {
+		return ErrInvalidPrice
+	}
+	switch item.Status {
+	case "":
+		item.Status = model.ItemActive
+		if item.PublishAt != nil {
+			item.Status = model.ItemDraft
+		}
+	case model.ItemDraft, model.ItemActive:
+	default:
+		return ErrInvalidStatus
+	}
+	if err := checkSchedule(*item, time.Now()); err != nil {
+		return err
+	}
+	return s.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.items.Create(ctx, item); err != nil {
+			return err
+		}
+		err := s.inventory.LogAdjustment(ctx, &model.InventoryAdjustment{
+			ItemID: item.ID, Delta: item.Stock, StockAfter: item.Stock,
+			Reason: model.AdjustmentInitial, ActorID: &ownerID,
+		})
+		if err != nil {
+			return err
+		}
+		return s.emit(ctx, Event{Type: EventItemCreated, ActorID: ownerID, Item: *item})
+	})
+}
+
+// Update changes an item owned by actorID
+func (s *ItemService) Update(ctx context.Context, actorID, id uint, in ItemUpdate) (*model.Item, error) {
+	return s.change(ctx, actorID, id, func(item *model.Item) error {
+		return applyUpdate(item, in)
+	})
+}
+
+// SetStatus moves an item owned by actorID to status. Schedules that no
+// longer apply are dropped.
+func (s *ItemService) SetStatus(ctx context.Context, actorID, id uint, status string) (*model.Item, error) {
+	return s.change(ctx, actorID, id, func(item *model.Item) error {
+		if !item.CanBecome(status) {
+			return ErrInvalidStatus
+		}
+		item.Status = status
+		if !item.CanBecome(model.ItemActive) {
+			item.PublishAt = nil
+		}
+		if item.Status != model.ItemActive && item.PublishAt == nil {
+			item.UnpublishAt = nil
+		}
+		return nil
+	})
+}
+
+// Schedule sets when an item owned by actorID is published and paused
+// again; nil times clear them
+func (s *ItemService) Schedule(ctx context.Context, actorID, id uint, publishAt, unpublishAt *time.Time) (*model.Item, error) {
+	return s.change(ctx, actorID, id, func(item *model.Item) error {
+		item.PublishAt, item.UnpublishAt = publishAt, unpublishAt
+		return checkSchedule(*item, time.Now())
+	})
+}
+
+// checkSchedule validates the publish and unpublish times of item: both in
+// the future, publishing only items that may become active, and
+// unpublishing only items that are or will be active, after publishing
+func checkSchedule(item model.Item, now time.Time) error {
+	if p := item.PublishAt; p != nil && (!p.After(now) || !item.CanBecome(model.ItemActive)) {
+		return ErrInvalidSchedule
+	}
+	if u := item.UnpublishAt; u != nil {
+		if !u.After(now) || (item.Status != model.ItemActive && item.PublishAt == nil) {
+			return ErrInvalidSchedule
+		}
+		if item.PublishAt != nil && !u.After(*item.PublishAt) {
+			return ErrInvalidSchedule
+		}
+	}
+	return nil
+}
+
+// change applies fn to an item owned by actorID, then stores and announces
+// the result
+func (s *ItemService) change(ctx context.Context, actorID, id uint, fn func(item *model.Item) error) (*model.Item, error) {
+	if _, err := s.owned(ctx, actorID, id); err != nil {
+		return nil, err
+	}
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		item, err := s.items.FindForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		before := *item
+		if err := fn(item); err != nil {
+			return err
+		}
+		return s.save(ctx, actorID, before, item)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.items.FindByID(ctx, id)
+}
+
+// save stores item, changed from before by actorID, and runs the hooks.
+// It must run inside a transaction.
+func (s *ItemService) save(ctx context.Context, actorID uint, before model.Item, item *model.Item) error {
+	if err := s.items.Update(ctx, item); err != nil {
+		return err
+	}
+	if item.Price != before.Price {
+		if err := s.fire(ctx, ItemChange{Before: before, After: *item}); err != nil {
+			return err
+		}
+	}
+	return s.emit(ctx, Event{Type: EventItemUpdated, ActorID: actorID, Item: *item, Before: &before})
+}
+
+// RunSchedules publishes and unpublishes the items whose time has come by
+// now and returns how many changed. Due publishing makes drafts and paused
+// items active; due unpublishing pauses active items. Times that no longer
+// apply, because the owner changed the status since, are just cleared.
+func (s *ItemService) RunSchedules(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.items.ListScheduled(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, d := range due {
+		changed := false
+		err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+			// The owner may have rescheduled it since it was listed
+			item, err := s.items.FindForUpdate(ctx, d.ID)
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			before := *item
+			if p := item.PublishAt; p != nil && !p.After(now) {
+				if item.CanBecome(model.ItemActive) {
+					item.Status = model.ItemActive
+				}
+				item.PublishAt = nil
+			}
+			if u := item.UnpublishAt; u != nil && !u.After(now) {
+				if item.CanBecome(model.ItemPaused) {
+					item.Status = model.ItemPaused
+				}
+				item.UnpublishAt = nil
+			}
+			if item.PublishAt == before.PublishAt && item.UnpublishAt == before.UnpublishAt {
+				return nil
+			}
+			changed = item.Status != before.Status
+			return s.save(ctx, 0, before, item)
+		})
+		if err != nil {
+			return n, err
+		}
+		if changed {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ScheduleJob handles JobItemSchedules jobs
+func (s *ItemService) ScheduleJob(ctx context.Context, _ json.RawMessage) error {
+	n, err := s.RunSchedules(ctx, time.Now())
+	if n > 0 {
+		logging.FromContext(ctx).Info("ran item schedules", "changed", n)
+	}
+	return err
+}
+
+// applyUpdate sets the fields of in on item
+func applyUpdate(item *model.Item, in ItemUpdate) error {
+	if in.Name != nil {
+		item.Name = *in.Name
+	}
+	if in.Description != nil {
+		item.Description = *in.Description
+	}
+	if in.Price != nil || in.Currency != nil {
+		amount, currency := item.Price.Decimal(), item.Price.Currency
+		if in.Price != nil {
+			amount = *in.Price
+		}
+		if in.Currency != nil {
+			currency = *in.Currency
+		}
+		price, err := money.Parse(amount, currency)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPrice, err)
+		}
+		if price.Amount < 0 {
+			return ErrInvalidPrice
+		}
+		item.Price = price
+	}
+	return nil
+}
+
+// Delete removes an item owned by actorID
+func (s *ItemService) Delete(ctx context.Context, actorID, id uint) error {
+	item, err := s.owned(ctx, actorID, id)
+	if err != nil {
+		return err
+	}
+	return s.remove(ctx, actorID, item)
+}
+
+// Remove deletes item id on behalf of moderatorID, whoever owns it. The
+// caller checks moderatorID may do so.
+func (s *ItemService) Remove(ctx context.Context, moderatorID, id uint) error {
+	item, err := s.items.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.remove(ctx, moderatorID, item)
+}
+
+// remove deletes item and announces it as deleted by actorID
+func (s *ItemService) remove(ctx context.Context, actorID uint, item *model.Item) error {
+	return s.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.items.Delete(ctx, item); err != nil {
+			return err
+		}
+		return s.emit(ctx, Event{Type: EventItemDeleted, ActorID: actorID, Item: *item})
+	})
+}
+
+func (s *ItemService) owned(ctx context.Context, actorID, id uint) (*model.Item, error) {
+	return ownedItem(ctx, s.items, actorID, id)
+}
+
+// ownedItem loads item id, failing with ErrForbidden unless actorID owns it
+func ownedItem(ctx context.Context, items repository.ItemRepository, actorID, id uint) (*model.Item, error) {
+	item, err := items.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if item.UserID != actorID {
+		return nil, ErrForbidden
+	}
+	return item, nil
+}
@@ -0,0 +1,166 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"app/model"
+	"app/money"
+	"app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemService_CreateForcesOwner(t *testing.T) {
+	items := newFakeItems()
+	svc := service.NewItemService(fakeTx{}, items, nil, fakeCategories{ids: map[uint]bool{3: true}}, &fakeInventory{})
+
+	item := &model.Item{ID: 42, Name: "Lamp", Price: money.New(1000, "EUR"), UserID: 9, User: model.User{ID: 9}, CategoryID: 3}
+	assert.NoError(t, svc.Create(context.Background(), 1, item))
+
+	stored, err := items.FindByID(context.Background(), item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), stored.UserID)
+	assert.Zero(t, stored.User.ID)
+}
+
+func TestItemService_UpdateRequiresOwner(t *testing.T) {
+	items := newFakeItems(model.Item{ID: 1, Name: "Lamp", Price: money.New(1000, "JPY"), UserID: 2})
+	svc := service.NewItemService(fakeTx{}, items, nil, fakeCategories{}, &fakeInventory{})
+
+	name := "Mine now"
+	_, err := svc.Update(context.Background(), 1, 1, service.ItemUpdate{Name: &name})
+	assert.ErrorIs(t, err, service.ErrForbidden)
+
+	name = "Desk lamp"
+	updated, err := svc.Update(context.Background(), 2, 1, service.ItemUpdate{Name: &name})
+	assert.NoError(t, err)
+	assert.Equal(t, "Desk lamp", updated.Name)
+	assert.Equal(t, money.New(1000, "JPY"), updated.Price)
+}
+
+func TestItemService_UpdatePrice(t *testing.T) {
+	items := newFakeItems(model.Item{ID: 1, Name: "Lamp", Price: money.New(1000, "JPY"), UserID: 2})
+	svc := service.NewItemService(fakeTx{}, items, nil, fakeCategories{}, &fakeInventory{})
+	ctx := context.Background()
+
+	// The amount is read in the item's currency unless one is given
+	price := "1500"
+	updated, err := svc.Update(ctx, 2, 1, service.ItemUpdate{Price: &price})
+	require.NoError(t, err)
+	assert.Equal(t, money.New(1500, "JPY"), updated.Price)
+
+	price, usd := "12.50", money.Currency("USD")
+	updated, err = svc.Update(ctx, 2, 1, service.ItemUpdate{Price: &price, Currency: &usd})
+	require.NoError(t, err)
+	assert.Equal(t, money.New(1250, "USD"), updated.Price)
+
+	price, jpy := "12.50", money.Currency("JPY")
+	_, err = svc.Update(ctx, 2, 1, service.ItemUpdate{Price: &price, Currency: &jpy})
+	assert.ErrorIs(t, err, service.ErrInvalidPrice)
+}
+
+func TestItemService_DeleteMissing(t *testing.T) {
+	svc := service.NewItemService(fakeTx{}, newFakeItems(), nil, fakeCategories{}, &fakeInventory{})
+
+	err := svc.Delete(context.Background(), 1, 99)
+	assert.ErrorIs(t, err, service.ErrNotFound)
+}
+
+func TestItemService_CreateRejectsUnknownCategory(t *testing.T) {
+	svc := service.NewItemService(fakeTx{}, newFakeItems(), nil, fakeCategories{}, &fakeInventory{})
+
+	err := svc.Create(context.Background(), 1, &model.Item{Name: "Lamp", CategoryID: 5})
+	assert.ErrorIs(t, err, service.ErrInvalidCategory)
+}
+
+func TestItemService_StatusTransitions(t *testing.T) {
+	items := newFakeItems(model.Item{ID: 1, Name: "Lamp", Price: money.New(1000, "EUR"), UserID: 2, Status: model.ItemDraft})
+	svc := service.NewItemService(fakeTx{}, items, nil, fakeCategories{}, &fakeInventory{})
+	ctx := context.Background()
+
+	_, err := svc.SetStatus(ctx, 2, 1, model.ItemPaused)
+	assert.ErrorIs(t, err, service.ErrInvalidStatus, "drafts are published before pausing")
+	_, err = svc.SetStatus(ctx, 1, 1, model.ItemActive)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+
+	for _, status := range []string{model.ItemActive, model.ItemSold, model.ItemActive, model.ItemArchived} {
+		item, err := svc.SetStatus(ctx, 2, 1, status)
+		require.NoError(t, err)
+		assert.Equal(t, status, item.Status)
+	}
+	_, err = svc.SetStatus(ctx, 2, 1, model.ItemActive)
+	assert.ErrorIs(t, err, service.ErrInvalidStatus, "archived items stay archived")
+}
+
+func TestItemService_RunSchedules(t *testing.T) {
+	now := time.Now()
+	items := newFakeItems(
+		model.Item{ID: 1, UserID: 2, Status: model.ItemDraft},
+		model.Item{ID: 2, UserID: 2, Status: model.ItemActive},
+		model.Item{ID: 3, UserID: 2, Status: model.ItemDraft},
+	)
+	svc := service.NewItemService(fakeTx{}, items, nil, fakeCategories{}, &fakeInventory{})
+	var events []service.Event
+	svc.OnEvent(func(_ context.Context, e service.Event) error {
+		events = append(events, e)
+		return nil
+	})
+	ctx := context.Background()
+
+	publish, unpublish := now.Add(time.Hour), now.Add(2*time.Hour)
+	_, err := svc.Schedule(ctx, 2, 1, &publish, &unpublish)
+	require.NoError(t, err)
+	_, err = svc.Schedule(ctx, 2, 2, nil, &publish)
+	require.NoError(t, err)
+	_, err = svc.Schedule(ctx, 2, 3, &unpublish, nil)
+	require.NoError(t, err)
+
+	past := now.Add(-time.Minute)
+	_, err = svc.Schedule(ctx, 2, 3, &past, nil)
+	assert.ErrorIs(t, err, service.ErrInvalidSchedule)
+	_, err = svc.Schedule(ctx, 2, 3, &unpublish, &publish)
+	assert.ErrorIs(t, err, service.ErrInvalidSchedule, "unpublishing before publishing")
+	_, err = svc.Schedule(ctx, 2, 2, &unpublish, nil)
+	assert.ErrorIs(t, err, service.ErrInvalidSchedule, "active items are published already")
+
+	// An hour on, item 1 is published and item 2 paused; item 3 waits
+	events = nil
+	n, err := svc.RunSchedules(ctx, publish)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Len(t, events, 2)
+	assert.Equal(t, model.ItemActive, items.items[1].Status)
+	assert.Nil(t, items.items[1].PublishAt)
+	assert.NotNil(t, items.items[1].UnpublishAt)
+	assert.Equal(t, model.ItemPaused, items.items[2].Status)
+	assert.Nil(t, items.items[2].UnpublishAt)
+	assert.Equal(t, model.ItemDraft, items.items[3].Status)
+
+	// Archiving item 3 drops its schedule, and nothing is left to run
+	_, err = svc.SetStatus(ctx, 2, 3, model.ItemArchived)
+	require.NoError(t, err)
+	assert.Nil(t, items.items[3].PublishAt)
+	n, err = svc.RunSchedules(ctx, unpublish)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, model.ItemPaused, items.items[1].Status)
+	n, err = svc.RunSchedules(ctx, unpublish.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestItemService_RunSchedulesCountsSavedChanges(t *testing.T) {
+	due := time.Now().Add(-time.Minute)
+	items := newFakeItems(model.Item{ID: 1, UserID: 2, Status: model.ItemDraft, PublishAt: &due})
+	svc := service.NewItemService(fakeTx{}, items, nil, fakeCategories{}, &fakeInventory{})
+	boom := errors.New("boom")
+	svc.OnEvent(func(context.Context, service.Event) error { return boom })
+
+	n, err := svc.RunSchedules(context.Background(), time.Now())
+	assert.ErrorIs(t, err, boom)
+	assert.Zero(t, n, "a change that wasn't saved isn't counted")
+}
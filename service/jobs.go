@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"app/jobs"
+	"app/logging"
+	"app/mail"
+	"app/repository"
+)
+
+// Background job kinds, run by cmd/worker
+const (
+	JobSendEmail          = "email.send"
+	JobImageVariants      = "image.variants"
+	JobDeliverWebhook     = "webhook.deliver"
+	JobExpireReservations = "inventory.expire_reservations"
+	JobPurgeAccounts      = "accounts.purge"
+	JobPruneJobs          = "jobs.prune"
+	JobItemSchedules      = "items.schedules"
+)
+
+// enqueue queues a job of kind in the transaction bound to ctx, so it only
+// runs if that transaction commits
+func enqueue(ctx context.Context, queue repository.JobRepository, kind string, payload any, opts ...jobs.Option) error {
+	job, err := jobs.New(kind, payload, opts...)
+	if err != nil {
+		return err
+	}
+	return queue.Enqueue(ctx, job)
+}
+
+// EmailJob handles JobSendEmail jobs, whose payload is a mail.Message. A
+// nil mailer drops the emails.
+func EmailJob(mailer mail.Mailer) jobs.Handler {
+	return func(ctx context.Context, payload json.RawMessage) error {
+		var msg mail.Message
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return err
+		}
+		if mailer == nil {
+			logging.FromContext(ctx).Debug("email is off, dropping message", "subject", msg.Subject)
+			return nil
+		}
+		return mailer.Send(ctx, msg)
+	}
+}
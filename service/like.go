@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+
+	"app/repository"
+)
+
+// LikeService lets users like items
+type LikeService struct {
+	tx    repository.TxManager
+	likes repository.LikeRepository
+	items repository.ItemRepository
+}
+
+// NewLikeService creates a LikeService
+func NewLikeService(tx repository.TxManager, likes repository.LikeRepository, items repository.ItemRepository) *LikeService {
+	return &LikeService{tx: tx, likes: likes, items: items}
+}
+
+// Like likes an item as userID. Liking twice changes nothing. It returns
+// the item's like count.
+func (s *LikeService) Like(ctx context.Context, userID, itemID uint) (int, error) {
+	return s.set(ctx, userID, itemID, true)
+}
+
+// Unlike takes back a like, returning the item's like count
+func (s *LikeService) Unlike(ctx context.Context, userID, itemID uint) (int, error) {
+	return s.set(ctx, userID, itemID, false)
+}
+
+// Liked reports whether userID likes an item, and the item's like count
+func (s *LikeService) Liked(ctx context.Context, userID, itemID uint) (bool, int, error) {
+	item, err := s.items.FindByID(ctx, itemID)
+	if err != nil {
+		return false, 0, err
+	}
+	liked, err := s.likes.Exists(ctx, userID, itemID)
+	if err != nil {
+		return false, 0, err
+	}
+	return liked, item.LikeCount, nil
+}
+
+func (s *LikeService) set(ctx context.Context, userID, itemID uint, like bool) (int, error) {
+	var count int
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		if _, err := s.items.FindByID(ctx, itemID); err != nil {
+			return err
+		}
+		var changed bool
+		var err error
+		delta := 1
+		if like {
+			changed, err = s.likes.Add(ctx, userID, itemID)
+		} else {
+			changed, err = s.likes.Remove(ctx, userID, itemID)
+			delta = -1
+		}
+		if err != nil {
+			return err
+		}
+		if changed {
+			if err := s.items.AdjustCounts(ctx, itemID, delta, 0); err != nil {
+				return err
+			}
+		}
+		item, err := s.items.FindByID(ctx, itemID)
+		if err != nil {
+			return err
+		}
+		count = item.LikeCount
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"app/events"
+	"app/model"
+	"app/repository"
+)
+
+// MaxMessageLength caps a message body, in characters
+const MaxMessageLength = 2000
+
+// MaxMessagePage caps how many messages one page lists
+const MaxMessagePage = 100
+
+// MessageService carries private messages between buyers and sellers.
+// Only the two participants of a conversation may see or add to it.
+type MessageService struct {
+	tx        repository.TxManager
+	messages  repository.MessageRepository
+	items     repository.ItemRepository
+	users     repository.UserRepository
+	publisher events.Publisher
+}
+
+// NewMessageService creates a MessageService pushing new messages and
+// read receipts through publisher
+func NewMessageService(tx repository.TxManager, messages repository.MessageRepository, items repository.ItemRepository, users repository.UserRepository, publisher events.Publisher) *MessageService {
+	return &MessageService{tx: tx, messages: messages, items: items, users: users, publisher: publisher}
+}
+
+// messageEvent is what the recipient sees of a new message
+type messageEvent struct {
+	ID             uint      `json:"id"`
+	ConversationID uint      `json:"conversation_id"`
+	SenderID       uint      `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// readEvent tells a sender their messages were read
+type readEvent struct {
+	ConversationID uint      `json:"conversation_id"`
+	ReadAt         time.Time `json:"read_at"`
+}
+
+// Start writes to the seller of an item on behalf of buyerID, opening
+// their conversation about it unless it exists
+func (s *MessageService) Start(ctx context.Context, buyerID, itemID uint, body string) (*model.Conversation, error) {
+	body, err := messageBody(body)
+	if err != nil {
+		return nil, err
+	}
+	var id uint
+	err = s.tx.Transaction(ctx, func(ctx context.Context) error {
+		item, err := s.items.FindByID(ctx, itemID)
+		if err != nil {
+			return err
+		}
+		if item.UserID == buyerID {
+			return ErrMessageSelf
+		}
+		conv, err := s.messages.FindByItemAndBuyer(ctx, itemID, buyerID)
+		if errors.Is(err, repository.ErrNotFound) {
+			conv = &model.Conversation{ItemID: itemID, BuyerID: buyerID, SellerID: item.UserID, LastMessageAt: time.Now()}
+			err = s.messages.CreateConversation(ctx, conv)
+		}
+		if err != nil {
+			return err
+		}
+		id = conv.ID
+		_, err = s.send(ctx, conv, buyerID, body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.messages.FindConversation(ctx, id)
+}
+
+// Send adds a message of senderID to a conversation
+func (s *MessageService) Send(ctx context.Context, senderID, conversationID uint, body string) (*model.Message, error) {
+	body, err := messageBody(body)
+	if err != nil {
+		return nil, err
+	}
+	var m *model.Message
+	err = s.tx.Transaction(ctx, func(ctx context.Context) error {
+		conv, err := s.Conversation(ctx, senderID, conversationID)
+		if err != nil {
+			return err
+		}
+		m, err = s.send(ctx, conv, senderID, body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// send stores a message unless either participant blocked the other and
+// tells the recipient once it commits
+func (s *MessageService) send(ctx context.Context, conv *model.Conversation, senderID uint, body string) (*model.Message, error) {
+	recipient := conv.Other(senderID)
+	blocked, err := s.messages.Blocked(ctx, senderID, recipient)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrBlocked
+	}
+	m := &model.Message{ConversationID: conv.ID, SenderID: senderID, Body: body, CreatedAt: time.Now()}
+	if err := s.messages.AddMessage(ctx, m); err != nil {
+		return nil, err
+	}
+	data := messageEvent{ID: m.ID, ConversationID: conv.ID, SenderID: senderID, Body: body, CreatedAt: m.CreatedAt}
+	publish(ctx, s.publisher, events.TypeMessage, data, recipient)
+	return m, nil
+}
+
+// Conversations lists the conversations of userID, most recently active
+// first, with their latest message and unread count
+func (s *MessageService) Conversations(ctx context.Context, userID uint) ([]repository.ConversationSummary, error) {
+	return s.messages.ListConversations(ctx, userID)
+}
+
+// Conversation loads a conversation userID takes part in
+func (s *MessageService) Conversation(ctx context.Context, userID, id uint) (*model.Conversation, error) {
+	conv, err := s.messages.FindConversation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !conv.Participant(userID) {
+		return nil, ErrForbidden
+	}
+	return conv, nil
+}
+
+// Messages returns up to limit messages of a conversation of userID sent
+// before the message before, or the latest ones when before is 0, oldest
+// first
+func (s *MessageService) Messages(ctx context.Context, userID, conversationID, before uint, limit int) ([]model.Message, error) {
+	if _, err := s.Conversation(ctx, userID, conversationID); err != nil {
+		return nil, err
+	}
+	return s.messages.ListMessages(ctx, conversationID, before, limit)
+}
+
+// MarkRead marks the messages userID received in a conversation read and
+// sends the other participant a read receipt
+func (s *MessageService) MarkRead(ctx context.Context, userID, conversationID uint) (int64, error) {
+	var n int64
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		conv, err := s.Conversation(ctx, userID, conversationID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if n, err = s.messages.MarkRead(ctx, conv.ID, userID, now); err != nil || n == 0 {
+			return err
+		}
+		publish(ctx, s.publisher, events.TypeMessagesRead, readEvent{ConversationID: conv.ID, ReadAt: now}, conv.Other(userID))
+		return nil
+	})
+	return n, err
+}
+
+// Block stops userID and otherID from messaging each other
+func (s *MessageService) Block(ctx context.Context, userID, otherID uint) error {
+	if userID == otherID {
+		return ErrBlockSelf
+	}
+	if _, err := s.users.FindByID(ctx, otherID); err != nil {
+		return err
+	}
+	return s.messages.Block(ctx, userID, otherID)
+}
+
+// Unblock lifts a block userID placed on otherID
+func (s *MessageService) Unblock(ctx context.Context, userID, otherID uint) error {
+	return s.messages.Unblock(ctx, userID, otherID)
+}
+
+func messageBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > MaxMessageLength {
+		return "", ErrInvalidMessage
+	}
+	return body, nil
+}
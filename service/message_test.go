@@ -0,0 +1,120 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"app/database"
+	"app/events"
+	"app/model"
+	"app/money"
+	"app/repository"
+	"app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupMessages(t *testing.T) (*service.MessageService, *events.Local) {
+	db := database.ConnectDBWithDSN(":memory:")
+	repos := repository.New(db)
+	for id, name := range map[uint]string{1: "seller", 2: "buyer", 3: "snoop"} {
+		require.NoError(t, db.Create(&model.User{ID: id, Username: name, Email: name + "@example.com", Password: "x"}).Error)
+	}
+	require.NoError(t, db.Create(&model.Item{ID: 1, Name: "Lamp", Description: "d", Price: money.New(1000, "EUR"), UserID: 1}).Error)
+	bus := events.NewLocal()
+	return service.NewMessageService(repos.Tx, repos.Messages, repos.Items, repos.Users, bus), bus
+}
+
+func TestMessages_Conversation(t *testing.T) {
+	messages, bus := setupMessages(t)
+	ctx := context.Background()
+	seller, cancel := bus.Subscribe(1)
+	defer cancel()
+	buyer, cancel := bus.Subscribe(2)
+	defer cancel()
+
+	conv, err := messages.Start(ctx, 2, 1, "Is it still available?")
+	require.NoError(t, err)
+	assert.Equal(t, "seller", conv.Seller.Username)
+	again, err := messages.Start(ctx, 2, 1, "  And does it ship?  ")
+	require.NoError(t, err)
+	assert.Equal(t, conv.ID, again.ID, "one conversation per buyer and item")
+	assert.Len(t, seller, 2)
+
+	reply, err := messages.Send(ctx, 1, conv.ID, "Yes to both")
+	require.NoError(t, err)
+	assert.Equal(t, events.TypeMessage, (<-buyer).Type)
+
+	_, err = messages.Start(ctx, 1, 1, "talking to myself")
+	assert.ErrorIs(t, err, service.ErrMessageSelf)
+	_, err = messages.Send(ctx, 2, conv.ID, "   ")
+	assert.ErrorIs(t, err, service.ErrInvalidMessage)
+	_, err = messages.Start(ctx, 2, 9, "hello")
+	assert.ErrorIs(t, err, service.ErrNotFound)
+
+	// Only the participants see or add to a conversation
+	_, err = messages.Send(ctx, 3, conv.ID, "hi")
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	_, err = messages.Messages(ctx, 3, conv.ID, 0, 10)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	_, err = messages.MarkRead(ctx, 3, conv.ID)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	none, err := messages.Conversations(ctx, 3)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	list, err := messages.Conversations(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, int64(2), list[0].Unread)
+	require.NotNil(t, list[0].LastMessage)
+	assert.Equal(t, reply.ID, list[0].LastMessage.ID)
+
+	n, err := messages.MarkRead(ctx, 1, conv.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	receipt := <-buyer
+	assert.Equal(t, events.TypeMessagesRead, receipt.Type)
+	n, err = messages.MarkRead(ctx, 1, conv.ID)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Empty(t, buyer, "no receipt when nothing was unread")
+
+	page, err := messages.Messages(ctx, 2, conv.ID, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "And does it ship?", page[0].Body)
+	assert.NotNil(t, page[0].ReadAt)
+	assert.Equal(t, "Yes to both", page[1].Body)
+	assert.Nil(t, page[1].ReadAt)
+	older, err := messages.Messages(ctx, 2, conv.ID, page[0].ID, 2)
+	require.NoError(t, err)
+	require.Len(t, older, 1)
+	assert.Equal(t, "Is it still available?", older[0].Body)
+}
+
+func TestMessages_Blocking(t *testing.T) {
+	messages, _ := setupMessages(t)
+	ctx := context.Background()
+
+	conv, err := messages.Start(ctx, 2, 1, "Hello")
+	require.NoError(t, err)
+
+	require.NoError(t, messages.Block(ctx, 1, 2))
+	require.NoError(t, messages.Block(ctx, 1, 2))
+	_, err = messages.Send(ctx, 2, conv.ID, "Hello?")
+	assert.ErrorIs(t, err, service.ErrBlocked)
+	_, err = messages.Send(ctx, 1, conv.ID, "Blocking goes both ways")
+	assert.ErrorIs(t, err, service.ErrBlocked)
+	_, err = messages.Start(ctx, 2, 1, "Hello again")
+	assert.ErrorIs(t, err, service.ErrBlocked)
+
+	assert.ErrorIs(t, messages.Block(ctx, 1, 1), service.ErrBlockSelf)
+	assert.ErrorIs(t, messages.Block(ctx, 1, 9), service.ErrNotFound)
+	assert.ErrorIs(t, messages.Unblock(ctx, 2, 1), service.ErrNotFound, "only the blocker lifts a block")
+
+	require.NoError(t, messages.Unblock(ctx, 1, 2))
+	_, err = messages.Send(ctx, 2, conv.ID, "Hello?")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"app/model"
+	"app/repository"
+)
+
+// MaxReportPage caps how many reports one page of the queue lists
+const MaxReportPage = 100
+
+// MaxReportDetails caps the free text of a report and a moderator's note
+const MaxReportDetails = 2000
+
+// Decisions a moderator takes on a report
+const (
+	DecisionHide    = "hide"
+	DecisionRemove  = "remove"
+	DecisionDismiss = "dismiss"
+)
+
+// reportReasons are the reasons users may give
+var reportReasons = map[string]bool{
+	model.ReportSpam: true, model.ReportProhibited: true, model.ReportOffensive: true,
+	model.ReportFraud: true, model.ReportOther: true,
+}
+
+// Blocklist flags text containing any of its terms. Terms are words,
+// matched whole and ignoring case, or /regular expressions/.
+type Blocklist struct {
+	terms []blockTerm
+}
+
+type blockTerm struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// ParseBlocklist reads comma-separated blocklist terms. It keeps the
+// terms it can parse even when it returns an error for others.
+func ParseBlocklist(spec string) (*Blocklist, error) {
+	var list Blocklist
+	var errs []error
+	for _, term := range strings.Split(spec, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		pattern := `(?i)\b` + regexp.QuoteMeta(term) + `\b`
+		if len(term) > 2 && strings.HasPrefix(term, "/") && strings.HasSuffix(term, "/") {
+			pattern = "(?i)" + term[1:len(term)-1]
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("blocklist term %q: %w", term, err))
+			continue
+		}
+		list.terms = append(list.terms, blockTerm{name: term, re: re})
+	}
+	return &list, errors.Join(errs...)
+}
+
+// Match returns the first term found in text
+func (b *Blocklist) Match(text string) (string, bool) {
+	if b == nil {
+		return "", false
+	}
+	for _, t := range b.terms {
+		if t.re.MatchString(text) {
+			return t.name, true
+		}
+	}
+	return "", false
+}
+
+// ModerationService takes reports of items, reviews and comments and lets
+// moderators resolve them. New and edited items matching the blocklist
+// are hidden until a moderator looks at them.
+type ModerationService struct {
+	tx        repository.TxManager
+	items     repository.ItemRepository
+	listings  *ItemService
+	reviews   repository.ReviewRepository
+	comments  repository.CommentRepository
+	reports   repository.ReportRepository
+	audit     *AuditService
+	blocklist *Blocklist
+}
+
+// NewModerationService creates a ModerationService screening items with
+// blocklist, which may be nil. Items are removed through listings so its
+// hooks hear of it.
+func NewModerationService(repos *repository.Repositories, listings *ItemService, audit *AuditService, blocklist *Blocklist) *ModerationService {
+	return &ModerationService{
+		tx:        repos.Tx,
+		items:     repos.Items,
+		listings:  listings,
+		reviews:   repos.Reviews,
+		comments:  repos.Comments,
+		reports:   repos.Reports,
+		audit:     audit,
+		blocklist: blocklist,
+	}
+}
+
+// Report files a report by reporterID, who may have one open report per
+// target
+func (s *ModerationService) Report(ctx context.Context, reporterID uint, targetType string, targetID uint, reason, details string) (*model.Report, error) {
+	details = strings.TrimSpace(details)
+	if !reportReasons[reason] || utf8.RuneCountInString(details) > MaxReportDetails {
+		return nil, ErrInvalidReport
+	}
+	if err := s.exists(ctx, targetType, targetID); err != nil {
+		return nil, err
+	}
+	if open, err := s.reports.HasOpen(ctx, &reporterID, targetType, targetID); err != nil {
+		return nil, err
+	} else if open {
+		return nil, ErrAlreadyReported
+	}
+	report := &model.Report{
+		ReporterID: &reporterID, TargetType: targetType, TargetID: targetID,
+		Reason: reason, Details: details, Status: model.ReportOpen,
+	}
+	if err := s.reports.Create(ctx, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Reports returns a page of matching reports, oldest first, and how many
+// match in all. Pages count from 1.
+func (s *ModerationService) Reports(ctx context.Context, filter repository.ReportFilter, page, limit int) ([]model.Report, int64, error) {
+	filter.Offset, filter.Limit = (page-1)*limit, limit
+	return s.reports.List(ctx, filter)
+}
+
+// Resolve applies a moderator's decision to the target of a report and
+// closes every open report on it. Dismissing an item hidden by the
+// blocklist shows it again.
+func (s *ModerationService) Resolve(ctx context.Context, moderatorID, id uint, decision, note string) (*model.Report, error) {
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > MaxReportDetails {
+		return nil, ErrInvalidReport
+	}
+	var report *model.Report
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		report, err = s.reports.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if report.Status != model.ReportOpen {
+			return ErrReportResolved
+		}
+		var status, action string
+		switch decision {
+		case DecisionHide:
+			status, action = model.ReportHidden, model.AuditContentHidden
+			err = s.setHidden(ctx, report.TargetType, report.TargetID, true)
+		case DecisionRemove:
+			status, action = model.ReportRemoved, model.AuditContentRemoved
+			// Content its author deleted meanwhile is removed already
+			if err = s.remove(ctx, moderatorID, report.TargetType, report.TargetID); errors.Is(err, ErrNotFound) {
+				err = nil
+			}
+		case DecisionDismiss:
+			status, action = model.ReportDismissed, model.AuditReportDismissed
+			err = s.dismiss(ctx, report.TargetType, report.TargetID)
+		default:
+			return ErrInvalidDecision
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := s.reports.Resolve(ctx, report.TargetType, report.TargetID, status, moderatorID, note, time.Now()); err != nil {
+			return err
+		}
+		err = s.audit.Record(ctx, AuditEntry{
+			ActorID: moderatorID, Action: action, TargetType: report.TargetType, TargetID: report.TargetID,
+			After: map[string]any{"report_id": report.ID, "note": note},
+		})
+		if err != nil {
+			return err
+		}
+		report, err = s.reports.FindByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Screen is an EventHook hiding new items and items whose text was edited
+// that match the blocklist, and queueing them for moderators
+func (s *ModerationService) Screen(ctx context.Context, event Event) error {
+	if event.Type != EventItemCreated && event.Type != EventItemUpdated {
+		return nil
+	}
+	// Status and price changes don't undo a moderator's dismissal
+	if b := event.Before; b != nil && b.Name == event.Item.Name && b.Description == event.Item.Description {
+		return nil
+	}
+	term, ok := s.blocklist.Match(event.Item.Name + "\n" + event.Item.Description)
+	if !ok {
+		return nil
+	}
+	if err := s.items.SetHidden(ctx, event.Item.ID, true); err != nil {
+		return err
+	}
+	if open, err := s.reports.HasOpen(ctx, nil, model.ReportItem, event.Item.ID); err != nil || open {
+		return err
+	}
+	return s.reports.Create(ctx, &model.Report{
+		TargetType: model.ReportItem, TargetID: event.Item.ID, Reason: model.ReportBlocklist,
+		Details: fmt.Sprintf("Matched blocklist term %q", term), Status: model.ReportOpen,
+	})
+}
+
+// exists checks the target of a report is still there
+func (s *ModerationService) exists(ctx context.Context, targetType string, id uint) error {
+	var err error
+	switch targetType {
+	case model.ReportItem:
+		_, err = s.items.FindByID(ctx, id)
+	case model.ReportReview:
+		_, err = s.reviews.FindByID(ctx, id)
+	case model.ReportComment:
+		_, err = s.comments.FindByID(ctx, id)
+	default:
+		return ErrInvalidReport
+	}
+	return err
+}
+
+func (s *ModerationService) setHidden(ctx context.Context, targetType string, id uint, hidden bool) error {
+	switch targetType {
+	case model.ReportItem:
+		return s.items.SetHidden(ctx, id, hidden)
+	case model.ReportReview:
+		return s.reviews.SetHidden(ctx, id, hidden)
+	default:
+		return s.comments.SetHidden(ctx, id, hidden)
+	}
+}
+
+func (s *ModerationService) remove(ctx context.Context, moderatorID uint, targetType string, id uint) error {
+	switch targetType {
+	case model.ReportItem:
+		return s.listings.Remove(ctx, moderatorID, id)
+	case model.ReportReview:
+		review, err := s.reviews.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		return s.reviews.Delete(ctx, review)
+	default:
+		c, err := s.comments.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.comments.Delete(ctx, c); err != nil {
+			return err
+		}
+		return s.items.AdjustCounts(ctx, c.ItemID, 0, -1)
+	}
+}
+
+// dismiss shows an item again if it was hidden by the blocklist
+func (s *ModerationService) dismiss(ctx context.Context, targetType string, id uint) error {
+	if targetType != model.ReportItem {
+		return nil
+	}
+	screened, err := s.reports.HasOpen(ctx, nil, targetType, id)
+	if err != nil || !screened {
+		return err
+	}
+	return s.items.SetHidden(ctx, id, false)
+}
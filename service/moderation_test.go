@@ -0,0 +1,71 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"app/database"
+	"app/model"
+	"app/repository"
+	"app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBlocklist(t *testing.T) {
+	list, err := service.ParseBlocklist(` Replica, /fake\s+rolex/ ,, /[unclosed/`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "[unclosed")
+
+	term, ok := list.Match("genuine REPLICA handbag")
+	assert.True(t, ok)
+	assert.Equal(t, "Replica", term)
+	term, ok = list.Match("a Fake   Rolex, barely worn")
+	assert.True(t, ok)
+	assert.Equal(t, `/fake\s+rolex/`, term)
+	_, ok = list.Match("Replicator 3000")
+	assert.False(t, ok, "words only match whole")
+
+	empty, err := service.ParseBlocklist("")
+	require.NoError(t, err)
+	_, ok = empty.Match("anything")
+	assert.False(t, ok)
+	_, ok = (*service.Blocklist)(nil).Match("anything")
+	assert.False(t, ok)
+}
+
+func TestModeration_RemoveItem(t *testing.T) {
+	db := database.ConnectDBWithDSN(":memory:")
+	repos := repository.New(db)
+	ctx := context.Background()
+	require.NoError(t, db.Create(&model.User{ID: 1, Username: "seller", Email: "s@example.com", Password: "x"}).Error)
+	require.NoError(t, db.Create(&model.User{ID: 2, Username: "mod", Email: "m@example.com", Password: "x", Role: model.RoleModerator}).Error)
+	require.NoError(t, db.Create(&model.Item{ID: 1, Name: "Lamp", Description: "old", UserID: 1}).Error)
+	audit := service.NewAuditService(repos.Audit)
+	items := service.NewItemService(repos.Tx, repos.Items, repos.Users, repos.Categories, repos.Inventory)
+	var events []service.Event
+	items.OnEvent(func(_ context.Context, e service.Event) error {
+		events = append(events, e)
+		return nil
+	})
+	items.OnEvent(audit.ItemEvent)
+	moderation := service.NewModerationService(repos, items, audit, nil)
+
+	report, err := moderation.Report(ctx, 2, model.ReportItem, 1, model.ReportFraud, "")
+	require.NoError(t, err)
+	report, err = moderation.Resolve(ctx, 2, report.ID, service.DecisionRemove, "")
+	require.NoError(t, err)
+	assert.Equal(t, model.ReportRemoved, report.Status)
+
+	// Removal is announced like the owner deleting the item
+	require.Len(t, events, 1)
+	assert.Equal(t, service.EventItemDeleted, events[0].Type)
+	assert.Equal(t, uint(2), events[0].ActorID)
+	assert.Equal(t, uint(1), events[0].Item.ID)
+	_, err = repos.Items.FindByID(ctx, 1)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, total, err := audit.List(ctx, repository.AuditFilter{Action: model.AuditItemDeleted, TargetID: 1}, 1, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"app/events"
+	"app/mail"
+	"app/model"
+	"app/repository"
+)
+
+// MaxNotificationPage caps how many notifications one page lists
+const MaxNotificationPage = 100
+
+// NotificationPage is a page of the notifications of a user. Total counts
+// every notification matching the query; Unread counts every unread one.
+type NotificationPage struct {
+	Notifications []model.Notification
+	Total         int64
+	Unread        int64
+}
+
+// NotificationService stores notifications, shows them to their users and
+// emails those who asked for it
+type NotificationService struct {
+	notifications repository.NotificationRepository
+	users         repository.UserRepository
+	jobs          repository.JobRepository
+	publisher     events.Publisher
+}
+
+// NewNotificationService creates a NotificationService queueing emails as
+// JobSendEmail jobs and pushing new notifications to their users through
+// publisher
+func NewNotificationService(notifications repository.NotificationRepository, users repository.UserRepository, jobs repository.JobRepository, publisher events.Publisher) *NotificationService {
+	return &NotificationService{notifications: notifications, users: users, jobs: jobs, publisher: publisher}
+}
+
+// notificationEvent is what subscribers see of a new notification
+type notificationEvent struct {
+	ID        uint      `json:"id"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	ItemID    *uint     `json:"item_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Notify delivers notifications as their users prefer: stored for the
+// app and queued for email, inside the caller's transaction if any
+func (s *NotificationService) Notify(ctx context.Context, notifications ...model.Notification) error {
+	var inApp []model.Notification
+	var emails []mail.Message
+	for _, kind := range model.NotificationTypes {
+		var userIDs []uint
+		for _, n := range notifications {
+			if n.Type == kind {
+				userIDs = append(userIDs, n.UserID)
+			}
+		}
+		if len(userIDs) == 0 {
+			continue
+		}
+		prefs, err := s.notifications.PreferencesFor(ctx, userIDs, kind)
+		if err != nil {
+			return err
+		}
+		for _, n := range notifications {
+			if n.Type != kind {
+				continue
+			}
+			pref, ok := prefs[n.UserID]
+			if !ok {
+				pref = model.DefaultNotificationPreference(n.UserID, kind)
+			}
+			if pref.InApp {
+				inApp = append(inApp, n)
+			}
+			if pref.Email {
+				user, err := s.users.FindByID(ctx, n.UserID)
+				switch {
+				case errors.Is(err, repository.ErrNotFound):
+					// Deactivated accounts get no email
+				case err != nil:
+					return err
+				default:
+					emails = append(emails, mail.Message{To: user.Email, Subject: n.Title, Body: n.Body})
+				}
+			}
+		}
+	}
+	if err := s.notifications.Create(ctx, inApp); err != nil {
+		return err
+	}
+	for _, n := range inApp {
+		data := notificationEvent{ID: n.ID, Type: n.Type, Title: n.Title, Body: n.Body, ItemID: n.ItemID, CreatedAt: n.CreatedAt}
+		publish(ctx, s.publisher, events.TypeNotification, data, n.UserID)
+	}
+	for _, msg := range emails {
+		if err := enqueue(ctx, s.jobs, JobSendEmail, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fanout is an EventHook notifying whoever an event concerns: the seller
+// of an ordered or reviewed item, or the author of a comment replied to.
+// Nobody is told about their own doing.
+func (s *NotificationService) Fanout(ctx context.Context, event Event) error {
+	itemID := event.Item.ID
+	n := model.Notification{Type: event.Type, ItemID: &itemID}
+	switch {
+	case event.Type == model.NotifyNewOrder && event.Order != nil:
+		n.UserID = event.Item.UserID
+		n.Title = "New order: " + event.Item.Name
+		n.Body = fmt.Sprintf("%d × %s was ordered for %s.", event.Order.Quantity, event.Item.Name, event.Order.TotalPrice)
+	case event.Type == model.NotifyReview && event.Review != nil:
+		n.UserID = event.Item.UserID
+		n.Title = "New review: " + event.Item.Name
+		n.Body = fmt.Sprintf("%s was rated %d out of 5.", event.Item.Name, event.Review.Rating)
+	case event.Type == model.NotifyCommentReply && event.Comment != nil && event.Parent != nil:
+		n.UserID = event.Parent.UserID
+		n.Title = "New reply on " + event.Item.Name
+		n.Body = truncateRunes(event.Comment.Body, 200)
+	default:
+		return nil
+	}
+	if n.UserID == event.ActorID {
+		return nil
+	}
+	return s.Notify(ctx, n)
+}
+
+// List returns a page of the notifications of userID, newest first.
+// Pages count from 1.
+func (s *NotificationService) List(ctx context.Context, userID uint, unreadOnly bool, page, limit int) (*NotificationPage, error) {
+	filter := repository.NotificationFilter{UnreadOnly: unreadOnly, Offset: (page - 1) * limit, Limit: limit}
+	notifications, total, err := s.notifications.List(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.notifications.CountUnread(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &NotificationPage{Notifications: notifications, Total: total, Unread: unread}, nil
+}
+
+// MarkRead marks a notification of userID read
+func (s *NotificationService) MarkRead(ctx context.Context, userID, id uint) error {
+	return s.notifications.MarkRead(ctx, userID, id, time.Now())
+}
+
+// MarkAllRead marks every notification of userID read, returning how many
+// were unread
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID uint) (int64, error) {
+	return s.notifications.MarkAllRead(ctx, userID, time.Now())
+}
+
+// Preferences returns the preference of userID for every notification
+// type, defaults included
+func (s *NotificationService) Preferences(ctx context.Context, userID uint) ([]model.NotificationPreference, error) {
+	stored, err := s.notifications.Preferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]model.NotificationPreference, len(model.NotificationTypes))
+	for i, kind := range model.NotificationTypes {
+		out[i] = model.DefaultNotificationPreference(userID, kind)
+		for _, p := range stored {
+			if p.Type == kind {
+				out[i] = p
+			}
+		}
+	}
+	return out, nil
+}
+
+// SetPreferences changes the preferences of userID for the types listed,
+// leaving the others alone
+func (s *NotificationService) SetPreferences(ctx context.Context, userID uint, prefs []model.NotificationPreference) ([]model.NotificationPreference, error) {
+	for i := range prefs {
+		if !slices.Contains(model.NotificationTypes, prefs[i].Type) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownNotification, prefs[i].Type)
+		}
+		prefs[i].UserID = userID
+	}
+	if err := s.notifications.SavePreferences(ctx, prefs); err != nil {
+		return nil, err
+	}
+	return s.Preferences(ctx, userID)
+}
+
+// truncateRunes shortens s to at most n characters, marking the cut
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,102 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"app/database"
+	"app/events"
+	"app/mail"
+	"app/model"
+	"app/repository"
+	"app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifications_EmailsQueuedWithTheChange(t *testing.T) {
+	db := database.ConnectDBWithDSN(":memory:")
+	repos := repository.New(db)
+	require.NoError(t, db.Create(&model.User{ID: 1, Username: "seller", Email: "s@example.com", Password: "x"}).Error)
+	bus := events.NewLocal()
+	live, cancel := bus.Subscribe(1)
+	defer cancel()
+	notifications := service.NewNotificationService(repos.Notifications, repos.Users, repos.Jobs, bus)
+	ctx := context.Background()
+
+	_, err := notifications.SetPreferences(ctx, 1, []model.NotificationPreference{{Type: model.NotifyNewOrder, InApp: true, Email: true}})
+	require.NoError(t, err)
+	order := model.Notification{UserID: 1, Type: model.NotifyNewOrder, Title: "New order: Lamp", Body: "1 × Lamp"}
+	queued := func() []model.Job {
+		jobs, err := repos.Jobs.List(ctx, model.JobPending, 10)
+		require.NoError(t, err)
+		return jobs
+	}
+
+	boom := errors.New("boom")
+	err = repos.Tx.Transaction(ctx, func(ctx context.Context) error {
+		require.NoError(t, notifications.Notify(ctx, order))
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+	assert.Empty(t, queued(), "nothing is emailed for a rolled back change")
+	assert.Empty(t, live)
+
+	require.NoError(t, repos.Tx.Transaction(ctx, func(ctx context.Context) error {
+		return notifications.Notify(ctx, order)
+	}))
+	require.Len(t, live, 1)
+	assert.Equal(t, events.TypeNotification, (<-live).Type)
+	jobs := queued()
+	require.Len(t, jobs, 1)
+	assert.Equal(t, service.JobSendEmail, jobs[0].Kind)
+
+	mailer := &mail.Fake{}
+	require.NoError(t, service.EmailJob(mailer)(ctx, json.RawMessage(jobs[0].Payload)))
+	assert.Equal(t, []mail.Message{{To: "s@example.com", Subject: "New order: Lamp", Body: "1 × Lamp"}}, mailer.Sent())
+	require.NoError(t, service.EmailJob(nil)(ctx, json.RawMessage(jobs[0].Payload)), "email off")
+
+	page, err := notifications.List(ctx, 1, false, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, page.Notifications, 1)
+	assert.Equal(t, int64(1), page.Unread)
+}
+
+func TestNotifications_Fanout(t *testing.T) {
+	db := database.ConnectDBWithDSN(":memory:")
+	repos := repository.New(db)
+	notifications := service.NewNotificationService(repos.Notifications, repos.Users, repos.Jobs, events.NewLocal())
+	ctx := context.Background()
+	item := model.Item{ID: 1, Name: "Lamp", UserID: 1}
+	question := &model.Comment{UserID: 2, Body: "Dimmable?"}
+
+	// Sellers answering their own question aren't told
+	require.NoError(t, notifications.Fanout(ctx, service.Event{
+		Type: model.NotifyCommentReply, ActorID: 2, Item: item, Parent: question, Comment: &model.Comment{UserID: 2, Body: "Also, blue?"},
+	}))
+	require.NoError(t, notifications.Fanout(ctx, service.Event{
+		Type: model.NotifyCommentReply, ActorID: 1, Item: item, Parent: question, Comment: &model.Comment{UserID: 1, Body: "Yes"},
+	}))
+	require.NoError(t, notifications.Fanout(ctx, service.Event{
+		Type: model.NotifyReview, ActorID: 2, Item: item, Review: &model.Review{Rating: 5},
+	}))
+
+	buyer, err := notifications.List(ctx, 2, false, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, buyer.Notifications, 1)
+	assert.Equal(t, "Yes", buyer.Notifications[0].Body)
+	seller, err := notifications.List(ctx, 1, false, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, seller.Notifications, 1)
+	assert.Equal(t, "Lamp was rated 5 out of 5.", seller.Notifications[0].Body)
+
+	n, err := notifications.MarkAllRead(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	_, err = notifications.SetPreferences(ctx, 1, []model.NotificationPreference{{Type: "spam"}})
+	assert.ErrorIs(t, err, service.ErrUnknownNotification)
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+
+	"app/model"
+	"app/repository"
+)
+
+// OrderService places orders and owns pricing. Its hooks see every order
+// placed.
+type OrderService struct {
+	EventHooks
+	tx        repository.TxManager
+	items     repository.ItemRepository
+	orders    repository.OrderRepository
+	inventory *InventoryService
+}
+
+// NewOrderService creates an OrderService
+func NewOrderService(tx repository.TxManager, items repository.ItemRepository, orders repository.OrderRepository, inventory *InventoryService) *OrderService {
+	return &OrderService{tx: tx, items: items, orders: orders, inventory: inventory}
+}
+
+// Place orders quantity units of an item for buyerID. The total is priced
+// from the stored item, never from client input, and the units are taken
+// from stock while the item row is locked.
+func (s *OrderService) Place(ctx context.Context, buyerID, itemID uint, quantity int) (*model.Order, error) {
+	if quantity < 1 {
+		return nil, ErrInvalidQuantity
+	}
+
+	var order *model.Order
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		order, err = s.place(ctx, buyerID, itemID, quantity)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// place orders quantity units of an item inside the caller's transaction
+func (s *OrderService) place(ctx context.Context, buyerID, itemID uint, quantity int) (*model.Order, error) {
+	item, err := s.items.FindForUpdate(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if item.UserID == buyerID {
+		return nil, ErrOwnItem
+	}
+	if !item.Listed() {
+		return nil, ErrItemUnavailable
+	}
+	if item.Stock < quantity {
+		return nil, ErrInsufficientStock
+	}
+	order, err := newOrder(item, buyerID, quantity)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.orders.Create(ctx, order); err != nil {
+		return nil, err
+	}
+	if err := s.inventory.take(ctx, item, quantity, buyerID, order.ID); err != nil {
+		return nil, err
+	}
+	if err := s.placed(ctx, item, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// PlaceReserved orders the units held by a reservation of buyerID
+func (s *OrderService) PlaceReserved(ctx context.Context, buyerID, reservationID uint) (*model.Order, error) {
+	var order *model.Order
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		res, err := s.inventory.consume(ctx, buyerID, reservationID)
+		if err != nil {
+			return err
+		}
+		item, err := s.items.FindForUpdate(ctx, res.ItemID)
+		if err != nil {
+			return err
+		}
+		order, err = newOrder(item, buyerID, res.Quantity)
+		if err != nil {
+			return err
+		}
+		if err := s.orders.Create(ctx, order); err != nil {
+			return err
+		}
+		if err := s.inventory.attach(ctx, res, order.ID); err != nil {
+			return err
+		}
+		return s.placed(ctx, item, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// placed tells the hooks about a new order
+func (s *OrderService) placed(ctx context.Context, item *model.Item, order *model.Order) error {
+	return s.emit(ctx, Event{Type: model.NotifyNewOrder, ActorID: order.UserID, Item: *item, Order: order})
+}
+
+// newOrder prices quantity units of item in the item's currency
+func newOrder(item *model.Item, buyerID uint, quantity int) (*model.Order, error) {
+	total, err := item.Price.Mul(int64(quantity))
+	if err != nil {
+		return nil, err
+	}
+	return &model.Order{
+		ItemID:     item.ID,
+		UserID:     buyerID,
+		Quantity:   quantity,
+		TotalPrice: total,
+		Status:     model.OrderPending,
+		CategoryID: item.CategoryID,
+	}, nil
+}
+
+// ListForBuyer returns the orders placed by buyerID
+func (s *OrderService) ListForBuyer(ctx context.Context, buyerID uint) ([]model.Order, error) {
+	return s.orders.ListByUser(ctx, buyerID)
+}
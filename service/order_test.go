@@ -0,0 +1,38 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"app/model"
+	"app/money"
+	"app/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderService_PricesFromItem(t *testing.T) {
+	items := newFakeItems(model.Item{ID: 1, Price: money.New(1999, "EUR"), UserID: 2, CategoryID: 3, Stock: 5})
+	orders := &fakeOrders{}
+	svc := service.NewOrderService(fakeTx{}, items, orders, service.NewInventoryService(fakeTx{}, items, &fakeInventory{}))
+
+	order, err := svc.Place(context.Background(), 1, 1, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(5997, "EUR"), order.TotalPrice)
+	assert.Equal(t, uint(3), order.CategoryID)
+	assert.Len(t, orders.orders, 1)
+}
+
+func TestOrderService_Rules(t *testing.T) {
+	items := newFakeItems(model.Item{ID: 1, Price: money.New(500, "EUR"), UserID: 2, Stock: 1})
+	svc := service.NewOrderService(fakeTx{}, items, &fakeOrders{}, service.NewInventoryService(fakeTx{}, items, &fakeInventory{}))
+
+	_, err := svc.Place(context.Background(), 1, 1, 0)
+	assert.ErrorIs(t, err, service.ErrInvalidQuantity)
+
+	_, err = svc.Place(context.Background(), 2, 1, 1)
+	assert.ErrorIs(t, err, service.ErrOwnItem)
+
+	_, err = svc.Place(context.Background(), 1, 7, 1)
+	assert.ErrorIs(t, err, service.ErrNotFound)
+}
@@ -0,0 +1,351 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"app/events"
+	"app/model"
+	"app/money"
+	"app/payment"
+	"app/repository"
+)
+
+// PaymentService collects payments for orders through a provider and
+// applies the provider's webhooks
+type PaymentService struct {
+	tx        repository.TxManager
+	orders    repository.OrderRepository
+	payments  repository.PaymentRepository
+	provider  payment.Provider
+	publisher events.Publisher
+}
+
+// NewPaymentService creates a PaymentService telling buyers and sellers
+// about order status changes through publisher
+func NewPaymentService(tx repository.TxManager, orders repository.OrderRepository, payments repository.PaymentRepository, provider payment.Provider, publisher events.Publisher) *PaymentService {
+	return &PaymentService{tx: tx, orders: orders, payments: payments, provider: provider, publisher: publisher}
+}
+
+// Pay starts paying an order of buyerID. Until it fails, calling again
+// returns the same payment.
+func (s *PaymentService) Pay(ctx context.Context, buyerID, orderID uint) (*model.Payment, error) {
+	order, err := s.orders.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserID != buyerID {
+		return nil, ErrForbidden
+	}
+
+	key := "order-" + strconv.FormatUint(uint64(order.ID), 10)
+	prev, err := s.payments.FindByOrder(ctx, order.ID)
+	switch {
+	case err == nil && live(prev):
+		return prev, nil
+	case err == nil:
+		// a new intent replaces the failed one
+		key += "-after-" + strconv.FormatUint(uint64(prev.ID), 10)
+	case !errors.Is(err, repository.ErrNotFound):
+		return nil, err
+	}
+	if order.Status != model.OrderPending && order.Status != model.OrderPaymentFailed {
+		return nil, ErrPaymentState
+	}
+
+	intent, err := s.provider.CreateIntent(ctx, order.TotalPrice, key, map[string]string{
+		"order_id": strconv.FormatUint(uint64(order.ID), 10),
+	})
+	if err != nil {
+		return nil, err
+	}
+	p := &model.Payment{
+		OrderID:      order.ID,
+		Provider:     s.provider.Name(),
+		IntentID:     intent.ID,
+		ClientSecret: intent.ClientSecret,
+		Amount:       intent.Amount,
+		Status:       intent.Status,
+	}
+	err = s.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.payments.Create(ctx, p); err != nil {
+			return err
+		}
+		if order.Status == model.OrderPaymentFailed {
+			return s.setStatus(ctx, order, model.OrderPending)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// live reports whether a payment may still be completed or was completed
+func live(p *model.Payment) bool {
+	return p.Status != payment.IntentFailed && p.Status != payment.IntentCanceled
+}
+
+// Capture collects the authorized payment of an order. Only the seller may
+// capture.
+func (s *PaymentService) Capture(ctx context.Context, sellerID, orderID uint) (*model.Payment, error) {
+	order, p, err := s.forSeller(ctx, sellerID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != model.OrderPending && order.Status != model.OrderAuthorized && order.Status != model.OrderPaid {
+		return nil, ErrPaymentState
+	}
+	intent, err := s.provider.Capture(ctx, p.IntentID)
+	if errors.Is(err, payment.ErrInvalidState) {
+		return nil, ErrPaymentState
+	} else if err != nil {
+		return nil, err
+	}
+	err = s.tx.Transaction(ctx, func(ctx context.Context) error {
+		return s.apply(ctx, p, &payment.Event{Type: payment.EventSucceeded, IntentID: intent.ID, Amount: intent.Amount})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Refund returns amount, a decimal in the payment's currency, of a paid
+// order to the buyer. A nil amount refunds everything not yet refunded.
+// Only the seller may refund. The entry is nil while the provider is still
+// processing the refund; its webhook records it later.
+func (s *PaymentService) Refund(ctx context.Context, sellerID, orderID uint, amount *string) (*model.LedgerEntry, error) {
+	order, p, err := s.forSeller(ctx, sellerID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != model.OrderPaid && order.Status != model.OrderPartiallyRefunded {
+		return nil, ErrPaymentState
+	}
+	entries, err := s.payments.ListLedger(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := balance(entries, p.Amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := remaining
+	if amount != nil {
+		if refund, err = money.Parse(*amount, p.Amount.Currency); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRefund, err)
+		}
+	}
+	if refund.Amount <= 0 || refund.Amount > remaining.Amount {
+		return nil, ErrInvalidRefund
+	}
+
+	// Keyed by the ledger length, a retried request refunds once
+	key := fmt.Sprintf("refund-%d-%d", order.ID, len(entries))
+	re, err := s.provider.Refund(ctx, p.IntentID, refund, key)
+	if errors.Is(err, payment.ErrInvalidState) {
+		return nil, ErrPaymentState
+	} else if err != nil {
+		return nil, err
+	}
+	if re.Status != payment.RefundSucceeded {
+		return nil, nil
+	}
+	err = s.tx.Transaction(ctx, func(ctx context.Context) error {
+		return s.apply(ctx, p, &payment.Event{Type: payment.EventRefunded, IntentID: p.IntentID, Amount: re.Amount, RefundID: re.ID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.LedgerEntry{
+		OrderID:   order.ID,
+		PaymentID: p.ID,
+		Kind:      model.LedgerRefund,
+		Amount:    money.New(-re.Amount.Amount, re.Amount.Currency),
+		Reference: refundReference(re.ID),
+	}, nil
+}
+
+// Payment returns the payment of an order and its ledger, for the buyer
+// or the seller
+func (s *PaymentService) Payment(ctx context.Context, userID, orderID uint) (*model.Payment, []model.LedgerEntry, error) {
+	order, err := s.orders.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if order.UserID != userID && order.Item.UserID != userID {
+		return nil, nil, ErrForbidden
+	}
+	p, err := s.payments.FindByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.Order = *order
+	entries, err := s.payments.ListLedger(ctx, order.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, entries, nil
+}
+
+// HandleWebhook verifies a webhook delivery and applies its event once.
+// Events about unknown intents are ignored. It returns
+// payment.ErrInvalidSignature for deliveries that fail verification.
+func (s *PaymentService) HandleWebhook(ctx context.Context, payload []byte, header http.Header) error {
+	ev, err := s.provider.ParseEvent(payload, header)
+	if err != nil {
+		return err
+	}
+	return s.tx.Transaction(ctx, func(ctx context.Context) error {
+		fresh, err := s.payments.RecordEvent(ctx, &model.PaymentEvent{ID: s.provider.Name() + ":" + ev.ID, Type: ev.Type})
+		if err != nil || !fresh || ev.Type == "" {
+			return err
+		}
+		p, err := s.payments.FindByIntent(ctx, s.provider.Name(), ev.IntentID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return s.apply(ctx, p, ev)
+	})
+}
+
+// apply moves a payment and its order forward for ev, inside the caller's
+// transaction. Statuses never move back, so a late or repeated event
+// changes nothing.
+func (s *PaymentService) apply(ctx context.Context, p *model.Payment, ev *payment.Event) error {
+	order, err := s.orders.FindForUpdate(ctx, p.OrderID)
+	if err != nil {
+		return err
+	}
+	status := order.Status
+	switch ev.Type {
+	case payment.EventAuthorized:
+		if p.Status == payment.IntentRequiresPayment {
+			p.Status = payment.IntentRequiresCapture
+		}
+		if status == model.OrderPending {
+			status = model.OrderAuthorized
+		}
+	case payment.EventSucceeded:
+		p.Status = payment.IntentSucceeded
+		amount := ev.Amount
+		if amount.Currency == "" {
+			amount = p.Amount
+		}
+		_, err := s.payments.AddLedgerEntry(ctx, &model.LedgerEntry{
+			OrderID:   order.ID,
+			PaymentID: p.ID,
+			Kind:      model.LedgerCharge,
+			Amount:    amount,
+			Reference: "charge:" + p.IntentID,
+		})
+		if err != nil {
+			return err
+		}
+		if status == model.OrderPending || status == model.OrderAuthorized || status == model.OrderPaymentFailed {
+			status = model.OrderPaid
+		}
+	case payment.EventFailed, payment.EventCanceled:
+		if p.Status == payment.IntentSucceeded {
+			return nil
+		}
+		p.Status = payment.IntentFailed
+		if ev.Type == payment.EventCanceled {
+			p.Status = payment.IntentCanceled
+		}
+		if status == model.OrderPending || status == model.OrderAuthorized {
+			status = model.OrderPaymentFailed
+		}
+	case payment.EventRefunded:
+		added, err := s.payments.AddLedgerEntry(ctx, &model.LedgerEntry{
+			OrderID:   order.ID,
+			PaymentID: p.ID,
+			Kind:      model.LedgerRefund,
+			Amount:    money.New(-ev.Amount.Amount, ev.Amount.Currency),
+			Reference: refundReference(ev.RefundID),
+		})
+		if err != nil || !added {
+			return err
+		}
+		entries, err := s.payments.ListLedger(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		remaining, err := balance(entries, p.Amount.Currency)
+		if err != nil {
+			return err
+		}
+		status = model.OrderPartiallyRefunded
+		if remaining.Amount <= 0 {
+			status = model.OrderRefunded
+		}
+	default:
+		return nil
+	}
+
+	if err := s.payments.Update(ctx, p); err != nil {
+		return err
+	}
+	if status != order.Status {
+		return s.setStatus(ctx, order, status)
+	}
+	return nil
+}
+
+// orderEvent is what buyers and sellers see of an order status change
+type orderEvent struct {
+	ID     uint   `json:"id"`
+	Status string `json:"status"`
+}
+
+// setStatus changes the status of an order and, once that commits, tells
+// its buyer and seller
+func (s *PaymentService) setStatus(ctx context.Context, order *model.Order, status string) error {
+	if err := s.orders.UpdateStatus(ctx, order.ID, status); err != nil {
+		return err
+	}
+	publish(ctx, s.publisher, events.TypeOrder, orderEvent{ID: order.ID, Status: status}, order.UserID, order.Item.UserID)
+	return nil
+}
+
+// forSeller loads an order sold by sellerID and its payment
+func (s *PaymentService) forSeller(ctx context.Context, sellerID, orderID uint) (*model.Order, *model.Payment, error) {
+	order, err := s.orders.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if order.Item.UserID != sellerID {
+		return nil, nil, ErrForbidden
+	}
+	p, err := s.payments.FindByOrder(ctx, order.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, ErrPaymentState
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return order, p, nil
+}
+
+// balance sums ledger entries: what was charged and not yet refunded
+func balance(entries []model.LedgerEntry, currency money.Currency) (money.Money, error) {
+	sum := money.New(0, currency)
+	for _, e := range entries {
+		var err error
+		if sum, err = sum.Add(e.Amount); err != nil {
+			return money.Money{}, err
+		}
+	}
+	return sum, nil
+}
+
+func refundReference(id string) string {
+	return "refund:" + id
+}
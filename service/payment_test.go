@@ -0,0 +1,164 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"app/database"
+	"app/events"
+	"app/model"
+	"app/money"
+	"app/payment"
+	"app/repository"
+	"app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupPayments(t *testing.T) (*service.PaymentService, *payment.Fake, *gorm.DB) {
+	db := database.ConnectDBWithDSN(":memory:")
+	repos := repository.New(db)
+	require.NoError(t, db.Create(&model.User{ID: 1, Username: "seller", Email: "s@example.com", Password: "x"}).Error)
+	require.NoError(t, db.Create(&model.User{ID: 2, Username: "buyer", Email: "b@example.com", Password: "x"}).Error)
+	require.NoError(t, db.Create(&model.Item{ID: 1, Name: "Lamp", Description: "d", Price: money.New(2000, "EUR"), UserID: 1}).Error)
+	require.NoError(t, db.Create(&model.Order{ID: 1, ItemID: 1, UserID: 2, Quantity: 1, TotalPrice: money.New(2000, "EUR"), Status: model.OrderPending}).Error)
+	fake := payment.NewFake([]byte("whsec"))
+	return service.NewPaymentService(repos.Tx, repos.Orders, repos.Payments, fake, events.NewLocal()), fake, db
+}
+
+func deliver(t *testing.T, payments *service.PaymentService, fake *payment.Fake, ev payment.Event) {
+	payload, header := fake.Webhook(ev)
+	require.NoError(t, payments.HandleWebhook(context.Background(), payload, header))
+}
+
+func orderStatus(t *testing.T, db *gorm.DB) string {
+	var order model.Order
+	require.NoError(t, db.First(&order, 1).Error)
+	return order.Status
+}
+
+func TestPayment_PublishesStatusChanges(t *testing.T) {
+	_, fake, db := setupPayments(t)
+	repos := repository.New(db)
+	bus := events.NewLocal()
+	payments := service.NewPaymentService(repos.Tx, repos.Orders, repos.Payments, fake, bus)
+	buyer, cancel := bus.Subscribe(2)
+	defer cancel()
+	seller, cancel := bus.Subscribe(1)
+	defer cancel()
+
+	p, err := payments.Pay(context.Background(), 2, 1)
+	require.NoError(t, err)
+	deliver(t, payments, fake, payment.Event{ID: "evt_1", Type: payment.EventSucceeded, IntentID: p.IntentID, Amount: p.Amount})
+
+	for _, sub := range []<-chan events.Event{buyer, seller} {
+		require.Len(t, sub, 1)
+		ev := <-sub
+		assert.Equal(t, events.TypeOrder, ev.Type)
+		assert.JSONEq(t, `{"id":1,"status":"paid"}`, string(ev.Data))
+	}
+}
+
+func TestPayment_WebhooksAreIdempotent(t *testing.T) {
+	payments, fake, db := setupPayments(t)
+	ctx := context.Background()
+
+	_, err := payments.Pay(ctx, 1, 1)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	p, err := payments.Pay(ctx, 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, money.New(2000, "EUR"), p.Amount)
+	again, err := payments.Pay(ctx, 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, p.ID, again.ID)
+
+	authorized := payment.Event{ID: "evt_1", Type: payment.EventAuthorized, IntentID: p.IntentID, Amount: p.Amount}
+	deliver(t, payments, fake, authorized)
+	assert.Equal(t, model.OrderAuthorized, orderStatus(t, db))
+
+	succeeded := payment.Event{ID: "evt_2", Type: payment.EventSucceeded, IntentID: p.IntentID, Amount: p.Amount}
+	deliver(t, payments, fake, succeeded)
+	deliver(t, payments, fake, succeeded)
+	// a late event does not move the order back
+	deliver(t, payments, fake, payment.Event{ID: "evt_3", Type: payment.EventFailed, IntentID: p.IntentID, Amount: p.Amount})
+	// unknown intents are acknowledged
+	deliver(t, payments, fake, payment.Event{ID: "evt_4", Type: payment.EventSucceeded, IntentID: "pi_other", Amount: p.Amount})
+	assert.Equal(t, model.OrderPaid, orderStatus(t, db))
+
+	_, ledger, err := payments.Payment(ctx, 2, 1)
+	require.NoError(t, err)
+	require.Len(t, ledger, 1)
+	assert.Equal(t, money.New(2000, "EUR"), ledger[0].Amount)
+
+	var events int64
+	db.Model(&model.PaymentEvent{}).Count(&events)
+	assert.Equal(t, int64(4), events)
+
+	payload, header := fake.Webhook(succeeded)
+	header.Set(payment.FakeSignatureHeader, "t=1,v1=00")
+	assert.ErrorIs(t, payments.HandleWebhook(ctx, payload, header), payment.ErrInvalidSignature)
+}
+
+func TestPayment_CaptureAndPartialRefunds(t *testing.T) {
+	payments, fake, db := setupPayments(t)
+	ctx := context.Background()
+
+	p, err := payments.Pay(ctx, 2, 1)
+	require.NoError(t, err)
+	_, err = payments.Capture(ctx, 1, 1)
+	assert.ErrorIs(t, err, service.ErrPaymentState, "not authorized yet")
+	require.NoError(t, fake.Authorize(p.IntentID))
+	_, err = payments.Capture(ctx, 2, 1)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	p, err = payments.Capture(ctx, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, payment.IntentSucceeded, p.Status)
+	assert.Equal(t, model.OrderPaid, orderStatus(t, db))
+
+	part := "5.50"
+	entry, err := payments.Refund(ctx, 1, 1, &part)
+	require.NoError(t, err)
+	assert.Equal(t, money.New(-550, "EUR"), entry.Amount)
+	assert.Equal(t, model.OrderPartiallyRefunded, orderStatus(t, db))
+
+	// the provider reporting the same refund adds nothing
+	deliver(t, payments, fake, payment.Event{
+		ID: "evt_r", Type: payment.EventRefunded, IntentID: p.IntentID,
+		Amount: money.New(550, "EUR"), RefundID: entry.Reference[len("refund:"):],
+	})
+
+	tooMuch := "15.00"
+	_, err = payments.Refund(ctx, 1, 1, &tooMuch)
+	assert.ErrorIs(t, err, service.ErrInvalidRefund)
+
+	entry, err = payments.Refund(ctx, 1, 1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, money.New(-1450, "EUR"), entry.Amount)
+	assert.Equal(t, model.OrderRefunded, orderStatus(t, db))
+
+	_, ledger, err := payments.Payment(ctx, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, ledger, 3)
+	assert.Equal(t, []string{model.LedgerCharge, model.LedgerRefund, model.LedgerRefund},
+		[]string{ledger[0].Kind, ledger[1].Kind, ledger[2].Kind})
+
+	_, err = payments.Refund(ctx, 1, 1, nil)
+	assert.ErrorIs(t, err, service.ErrPaymentState)
+}
+
+func TestPayment_RetryAfterFailure(t *testing.T) {
+	payments, fake, db := setupPayments(t)
+	ctx := context.Background()
+
+	first, err := payments.Pay(ctx, 2, 1)
+	require.NoError(t, err)
+	deliver(t, payments, fake, payment.Event{ID: "evt_f", Type: payment.EventFailed, IntentID: first.IntentID, Amount: first.Amount})
+	assert.Equal(t, model.OrderPaymentFailed, orderStatus(t, db))
+
+	second, err := payments.Pay(ctx, 2, 1)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.IntentID, second.IntentID)
+	assert.Equal(t, model.OrderPending, orderStatus(t, db))
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+
+	"app/events"
+	"app/logging"
+	"app/repository"
+)
+
+// publish sends data to every user in userIDs once the transaction bound
+// to ctx commits. Real-time updates are best effort: failures are logged.
+func publish(ctx context.Context, publisher events.Publisher, kind string, data any, userIDs ...uint) {
+	repository.AfterCommit(ctx, func() {
+		for _, userID := range userIDs {
+			event, err := events.New(userID, kind, data)
+			if err == nil {
+				err = publisher.Publish(ctx, event)
+			}
+			if err != nil {
+				logging.FromContext(ctx).Error("failed to publish event", "type", kind, "error", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"app/model"
+	"app/repository"
+)
+
+// ReviewService takes reviews of items. Its hooks see every review posted.
+type ReviewService struct {
+	EventHooks
+	tx      repository.TxManager
+	reviews repository.ReviewRepository
+	items   repository.ItemRepository
+}
+
+// NewReviewService creates a ReviewService
+func NewReviewService(tx repository.TxManager, reviews repository.ReviewRepository, items repository.ItemRepository) *ReviewService {
+	return &ReviewService{tx: tx, reviews: reviews, items: items}
+}
+
+// List returns the reviews of an item
+func (s *ReviewService) List(ctx context.Context, itemID uint) ([]model.Review, error) {
+	if _, err := s.items.FindByID(ctx, itemID); err != nil {
+		return nil, err
+	}
+	return s.reviews.ListByItem(ctx, itemID)
+}
+
+// Create reviews an item on behalf of userID, who must not be its seller
+func (s *ReviewService) Create(ctx context.Context, userID, itemID uint, rating int, comment string) (*model.Review, error) {
+	comment = strings.TrimSpace(comment)
+	if rating < 1 || rating > 5 || utf8.RuneCountInString(comment) > MaxCommentLength {
+		return nil, ErrInvalidReview
+	}
+	now := time.Now()
+	review := &model.Review{ItemID: itemID, UserID: userID, Rating: rating, Comment: comment, CreatedAt: &now}
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		item, err := s.items.FindByID(ctx, itemID)
+		if err != nil {
+			return err
+		}
+		if item.UserID == userID {
+			return ErrReviewOwnItem
+		}
+		if err := s.reviews.Create(ctx, review); err != nil {
+			return err
+		}
+		return s.emit(ctx, Event{Type: model.NotifyReview, ActorID: userID, Item: *item, Review: review})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+
+	"app/repository"
+)
+
+// Errors returned by services; handlers map them to HTTP statuses
+var (
+	ErrNotFound            = repository.ErrNotFound
+	ErrForbidden           = errors.New("forbidden")
+	ErrInvalidCredentials  = errors.New("invalid identity or password")
+	ErrEmailTaken          = errors.New("email already exists")
+	ErrUsernameTaken       = errors.New("username already exists")
+	ErrInvalidQuantity     = errors.New("quantity must be at least 1")
+	ErrOwnItem             = errors.New("cannot order your own item")
+	ErrInvalidCategory     = errors.New("category does not exist")
+	ErrInvalidPrice        = errors.New("invalid price")
+	ErrCartEmpty           = errors.New("cart is empty")
+	ErrPaymentState        = errors.New("order is not in a state allowing this payment operation")
+	ErrInvalidRefund       = errors.New("refund must be positive and at most what remains to refund")
+	ErrInvalidStatsQuery   = errors.New("stats window must be positive and at most two years, bucketed by day, week or month")
+	ErrInvalidComment      = errors.New("comment must be 1 to 2000 characters")
+	ErrThreadTooDeep       = errors.New("replies are nested too deeply")
+	ErrInvalidReview       = errors.New("rating must be 1 to 5 and the comment at most 2000 characters")
+	ErrReviewOwnItem       = errors.New("cannot review your own item")
+	ErrUnknownNotification = errors.New("unknown notification type")
+	ErrInvalidMessage      = errors.New("message must be 1 to 2000 characters")
+	ErrMessageSelf         = errors.New("cannot message yourself")
+	ErrBlockSelf           = errors.New("cannot block yourself")
+	ErrBlocked             = errors.New("one of you blocked the other")
+	ErrRetentionExpired    = errors.New("account can no longer be reactivated")
+	ErrUnsupportedImage    = errors.New("image must be a JPEG, PNG or GIF")
+	ErrImageTooLarge       = errors.New("image is too large")
+	ErrTooManyImages       = errors.New("item has too many images")
+	ErrInvalidImageOrder   = errors.New("image order must list every image of the item once")
+	ErrInsufficientStock   = errors.New("not enough stock")
+	ErrReservationInactive = errors.New("reservation is no longer active")
+	ErrInvalidWebhook      = errors.New("invalid webhook subscription")
+	ErrTooManyWebhooks     = errors.New("too many webhook subscriptions")
+	ErrSuspended           = errors.New("account is suspended")
+	ErrSessionEnded        = errors.New("session has ended")
+	ErrInvalidSuspension   = errors.New("suspension needs a reason of at most 500 characters and an end in the future")
+	ErrInvalidReport       = errors.New("report needs a known target type and reason, and details of at most 2000 characters")
+	ErrAlreadyReported     = errors.New("you already reported this")
+	ErrReportResolved      = errors.New("report is already resolved")
+	ErrInvalidDecision     = errors.New("decision must be hide, remove or dismiss")
+	ErrItemUnavailable     = errors.New("item is not for sale")
+	ErrInvalidStatus       = errors.New("item can't move to that status")
+	ErrInvalidSchedule     = errors.New("publish and unpublish times must be in the future, unpublishing after publishing")
+)
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"app/money"
+	"app/repository"
+)
+
+// Stats window limits
+const (
+	MaxStatsWindow = 2 * 366 * 24 * time.Hour
+	MaxTopItems    = 50
+)
+
+// StatsQuery selects the window [From, To) and how to bucket it
+type StatsQuery struct {
+	From   time.Time
+	To     time.Time
+	Bucket string
+	Top    int
+}
+
+// SalesTotals sums sales and reviews over a bucket or a whole window
+type SalesTotals struct {
+	// Revenue has one sum per currency sold in
+	Revenue []money.Money
+	Units   int64
+	Orders  int64
+	// AverageRating is nil without reviews
+	AverageRating *float64
+	Reviews       int64
+}
+
+// StatsBucket is the totals of one day, week or month
+type StatsBucket struct {
+	Start time.Time
+	SalesTotals
+}
+
+// TopItem is an item among a seller's best sellers by units
+type TopItem struct {
+	ItemID  uint
+	Name    string
+	Units   int64
+	Revenue money.Money
+}
+
+// SellerStats is a seller's dashboard
+type SellerStats struct {
+	Query StatsQuery
+	SalesTotals
+	// Buckets covers the whole window, including empty buckets
+	Buckets  []StatsBucket
+	TopItems []TopItem
+}
+
+// StatsService computes seller dashboards
+type StatsService struct {
+	stats repository.StatsRepository
+}
+
+// NewStatsService creates a StatsService
+func NewStatsService(stats repository.StatsRepository) *StatsService {
+	return &StatsService{stats: stats}
+}
+
+// Seller returns the sales of sellerID's items and the reviews they got
+func (s *StatsService) Seller(ctx context.Context, sellerID uint, q StatsQuery) (*SellerStats, error) {
+	if !q.From.Before(q.To) || q.To.Sub(q.From) > MaxStatsWindow || q.Top < 0 || q.Top > MaxTopItems {
+		return nil, ErrInvalidStatsQuery
+	}
+	switch q.Bucket {
+	case repository.BucketDay, repository.BucketWeek, repository.BucketMonth:
+	default:
+		return nil, ErrInvalidStatsQuery
+	}
+
+	sales, err := s.stats.Sales(ctx, sellerID, q.From, q.To, q.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	ratings, err := s.stats.Ratings(ctx, sellerID, q.From, q.To, q.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	out := &SellerStats{Query: q, TopItems: []TopItem{}}
+	if q.Top > 0 {
+		top, err := s.stats.TopItems(ctx, sellerID, q.From, q.To, q.Top)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range top {
+			out.TopItems = append(out.TopItems, TopItem{ItemID: t.ItemID, Name: t.Name, Units: t.Units, Revenue: money.New(t.Revenue, t.Currency)})
+		}
+	}
+
+	index := map[time.Time]int{}
+	for t := truncate(q.From, q.Bucket); t.Before(q.To); t = next(t, q.Bucket) {
+		index[t] = len(out.Buckets)
+		out.Buckets = append(out.Buckets, StatsBucket{Start: t, SalesTotals: SalesTotals{Revenue: []money.Money{}}})
+	}
+	bucket := func(start time.Time) *SalesTotals {
+		if i, ok := index[start]; ok {
+			return &out.Buckets[i].SalesTotals
+		}
+		return &SalesTotals{}
+	}
+	var ratingSum int64
+	for _, r := range sales {
+		b := bucket(r.Start)
+		revenue := money.New(r.Revenue, r.Currency)
+		b.Revenue = append(b.Revenue, revenue)
+		b.Units += r.Units
+		b.Orders += r.Orders
+		if out.Revenue, err = addTo(out.Revenue, revenue); err != nil {
+			return nil, err
+		}
+		out.Units += r.Units
+		out.Orders += r.Orders
+	}
+	for _, r := range ratings {
+		b := bucket(r.Start)
+		b.Reviews = r.Reviews
+		b.AverageRating = average(r.Sum, r.Reviews)
+		ratingSum += r.Sum
+		out.Reviews += r.Reviews
+	}
+	out.AverageRating = average(ratingSum, out.Reviews)
+	if out.Revenue == nil {
+		out.Revenue = []money.Money{}
+	}
+	return out, nil
+}
+
+// addTo adds m to the sum of its currency in sums
+func addTo(sums []money.Money, m money.Money) ([]money.Money, error) {
+	for i, s := range sums {
+		if s.Currency == m.Currency {
+			sum, err := s.Add(m)
+			if err != nil {
+				return nil, err
+			}
+			sums[i] = sum
+			return sums, nil
+		}
+	}
+	return append(sums, m), nil
+}
+
+func average(sum, n int64) *float64 {
+	if n == 0 {
+		return nil
+	}
+	avg := float64(sum) / float64(n)
+	return &avg
+}
+
+// truncate returns the start of the bucket holding t, matching the
+// repository's UTC buckets
+func truncate(t time.Time, bucket string) time.Time {
+	y, m, d := t.UTC().Date()
+	switch bucket {
+	case repository.BucketMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	case repository.BucketWeek:
+		day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func next(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case repository.BucketMonth:
+		return t.AddDate(0, 1, 0)
+	case repository.BucketWeek:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
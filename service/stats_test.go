@@ -0,0 +1,91 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"app/database"
+	"app/model"
+	"app/money"
+	"app/repository"
+	"app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats_SellerBuckets(t *testing.T) {
+	db := database.ConnectDBWithDSN(":memory:")
+	stats := service.NewStatsService(repository.New(db).Stats)
+	require.NoError(t, db.Create(&model.User{ID: 1, Username: "seller", Email: "s@example.com", Password: "x"}).Error)
+	require.NoError(t, db.Create(&model.User{ID: 2, Username: "buyer", Email: "b@example.com", Password: "x"}).Error)
+	require.NoError(t, db.Create(&model.Item{ID: 1, Name: "Lamp", Description: "d", Price: money.New(1000, "EUR"), UserID: 1}).Error)
+	require.NoError(t, db.Create(&model.Item{ID: 2, Name: "Vase", Description: "d", Price: money.New(500, "JPY"), UserID: 1}).Error)
+	require.NoError(t, db.Create(&model.Item{ID: 3, Name: "Other", Description: "d", Price: money.New(100, "EUR"), UserID: 2}).Error)
+
+	// Monday 2026-03-02 and the Sunday ending that week, then the next week
+	mon := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	sun := time.Date(2026, 3, 8, 23, 0, 0, 0, time.UTC)
+	nextWeek := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	orders := []model.Order{
+		{ItemID: 1, UserID: 2, Quantity: 2, TotalPrice: money.New(2000, "EUR"), Status: model.OrderPaid, CreatedAt: mon},
+		{ItemID: 2, UserID: 2, Quantity: 1, TotalPrice: money.New(500, "JPY"), Status: model.OrderPending, CreatedAt: sun},
+		{ItemID: 1, UserID: 2, Quantity: 1, TotalPrice: money.New(1000, "EUR"), Status: model.OrderPartiallyRefunded, CreatedAt: nextWeek},
+		// not sales, or not the seller's
+		{ItemID: 1, UserID: 2, Quantity: 5, TotalPrice: money.New(5000, "EUR"), Status: model.OrderPaymentFailed, CreatedAt: mon},
+		{ItemID: 1, UserID: 2, Quantity: 5, TotalPrice: money.New(5000, "EUR"), Status: model.OrderRefunded, CreatedAt: mon},
+		{ItemID: 3, UserID: 1, Quantity: 1, TotalPrice: money.New(100, "EUR"), Status: model.OrderPaid, CreatedAt: mon},
+	}
+	for i := range orders {
+		orders[i].CategoryID = 1
+		require.NoError(t, db.Create(&orders[i]).Error)
+	}
+	for _, r := range []model.Review{
+		{ItemID: 1, UserID: 2, Rating: 5, Comment: "great", CreatedAt: &mon},
+		{ItemID: 2, UserID: 2, Rating: 2, Comment: "chipped", CreatedAt: &sun},
+		{ItemID: 3, UserID: 1, Rating: 1, Comment: "bad", CreatedAt: &mon},
+	} {
+		require.NoError(t, db.Create(&r).Error)
+	}
+
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	s, err := stats.Seller(context.Background(), 1, service.StatsQuery{From: from, To: to, Bucket: repository.BucketWeek, Top: 5})
+	require.NoError(t, err)
+
+	assert.Equal(t, []money.Money{money.New(3000, "EUR"), money.New(500, "JPY")}, s.Revenue)
+	assert.Equal(t, int64(4), s.Units)
+	assert.Equal(t, int64(3), s.Orders)
+	require.NotNil(t, s.AverageRating)
+	assert.InDelta(t, 3.5, *s.AverageRating, 0.001)
+
+	// weeks starting Monday Feb 23, Mar 2 and Mar 9
+	require.Len(t, s.Buckets, 3)
+	assert.Equal(t, time.Date(2026, 2, 23, 0, 0, 0, 0, time.UTC), s.Buckets[0].Start)
+	assert.Empty(t, s.Buckets[0].Revenue)
+	assert.Nil(t, s.Buckets[0].AverageRating)
+	assert.Equal(t, int64(3), s.Buckets[1].Units)
+	assert.Equal(t, int64(2), s.Buckets[1].Reviews)
+	assert.Equal(t, []money.Money{money.New(1000, "EUR")}, s.Buckets[2].Revenue)
+
+	require.Len(t, s.TopItems, 2)
+	assert.Equal(t, "Lamp", s.TopItems[0].Name)
+	assert.Equal(t, int64(3), s.TopItems[0].Units)
+
+	s, err = stats.Seller(context.Background(), 1, service.StatsQuery{From: from, To: to, Bucket: repository.BucketMonth})
+	require.NoError(t, err)
+	require.Len(t, s.Buckets, 1)
+	assert.Equal(t, int64(3), s.Buckets[0].Orders)
+	assert.Empty(t, s.TopItems)
+
+	s, err = stats.Seller(context.Background(), 1, service.StatsQuery{From: from, To: to, Bucket: repository.BucketDay})
+	require.NoError(t, err)
+	require.Len(t, s.Buckets, 14)
+	assert.Equal(t, int64(1), s.Buckets[7].Orders, "Sunday March 8")
+
+	_, err = stats.Seller(context.Background(), 1, service.StatsQuery{From: to, To: from, Bucket: repository.BucketDay})
+	assert.ErrorIs(t, err, service.ErrInvalidStatsQuery)
+	_, err = stats.Seller(context.Background(), 1, service.StatsQuery{From: from, To: to, Bucket: "year"})
+	assert.ErrorIs(t, err, service.ErrInvalidStatsQuery)
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"time"
+
+	"app/metrics"
+	"app/model"
+	"app/repository"
+)
+
+// UserService holds account rules: uniqueness, credentials and ownership
+type UserService struct {
+	tx      repository.TxManager
+	users   repository.UserRepository
+	items   repository.ItemRepository
+	reviews repository.ReviewRepository
+	audit   *AuditService
+}
+
+// NewUserService creates a UserService recording sign-ins and profile
+// changes in audit
+func NewUserService(tx repository.TxManager, users repository.UserRepository, items repository.ItemRepository, reviews repository.ReviewRepository, audit *AuditService) *UserService {
+	return &UserService{tx: tx, users: users, items: items, reviews: reviews, audit: audit}
+}
+
+// Profile is a user's public profile with their seller statistics
+type Profile struct {
+	User          model.User
+	ListingCount  int64
+	AverageRating float64
+	ReviewCount   int64
+}
+
+// ProfileUpdate lists the fields a user may change about themselves; nil
+// fields are left unchanged
+type ProfileUpdate struct {
+	Username    *string
+	DisplayName *string
+	AvatarURL   *string
+	Bio         *string
+}
+
+// Get returns the user with id
+func (s *UserService) Get(ctx context.Context, id uint) (*model.User, error) {
+	return s.users.FindByID(ctx, id)
+}
+
+// Session returns the user a token was issued to, as long as the token
+// is still good: the account exists and isn't suspended, and the user
+// hasn't been signed out everywhere since it was issued with version
+func (s *UserService) Session(ctx context.Context, id uint, version int) (*model.User, error) {
+	user, err := s.users.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Suspended(time.Now()) {
+		return nil, ErrSuspended
+	}
+	if user.TokenVersion != version {
+		return nil, ErrSessionEnded
+	}
+	return user, nil
+}
+
+// Register creates a user after checking email and username are free and
+// counts it in metrics.RegistrationsTotal, whichever route it came by
+func (s *UserService) Register(ctx context.Context, username, email, password string) (*model.User, error) {
+	if taken, err := s.users.EmailTaken(ctx, email); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrEmailTaken
+	}
+	if taken, err := s.users.UsernameTaken(ctx, username); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrUsernameTaken
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user := &model.User{Username: username, Email: email, Password: hash}
+	err = s.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.users.Create(ctx, user); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditEntry{
+			ActorID: user.ID, Action: model.AuditUserRegistered, TargetType: model.AuditTargetUser, TargetID: user.ID,
+			After: newUserSnapshot(*user, time.Now()),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.RegistrationsTotal.Inc()
+	return user, nil
+}
+
+// Authenticate finds the user by email or username and checks the password
+func (s *UserService) Authenticate(ctx context.Context, identity, password string) (*model.User, error) {
+	var user *model.User
+	var err error
+	if _, perr := mail.ParseAddress(identity); perr == nil {
+		user, err = s.users.FindByEmail(ctx, identity)
+	} else {
+		user, err = s.users.FindByUsername(ctx, identity)
+	}
+
+	if errors.Is(err, repository.ErrNotFound) {
+		CheckPasswordHash(password, dummyHash) // prevent timing attacks
+		return nil, s.loginFailed(ctx, 0, model.LoginUnknownUser)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !CheckPasswordHash(password, user.Password) {
+		return nil, s.loginFailed(ctx, user.ID, model.LoginBadPassword)
+	}
+	if user.Suspended(time.Now()) {
+		if err := s.recordLoginFailure(ctx, user.ID, model.LoginSuspended); err != nil {
+			return nil, err
+		}
+		return nil, ErrSuspended
+	}
+	err = s.audit.Record(ctx, AuditEntry{
+		ActorID: user.ID, Action: model.AuditLoginSucceeded, TargetType: model.AuditTargetUser, TargetID: user.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// loginFailed records a failed sign-in for reason, against userID when
+// the account exists, and returns ErrInvalidCredentials
+func (s *UserService) loginFailed(ctx context.Context, userID uint, reason string) error {
+	if err := s.recordLoginFailure(ctx, userID, reason); err != nil {
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+// recordLoginFailure writes a failed sign-in to the audit log. Only the
+// reason is kept, never what was typed.
+func (s *UserService) recordLoginFailure(ctx context.Context, userID uint, reason string) error {
+	return s.audit.Record(ctx, AuditEntry{
+		Action: model.AuditLoginFailed, TargetType: model.AuditTargetUser, TargetID: userID,
+		After: map[string]string{"reason": reason},
+	})
+}
+
+// Profile returns the public profile of the user called username
+func (s *UserService) Profile(ctx context.Context, username string) (*Profile, error) {
+	user, err := s.users.FindByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	listings, err := s.items.CountByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	rating, reviews, err := s.reviews.SellerRating(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Profile{User: *user, ListingCount: listings, AverageRating: rating, ReviewCount: reviews}, nil
+}
+
+// UpdateUsername renames a user; only the user themselves may do so
+func (s *UserService) UpdateUsername(ctx context.Context, actorID, id uint, username string) (*model.User, error) {
+	if actorID != id {
+		return nil, ErrForbidden
+	}
+	return s.UpdateProfile(ctx, id, ProfileUpdate{Username: &username})
+}
+
+// UpdateProfile changes the profile of user id
+func (s *UserService) UpdateProfile(ctx context.Context, id uint, in ProfileUpdate) (*model.User, error) {
+	user, err := s.users.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	old := *user
+	if in.Username != nil && *in.Username != user.Username {
+		if taken, err := s.users.UsernameTaken(ctx, *in.Username); err != nil {
+			return nil, err
+		} else if taken {
+			return nil, ErrUsernameTaken
+		}
+		user.Username = *in.Username
+	}
+	if in.DisplayName != nil {
+		user.DisplayName = *in.DisplayName
+	}
+	if in.AvatarURL != nil {
+		user.AvatarURL = *in.AvatarURL
+	}
+	if in.Bio != nil {
+		user.Bio = *in.Bio
+	}
+	err = s.tx.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.users.Update(ctx, user); err != nil {
+			return err
+		}
+		changed := changedUserFields(old, *user)
+		if len(changed) == 0 {
+			return nil
+		}
+		now := time.Now()
+		after := newUserSnapshot(*user, now)
+		after.Changed = changed
+		return s.audit.Record(ctx, AuditEntry{
+			ActorID: id, Action: model.AuditUserUpdated, TargetType: model.AuditTargetUser, TargetID: id,
+			Before: newUserSnapshot(old, now), After: after,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,399 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"syscall"
+	"time"
+
+	"app/jobs"
+	"app/model"
+	"app/money"
+	"app/payment"
+	"app/repository"
+
+	"github.com/google/uuid"
+)
+
+// Webhook limits
+const (
+	MaxWebhooksPerUser = 10
+	// MaxWebhookFailures is how many deliveries in a row may fail before a
+	// subscription is disabled
+	MaxWebhookFailures = 15
+	// WebhookTimeout bounds a single delivery
+	WebhookTimeout = 10 * time.Second
+)
+
+// Headers of webhook deliveries. The signature is in the format of
+// payment.Sign, computed with the subscription secret.
+const (
+	WebhookSignatureHeader = "Webhook-Signature"
+	WebhookEventHeader     = "Webhook-Event"
+	WebhookIDHeader        = "Webhook-Id"
+)
+
+// errPrivateAddress refuses deliveries to the internal network
+var errPrivateAddress = errors.New("webhook URL resolves to a private address")
+
+// NewWebhookClient returns the HTTP client delivering webhooks. Unless
+// allowPrivate is set it refuses loopback, private and link-local
+// addresses, so subscriptions can't reach internal services.
+func NewWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: WebhookTimeout}
+	if !allowPrivate {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout:   WebhookTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		// A redirect is answered like any other non-2xx status
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// WebhookUpdate lists the fields of a subscription to change; nil fields
+// are left unchanged. Activating a subscription forgets its failures.
+type WebhookUpdate struct {
+	URL    *string
+	Events []string
+	Active *bool
+}
+
+// WebhookService manages the webhook subscriptions of users and delivers
+// the events of their shops: orders for their items and changes to their
+// items
+type WebhookService struct {
+	webhooks repository.WebhookRepository
+	jobs     repository.JobRepository
+	client   *http.Client
+}
+
+// NewWebhookService creates a WebhookService queueing deliveries as
+// JobDeliverWebhook jobs and sending them with client
+func NewWebhookService(webhooks repository.WebhookRepository, jobs repository.JobRepository, client *http.Client) *WebhookService {
+	return &WebhookService{webhooks: webhooks, jobs: jobs, client: client}
+}
+
+// webhookBody is what subscribers receive
+type webhookBody struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// webhookJob is the payload of JobDeliverWebhook jobs
+type webhookJob struct {
+	SubscriptionID uint            `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	Type           string          `json:"type"`
+	Body           json.RawMessage `json:"body"`
+}
+
+type webhookItem struct {
+	ID          uint        `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+	Stock       int         `json:"stock"`
+	CategoryID  uint        `json:"category_id"`
+	Status      string      `json:"status"`
+}
+
+type webhookOrder struct {
+	ID         uint        `json:"id"`
+	Item       webhookItem `json:"item"`
+	BuyerID    uint        `json:"buyer_id"`
+	Quantity   int         `json:"quantity"`
+	TotalPrice money.Money `json:"total_price"`
+	Status     string      `json:"status"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+func newWebhookItem(item model.Item) webhookItem {
+	return webhookItem{
+		ID: item.ID, Name: item.Name, Description: item.Description,
+		Price: item.Price, Stock: item.Stock, CategoryID: item.CategoryID, Status: item.Status,
+	}
+}
+
+// List returns the subscriptions of userID
+func (s *WebhookService) List(ctx context.Context, userID uint) ([]model.WebhookSubscription, error) {
+	return s.webhooks.ListByUser(ctx, userID)
+}
+
+// Create subscribes userID to events, delivered to rawURL. An empty
+// secret is generated.
+func (s *WebhookService) Create(ctx context.Context, userID uint, rawURL string, events []string, secret string) (*model.WebhookSubscription, error) {
+	n, err := s.webhooks.CountByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if n >= MaxWebhooksPerUser {
+		return nil, ErrTooManyWebhooks
+	}
+	if err := validWebhookURL(rawURL); err != nil {
+		return nil, err
+	}
+	if events, err = webhookEvents(events); err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		key := make([]byte, 24)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		secret = "whsec_" + hex.EncodeToString(key)
+	}
+	sub := &model.WebhookSubscription{UserID: userID, URL: rawURL, Events: events, Secret: secret, Active: true}
+	if err := s.webhooks.Create(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Update changes a subscription of userID
+func (s *WebhookService) Update(ctx context.Context, userID, id uint, in WebhookUpdate) (*model.WebhookSubscription, error) {
+	sub, err := s.owned(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if in.URL != nil {
+		if err := validWebhookURL(*in.URL); err != nil {
+			return nil, err
+		}
+		sub.URL = *in.URL
+	}
+	if in.Events != nil {
+		if sub.Events, err = webhookEvents(in.Events); err != nil {
+			return nil, err
+		}
+	}
+	if in.Active != nil {
+		if *in.Active && !sub.Active {
+			sub.Failures = 0
+			sub.DisabledAt = nil
+		}
+		sub.Active = *in.Active
+	}
+	if err := s.webhooks.Update(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Delete removes a subscription of userID. Deliveries still queued for it
+// are dropped.
+func (s *WebhookService) Delete(ctx context.Context, userID, id uint) error {
+	sub, err := s.owned(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	return s.webhooks.Delete(ctx, sub)
+}
+
+// Deliveries returns the latest delivery attempts of a subscription of
+// userID, newest first
+func (s *WebhookService) Deliveries(ctx context.Context, userID, id uint, limit int) ([]model.WebhookDelivery, error) {
+	if _, err := s.owned(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	return s.webhooks.Deliveries(ctx, id, limit)
+}
+
+// Test sends a ping event to a subscription of userID right away, so the
+// caller sees how the endpoint answers. Its outcome doesn't count towards
+// disabling the subscription.
+func (s *WebhookService) Test(ctx context.Context, userID, id uint) (*model.WebhookDelivery, error) {
+	sub, err := s.owned(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	eventID := uuid.NewString()
+	body, err := json.Marshal(webhookBody{ID: eventID, Type: model.WebhookPing, CreatedAt: time.Now(), Data: map[string]uint{"subscription_id": sub.ID}})
+	if err != nil {
+		return nil, err
+	}
+	return s.deliver(ctx, *sub, eventID, model.WebhookPing, body)
+}
+
+// Relay is an EventHook queueing deliveries of new orders and item
+// changes to the subscriptions of the seller
+func (s *WebhookService) Relay(ctx context.Context, event Event) error {
+	switch {
+	case event.Type == model.NotifyNewOrder && event.Order != nil:
+		o := event.Order
+		return s.dispatch(ctx, event.Item.UserID, model.WebhookOrderCreated, webhookOrder{
+			ID: o.ID, Item: newWebhookItem(event.Item), BuyerID: o.UserID, Quantity: o.Quantity,
+			TotalPrice: o.TotalPrice, Status: o.Status, CreatedAt: o.CreatedAt,
+		})
+	case event.Type == EventItemCreated:
+		return s.dispatch(ctx, event.Item.UserID, model.WebhookItemCreated, newWebhookItem(event.Item))
+	case event.Type == EventItemUpdated:
+		return s.dispatch(ctx, event.Item.UserID, model.WebhookItemUpdated, newWebhookItem(event.Item))
+	case event.Type == EventItemDeleted:
+		return s.dispatch(ctx, event.Item.UserID, model.WebhookItemDeleted, newWebhookItem(event.Item))
+	}
+	return nil
+}
+
+// StockChanged is an ItemHook reporting stock changes as item updates
+func (s *WebhookService) StockChanged(ctx context.Context, change ItemChange) error {
+	if change.Before.Stock == change.After.Stock {
+		return nil
+	}
+	return s.dispatch(ctx, change.After.UserID, model.WebhookItemUpdated, newWebhookItem(change.After))
+}
+
+// dispatch queues an event for every active subscription of userID that
+// asked for kind, in the transaction bound to ctx
+func (s *WebhookService) dispatch(ctx context.Context, userID uint, kind string, data any) error {
+	subs, err := s.webhooks.ListActive(ctx, userID)
+	if err != nil {
+		return err
+	}
+	var body []byte
+	eventID := uuid.NewString()
+	for _, sub := range subs {
+		if !sub.Wants(kind) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(webhookBody{ID: eventID, Type: kind, CreatedAt: time.Now(), Data: data}); err != nil {
+				return err
+			}
+		}
+		job := webhookJob{SubscriptionID: sub.ID, EventID: eventID, Type: kind, Body: body}
+		// A delivery fails as often as its subscription may before being disabled
+		if err := enqueue(ctx, s.jobs, JobDeliverWebhook, job, jobs.MaxAttempts(MaxWebhookFailures)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeliverJob handles JobDeliverWebhook jobs. A failed delivery is retried
+// by the job runner up to MaxWebhookFailures times, by when a subscription
+// failing all along is disabled.
+func (s *WebhookService) DeliverJob(ctx context.Context, payload json.RawMessage) error {
+	var job webhookJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	sub, err := s.webhooks.FindByID(ctx, job.SubscriptionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil || !sub.Active {
+		return err
+	}
+
+	delivery, err := s.deliver(ctx, *sub, job.EventID, job.Type, job.Body)
+	if err != nil {
+		return err
+	}
+	if delivery.Error == "" {
+		return s.webhooks.ResetFailures(ctx, sub.ID)
+	}
+	disabled, err := s.webhooks.AddFailure(ctx, sub.ID, MaxWebhookFailures, time.Now())
+	if err != nil || disabled {
+		return err
+	}
+	return fmt.Errorf("webhook delivery failed: %s", delivery.Error)
+}
+
+// deliver POSTs a signed event to sub and logs the attempt
+func (s *WebhookService) deliver(ctx context.Context, sub model.WebhookSubscription, eventID, kind string, body []byte) (*model.WebhookDelivery, error) {
+	attempts, err := s.webhooks.CountAttempts(ctx, sub.ID, eventID)
+	if err != nil {
+		return nil, err
+	}
+	delivery := &model.WebhookDelivery{SubscriptionID: sub.ID, EventID: eventID, Event: kind, Attempt: int(attempts) + 1}
+
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Retot-Webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, kind)
+	req.Header.Set(WebhookIDHeader, eventID)
+	req.Header.Set(WebhookSignatureHeader, payment.Sign(body, []byte(sub.Secret), start))
+	resp, err := s.client.Do(req)
+	delivery.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		delivery.Error = truncateRunes(err.Error(), 1000)
+	} else {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		delivery.StatusCode = resp.StatusCode
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			delivery.Error = fmt.Sprintf("endpoint answered %d", resp.StatusCode)
+		}
+	}
+	if err := s.webhooks.RecordDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// owned loads subscription id, hiding those of other users
+func (s *WebhookService) owned(ctx context.Context, userID, id uint) (*model.WebhookSubscription, error) {
+	sub, err := s.webhooks.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return sub, nil
+}
+
+func validWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.User != nil {
+		return fmt.Errorf("%w: URL must be an absolute http or https URL without credentials", ErrInvalidWebhook)
+	}
+	return nil
+}
+
+// webhookEvents checks the event types of a subscription, dropping
+// duplicates
+func webhookEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("%w: no event types", ErrInvalidWebhook)
+	}
+	out := make([]string, 0, len(events))
+	for _, e := range events {
+		if !slices.Contains(model.WebhookEvents, e) {
+			return nil, fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, e)
+		}
+		if !slices.Contains(out, e) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"app/database"
+	"app/jobs"
+	"app/model"
+	"app/payment"
+	"app/repository"
+	"app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhooks_DisabledAfterRepeatedFailures(t *testing.T) {
+	repos := repository.New(database.ConnectDBWithDSN(":memory:"))
+	ctx := context.Background()
+	var calls atomic.Int32
+	var sub *model.WebhookSubscription
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, payment.Verify(body, r.Header.Get(service.WebhookSignatureHeader), []byte(sub.Secret), time.Now()))
+		assert.Equal(t, model.WebhookItemCreated, r.Header.Get(service.WebhookEventHeader))
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	webhooks := service.NewWebhookService(repos.Webhooks, repos.Jobs, service.NewWebhookClient(true))
+	runner := jobs.NewRunner(repos.Jobs, "test")
+	runner.Register(service.JobDeliverWebhook, webhooks.DeliverJob)
+
+	sub, err := webhooks.Create(ctx, 1, srv.URL, []string{model.WebhookItemCreated}, "")
+	require.NoError(t, err)
+	// Other users' items aren't relayed
+	require.NoError(t, webhooks.Relay(ctx, service.Event{Type: service.EventItemCreated, Item: model.Item{ID: 7, UserID: 2}}))
+	require.NoError(t, webhooks.Relay(ctx, service.Event{Type: service.EventItemCreated, Item: model.Item{ID: 8, UserID: 1}}))
+	queued, err := repos.Jobs.List(ctx, model.JobPending, 10)
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+
+	// The job is retried, past its backoff each time, until the last
+	// failure disables the subscription
+	at := time.Now()
+	for i := 1; i <= service.MaxWebhookFailures; i++ {
+		n, err := runner.RunOnce(ctx, at)
+		require.NoError(t, err)
+		require.Equal(t, 1, n, "attempt %d runs", i)
+		at = at.Add(2 * time.Hour)
+	}
+	n, err := runner.RunOnce(ctx, at)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Equal(t, int32(service.MaxWebhookFailures), calls.Load())
+	done, err := repos.Jobs.List(ctx, model.JobDone, 10)
+	require.NoError(t, err)
+	assert.Len(t, done, 1)
+
+	subs, err := webhooks.List(ctx, 1)
+	require.NoError(t, err)
+	assert.False(t, subs[0].Active)
+	assert.NotNil(t, subs[0].DisabledAt)
+	deliveries, err := webhooks.Deliveries(ctx, 1, sub.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, service.MaxWebhookFailures, deliveries[0].Attempt)
+
+	// Turning it back on forgets the failures
+	on := true
+	sub, err = webhooks.Update(ctx, 1, sub.ID, service.WebhookUpdate{Active: &on})
+	require.NoError(t, err)
+	assert.True(t, sub.Active)
+	assert.Zero(t, sub.Failures)
+}
+
+func TestWebhooks_RefusePrivateAddresses(t *testing.T) {
+	repos := repository.New(database.ConnectDBWithDSN(":memory:"))
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("a private address was reached")
+	}))
+	defer srv.Close()
+	webhooks := service.NewWebhookService(repos.Webhooks, repos.Jobs, service.NewWebhookClient(false))
+
+	_, err := webhooks.Create(ctx, 1, "ftp://example.com", []string{model.WebhookItemCreated}, "")
+	assert.ErrorIs(t, err, service.ErrInvalidWebhook)
+	sub, err := webhooks.Create(ctx, 1, srv.URL, []string{model.WebhookItemCreated}, "")
+	require.NoError(t, err)
+	delivery, err := webhooks.Test(ctx, 1, sub.ID)
+	require.NoError(t, err)
+	assert.Zero(t, delivery.StatusCode)
+	assert.Contains(t, delivery.Error, "private address")
+}
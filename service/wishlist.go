@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"app/model"
+	"app/repository"
+)
+
+// WishlistService keeps the items users watch and tells them when a
+// watched item gets cheaper or is back in stock
+type WishlistService struct {
+	wishlists     repository.WishlistRepository
+	items         repository.ItemRepository
+	notifications *NotificationService
+}
+
+// NewWishlistService creates a WishlistService
+func NewWishlistService(wishlists repository.WishlistRepository, items repository.ItemRepository, notifications *NotificationService) *WishlistService {
+	return &WishlistService{wishlists: wishlists, items: items, notifications: notifications}
+}
+
+// List returns the wishlist of userID, newest first. Deleted items stay
+// listed until removed.
+func (s *WishlistService) List(ctx context.Context, userID uint) ([]model.WishlistEntry, error) {
+	return s.wishlists.List(ctx, userID)
+}
+
+// Add saves an item to the wishlist of userID; saving twice changes nothing
+func (s *WishlistService) Add(ctx context.Context, userID, itemID uint) error {
+	if _, err := s.items.FindByID(ctx, itemID); err != nil {
+		return err
+	}
+	_, err := s.wishlists.Add(ctx, userID, itemID)
+	return err
+}
+
+// Remove takes an item off the wishlist of userID
+func (s *WishlistService) Remove(ctx context.Context, userID, itemID uint) error {
+	err := s.wishlists.Remove(ctx, userID, itemID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// ItemChanged is an ItemHook notifying the watchers of an item whose price
+// dropped, in the same currency, or that came back in stock
+func (s *WishlistService) ItemChanged(ctx context.Context, change ItemChange) error {
+	before, after := change.Before, change.After
+	var kind, title, body string
+	switch {
+	case after.Price.Currency == before.Price.Currency && after.Price.Amount < before.Price.Amount:
+		kind = model.NotifyPriceDrop
+		title = "Price drop: " + after.Name
+		body = fmt.Sprintf("%s is now %s, down from %s.", after.Name, after.Price, before.Price)
+	case before.Stock == 0 && after.Stock > 0:
+		kind = model.NotifyBackInStock
+		title = "Back in stock: " + after.Name
+		body = fmt.Sprintf("%s is available again.", after.Name)
+	default:
+		return nil
+	}
+
+	watchers, err := s.wishlists.Watchers(ctx, after.ID)
+	if err != nil {
+		return err
+	}
+	var out []model.Notification
+	for _, userID := range watchers {
+		if userID == after.UserID {
+			continue
+		}
+		itemID := after.ID
+		out = append(out, model.Notification{UserID: userID, Type: kind, Title: title, Body: body, ItemID: &itemID})
+	}
+	return s.notifications.Notify(ctx, out...)
+}
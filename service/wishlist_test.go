@@ -0,0 +1,85 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"app/database"
+	"app/events"
+	"app/model"
+	"app/money"
+	"app/repository"
+	"app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type wishlistFixture struct {
+	items         *service.ItemService
+	inventory     *service.InventoryService
+	wishlists     *service.WishlistService
+	notifications *service.NotificationService
+}
+
+func setupWishlist(t *testing.T) wishlistFixture {
+	db := database.ConnectDBWithDSN(":memory:")
+	repos := repository.New(db)
+	require.NoError(t, db.Create(&model.User{ID: 1, Username: "seller", Email: "s@example.com", Password: "x"}).Error)
+	require.NoError(t, db.Create(&model.User{ID: 2, Username: "buyer", Email: "b@example.com", Password: "x"}).Error)
+	require.NoError(t, db.Create(&model.Item{ID: 1, Name: "Lamp", Description: "d", Price: money.New(2000, "EUR"), Stock: 0, UserID: 1}).Error)
+
+	f := wishlistFixture{
+		items:         service.NewItemService(repos.Tx, repos.Items, repos.Users, repos.Categories, repos.Inventory),
+		inventory:     service.NewInventoryService(repos.Tx, repos.Items, repos.Inventory),
+		notifications: service.NewNotificationService(repos.Notifications, repos.Users, repos.Jobs, events.NewLocal()),
+	}
+	f.wishlists = service.NewWishlistService(repos.Wishlists, repos.Items, f.notifications)
+	f.items.OnChange(f.wishlists.ItemChanged)
+	f.inventory.OnChange(f.wishlists.ItemChanged)
+	return f
+}
+
+func price(s string) service.ItemUpdate {
+	return service.ItemUpdate{Price: &s}
+}
+
+func TestWishlist_Notifications(t *testing.T) {
+	f := setupWishlist(t)
+	ctx := context.Background()
+
+	require.NoError(t, f.wishlists.Add(ctx, 2, 1))
+	require.NoError(t, f.wishlists.Add(ctx, 2, 1), "saving twice is fine")
+	require.NoError(t, f.wishlists.Add(ctx, 1, 1))
+	assert.ErrorIs(t, f.wishlists.Add(ctx, 2, 9), service.ErrNotFound)
+
+	_, err := f.items.Update(ctx, 1, 1, price("25.00"))
+	require.NoError(t, err)
+	usd, cheaper := money.Currency("USD"), "5.00"
+	_, err = f.items.Update(ctx, 1, 1, service.ItemUpdate{Price: &cheaper, Currency: &usd})
+	require.NoError(t, err)
+	_, err = f.items.Update(ctx, 1, 1, price("4.50"))
+	require.NoError(t, err)
+	_, err = f.inventory.Adjust(ctx, 1, 1, 3, model.AdjustmentRestock, "")
+	require.NoError(t, err)
+	_, err = f.inventory.Adjust(ctx, 1, 1, 2, model.AdjustmentRestock, "")
+	require.NoError(t, err)
+
+	page, err := f.notifications.List(ctx, 2, false, 1, 10)
+	require.NoError(t, err)
+	got := page.Notifications
+	require.Len(t, got, 2)
+	assert.Equal(t, model.NotifyBackInStock, got[0].Type)
+	assert.Equal(t, model.NotifyPriceDrop, got[1].Type)
+	assert.Contains(t, got[1].Body, "4.50 USD")
+
+	owner, err := f.notifications.List(ctx, 1, false, 1, 10)
+	require.NoError(t, err)
+	assert.Empty(t, owner.Notifications, "sellers are not told about their own items")
+
+	require.NoError(t, f.notifications.MarkRead(ctx, 2, got[0].ID))
+	assert.ErrorIs(t, f.notifications.MarkRead(ctx, 1, got[1].ID), service.ErrNotFound)
+
+	require.NoError(t, f.wishlists.Remove(ctx, 2, 1))
+	assert.ErrorIs(t, f.wishlists.Remove(ctx, 2, 1), service.ErrNotFound)
+}
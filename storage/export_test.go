@@ -0,0 +1,9 @@
+package storage
+
+import "time"
+
+// SetNow fixes the clock used for signing
+func (s *S3) SetNow(t time.Time) { s.now = func() time.Time { return t } }
+
+// SetNow fixes the clock used for signing
+func (l *Local) SetNow(t time.Time) { l.now = func() time.Time { return t } }
@@ -0,0 +1,76 @@
+// Package storage keeps binary objects such as item images in a local
+// directory or an S3-compatible bucket
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"app/config"
+)
+
+// Errors returned by blob stores
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Blob stores objects under slash separated keys such as
+// "items/1/abc/original.jpg"
+type Blob interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL granting read access to key for ttl
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// FromConfig builds the store selected by STORAGE_BACKEND, "local" (the
+// default) or "s3"
+func FromConfig() (Blob, error) {
+	switch strings.ToLower(config.Config("STORAGE_BACKEND")) {
+	case "", "local":
+		dir := config.Config("STORAGE_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		secret := config.Config("STORAGE_SECRET")
+		if secret == "" {
+			secret = config.Config("SECRET")
+		}
+		return NewLocal(dir, "/media", []byte(secret)), nil
+	case "s3":
+		s3, err := NewS3(S3Config{
+			Endpoint:        config.Config("S3_ENDPOINT"),
+			Region:          config.Config("S3_REGION"),
+			Bucket:          config.Config("S3_BUCKET"),
+			AccessKeyID:     config.Config("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: config.Config("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       config.Config("S3_PATH_STYLE") == "true",
+		})
+		if err != nil {
+			return nil, err
+		}
+		return s3, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", config.Config("STORAGE_BACKEND"))
+	}
+}
+
+// validKey rejects keys that could escape the store's root
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key {
+		return ErrInvalidKey
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == ".." || seg == "." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"app/money"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,30}$`)
+
+// MaxAmount caps prices and other currency amounts
+const MaxAmount = 1_000_000_000
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their JSON name
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+
+	v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernamePattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("amount", func(fl validator.FieldLevel) bool {
+		return validAmount(fl.Field())
+	})
+	v.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		_, err := money.ParseCurrency(fl.Field().String())
+		return err == nil
+	})
+	return v
+}
+
+// validAmount accepts non-negative values up to MaxAmount. Floats may have
+// at most two decimal places. Decimal strings, such as a json.Number, may
+// have as many as any currency; money.Parse checks the actual currency.
+func validAmount(f reflect.Value) bool {
+	switch f.Kind() {
+	case reflect.String:
+		r, ok := new(big.Rat).SetString(f.String())
+		if !ok || r.Sign() < 0 || r.Cmp(big.NewRat(MaxAmount, 1)) > 0 {
+			return false
+		}
+		return r.Mul(r, big.NewRat(int64(math.Pow10(money.MaxExponent)), 1)).IsInt()
+	case reflect.Float32, reflect.Float64:
+		v := f.Float()
+		if math.IsNaN(v) || math.IsInf(v, 0) || v < 0 || v > MaxAmount {
+			return false
+		}
+		cents := v * 100
+		return math.Abs(cents-math.Round(cents)) < 1e-6
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return f.Int() >= 0 && f.Int() <= MaxAmount*100
+	default:
+		return false
+	}
+}
+
+// FieldError describes one invalid input field
+type FieldError struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Message string `json:"message"`
+}
+
+// Errors collects every invalid field of a request
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, f := range e {
+		msgs[i] = f.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Struct validates s and returns Errors listing each invalid field
+func Struct(s any) error {
+	err := validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+	var ves validator.ValidationErrors
+	if !errors.As(err, &ves) {
+		return err
+	}
+	out := make(Errors, 0, len(ves))
+	for _, fe := range ves {
+		out = append(out, FieldError{Field: fe.Field(), Tag: fe.Tag(), Message: message(fe)})
+	}
+	return out
+}
+
+func message(fe validator.FieldError) string {
+	f := fe.Field()
+	switch fe.Tag() {
+	case "required", "required_without":
+		return f + " is required"
+	case "email":
+		return f + " must be a valid email address"
+	case "username":
+		return f + " must be 3-30 letters, digits, '_', '.' or '-'"
+	case "amount":
+		return f + " must be a non-negative amount with no more decimal places than its currency allows"
+	case "currency":
+		return f + " must be a supported ISO 4217 currency code"
+	case "min":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at least %s characters long", f, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", f, fe.Param())
+	case "max":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at most %s characters long", f, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", f, fe.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", f, fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", f, fe.Param())
+	default:
+		return fmt.Sprintf("%s failed %s validation", f, fe.Tag())
+	}
+}
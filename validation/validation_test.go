@@ -0,0 +1,53 @@
+package validation_test
+
+import (
+	"testing"
+
+	"app/validation"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type listing struct {
+	Seller string  `json:"seller" validate:"required,username"`
+	Price  float64 `json:"price" validate:"amount"`
+}
+
+func TestStruct_Valid(t *testing.T) {
+	assert.NoError(t, validation.Struct(listing{Seller: "jane_doe.99", Price: 12.5}))
+}
+
+func TestStruct_ReportsEveryField(t *testing.T) {
+	err := validation.Struct(listing{Seller: "no spaces!", Price: -1})
+
+	var fields validation.Errors
+	assert.ErrorAs(t, err, &fields)
+	assert.Len(t, fields, 2)
+	assert.Equal(t, "seller", fields[0].Field)
+	assert.Equal(t, "username", fields[0].Tag)
+	assert.Equal(t, "price", fields[1].Field)
+	assert.Equal(t, "amount", fields[1].Tag)
+}
+
+func TestStruct_AmountPrecision(t *testing.T) {
+	assert.NoError(t, validation.Struct(listing{Seller: "bob", Price: 0.1 + 0.2}))
+	assert.Error(t, validation.Struct(listing{Seller: "bob", Price: 1.005}))
+	assert.Error(t, validation.Struct(listing{Seller: "bob", Price: validation.MaxAmount + 1}))
+}
+
+type priced struct {
+	Price    string `json:"price" validate:"amount"`
+	Currency string `json:"currency" validate:"omitempty,currency"`
+}
+
+func TestStruct_DecimalAmountAndCurrency(t *testing.T) {
+	assert.NoError(t, validation.Struct(priced{Price: "19.99", Currency: "eur"}))
+	assert.NoError(t, validation.Struct(priced{Price: "1.005", Currency: "KWD"}))
+	assert.Error(t, validation.Struct(priced{Price: "1.0001"}))
+	assert.Error(t, validation.Struct(priced{Price: "-1"}))
+	assert.Error(t, validation.Struct(priced{Price: "ten"}))
+
+	var fields validation.Errors
+	assert.ErrorAs(t, validation.Struct(priced{Price: "1", Currency: "XYZ"}), &fields)
+	assert.Equal(t, "currency", fields[0].Tag)
+}